@@ -6,17 +6,26 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/sirupsen/logrus"
 	fiberSwagger "github.com/swaggo/fiber-swagger"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	_ "sms-otp-service/docs" // swagger docs
 	"sms-otp-service/internal/application/usecases"
+	"sms-otp-service/internal/application/workers"
+	"sms-otp-service/internal/domain/entities"
 	"sms-otp-service/internal/domain/repositories"
 	"sms-otp-service/internal/domain/services"
+	"sms-otp-service/internal/infrastructure/challenge"
 	"sms-otp-service/internal/infrastructure/config"
 	"sms-otp-service/internal/infrastructure/database"
+	"sms-otp-service/internal/infrastructure/email"
+	"sms-otp-service/internal/infrastructure/messenger"
+	"sms-otp-service/internal/infrastructure/ratelimit"
 	infraRepos "sms-otp-service/internal/infrastructure/repositories"
 	"sms-otp-service/internal/infrastructure/sms"
+	"sms-otp-service/internal/infrastructure/token"
+	"sms-otp-service/internal/infrastructure/voice"
 	"sms-otp-service/internal/interfaces/http/handlers"
 	"sms-otp-service/internal/interfaces/http/routes"
 	"sms-otp-service/pkg/logger"
@@ -36,6 +45,10 @@ import (
 // @host localhost:8080
 // @BasePath /
 // @schemes http https
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description Bearer token set with ADMIN_API_TOKEN
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
@@ -54,38 +67,177 @@ func main() {
 	}
 	defer db.Close()
 
+	codeHasher := utils.NewCodeHasher([]byte(cfg.OTP.HashKey))
+
 	// Auto migrate database
-	if err := db.AutoMigrate(); err != nil {
+	if err := db.AutoMigrate(codeHasher); err != nil {
 		appLogger.WithError(err).Fatal("Failed to migrate database")
 	}
 
 	otpRepo := infraRepos.NewGormOTPRepository(db.DB)
+	// Outbox messages hold the plaintext code until they are sent, so they
+	// are sealed at rest under a key derived from the hash key.
+	outboxRepo := infraRepos.NewGormOutboxRepository(db.DB, utils.NewSealer([]byte(cfg.OTP.HashKey), "outbox"))
+	deliveryEventRepo := infraRepos.NewGormDeliveryEventRepository(db.DB)
+	idempotencyRepo := infraRepos.NewGormIdempotencyRepository(db.DB)
+	numberRuleRepo := infraRepos.NewGormNumberRuleRepository(db.DB)
+	sendAttemptRepo := infraRepos.NewGormSendAttemptRepository(db.DB)
+	txManager := infraRepos.NewGormTransactionManager(db.DB)
+
+	otpGenerator := utils.NewOTPGenerator()
+	policies := otpPolicies(cfg.OTP)
+	phoneValidator, err := utils.NewPhoneValidator(cfg.Phone.DefaultRegion)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Invalid PHONE_DEFAULT_REGION")
+	}
+	emailValidator := utils.NewEmailValidator()
+
+	smsService, err := sms.NewSMSService(cfg, appLogger)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to initialize SMS provider")
+	}
 
-	otpGenerator := utils.NewOTPGenerator(cfg.OTP.CodeLength)
-	phoneValidator := utils.NewPhoneValidator()
+	voiceService, err := voice.NewVoiceService(cfg, appLogger)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to initialize voice provider")
+	}
 
-	smsService := sms.NewSMSService(cfg, appLogger)
+	whatsAppService, err := messenger.NewWhatsAppService(cfg.WhatsApp, appLogger)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to initialize WhatsApp provider")
+	}
+
+	telegramService, err := messenger.NewTelegramService(cfg.Telegram, appLogger)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to initialize Telegram provider")
+	}
+
+	emailService, err := email.NewEmailService(cfg.Email, appLogger)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to initialize email provider")
+	}
+
+	limiterStore, err := ratelimit.NewStore(cfg.Limiter)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to initialize limiter store")
+	}
 
 	otpDomainService := services.NewOTPDomainService(
 		otpRepo,
+		numberRuleRepo,
+		limiterStore,
+		txManager,
 		otpGenerator,
+		codeHasher,
 		phoneValidator,
+		emailValidator,
+		policies,
+		channelRoutes(cfg.Channels),
+		lockoutPolicy(cfg.OTP.Lockout),
 		cfg.OTP.RateLimitMinutes,
 		cfg.OTP.MaxOTPsPerPeriod,
-		cfg.OTP.ValidityMinutes,
+		cfg.OTP.MaxVerifiesPerPeriod,
 	)
 
+	challengeVerifier, err := challenge.NewVerifier(cfg.Fraud.Challenge, appLogger)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to initialize challenge provider")
+	}
+	fraudService := services.NewFraudService(sendAttemptRepo, phoneValidator, challengeVerifier, fraudPolicy(cfg.Fraud))
+
+	deliveryService := services.NewDeliveryService(otpRepo, deliveryEventRepo, outboxRepo, txManager)
+
+	receiptDecoders := make(map[string]usecases.ReceiptDecoder)
+	for name, decoder := range sms.NewReceiptDecoders(cfg.SMS, appLogger) {
+		receiptDecoders[name] = decoder
+	}
+	deliveryUseCase := usecases.NewDeliveryUseCase(deliveryService, receiptDecoders, appLogger)
+
+	// Providers such as SMPP report receipts over their own connection.
+	if source, ok := smsService.(sms.ReceiptSource); ok {
+		source.OnReceipt(func(receipt *entities.DeliveryReceipt) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_ = deliveryUseCase.ProcessReceipt(ctx, receipt)
+		})
+	}
+
+	tokenSigner, err := token.NewSigner(cfg.Token)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to load verification token key")
+	}
+	if tokenSigner.Ephemeral() {
+		appLogger.Warn("No verification token key is configured, using a random key; tokens will not validate across restarts or instances")
+	}
+	tokenUseCase := usecases.NewTokenUseCase(tokenSigner, appLogger)
+
 	otpUseCase := usecases.NewOTPUseCase(
 		otpDomainService,
-		smsService,
-		cfg.OTP.ValidityMinutes,
+		fraudService,
+		txManager,
+		outboxRepo,
+		cfg.Outbox.MaxAttempts,
+		policies,
+		escalationPolicy(cfg.Escalation),
+		phoneValidator,
+		tokenSigner,
+		voice.NewScriptBuilder(cfg.Voice),
+		email.NewComposer(),
+		appLogger,
+	)
+
+	outboxWorker := workers.NewOutboxWorker(
+		outboxRepo,
+		deliveryService,
+		map[entities.Channel]workers.Sender{
+			entities.ChannelSMS:      workers.SenderFunc(smsService.SendSMS),
+			entities.ChannelVoice:    workers.SenderFunc(voiceService.Call),
+			entities.ChannelWhatsApp: workers.SenderFunc(whatsAppService.SendCode),
+			entities.ChannelTelegram: workers.SenderFunc(telegramService.SendCode),
+			entities.ChannelEmail:    workers.SenderFunc(emailService.SendEmail),
+		},
+		workers.OutboxWorkerConfig{
+			Workers:       cfg.Outbox.Workers,
+			BatchSize:     cfg.Outbox.BatchSize,
+			PollInterval:  cfg.Outbox.PollInterval,
+			LeaseDuration: cfg.Outbox.LeaseDuration,
+			BaseBackoff:   cfg.Outbox.BaseBackoff,
+			MaxBackoff:    cfg.Outbox.MaxBackoff,
+		},
 		appLogger,
 	)
 
-	otpHandler := handlers.NewOTPHandler(otpUseCase, appLogger)
-	healthHandler := handlers.NewHealthHandler(db, appLogger)
+	idempotencyUseCase := usecases.NewIdempotencyUseCase(idempotencyRepo, cfg.Idempotency.TTL, appLogger)
+	numberRuleUseCase := usecases.NewNumberRuleUseCase(numberRuleRepo, appLogger)
+	lockoutUseCase := usecases.NewLockoutUseCase(otpDomainService, policies, appLogger)
 
-	routesHandler := routes.NewRoutes(otpHandler, healthHandler)
+	clients := handlers.NewClients(cfg.Server.APIKeys)
+	otpHandler := handlers.NewOTPHandler(otpUseCase, idempotencyUseCase, clients, phoneValidator, appLogger)
+	verificationHandler := handlers.NewVerificationHandler(otpUseCase, clients, phoneValidator, appLogger)
+	deliveryHandler := handlers.NewDeliveryHandler(deliveryUseCase, appLogger)
+	tokenHandler := handlers.NewTokenHandler(tokenUseCase, appLogger)
+	numberRuleHandler := handlers.NewNumberRuleHandler(numberRuleUseCase, appLogger)
+	lockoutHandler := handlers.NewLockoutHandler(lockoutUseCase, phoneValidator, appLogger)
+	breakers, _ := smsService.(sms.BreakerReporter)
+	healthHandler := handlers.NewHealthHandler(db, breakers, appLogger)
+
+	routesHandler := routes.NewRoutes(
+		otpHandler,
+		verificationHandler,
+		deliveryHandler,
+		tokenHandler,
+		numberRuleHandler,
+		lockoutHandler,
+		healthHandler,
+		handlers.NewAdminAuth(cfg.Admin.Token),
+		handlers.NewRateLimiter(
+			limiterStore,
+			rateLimitRules(cfg.RateLimit),
+			phoneValidator,
+			cfg.RateLimit.PrefixLength,
+			appLogger,
+		),
+	)
 
 	app := fiber.New(fiber.Config{
 		ReadTimeout:  cfg.Server.ReadTimeout,
@@ -111,7 +263,10 @@ func main() {
 
 	routesHandler.Setup(app)
 
-	go startCleanupRoutine(otpRepo, cfg.OTP.CleanupInterval, appLogger)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	outboxWorker.Start(workerCtx)
+
+	go startCleanupRoutine(otpRepo, outboxRepo, deliveryEventRepo, idempotencyRepo, sendAttemptRepo, cfg, appLogger)
 
 	serverAddr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
 	go func() {
@@ -134,14 +289,133 @@ func main() {
 		appLogger.WithError(err).Error("Server forced to shutdown")
 	}
 
+	stopWorkers()
+	outboxWorker.Wait()
+
+	if closer, ok := smsService.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			appLogger.WithError(err).Error("Failed to close SMS provider")
+		}
+	}
+	if closer, ok := limiterStore.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			appLogger.WithError(err).Error("Failed to close limiter store")
+		}
+	}
+
 	appLogger.Info("Server exited")
 }
 
-func startCleanupRoutine(otpRepo repositories.OTPRepository, interval time.Duration, logger *logrus.Logger) {
-	ticker := time.NewTicker(interval)
+// otpPolicies builds the policy registry from the configured purposes.
+func otpPolicies(cfg config.OTPConfig) entities.PolicyRegistry {
+	policies := make(entities.PolicyRegistry, len(cfg.Purposes))
+	for _, purpose := range cfg.Purposes {
+		policies[entities.OTPPurpose(purpose.Name)] = entities.OTPPolicy{
+			Purpose:     entities.OTPPurpose(purpose.Name),
+			CodeLength:  purpose.CodeLength,
+			Alphabet:    purpose.Alphabet,
+			TTL:         purpose.TTL,
+			MaxAttempts: purpose.MaxAttempts,
+			ResendBackoff: entities.Backoff{
+				Delays: purpose.ResendCooldowns,
+				Reset:  purpose.ResendCooldownReset,
+			},
+			SMSTemplate:  purpose.SMSTemplate,
+			EmailSubject: purpose.EmailSubject,
+		}
+	}
+	return policies
+}
+
+// channelRoutes builds the per-country channel routing from the
+// configuration, which has already validated the channel names.
+func channelRoutes(cfg config.ChannelConfig) entities.ChannelRoutes {
+	routes := entities.ChannelRoutes{Default: entities.Channel(cfg.Default)}
+	for _, route := range cfg.Routes {
+		routes.Routes = append(routes.Routes, entities.ChannelRoute{
+			Prefix:  route.Prefix,
+			Channel: entities.Channel(route.Channel),
+		})
+	}
+	return routes
+}
+
+// lockoutPolicy builds the verification lockout policy from the
+// configuration.
+func lockoutPolicy(cfg config.LockoutConfig) entities.Lockout {
+	return entities.Lockout{
+		Threshold:    cfg.Threshold,
+		Window:       cfg.Window,
+		BaseDuration: cfg.Duration,
+		MaxDuration:  cfg.MaxDuration,
+		Reset:        cfg.Reset,
+	}
+}
+
+// escalationPolicy builds the channel escalation policy from the
+// configuration, which has already validated the channel names.
+func escalationPolicy(cfg config.EscalationConfig) entities.EscalationPolicy {
+	policy := entities.EscalationPolicy{
+		Chains:  make(map[entities.Channel][]entities.Channel, len(cfg.Chains)),
+		Timeout: cfg.Timeout,
+	}
+	for from, chain := range cfg.Chains {
+		for _, to := range chain {
+			policy.Chains[entities.Channel(from)] = append(policy.Chains[entities.Channel(from)], entities.Channel(to))
+		}
+	}
+	return policy
+}
+
+// fraudPolicy builds the fraud screening policy from the configuration.
+func fraudPolicy(cfg config.FraudConfig) entities.FraudPolicy {
+	return entities.FraudPolicy{
+		Enabled:            cfg.Enabled,
+		Window:             cfg.Window,
+		IPLimit:            cfg.IPLimit,
+		PrefixLength:       cfg.PrefixLength,
+		PrefixLimit:        cfg.PrefixLimit,
+		CountryLimit:       cfg.CountryLimit,
+		CountryLimits:      cfg.CountryLimits,
+		ConversionWindow:   cfg.ConversionWindow,
+		ConversionGrace:    cfg.ConversionGrace,
+		ConversionMinSends: cfg.ConversionMinSends,
+		MinConversion:      cfg.MinConversion,
+		ChallengeScore:     cfg.ChallengeScore,
+		BlockScore:         cfg.BlockScore,
+	}
+}
+
+// rateLimitRules builds the request rate limits from the configuration,
+// which has already validated the scopes and algorithms.
+func rateLimitRules(cfg config.RateLimitConfig) []handlers.RateLimitRule {
+	rules := make([]handlers.RateLimitRule, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		rules = append(rules, handlers.RateLimitRule{
+			Scope: entities.RateLimitScope(rule.Scope),
+			Limit: entities.RateLimit{
+				Algorithm: entities.RateLimitAlgorithm(rule.Algorithm),
+				Limit:     rule.Limit,
+				Window:    rule.Window,
+			},
+		})
+	}
+	return rules
+}
+
+func startCleanupRoutine(
+	otpRepo repositories.OTPRepository,
+	outboxRepo repositories.OutboxRepository,
+	deliveryEventRepo repositories.DeliveryEventRepository,
+	idempotencyRepo repositories.IdempotencyRepository,
+	sendAttemptRepo repositories.SendAttemptRepository,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) {
+	ticker := time.NewTicker(cfg.OTP.CleanupInterval)
 	defer ticker.Stop()
 
-	logger.WithField("interval", interval).Info("Starting OTP cleanup routine")
+	logger.WithField("interval", cfg.OTP.CleanupInterval).Info("Starting OTP cleanup routine")
 
 	for {
 		select {
@@ -154,6 +428,22 @@ func startCleanupRoutine(otpRepo repositories.OTPRepository, interval time.Durat
 				logger.Debug("Expired OTPs cleaned up successfully")
 			}
 
+			if err := outboxRepo.DeleteProcessedBefore(ctx, time.Now().Add(-cfg.Outbox.Retention)); err != nil {
+				logger.WithError(err).Error("Failed to clean up processed outbox messages")
+			}
+
+			if err := deliveryEventRepo.DeleteBefore(ctx, time.Now().Add(-cfg.SMS.DLR.EventRetention)); err != nil {
+				logger.WithError(err).Error("Failed to clean up delivery events")
+			}
+
+			if err := idempotencyRepo.DeleteExpired(ctx); err != nil {
+				logger.WithError(err).Error("Failed to clean up idempotency keys")
+			}
+
+			if err := sendAttemptRepo.DeleteBefore(ctx, time.Now().Add(-cfg.Fraud.Retention)); err != nil {
+				logger.WithError(err).Error("Failed to clean up send attempts")
+			}
+
 			cancel()
 		}
 	}
@@ -23,35 +23,66 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/otp/resend": {
-            "post": {
-                "description": "Resend OTP to phone number",
-                "consumes": [
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "Public keys that validate verification tokens, as a JSON Web Key Set. Empty when tokens are signed with HS256.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "Token"
+                ],
+                "summary": "Verification token keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.JWKSResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/lockouts": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Show the wrong codes and locks of a phone number or email address, for one purpose or every purpose",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "OTP"
+                    "Admin"
                 ],
-                "summary": "Resend OTP",
+                "summary": "List verification lockouts",
                 "parameters": [
                     {
-                        "description": "Resend OTP request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dto.ResendOTPRequest"
-                        }
+                        "type": "string",
+                        "description": "Phone number; exactly one of phone_number and email is required",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Email address",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this purpose",
+                        "name": "purpose",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.ResendOTPResponse"
+                            "$ref": "#/definitions/dto.LockoutListResponse"
                         }
                     },
                     "400": {
@@ -60,8 +91,63 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     },
-                    "429": {
-                        "description": "Too Many Requests",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Lift the locks of a phone number or email address and forget their wrong codes, for one purpose or every purpose",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Clear verification lockouts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Phone number; exactly one of phone_number and email is required",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Email address",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this purpose",
+                        "name": "purpose",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
@@ -75,9 +161,73 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/otp/send": {
+        "/api/v1/admin/number-rules": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "List the rules that block or allow OTPs to phone numbers",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List number rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only rules limited to this client",
+                        "name": "client_id",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "block",
+                            "allow"
+                        ],
+                        "type": "string",
+                        "description": "Only rules with this action",
+                        "name": "action",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.NumberRuleListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Send OTP to phone number",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Block or allow OTPs to a phone number, a prefix or a country, for the requests sent with api_key or, without it, for every request. The most specific matching rule decides; once a client has allow rules of its own, numbers no allow rule matches are blocked for that client. Allow rules without an API key do the same for requests without one.",
                 "consumes": [
                     "application/json"
                 ],
@@ -85,25 +235,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "OTP"
+                    "Admin"
                 ],
-                "summary": "Send OTP",
+                "summary": "Create number rule",
                 "parameters": [
                     {
-                        "description": "Send OTP request",
+                        "description": "Number rule",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.SendOTPRequest"
+                            "$ref": "#/definitions/dto.NumberRuleRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/dto.SendOTPResponse"
+                            "$ref": "#/definitions/dto.NumberRuleDetailResponse"
                         }
                     },
                     "400": {
@@ -112,8 +262,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     },
-                    "429": {
-                        "description": "Too Many Requests",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
@@ -127,9 +283,62 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/otp/verify": {
-            "post": {
-                "description": "Verify OTP code",
+        "/api/v1/admin/number-rules/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get number rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.NumberRuleDetailResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
                 "consumes": [
                     "application/json"
                 ],
@@ -137,17 +346,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "OTP"
+                    "Admin"
                 ],
-                "summary": "Verify OTP",
+                "summary": "Update number rule",
                 "parameters": [
                     {
-                        "description": "Verify OTP request",
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Number rule",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.VerifyOTPRequest"
+                            "$ref": "#/definitions/dto.NumberRuleRequest"
                         }
                     }
                 ],
@@ -155,7 +371,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.VerifyOTPResponse"
+                            "$ref": "#/definitions/dto.NumberRuleDetailResponse"
                         }
                     },
                     "400": {
@@ -164,6 +380,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -171,94 +405,1088 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/health": {
-            "get": {
-                "description": "Get health status of the service",
-                "produces": [
-                    "application/json"
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
                 ],
                 "tags": [
-                    "Health"
+                    "Admin"
+                ],
+                "summary": "Delete number rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/dto.HealthResponse"
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/ready": {
-            "get": {
-                "description": "Check if service is ready to serve requests",
+        "/api/v1/dlr/{provider}": {
+            "post": {
+                "description": "Delivery receipt (DLR) callback for the configured SMS providers. The body format depends on the provider. Every receipt must be authenticated: Twilio status callbacks with X-Twilio-Signature, other providers with the X-DLR-Token header or token query parameter. Providers whose secret is not configured get 404.",
+                "consumes": [
+                    "application/json",
+                    "application/x-www-form-urlencoded"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Health"
+                    "Delivery"
+                ],
+                "summary": "Receive delivery receipt",
+                "parameters": [
+                    {
+                        "enum": [
+                            "twilio",
+                            "webhook",
+                            "mock"
+                        ],
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Readiness check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/dto.DeliveryReceiptResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dto.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "type": "string"
-                },
+        },
+        "/api/v1/otp/resend": {
+            "post": {
+                "description": "Resend OTP to a phone number or email address",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OTP"
+                ],
+                "summary": "Resend OTP",
+                "parameters": [
+                    {
+                        "description": "Resend OTP request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ResendOTPRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ResendOTPResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/otp/send": {
+            "post": {
+                "description": "Send OTP to a phone number or email address",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OTP"
+                ],
+                "summary": "Send OTP",
+                "parameters": [
+                    {
+                        "description": "Send OTP request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.SendOTPRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SendOTPResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/otp/token/introspect": {
+            "post": {
+                "description": "Validate a verification token issued by /api/v1/otp/verify and return its claims (RFC 7662). Invalid and expired tokens are reported with active set to false.",
+                "consumes": [
+                    "application/json",
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Token"
+                ],
+                "summary": "Introspect verification token",
+                "parameters": [
+                    {
+                        "description": "Introspect token request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.IntrospectTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.IntrospectTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/otp/verify": {
+            "post": {
+                "description": "Verify OTP code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OTP"
+                ],
+                "summary": "Verify OTP",
+                "parameters": [
+                    {
+                        "description": "Verify OTP request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.VerifyOTPRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.VerifyOTPResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Locked",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/otp/{id}/delivery": {
+            "get": {
+                "description": "Get the delivery status of an OTP message and its full delivery history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Delivery"
+                ],
+                "summary": "Get OTP delivery status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "OTP ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DeliveryStatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/verifications": {
+            "post": {
+                "description": "Send an OTP and open a verification session for it. Sessions for the same recipient and purpose do not supersede each other.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Verifications"
+                ],
+                "summary": "Start verification",
+                "parameters": [
+                    {
+                        "description": "Create verification request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateVerificationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.VerificationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/verifications/{id}": {
+            "get": {
+                "description": "Get the state of a verification session",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Verifications"
+                ],
+                "summary": "Get verification",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Verification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.VerificationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/verifications/{id}/cancel": {
+            "post": {
+                "description": "Cancel a pending verification session so its code can no longer be used. Canceling twice is allowed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Verifications"
+                ],
+                "summary": "Cancel verification",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Verification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.VerificationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/verifications/{id}/check": {
+            "post": {
+                "description": "Check a code against a verification session. An approved check returns a signed verification token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Verifications"
+                ],
+                "summary": "Check verification code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Verification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Check verification request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CheckVerificationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.VerificationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.VerificationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Locked",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Get health status of the service. SMS is degraded while the circuit of some provider in a failover chain is open, and unhealthy while all are; the service then still answers 200, as only the database makes it unhealthy.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ready": {
+            "get": {
+                "description": "Check if service is ready to serve requests",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dto.CheckVerificationRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateVerificationRequest": {
+            "type": "object",
+            "properties": {
+                "challenge_token": {
+                    "description": "ChallengeToken is the response to the challenge, such as a CAPTCHA,\nthat a send rejected with CHALLENGE_REQUIRED must pass.",
+                    "type": "string"
+                },
+                "channel": {
+                    "description": "Channel selects how the code is delivered: \"sms\", \"voice\", \"whatsapp\"\nor \"telegram\" for phone numbers, \"email\" for email addresses. Without\nit email addresses get email and phone numbers the channel configured\nfor their country. Messenger channels fall back to SMS on failure.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entities.Channel"
+                        }
+                    ]
+                },
+                "email": {
+                    "type": "string"
+                },
+                "language": {
+                    "description": "Language is the language voice calls are read in, such as \"en\" or \"az\".",
+                    "type": "string"
+                },
+                "phone_number": {
+                    "description": "Exactly one of PhoneNumber and Email is required.",
+                    "type": "string"
+                },
+                "purpose": {
+                    "$ref": "#/definitions/entities.OTPPurpose"
+                }
+            }
+        },
+        "dto.DeliveryAttemptResponse": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "channel": {
+                    "$ref": "#/definitions/entities.Channel"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/entities.OutboxStatus"
+                },
+                "step": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.DeliveryEventResponse": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "error_code": {
+                    "type": "string"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "provider_message_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/entities.DeliveryStatus"
+                }
+            }
+        },
+        "dto.DeliveryReceiptResponse": {
+            "type": "object",
+            "properties": {
+                "accepted": {
+                    "type": "integer"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.DeliveryStatusResponse": {
+            "type": "object",
+            "properties": {
+                "delivery_status": {
+                    "$ref": "#/definitions/entities.DeliveryStatus"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.DeliveryEventResponse"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "provider_message_id": {
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
                 "error": {
                     "type": "string"
                 },
+                "next_resend_at": {
+                    "type": "string"
+                },
+                "retry_after": {
+                    "description": "RetryAfter is how many seconds until a rate limited or locked out\nrequest may be retried. NextResendAt is set when the limit was on\nsending OTPs.",
+                    "type": "integer"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "circuits": {
+                    "description": "Circuits is the circuit breaker state of each SMS provider in a\nfailover chain: \"closed\", \"open\" or \"half-open\".",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "services": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.IntrospectTokenRequest": {
+            "type": "object",
+            "required": [
+                "token"
+            ],
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.IntrospectTokenResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "aud": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "exp": {
+                    "type": "integer"
+                },
+                "iat": {
+                    "type": "integer"
+                },
+                "iss": {
+                    "type": "string"
+                },
+                "jti": {
+                    "type": "string"
+                },
+                "otp_id": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "purpose": {
+                    "$ref": "#/definitions/entities.OTPPurpose"
+                },
+                "sub": {
+                    "type": "string"
+                },
+                "verified_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.JWKSResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.JSONWebKey"
+                    }
+                }
+            }
+        },
+        "dto.LockoutListResponse": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "lockouts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.LockoutResponse"
+                    }
+                },
+                "phone_number": {
+                    "type": "string"
+                },
                 "success": {
                     "type": "boolean"
                 }
             }
         },
-        "dto.HealthResponse": {
+        "dto.LockoutResponse": {
             "type": "object",
             "properties": {
-                "services": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "string"
+                "failures": {
+                    "type": "integer"
+                },
+                "locked": {
+                    "type": "boolean"
+                },
+                "locked_until": {
+                    "type": "string"
+                },
+                "locks": {
+                    "type": "integer"
+                },
+                "purpose": {
+                    "$ref": "#/definitions/entities.OTPPurpose"
+                },
+                "retry_after": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.NumberRuleDetailResponse": {
+            "type": "object",
+            "properties": {
+                "rule": {
+                    "$ref": "#/definitions/dto.NumberRuleResponse"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.NumberRuleListResponse": {
+            "type": "object",
+            "properties": {
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.NumberRuleResponse"
                     }
                 },
-                "status": {
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.NumberRuleRequest": {
+            "type": "object",
+            "required": [
+                "action",
+                "match",
+                "value"
+            ],
+            "properties": {
+                "action": {
+                    "description": "Action is \"block\" or \"allow\".",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entities.NumberRuleAction"
+                        }
+                    ]
+                },
+                "api_key": {
+                    "description": "APIKey limits the rule to the requests sent with this key. Without\nit the rule applies to every request. The key is only stored hashed,\nas the client_id of the rule.",
                     "type": "string"
                 },
-                "timestamp": {
+                "match": {
+                    "description": "Match is \"number\" for one E.164 number, \"prefix\" for the digits a\nnumber starts with, country code included, or \"country\" for an ISO\n3166-1 alpha-2 region.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entities.NumberRuleMatch"
+                        }
+                    ]
+                },
+                "note": {
                     "type": "string"
                 },
-                "version": {
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.NumberRuleResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/entities.NumberRuleAction"
+                },
+                "client_id": {
+                    "description": "ClientID identifies the API key the rule is limited to.",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "match": {
+                    "$ref": "#/definitions/entities.NumberRuleMatch"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "value": {
                     "type": "string"
                 }
             }
         },
         "dto.ResendOTPRequest": {
             "type": "object",
-            "required": [
-                "phone_number"
-            ],
             "properties": {
+                "challenge_token": {
+                    "description": "ChallengeToken is the response to the challenge, such as a CAPTCHA,\nthat a send rejected with CHALLENGE_REQUIRED must pass.",
+                    "type": "string"
+                },
+                "channel": {
+                    "description": "Channel selects how the code is delivered: \"sms\", \"voice\", \"whatsapp\"\nor \"telegram\" for phone numbers, \"email\" for email addresses. Without\nit email addresses get email and phone numbers the channel configured\nfor their country. Messenger channels fall back to SMS on failure.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entities.Channel"
+                        }
+                    ]
+                },
+                "email": {
+                    "type": "string"
+                },
+                "language": {
+                    "description": "Language is the language voice calls are read in, such as \"en\" or \"az\".",
+                    "type": "string"
+                },
                 "phone_number": {
+                    "description": "Exactly one of PhoneNumber and Email is required.",
                     "type": "string"
                 },
                 "purpose": {
@@ -269,12 +1497,24 @@ const docTemplate = `{
         "dto.ResendOTPResponse": {
             "type": "object",
             "properties": {
+                "channel": {
+                    "$ref": "#/definitions/entities.Channel"
+                },
+                "delivery_status": {
+                    "$ref": "#/definitions/entities.DeliveryStatus"
+                },
                 "expires_in": {
                     "type": "integer"
                 },
                 "message": {
                     "type": "string"
                 },
+                "next_resend_at": {
+                    "type": "string"
+                },
+                "retry_after": {
+                    "type": "integer"
+                },
                 "success": {
                     "type": "boolean"
                 }
@@ -282,11 +1522,28 @@ const docTemplate = `{
         },
         "dto.SendOTPRequest": {
             "type": "object",
-            "required": [
-                "phone_number"
-            ],
             "properties": {
+                "challenge_token": {
+                    "description": "ChallengeToken is the response to the challenge, such as a CAPTCHA,\nthat a send rejected with CHALLENGE_REQUIRED must pass.",
+                    "type": "string"
+                },
+                "channel": {
+                    "description": "Channel selects how the code is delivered: \"sms\", \"voice\", \"whatsapp\"\nor \"telegram\" for phone numbers, \"email\" for email addresses. Without\nit email addresses get email and phone numbers the channel configured\nfor their country. Messenger channels fall back to SMS on failure.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entities.Channel"
+                        }
+                    ]
+                },
+                "email": {
+                    "type": "string"
+                },
+                "language": {
+                    "description": "Language is the language voice calls are read in, such as \"en\" or \"az\".",
+                    "type": "string"
+                },
                 "phone_number": {
+                    "description": "Exactly one of PhoneNumber and Email is required.",
                     "type": "string"
                 },
                 "purpose": {
@@ -297,6 +1554,12 @@ const docTemplate = `{
         "dto.SendOTPResponse": {
             "type": "object",
             "properties": {
+                "channel": {
+                    "$ref": "#/definitions/entities.Channel"
+                },
+                "delivery_status": {
+                    "$ref": "#/definitions/entities.DeliveryStatus"
+                },
                 "expires_in": {
                     "type": "integer"
                 },
@@ -306,22 +1569,98 @@ const docTemplate = `{
                 "message": {
                     "type": "string"
                 },
+                "next_resend_at": {
+                    "type": "string"
+                },
+                "retry_after": {
+                    "description": "RetryAfter is how many seconds until a resend is allowed, at\nNextResendAt.",
+                    "type": "integer"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.VerificationResponse": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "canceled_at": {
+                    "type": "string"
+                },
+                "channel": {
+                    "$ref": "#/definitions/entities.Channel"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivery_attempts": {
+                    "description": "DeliveryAttempts lists the messages sent or scheduled for the OTP. It\nis only returned when the session is fetched.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.DeliveryAttemptResponse"
+                    }
+                },
+                "delivery_status": {
+                    "$ref": "#/definitions/entities.DeliveryStatus"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "purpose": {
+                    "$ref": "#/definitions/entities.OTPPurpose"
+                },
+                "status": {
+                    "$ref": "#/definitions/entities.VerificationStatus"
+                },
                 "success": {
                     "type": "boolean"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "token_expires_in": {
+                    "type": "integer"
+                },
+                "token_type": {
+                    "type": "string"
+                },
+                "verified_at": {
+                    "type": "string"
                 }
             }
         },
         "dto.VerifyOTPRequest": {
             "type": "object",
             "required": [
-                "code",
-                "phone_number"
+                "code"
             ],
             "properties": {
                 "code": {
                     "type": "string"
                 },
+                "email": {
+                    "type": "string"
+                },
                 "phone_number": {
+                    "description": "Exactly one of PhoneNumber and Email is required.",
                     "type": "string"
                 },
                 "purpose": {
@@ -338,11 +1677,108 @@ const docTemplate = `{
                 "success": {
                     "type": "boolean"
                 },
+                "token": {
+                    "description": "Token is a signed JWT proving possession of the phone number or email\naddress, which other services validate with the JWKS or the\nintrospection endpoint.",
+                    "type": "string"
+                },
+                "token_expires_in": {
+                    "type": "integer"
+                },
+                "token_type": {
+                    "type": "string"
+                },
                 "verified_at": {
                     "type": "string"
                 }
             }
         },
+        "entities.Channel": {
+            "type": "string",
+            "enum": [
+                "sms",
+                "voice",
+                "whatsapp",
+                "telegram",
+                "email"
+            ],
+            "x-enum-varnames": [
+                "ChannelSMS",
+                "ChannelVoice",
+                "ChannelWhatsApp",
+                "ChannelTelegram",
+                "ChannelEmail"
+            ]
+        },
+        "entities.DeliveryStatus": {
+            "type": "string",
+            "enum": [
+                "queued",
+                "sent",
+                "delivered",
+                "failed",
+                "expired"
+            ],
+            "x-enum-varnames": [
+                "DeliveryQueued",
+                "DeliverySent",
+                "DeliveryDelivered",
+                "DeliveryFailed",
+                "DeliveryExpired"
+            ]
+        },
+        "entities.JSONWebKey": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                }
+            }
+        },
+        "entities.NumberRuleAction": {
+            "type": "string",
+            "enum": [
+                "block",
+                "allow"
+            ],
+            "x-enum-varnames": [
+                "NumberRuleBlock",
+                "NumberRuleAllow"
+            ]
+        },
+        "entities.NumberRuleMatch": {
+            "type": "string",
+            "enum": [
+                "number",
+                "prefix",
+                "country"
+            ],
+            "x-enum-varnames": [
+                "NumberRuleMatchNumber",
+                "NumberRuleMatchPrefix",
+                "NumberRuleMatchCountry"
+            ]
+        },
         "entities.OTPPurpose": {
             "type": "string",
             "enum": [
@@ -355,6 +1791,48 @@ const docTemplate = `{
                 "PurposeLogin",
                 "PurposeReset"
             ]
+        },
+        "entities.OutboxStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "processing",
+                "sent",
+                "dead",
+                "canceled"
+            ],
+            "x-enum-varnames": [
+                "OutboxPending",
+                "OutboxProcessing",
+                "OutboxSent",
+                "OutboxDead",
+                "OutboxCanceled"
+            ]
+        },
+        "entities.VerificationStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "approved",
+                "canceled",
+                "expired",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "VerificationPending",
+                "VerificationApproved",
+                "VerificationCanceled",
+                "VerificationExpired",
+                "VerificationFailed"
+            ]
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "Bearer token set with ADMIN_API_TOKEN",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
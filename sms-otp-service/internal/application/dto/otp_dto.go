@@ -6,20 +6,53 @@ import (
 )
 
 type SendOTPRequest struct {
-	PhoneNumber string              `json:"phone_number" validate:"required,phone"`
+	// Exactly one of PhoneNumber and Email is required.
+	PhoneNumber string              `json:"phone_number,omitempty" validate:"required_without=Email,omitempty,phone"`
+	Email       string              `json:"email,omitempty" validate:"required_without=PhoneNumber,omitempty,email"`
 	Purpose     entities.OTPPurpose `json:"purpose,omitempty"`
+	// Channel selects how the code is delivered: "sms", "voice", "whatsapp"
+	// or "telegram" for phone numbers, "email" for email addresses. Without
+	// it email addresses get email and phone numbers the channel configured
+	// for their country. Messenger channels fall back to SMS on failure.
+	Channel entities.Channel `json:"channel,omitempty"`
+	// Language is the language voice calls are read in, such as "en" or "az".
+	Language string `json:"language,omitempty"`
+	// ChallengeToken is the response to the challenge, such as a CAPTCHA,
+	// that a send rejected with CHALLENGE_REQUIRED must pass.
+	ChallengeToken string `json:"challenge_token,omitempty"`
+	// ClientIP is the address the request came from, set by the handler.
+	ClientIP string `json:"-"`
+	// ClientID identifies the configured API key the request was sent
+	// with, if any, set by the handler.
+	ClientID string `json:"-"`
+}
+
+// Fingerprint returns the part of the request that retries with the same
+// Idempotency-Key must repeat. Retries after a challenge carry a fresh
+// token, so it is left out; ClientIP and ClientID are never serialized.
+func (r SendOTPRequest) Fingerprint() SendOTPRequest {
+	r.ChallengeToken = ""
+	return r
 }
 
 type SendOTPResponse struct {
-	Success   bool   `json:"success"`
-	Message   string `json:"message"`
-	ExpiresIn int    `json:"expires_in"`
-	ID        string `json:"id,omitempty"`
+	Success        bool                    `json:"success"`
+	Message        string                  `json:"message"`
+	ExpiresIn      int                     `json:"expires_in"`
+	ID             string                  `json:"id,omitempty"`
+	Channel        entities.Channel        `json:"channel,omitempty"`
+	DeliveryStatus entities.DeliveryStatus `json:"delivery_status,omitempty"`
+	// RetryAfter is how many seconds until a resend is allowed, at
+	// NextResendAt.
+	RetryAfter   int       `json:"retry_after"`
+	NextResendAt time.Time `json:"next_resend_at"`
 }
 
 type VerifyOTPRequest struct {
-	PhoneNumber string              `json:"phone_number" validate:"required,phone"`
-	Code        string              `json:"code" validate:"required,len=6,numeric"`
+	// Exactly one of PhoneNumber and Email is required.
+	PhoneNumber string              `json:"phone_number,omitempty" validate:"required_without=Email,omitempty,phone"`
+	Email       string              `json:"email,omitempty" validate:"required_without=PhoneNumber,omitempty,email"`
+	Code        string              `json:"code" validate:"required"`
 	Purpose     entities.OTPPurpose `json:"purpose,omitempty"`
 }
 
@@ -27,28 +60,248 @@ type VerifyOTPResponse struct {
 	Success    bool      `json:"success"`
 	Message    string    `json:"message"`
 	VerifiedAt time.Time `json:"verified_at,omitempty"`
+	// Token is a signed JWT proving possession of the phone number or email
+	// address, which other services validate with the JWKS or the
+	// introspection endpoint.
+	Token          string `json:"token,omitempty"`
+	TokenType      string `json:"token_type,omitempty"`
+	TokenExpiresIn int    `json:"token_expires_in,omitempty"`
 }
 
 type ResendOTPRequest struct {
-	PhoneNumber string              `json:"phone_number" validate:"required,phone"`
+	// Exactly one of PhoneNumber and Email is required.
+	PhoneNumber string              `json:"phone_number,omitempty" validate:"required_without=Email,omitempty,phone"`
+	Email       string              `json:"email,omitempty" validate:"required_without=PhoneNumber,omitempty,email"`
 	Purpose     entities.OTPPurpose `json:"purpose,omitempty"`
+	// Channel selects how the code is delivered: "sms", "voice", "whatsapp"
+	// or "telegram" for phone numbers, "email" for email addresses. Without
+	// it email addresses get email and phone numbers the channel configured
+	// for their country. Messenger channels fall back to SMS on failure.
+	Channel entities.Channel `json:"channel,omitempty"`
+	// Language is the language voice calls are read in, such as "en" or "az".
+	Language string `json:"language,omitempty"`
+	// ChallengeToken is the response to the challenge, such as a CAPTCHA,
+	// that a send rejected with CHALLENGE_REQUIRED must pass.
+	ChallengeToken string `json:"challenge_token,omitempty"`
+	// ClientIP is the address the request came from, set by the handler.
+	ClientIP string `json:"-"`
+	// ClientID identifies the configured API key the request was sent
+	// with, if any, set by the handler.
+	ClientID string `json:"-"`
+}
+
+// Fingerprint leaves out the challenge token, as SendOTPRequest.Fingerprint
+// does.
+func (r ResendOTPRequest) Fingerprint() ResendOTPRequest {
+	r.ChallengeToken = ""
+	return r
 }
 
 type ResendOTPResponse struct {
-	Success   bool   `json:"success"`
-	Message   string `json:"message"`
-	ExpiresIn int    `json:"expires_in"`
+	Success        bool                    `json:"success"`
+	Message        string                  `json:"message"`
+	ExpiresIn      int                     `json:"expires_in"`
+	Channel        entities.Channel        `json:"channel,omitempty"`
+	DeliveryStatus entities.DeliveryStatus `json:"delivery_status,omitempty"`
+	RetryAfter     int                     `json:"retry_after"`
+	NextResendAt   time.Time               `json:"next_resend_at"`
 }
 
 type ErrorResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error"`
 	Code    string `json:"code,omitempty"`
+	// RetryAfter is how many seconds until a rate limited or locked out
+	// request may be retried. NextResendAt is set when the limit was on
+	// sending OTPs.
+	RetryAfter   int        `json:"retry_after,omitempty"`
+	NextResendAt *time.Time `json:"next_resend_at,omitempty"`
 }
 
 type HealthResponse struct {
 	Status    string            `json:"status"`
 	Timestamp time.Time         `json:"timestamp"`
 	Services  map[string]string `json:"services"`
-	Version   string            `json:"version"`
+	// Circuits is the circuit breaker state of each SMS provider in a
+	// failover chain: "closed", "open" or "half-open".
+	Circuits map[string]string `json:"circuits,omitempty"`
+	Version  string            `json:"version"`
+}
+
+type DeliveryReceiptResponse struct {
+	Success  bool `json:"success"`
+	Accepted int  `json:"accepted"`
+}
+
+type DeliveryEventResponse struct {
+	Status            entities.DeliveryStatus `json:"status"`
+	Provider          string                  `json:"provider,omitempty"`
+	ProviderMessageID string                  `json:"provider_message_id,omitempty"`
+	ErrorCode         string                  `json:"error_code,omitempty"`
+	Description       string                  `json:"description,omitempty"`
+	OccurredAt        time.Time               `json:"occurred_at"`
+}
+
+type DeliveryStatusResponse struct {
+	Success           bool                    `json:"success"`
+	ID                string                  `json:"id"`
+	Recipient         string                  `json:"recipient,omitempty"`
+	DeliveryStatus    entities.DeliveryStatus `json:"delivery_status,omitempty"`
+	Provider          string                  `json:"provider,omitempty"`
+	ProviderMessageID string                  `json:"provider_message_id,omitempty"`
+	Events            []DeliveryEventResponse `json:"events"`
+}
+
+type IntrospectTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// IntrospectTokenResponse follows RFC 7662: tokens that are invalid or
+// expired are reported as inactive with no other fields.
+type IntrospectTokenResponse struct {
+	Active      bool                `json:"active"`
+	TokenID     string              `json:"jti,omitempty"`
+	Issuer      string              `json:"iss,omitempty"`
+	Subject     string              `json:"sub,omitempty"`
+	Audience    string              `json:"aud,omitempty"`
+	IssuedAt    int64               `json:"iat,omitempty"`
+	ExpiresAt   int64               `json:"exp,omitempty"`
+	PhoneNumber string              `json:"phone_number,omitempty"`
+	Email       string              `json:"email,omitempty"`
+	Purpose     entities.OTPPurpose `json:"purpose,omitempty"`
+	OTPID       string              `json:"otp_id,omitempty"`
+	VerifiedAt  *time.Time          `json:"verified_at,omitempty"`
+}
+
+type JWKSResponse struct {
+	Keys []entities.JSONWebKey `json:"keys"`
+}
+
+type CreateVerificationRequest struct {
+	// Exactly one of PhoneNumber and Email is required.
+	PhoneNumber string              `json:"phone_number,omitempty" validate:"required_without=Email,omitempty,phone"`
+	Email       string              `json:"email,omitempty" validate:"required_without=PhoneNumber,omitempty,email"`
+	Purpose     entities.OTPPurpose `json:"purpose,omitempty"`
+	// Channel selects how the code is delivered: "sms", "voice", "whatsapp"
+	// or "telegram" for phone numbers, "email" for email addresses. Without
+	// it email addresses get email and phone numbers the channel configured
+	// for their country. Messenger channels fall back to SMS on failure.
+	Channel entities.Channel `json:"channel,omitempty"`
+	// Language is the language voice calls are read in, such as "en" or "az".
+	Language string `json:"language,omitempty"`
+	// ChallengeToken is the response to the challenge, such as a CAPTCHA,
+	// that a send rejected with CHALLENGE_REQUIRED must pass.
+	ChallengeToken string `json:"challenge_token,omitempty"`
+	// ClientIP is the address the request came from, set by the handler.
+	ClientIP string `json:"-"`
+	// ClientID identifies the configured API key the request was sent
+	// with, if any, set by the handler.
+	ClientID string `json:"-"`
+}
+
+type CheckVerificationRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// DeliveryAttemptResponse is one message of an OTP. Step 0 is the first
+// message; later steps escalate delivery to another channel.
+type DeliveryAttemptResponse struct {
+	Step          int                   `json:"step"`
+	Channel       entities.Channel      `json:"channel"`
+	Status        entities.OutboxStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt *time.Time            `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time            `json:"sent_at,omitempty"`
+}
+
+// VerificationResponse describes a verification session. The token fields
+// are set only by the check that approves it.
+type VerificationResponse struct {
+	Success        bool                        `json:"success"`
+	Message        string                      `json:"message,omitempty"`
+	ID             string                      `json:"id"`
+	PhoneNumber    string                      `json:"phone_number,omitempty"`
+	Email          string                      `json:"email,omitempty"`
+	Purpose        entities.OTPPurpose         `json:"purpose"`
+	Channel        entities.Channel            `json:"channel"`
+	Status         entities.VerificationStatus `json:"status"`
+	Attempts       int                         `json:"attempts"`
+	MaxAttempts    int                         `json:"max_attempts"`
+	DeliveryStatus entities.DeliveryStatus     `json:"delivery_status,omitempty"`
+	ExpiresAt      time.Time                   `json:"expires_at"`
+	CreatedAt      time.Time                   `json:"created_at"`
+	VerifiedAt     *time.Time                  `json:"verified_at,omitempty"`
+	CanceledAt     *time.Time                  `json:"canceled_at,omitempty"`
+	// DeliveryAttempts lists the messages sent or scheduled for the OTP. It
+	// is only returned when the session is fetched.
+	DeliveryAttempts []DeliveryAttemptResponse `json:"delivery_attempts,omitempty"`
+	Token            string                    `json:"token,omitempty"`
+	TokenType        string                    `json:"token_type,omitempty"`
+	TokenExpiresIn   int                       `json:"token_expires_in,omitempty"`
+}
+
+// NumberRuleRequest creates or replaces a block or allow rule.
+type NumberRuleRequest struct {
+	// APIKey limits the rule to the requests sent with this key. Without
+	// it the rule applies to every request. The key is only stored hashed,
+	// as the client_id of the rule.
+	APIKey string `json:"api_key,omitempty"`
+	// Action is "block" or "allow".
+	Action entities.NumberRuleAction `json:"action" validate:"required"`
+	// Match is "number" for one E.164 number, "prefix" for the digits a
+	// number starts with, country code included, or "country" for an ISO
+	// 3166-1 alpha-2 region.
+	Match entities.NumberRuleMatch `json:"match" validate:"required"`
+	Value string                   `json:"value" validate:"required"`
+	Note  string                   `json:"note,omitempty"`
+}
+
+type NumberRuleResponse struct {
+	ID string `json:"id"`
+	// ClientID identifies the API key the rule is limited to.
+	ClientID  string                    `json:"client_id,omitempty"`
+	Action    entities.NumberRuleAction `json:"action"`
+	Match     entities.NumberRuleMatch  `json:"match"`
+	Value     string                    `json:"value"`
+	Note      string                    `json:"note,omitempty"`
+	CreatedAt time.Time                 `json:"created_at"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+}
+
+type NumberRuleDetailResponse struct {
+	Success bool               `json:"success"`
+	Rule    NumberRuleResponse `json:"rule"`
+}
+
+type NumberRuleListResponse struct {
+	Success bool                 `json:"success"`
+	Rules   []NumberRuleResponse `json:"rules"`
+}
+
+// LockoutQuery selects the lockouts of a phone number or email address, for
+// one purpose or, without it, for every purpose.
+type LockoutQuery struct {
+	// Exactly one of PhoneNumber and Email is required.
+	PhoneNumber string              `query:"phone_number"`
+	Email       string              `query:"email"`
+	Purpose     entities.OTPPurpose `query:"purpose"`
+}
+
+// LockoutResponse is the lockout of a recipient for one purpose. Failures
+// are the wrong codes counting toward the next lock, and Locks the locks
+// that make the next one longer.
+type LockoutResponse struct {
+	Purpose     entities.OTPPurpose `json:"purpose"`
+	Locked      bool                `json:"locked"`
+	Failures    int                 `json:"failures"`
+	Locks       int                 `json:"locks"`
+	RetryAfter  int                 `json:"retry_after,omitempty"`
+	LockedUntil *time.Time          `json:"locked_until,omitempty"`
+}
+
+type LockoutListResponse struct {
+	Success     bool              `json:"success"`
+	PhoneNumber string            `json:"phone_number,omitempty"`
+	Email       string            `json:"email,omitempty"`
+	Lockouts    []LockoutResponse `json:"lockouts"`
 }
@@ -0,0 +1,127 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/services"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrUnknownReceiptProvider = errors.New("unknown delivery receipt provider")
+
+// ReceiptDecoder parses the delivery receipt callbacks of one provider.
+type ReceiptDecoder interface {
+	DecodeReceipts(requestURL string, header http.Header, body []byte) ([]*entities.DeliveryReceipt, error)
+}
+
+type DeliveryUseCase interface {
+	HandleReceiptCallback(ctx context.Context, provider, requestURL string, header http.Header, body []byte) (*dto.DeliveryReceiptResponse, error)
+	ProcessReceipt(ctx context.Context, receipt *entities.DeliveryReceipt) error
+	GetDeliveryStatus(ctx context.Context, otpID string) (*dto.DeliveryStatusResponse, error)
+}
+
+type deliveryUseCase struct {
+	deliveryService services.DeliveryService
+	decoders        map[string]ReceiptDecoder
+	logger          *logrus.Logger
+}
+
+func NewDeliveryUseCase(
+	deliveryService services.DeliveryService,
+	decoders map[string]ReceiptDecoder,
+	logger *logrus.Logger,
+) DeliveryUseCase {
+	return &deliveryUseCase{
+		deliveryService: deliveryService,
+		decoders:        decoders,
+		logger:          logger,
+	}
+}
+
+func (uc *deliveryUseCase) HandleReceiptCallback(ctx context.Context, provider, requestURL string, header http.Header, body []byte) (*dto.DeliveryReceiptResponse, error) {
+	decoder, ok := uc.decoders[provider]
+	if !ok {
+		return nil, ErrUnknownReceiptProvider
+	}
+
+	receipts, err := decoder.DecodeReceipts(requestURL, header, body)
+	if err != nil {
+		uc.logger.WithError(err).WithField("provider", provider).Warn("Rejected delivery receipt callback")
+		return nil, err
+	}
+
+	for _, receipt := range receipts {
+		if err := uc.ProcessReceipt(ctx, receipt); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.DeliveryReceiptResponse{
+		Success:  true,
+		Accepted: len(receipts),
+	}, nil
+}
+
+func (uc *deliveryUseCase) ProcessReceipt(ctx context.Context, receipt *entities.DeliveryReceipt) error {
+	logEntry := uc.logger.WithFields(logrus.Fields{
+		"provider":   receipt.Provider,
+		"message_id": receipt.MessageID,
+		"status":     receipt.Status,
+	})
+
+	matched, err := uc.deliveryService.ProcessReceipt(ctx, receipt)
+	if err != nil {
+		logEntry.WithError(err).Error("Failed to process delivery receipt")
+		return err
+	}
+
+	if !matched {
+		logEntry.Warn("Delivery receipt for unknown message stored unmatched")
+		return nil
+	}
+	logEntry.Info("Delivery receipt processed")
+	return nil
+}
+
+func (uc *deliveryUseCase) GetDeliveryStatus(ctx context.Context, otpID string) (*dto.DeliveryStatusResponse, error) {
+	if _, err := uuid.Parse(otpID); err != nil {
+		return nil, entities.ErrOTPNotFound
+	}
+
+	otp, events, err := uc.deliveryService.GetHistory(ctx, otpID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.DeliveryStatusResponse{
+		Success: true,
+		ID:      otpID,
+		Events:  make([]dto.DeliveryEventResponse, 0, len(events)),
+	}
+	if otp != nil {
+		resp.Recipient = otp.Recipient
+		resp.DeliveryStatus = otp.DeliveryStatus
+		resp.Provider = otp.DeliveryProvider
+		resp.ProviderMessageID = otp.ProviderMessageID
+	}
+	for _, event := range events {
+		if resp.Recipient == "" {
+			resp.Recipient = event.Recipient
+		}
+		resp.Events = append(resp.Events, dto.DeliveryEventResponse{
+			Status:            event.Status,
+			Provider:          event.Provider,
+			ProviderMessageID: event.ProviderMessageID,
+			ErrorCode:         event.ErrorCode,
+			Description:       event.Description,
+			OccurredAt:        event.OccurredAt,
+		})
+	}
+
+	return resp, nil
+}
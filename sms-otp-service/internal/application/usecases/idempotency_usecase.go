@@ -0,0 +1,146 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// idempotencyLock is how long a reservation blocks retries of a request that
+// never completed, for example because the instance handling it crashed.
+const idempotencyLock = time.Minute
+
+// IdempotentRequest identifies a request made with an idempotency key.
+// Payload is the parsed request; retries must carry an equal payload.
+// Stored is the completed record found by Lookup, if any, which is replayed
+// without reserving the key again.
+type IdempotentRequest struct {
+	ClientID string
+	Key      string
+	Endpoint string
+	Payload  any
+	Stored   *entities.IdempotencyRecord
+}
+
+// IdempotentResponse is a response ready to be written. Replayed is set when
+// it was stored from an earlier request.
+type IdempotentResponse struct {
+	StatusCode int
+	Body       []byte
+	Replayed   bool
+}
+
+type IdempotencyUseCase interface {
+	// Lookup returns the stored response to a completed request made by
+	// the client with the key, or nil. It lets a retry be recognized before
+	// the request is parsed, so it is not rate limited again.
+	Lookup(ctx context.Context, clientID, key string) (*entities.IdempotencyRecord, error)
+	// Execute runs handle at most once per client and key within the
+	// retention window, replaying its response to retries. Only successful
+	// responses are stored; after a failure the request can be retried.
+	Execute(ctx context.Context, req IdempotentRequest, handle func() (int, any)) (*IdempotentResponse, error)
+}
+
+type idempotencyUseCase struct {
+	repo   repositories.IdempotencyRepository
+	ttl    time.Duration
+	logger *logrus.Logger
+}
+
+func NewIdempotencyUseCase(repo repositories.IdempotencyRepository, ttl time.Duration, logger *logrus.Logger) IdempotencyUseCase {
+	return &idempotencyUseCase{
+		repo:   repo,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+func (uc *idempotencyUseCase) Lookup(ctx context.Context, clientID, key string) (*entities.IdempotencyRecord, error) {
+	record, err := uc.repo.FindCompleted(ctx, clientID, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up idempotency key: %w", err)
+	}
+	return record, nil
+}
+
+func (uc *idempotencyUseCase) Execute(ctx context.Context, req IdempotentRequest, handle func() (int, any)) (*IdempotentResponse, error) {
+	hash, err := requestHash(req)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := req.Stored
+	var record *entities.IdempotencyRecord
+	if existing == nil {
+		record = entities.NewIdempotencyRecord(req.ClientID, req.Key, hash, idempotencyLock, uc.ttl)
+		existing, err = uc.repo.Reserve(ctx, record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+	}
+
+	if existing != nil {
+		switch {
+		case existing.RequestHash != hash:
+			return nil, entities.ErrIdempotencyKeyReused
+		case !existing.IsComplete():
+			return nil, entities.ErrIdempotencyKeyInFlight
+		}
+
+		uc.logger.WithFields(logrus.Fields{
+			"client_id": req.ClientID,
+			"endpoint":  req.Endpoint,
+		}).Info("Replaying idempotent response")
+
+		return &IdempotentResponse{
+			StatusCode: existing.StatusCode,
+			Body:       existing.ResponseBody,
+			Replayed:   true,
+		}, nil
+	}
+
+	statusCode, resp := handle()
+	body, err := json.Marshal(resp)
+	if err != nil {
+		uc.release(ctx, record)
+		return nil, err
+	}
+
+	if statusCode < 200 || statusCode >= 300 {
+		uc.release(ctx, record)
+	} else if err := uc.repo.Complete(ctx, record.ID.String(), statusCode, body); err != nil {
+		// The request has taken effect, so its response is returned anyway;
+		// a retry will be rejected as in flight until the lock lapses.
+		uc.logger.WithError(err).Error("Failed to store idempotent response")
+	}
+
+	return &IdempotentResponse{StatusCode: statusCode, Body: body}, nil
+}
+
+func (uc *idempotencyUseCase) release(ctx context.Context, record *entities.IdempotencyRecord) {
+	if err := uc.repo.Release(ctx, record.ID.String()); err != nil {
+		uc.logger.WithError(err).Error("Failed to release idempotency key")
+	}
+}
+
+// requestHash fingerprints the endpoint and payload, so a key reused for a
+// different request can be told apart from a retry.
+func requestHash(req IdempotentRequest) (string, error) {
+	payload, err := json.Marshal(req.Payload)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(req.Endpoint))
+	sum.Write([]byte{0})
+	sum.Write(payload)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
@@ -0,0 +1,309 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/domain/entities"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeIdempotencyRepository keeps records in memory by client and key.
+type fakeIdempotencyRepository struct {
+	mu       sync.Mutex
+	records  map[string]*entities.IdempotencyRecord
+	reserves int
+}
+
+func newFakeIdempotencyRepository() *fakeIdempotencyRepository {
+	return &fakeIdempotencyRepository{records: make(map[string]*entities.IdempotencyRecord)}
+}
+
+func (r *fakeIdempotencyRepository) Reserve(ctx context.Context, record *entities.IdempotencyRecord) (*entities.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserves++
+
+	id := record.ClientID + "\x00" + record.Key
+	if existing, ok := r.records[id]; ok && r.live(existing) {
+		stored := *existing
+		return &stored, nil
+	}
+	stored := *record
+	r.records[id] = &stored
+	return nil, nil
+}
+
+func (r *fakeIdempotencyRepository) FindCompleted(ctx context.Context, clientID, key string) (*entities.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.records[clientID+"\x00"+key]
+	if !ok || !existing.IsComplete() || !r.live(existing) {
+		return nil, nil
+	}
+	stored := *existing
+	return &stored, nil
+}
+
+func (r *fakeIdempotencyRepository) Complete(ctx context.Context, id string, statusCode int, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range r.records {
+		if record.ID.String() == id {
+			record.StatusCode = statusCode
+			record.ResponseBody = body
+		}
+	}
+	return nil
+}
+
+func (r *fakeIdempotencyRepository) Release(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, record := range r.records {
+		if record.ID.String() == id && !record.IsComplete() {
+			delete(r.records, key)
+		}
+	}
+	return nil
+}
+
+func (r *fakeIdempotencyRepository) DeleteExpired(ctx context.Context) error {
+	return nil
+}
+
+// live reports whether a record still holds its key: it has not expired and
+// is either complete or still locked.
+func (r *fakeIdempotencyRepository) live(record *entities.IdempotencyRecord) bool {
+	now := time.Now()
+	return record.ExpiresAt.After(now) && (record.IsComplete() || record.LockedUntil.After(now))
+}
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func newTestIdempotencyUseCase() (IdempotencyUseCase, *fakeIdempotencyRepository) {
+	repo := newFakeIdempotencyRepository()
+	return NewIdempotencyUseCase(repo, 24*time.Hour, testLogger()), repo
+}
+
+var testSendRequest = dto.SendOTPRequest{
+	PhoneNumber: "+14155550100",
+	Purpose:     entities.PurposeVerification,
+	ClientIP:    "192.0.2.1",
+}
+
+// handler returns a handle func answering with statusCode and counting its
+// calls.
+func handler(calls *int, statusCode int) func() (int, any) {
+	return func() (int, any) {
+		*calls++
+		return statusCode, map[string]int{"call": *calls}
+	}
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	uc, repo := newTestIdempotencyUseCase()
+	ctx := context.Background()
+	req := IdempotentRequest{ClientID: "client", Key: "key-1", Endpoint: "send", Payload: testSendRequest}
+
+	var calls int
+	first, err := uc.Execute(ctx, req, handler(&calls, http.StatusOK))
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if first.Replayed || first.StatusCode != http.StatusOK || string(first.Body) != `{"call":1}` {
+		t.Fatalf("first response = %+v, want the handled response", first)
+	}
+
+	second, err := uc.Execute(ctx, req, handler(&calls, http.StatusOK))
+	if err != nil {
+		t.Fatalf("Execute retry: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("handled %d times, want once", calls)
+	}
+	if !second.Replayed || second.StatusCode != first.StatusCode || string(second.Body) != string(first.Body) {
+		t.Errorf("retry response = %+v, want the first replayed", second)
+	}
+
+	// A retry found by Lookup ahead of the rate limiter is replayed without
+	// reserving the key again.
+	stored, err := uc.Lookup(ctx, "client", "key-1")
+	if err != nil || stored == nil {
+		t.Fatalf("Lookup = %v, %v, want the stored response", stored, err)
+	}
+	reserves := repo.reserves
+	req.Stored = stored
+	third, err := uc.Execute(ctx, req, handler(&calls, http.StatusOK))
+	if err != nil || !third.Replayed || string(third.Body) != string(first.Body) {
+		t.Errorf("Execute with the stored response = %+v, %v, want it replayed", third, err)
+	}
+	if calls != 1 || repo.reserves != reserves {
+		t.Errorf("handled %d times with %d more reservations, want the stored response used", calls, repo.reserves-reserves)
+	}
+}
+
+func TestIdempotencyKeyConflicts(t *testing.T) {
+	otherNumber := testSendRequest
+	otherNumber.PhoneNumber = "+14155550199"
+
+	tests := []struct {
+		name      string
+		retry     IdempotentRequest
+		want      error
+		wantCalls int
+	}{
+		{
+			name:      "different payload",
+			retry:     IdempotentRequest{ClientID: "client", Key: "key-1", Endpoint: "send", Payload: otherNumber},
+			want:      entities.ErrIdempotencyKeyReused,
+			wantCalls: 1,
+		},
+		{
+			name:      "different endpoint",
+			retry:     IdempotentRequest{ClientID: "client", Key: "key-1", Endpoint: "resend", Payload: testSendRequest},
+			want:      entities.ErrIdempotencyKeyReused,
+			wantCalls: 1,
+		},
+		{
+			name:      "another client",
+			retry:     IdempotentRequest{ClientID: "other", Key: "key-1", Endpoint: "send", Payload: otherNumber},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, _ := newTestIdempotencyUseCase()
+			ctx := context.Background()
+			var calls int
+			if _, err := uc.Execute(ctx, IdempotentRequest{ClientID: "client", Key: "key-1", Endpoint: "send", Payload: testSendRequest}, handler(&calls, http.StatusOK)); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+
+			resp, err := uc.Execute(ctx, tt.retry, handler(&calls, http.StatusOK))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Execute = %+v, %v, want %v", resp, err, tt.want)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handled %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyInFlight(t *testing.T) {
+	uc, _ := newTestIdempotencyUseCase()
+	ctx := context.Background()
+	req := IdempotentRequest{ClientID: "client", Key: "key-1", Endpoint: "send", Payload: testSendRequest}
+
+	// A retry arriving while the first request is being handled is turned
+	// away rather than handled a second time.
+	var retryErr error
+	_, err := uc.Execute(ctx, req, func() (int, any) {
+		_, retryErr = uc.Execute(ctx, req, func() (int, any) {
+			t.Error("retry handled while the first request was in flight")
+			return http.StatusOK, nil
+		})
+		return http.StatusOK, "sent"
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !errors.Is(retryErr, entities.ErrIdempotencyKeyInFlight) {
+		t.Errorf("retry error = %v, want %v", retryErr, entities.ErrIdempotencyKeyInFlight)
+	}
+}
+
+func TestIdempotencyReleaseOnFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		handle     func() (int, any)
+	}{
+		{name: "client error", statusCode: http.StatusBadRequest},
+		{name: "rate limited", statusCode: http.StatusTooManyRequests},
+		{name: "server error", statusCode: http.StatusInternalServerError},
+		{name: "response not encodable", statusCode: http.StatusOK, handle: func() (int, any) { return http.StatusOK, func() {} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, repo := newTestIdempotencyUseCase()
+			ctx := context.Background()
+			req := IdempotentRequest{ClientID: "client", Key: "key-1", Endpoint: "send", Payload: testSendRequest}
+
+			var calls int
+			handle := tt.handle
+			if handle == nil {
+				handle = handler(&calls, tt.statusCode)
+			}
+			if resp, err := uc.Execute(ctx, req, handle); err == nil && (resp.StatusCode != tt.statusCode || resp.Replayed) {
+				t.Fatalf("Execute = %+v, want the %d response", resp, tt.statusCode)
+			}
+			if len(repo.records) != 0 {
+				t.Fatalf("key still reserved after a failed request")
+			}
+
+			// The key is free for a retry, which is handled and stored.
+			resp, err := uc.Execute(ctx, req, handler(&calls, http.StatusOK))
+			if err != nil || resp.Replayed || resp.StatusCode != http.StatusOK {
+				t.Fatalf("retry = %+v, %v, want it handled", resp, err)
+			}
+			if stored, _ := uc.Lookup(ctx, "client", "key-1"); stored == nil || stored.StatusCode != http.StatusOK {
+				t.Errorf("stored = %+v, want the successful response", stored)
+			}
+		})
+	}
+}
+
+func TestIdempotencyFingerprint(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*dto.SendOTPRequest)
+		want   error
+	}{
+		{name: "client IP", change: func(r *dto.SendOTPRequest) { r.ClientIP = "198.51.100.7" }},
+		{name: "challenge token", change: func(r *dto.SendOTPRequest) { r.ChallengeToken = "fresh-token" }},
+		{name: "client ID", change: func(r *dto.SendOTPRequest) { r.ClientID = "client" }},
+		{name: "channel", change: func(r *dto.SendOTPRequest) { r.Channel = entities.ChannelVoice }, want: entities.ErrIdempotencyKeyReused},
+		{name: "purpose", change: func(r *dto.SendOTPRequest) { r.Purpose = entities.PurposeLogin }, want: entities.ErrIdempotencyKeyReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, _ := newTestIdempotencyUseCase()
+			ctx := context.Background()
+
+			first := testSendRequest
+			first.ChallengeToken = "first-token"
+			var calls int
+			if _, err := uc.Execute(ctx, IdempotentRequest{ClientID: "client", Key: "key-1", Endpoint: "send", Payload: first.Fingerprint()}, handler(&calls, http.StatusOK)); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+
+			retry := first
+			tt.change(&retry)
+			resp, err := uc.Execute(ctx, IdempotentRequest{ClientID: "client", Key: "key-1", Endpoint: "send", Payload: retry.Fingerprint()}, handler(&calls, http.StatusOK))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("retry = %+v, %v, want %v", resp, err, tt.want)
+			}
+			if tt.want == nil && !resp.Replayed {
+				t.Errorf("retry with another %s was handled again, want it replayed", tt.name)
+			}
+		})
+	}
+}
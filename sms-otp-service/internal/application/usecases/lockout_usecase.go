@@ -0,0 +1,114 @@
+package usecases
+
+import (
+	"context"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/services"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LockoutUseCase inspects and lifts the locks recipients get after checking
+// too many wrong codes.
+type LockoutUseCase interface {
+	ListLockouts(ctx context.Context, query *dto.LockoutQuery) (*dto.LockoutListResponse, error)
+	// ClearLockouts lifts the locks selected by query and forgets the
+	// failures counting toward them.
+	ClearLockouts(ctx context.Context, query *dto.LockoutQuery) error
+}
+
+type lockoutUseCase struct {
+	otpDomainService services.OTPDomainService
+	policies         entities.PolicyRegistry
+	logger           *logrus.Logger
+}
+
+func NewLockoutUseCase(otpDomainService services.OTPDomainService, policies entities.PolicyRegistry, logger *logrus.Logger) LockoutUseCase {
+	return &lockoutUseCase{
+		otpDomainService: otpDomainService,
+		policies:         policies,
+		logger:           logger,
+	}
+}
+
+func (uc *lockoutUseCase) ListLockouts(ctx context.Context, query *dto.LockoutQuery) (*dto.LockoutListResponse, error) {
+	recipient, purposes, err := uc.selection(query)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.LockoutListResponse{
+		Success:     true,
+		PhoneNumber: query.PhoneNumber,
+		Email:       query.Email,
+		Lockouts:    make([]dto.LockoutResponse, 0, len(purposes)),
+	}
+	for _, purpose := range purposes {
+		state, err := uc.otpDomainService.Lockout(ctx, recipient.Address, purpose)
+		if err != nil {
+			return nil, err
+		}
+		resp.Lockouts = append(resp.Lockouts, toLockoutResponse(purpose, state))
+	}
+	return resp, nil
+}
+
+func (uc *lockoutUseCase) ClearLockouts(ctx context.Context, query *dto.LockoutQuery) error {
+	recipient, purposes, err := uc.selection(query)
+	if err != nil {
+		return err
+	}
+
+	for _, purpose := range purposes {
+		if err := uc.otpDomainService.ClearLockout(ctx, recipient.Address, purpose); err != nil {
+			return err
+		}
+	}
+
+	uc.logger.WithFields(logrus.Fields{
+		"recipient": recipient.Address,
+		"purposes":  purposes,
+	}).Info("Verification lockouts cleared")
+	return nil
+}
+
+// selection returns the recipient of query and the purposes it covers: the
+// one it names, or every configured purpose.
+func (uc *lockoutUseCase) selection(query *dto.LockoutQuery) (entities.Recipient, []entities.OTPPurpose, error) {
+	recipient, err := entities.NewRecipient(query.PhoneNumber, query.Email)
+	if err != nil {
+		return entities.Recipient{}, nil, err
+	}
+
+	if query.Purpose != "" {
+		if _, err := uc.policies.Lookup(query.Purpose); err != nil {
+			return entities.Recipient{}, nil, err
+		}
+		return recipient, []entities.OTPPurpose{query.Purpose}, nil
+	}
+
+	purposes := make([]entities.OTPPurpose, 0, len(uc.policies))
+	for purpose := range uc.policies {
+		purposes = append(purposes, purpose)
+	}
+	sort.Slice(purposes, func(i, j int) bool { return purposes[i] < purposes[j] })
+	return recipient, purposes, nil
+}
+
+func toLockoutResponse(purpose entities.OTPPurpose, state entities.LockoutState) dto.LockoutResponse {
+	resp := dto.LockoutResponse{
+		Purpose:  purpose,
+		Locked:   state.Locked(),
+		Failures: state.Failures,
+		Locks:    state.Locks,
+	}
+	if state.Locked() {
+		lockedUntil := time.Now().Add(state.RetryAfter)
+		resp.RetryAfter = retryAfter(lockedUntil)
+		resp.LockedUntil = &lockedUntil
+	}
+	return resp
+}
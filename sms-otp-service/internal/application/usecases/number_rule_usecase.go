@@ -0,0 +1,146 @@
+package usecases
+
+import (
+	"context"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// NumberRuleUseCase manages the rules that block or allow OTPs to phone
+// numbers.
+type NumberRuleUseCase interface {
+	CreateRule(ctx context.Context, req *dto.NumberRuleRequest) (*dto.NumberRuleDetailResponse, error)
+	// ListRules returns the rules of the client clientID with action. An
+	// empty clientID or action lists the rules of every client or action.
+	ListRules(ctx context.Context, clientID string, action entities.NumberRuleAction) (*dto.NumberRuleListResponse, error)
+	GetRule(ctx context.Context, id string) (*dto.NumberRuleDetailResponse, error)
+	UpdateRule(ctx context.Context, id string, req *dto.NumberRuleRequest) (*dto.NumberRuleDetailResponse, error)
+	DeleteRule(ctx context.Context, id string) error
+}
+
+type numberRuleUseCase struct {
+	numberRuleRepo repositories.NumberRuleRepository
+	logger         *logrus.Logger
+}
+
+func NewNumberRuleUseCase(numberRuleRepo repositories.NumberRuleRepository, logger *logrus.Logger) NumberRuleUseCase {
+	return &numberRuleUseCase{
+		numberRuleRepo: numberRuleRepo,
+		logger:         logger,
+	}
+}
+
+func (uc *numberRuleUseCase) CreateRule(ctx context.Context, req *dto.NumberRuleRequest) (*dto.NumberRuleDetailResponse, error) {
+	rule, err := entities.NewNumberRule(entities.ClientID(req.APIKey), req.Action, req.Match, req.Value, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.numberRuleRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	uc.logRule(rule).Info("Number rule created")
+	return numberRuleDetail(rule), nil
+}
+
+func (uc *numberRuleUseCase) ListRules(ctx context.Context, clientID string, action entities.NumberRuleAction) (*dto.NumberRuleListResponse, error) {
+	switch action {
+	case "", entities.NumberRuleBlock, entities.NumberRuleAllow:
+	default:
+		return nil, entities.ErrInvalidNumberRule
+	}
+
+	rules, err := uc.numberRuleRepo.FindAll(ctx, clientID, action)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.NumberRuleListResponse{
+		Success: true,
+		Rules:   make([]dto.NumberRuleResponse, 0, len(rules)),
+	}
+	for _, rule := range rules {
+		resp.Rules = append(resp.Rules, toNumberRuleResponse(rule))
+	}
+	return resp, nil
+}
+
+func (uc *numberRuleUseCase) GetRule(ctx context.Context, id string) (*dto.NumberRuleDetailResponse, error) {
+	rule, err := uc.findRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return numberRuleDetail(rule), nil
+}
+
+func (uc *numberRuleUseCase) UpdateRule(ctx context.Context, id string, req *dto.NumberRuleRequest) (*dto.NumberRuleDetailResponse, error) {
+	rule, err := uc.findRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rule.Set(entities.ClientID(req.APIKey), req.Action, req.Match, req.Value, req.Note); err != nil {
+		return nil, err
+	}
+	if err := uc.numberRuleRepo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	uc.logRule(rule).Info("Number rule updated")
+	return numberRuleDetail(rule), nil
+}
+
+func (uc *numberRuleUseCase) DeleteRule(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return entities.ErrNumberRuleNotFound
+	}
+
+	if err := uc.numberRuleRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	uc.logger.WithField("rule_id", id).Info("Number rule deleted")
+	return nil
+}
+
+func (uc *numberRuleUseCase) findRule(ctx context.Context, id string) (*entities.NumberRule, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, entities.ErrNumberRuleNotFound
+	}
+	return uc.numberRuleRepo.FindByID(ctx, id)
+}
+
+func (uc *numberRuleUseCase) logRule(rule *entities.NumberRule) *logrus.Entry {
+	return uc.logger.WithFields(logrus.Fields{
+		"rule_id":   rule.ID,
+		"client_id": rule.ClientID,
+		"action":    rule.Action,
+		"match":     rule.Match,
+		"value":     rule.Value,
+	})
+}
+
+func numberRuleDetail(rule *entities.NumberRule) *dto.NumberRuleDetailResponse {
+	return &dto.NumberRuleDetailResponse{
+		Success: true,
+		Rule:    toNumberRuleResponse(rule),
+	}
+}
+
+func toNumberRuleResponse(rule *entities.NumberRule) dto.NumberRuleResponse {
+	return dto.NumberRuleResponse{
+		ID:        rule.ID.String(),
+		ClientID:  rule.ClientID,
+		Action:    rule.Action,
+		Match:     rule.Match,
+		Value:     rule.Value,
+		Note:      rule.Note,
+		CreatedAt: rule.CreatedAt,
+		UpdatedAt: rule.UpdatedAt,
+	}
+}
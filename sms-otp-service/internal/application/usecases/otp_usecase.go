@@ -2,12 +2,18 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"sms-otp-service/internal/application/dto"
 	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
 	"sms-otp-service/internal/domain/services"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,30 +21,77 @@ type OTPUseCase interface {
 	SendOTP(ctx context.Context, req *dto.SendOTPRequest) (*dto.SendOTPResponse, error)
 	VerifyOTP(ctx context.Context, req *dto.VerifyOTPRequest) (*dto.VerifyOTPResponse, error)
 	ResendOTP(ctx context.Context, req *dto.ResendOTPRequest) (*dto.ResendOTPResponse, error)
+
+	CreateVerification(ctx context.Context, req *dto.CreateVerificationRequest) (*dto.VerificationResponse, error)
+	CheckVerification(ctx context.Context, id string, req *dto.CheckVerificationRequest) (*dto.VerificationResponse, error)
+	GetVerification(ctx context.Context, id string) (*dto.VerificationResponse, error)
+	CancelVerification(ctx context.Context, id string) (*dto.VerificationResponse, error)
 }
 
-type otpUseCase struct {
-	otpDomainService services.OTPDomainService
-	smsService       SMSService
-	validityMinutes  int
-	logger           *logrus.Logger
+// VoiceScriptBuilder renders the script read out on voice OTP calls.
+type VoiceScriptBuilder interface {
+	Build(code string, purpose entities.OTPPurpose, language string) string
+}
+
+// EmailComposer renders an OTP email from its subject and plain text body.
+type EmailComposer interface {
+	Compose(subject, body string) string
 }
 
-type SMSService interface {
-	SendSMS(ctx context.Context, phoneNumber, message string) error
+type otpUseCase struct {
+	otpDomainService  services.OTPDomainService
+	fraudService      services.FraudService
+	txManager         repositories.TransactionManager
+	outboxRepo        repositories.OutboxRepository
+	outboxMaxAttempts int
+	policies          entities.PolicyRegistry
+	escalation        entities.EscalationPolicy
+	phoneValidator    services.PhoneValidator
+	templates         map[entities.OTPPurpose]*template.Template
+	tokenSigner       TokenSigner
+	voiceScripts      VoiceScriptBuilder
+	emailComposer     EmailComposer
+	logger            *logrus.Logger
 }
 
 func NewOTPUseCase(
 	otpDomainService services.OTPDomainService,
-	smsService SMSService,
-	validityMinutes int,
+	fraudService services.FraudService,
+	txManager repositories.TransactionManager,
+	outboxRepo repositories.OutboxRepository,
+	outboxMaxAttempts int,
+	policies entities.PolicyRegistry,
+	escalation entities.EscalationPolicy,
+	phoneValidator services.PhoneValidator,
+	tokenSigner TokenSigner,
+	voiceScripts VoiceScriptBuilder,
+	emailComposer EmailComposer,
 	logger *logrus.Logger,
 ) OTPUseCase {
+	templates := make(map[entities.OTPPurpose]*template.Template, len(policies))
+	for purpose, policy := range policies {
+		tmpl, err := template.New(string(purpose)).Parse(policy.SMSTemplate)
+		if err != nil {
+			logger.WithError(err).WithField("purpose", purpose).Error("Invalid SMS template, using the default message")
+			continue
+		}
+		templates[purpose] = tmpl
+	}
+
 	return &otpUseCase{
-		otpDomainService: otpDomainService,
-		smsService:       smsService,
-		validityMinutes:  validityMinutes,
-		logger:           logger,
+		otpDomainService:  otpDomainService,
+		fraudService:      fraudService,
+		txManager:         txManager,
+		outboxRepo:        outboxRepo,
+		outboxMaxAttempts: outboxMaxAttempts,
+		policies:          policies,
+		escalation:        escalation,
+		phoneValidator:    phoneValidator,
+		templates:         templates,
+		tokenSigner:       tokenSigner,
+		voiceScripts:      voiceScripts,
+		emailComposer:     emailComposer,
+		logger:            logger,
 	}
 }
 
@@ -47,33 +100,53 @@ func (uc *otpUseCase) SendOTP(ctx context.Context, req *dto.SendOTPRequest) (*dt
 		req.Purpose = entities.PurposeVerification
 	}
 
+	recipient, err := entities.NewRecipient(req.PhoneNumber, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
 	uc.logger.WithFields(logrus.Fields{
-		"phone_number": req.PhoneNumber,
-		"purpose":      req.Purpose,
+		"recipient": recipient.Address,
+		"purpose":   req.Purpose,
+		"channel":   req.Channel,
 	}).Info("Generating OTP")
 
-	otp, err := uc.otpDomainService.GenerateOTP(ctx, req.PhoneNumber, req.Purpose)
+	attempt, err := uc.screenSend(ctx, recipient, req.ClientIP, req.ChallengeToken)
 	if err != nil {
-		uc.logger.WithError(err).Error("Failed to generate OTP")
 		return nil, err
 	}
 
-	message := uc.buildSMSMessage(otp.Code, req.Purpose)
-	if err := uc.smsService.SendSMS(ctx, req.PhoneNumber, message); err != nil {
-		uc.logger.WithError(err).Error("Failed to send SMS")
-		return nil, fmt.Errorf("failed to send SMS: %w", err)
+	var otp *entities.OTP
+	err = uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		otp, err = uc.otpDomainService.GenerateOTP(ctx, recipient, req.Purpose, req.Channel, req.ClientID)
+		if err != nil {
+			return err
+		}
+		if err := uc.fraudService.RecordSend(ctx, attempt, otp); err != nil {
+			return err
+		}
+		return uc.enqueueMessage(ctx, otp, req.Language)
+	})
+	if err != nil {
+		uc.logger.WithError(err).Error("Failed to generate OTP")
+		return nil, err
 	}
 
 	uc.logger.WithFields(logrus.Fields{
-		"otp_id":       otp.ID,
-		"phone_number": req.PhoneNumber,
-	}).Info("OTP sent successfully")
+		"otp_id":    otp.ID,
+		"recipient": recipient.Address,
+	}).Info("OTP queued for delivery")
 
 	return &dto.SendOTPResponse{
-		Success:   true,
-		Message:   "OTP sent successfully",
-		ExpiresIn: uc.validityMinutes * 60,
-		ID:        otp.ID.String(),
+		Success:        true,
+		Message:        "OTP sent successfully",
+		ExpiresIn:      uc.expiresIn(otp.Purpose),
+		ID:             otp.ID.String(),
+		Channel:        otp.Channel,
+		DeliveryStatus: otp.DeliveryStatus,
+		RetryAfter:     retryAfter(otp.NextResendAt),
+		NextResendAt:   otp.NextResendAt,
 	}, nil
 }
 
@@ -82,12 +155,21 @@ func (uc *otpUseCase) VerifyOTP(ctx context.Context, req *dto.VerifyOTPRequest)
 		req.Purpose = entities.PurposeVerification
 	}
 
+	recipient, err := entities.NewRecipient(req.PhoneNumber, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
 	uc.logger.WithFields(logrus.Fields{
-		"phone_number": req.PhoneNumber,
-		"purpose":      req.Purpose,
+		"recipient": recipient.Address,
+		"purpose":   req.Purpose,
 	}).Info("Verifying OTP")
 
-	err := uc.otpDomainService.VerifyOTP(ctx, req.PhoneNumber, req.Code, req.Purpose)
+	otp, err := uc.otpDomainService.VerifyOTP(ctx, recipient, req.Code, req.Purpose)
+	if errors.Is(err, entities.ErrInvalidCodeFormat) || errors.Is(err, entities.ErrUnknownPurpose) ||
+		errors.Is(err, services.ErrRateLimitExceeded) || errors.Is(err, services.ErrVerificationLocked) {
+		return nil, err
+	}
 	if err != nil {
 		uc.logger.WithError(err).Warn("OTP verification failed")
 		return &dto.VerifyOTPResponse{
@@ -97,62 +179,357 @@ func (uc *otpUseCase) VerifyOTP(ctx context.Context, req *dto.VerifyOTPRequest)
 	}
 
 	uc.logger.WithFields(logrus.Fields{
-		"phone_number": req.PhoneNumber,
-		"purpose":      req.Purpose,
+		"recipient": recipient.Address,
+		"purpose":   req.Purpose,
 	}).Info("OTP verified successfully")
+	uc.recordVerification(ctx, otp)
+
+	claims, token, err := uc.issueToken(otp)
+	if err != nil {
+		return nil, err
+	}
 
 	return &dto.VerifyOTPResponse{
-		Success:    true,
-		Message:    "OTP verified successfully",
-		VerifiedAt: time.Now(),
+		Success:        true,
+		Message:        "OTP verified successfully",
+		VerifiedAt:     claims.VerifiedAt,
+		Token:          token,
+		TokenType:      "Bearer",
+		TokenExpiresIn: int(claims.ExpiresAt.Sub(claims.IssuedAt).Seconds()),
 	}, nil
 }
 
+func (uc *otpUseCase) issueToken(otp *entities.OTP) (*entities.VerificationClaims, string, error) {
+	claims := entities.NewVerificationClaims(otp)
+	token, err := uc.tokenSigner.Issue(claims)
+	if err != nil {
+		uc.logger.WithError(err).Error("Failed to issue verification token")
+		return nil, "", err
+	}
+	return claims, token, nil
+}
+
 func (uc *otpUseCase) ResendOTP(ctx context.Context, req *dto.ResendOTPRequest) (*dto.ResendOTPResponse, error) {
 	if req.Purpose == "" {
 		req.Purpose = entities.PurposeVerification
 	}
 
+	recipient, err := entities.NewRecipient(req.PhoneNumber, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
 	uc.logger.WithFields(logrus.Fields{
-		"phone_number": req.PhoneNumber,
-		"purpose":      req.Purpose,
+		"recipient": recipient.Address,
+		"purpose":   req.Purpose,
+		"channel":   req.Channel,
 	}).Info("Resending OTP")
 
-	otp, err := uc.otpDomainService.ResendOTP(ctx, req.PhoneNumber, req.Purpose)
+	attempt, err := uc.screenSend(ctx, recipient, req.ClientIP, req.ChallengeToken)
 	if err != nil {
-		uc.logger.WithError(err).Error("Failed to resend OTP")
 		return nil, err
 	}
 
-	message := uc.buildSMSMessage(otp.Code, req.Purpose)
-	if err := uc.smsService.SendSMS(ctx, req.PhoneNumber, message); err != nil {
-		uc.logger.WithError(err).Error("Failed to send SMS")
-		return nil, fmt.Errorf("failed to send SMS: %w", err)
+	var otp *entities.OTP
+	err = uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		otp, err = uc.otpDomainService.ResendOTP(ctx, recipient, req.Purpose, req.Channel, req.ClientID)
+		if err != nil {
+			return err
+		}
+		if err := uc.fraudService.RecordSend(ctx, attempt, otp); err != nil {
+			return err
+		}
+		return uc.enqueueMessage(ctx, otp, req.Language)
+	})
+	if err != nil {
+		uc.logger.WithError(err).Error("Failed to resend OTP")
+		return nil, err
 	}
 
 	uc.logger.WithFields(logrus.Fields{
-		"otp_id":       otp.ID,
-		"phone_number": req.PhoneNumber,
-	}).Info("OTP resent successfully")
+		"otp_id":    otp.ID,
+		"recipient": recipient.Address,
+	}).Info("OTP queued for redelivery")
 
 	return &dto.ResendOTPResponse{
-		Success:   true,
-		Message:   "OTP resent successfully",
-		ExpiresIn: uc.validityMinutes * 60,
+		Success:        true,
+		Message:        "OTP resent successfully",
+		ExpiresIn:      uc.expiresIn(otp.Purpose),
+		Channel:        otp.Channel,
+		DeliveryStatus: otp.DeliveryStatus,
+		RetryAfter:     retryAfter(otp.NextResendAt),
+		NextResendAt:   otp.NextResendAt,
 	}, nil
 }
 
-func (uc *otpUseCase) buildSMSMessage(code string, purpose entities.OTPPurpose) string {
-	switch purpose {
-	case entities.PurposeLogin:
-		return fmt.Sprintf("Your login code is: %s. Valid for %d minutes. Do not share this code.", code, uc.validityMinutes)
-	case entities.PurposeReset:
-		return fmt.Sprintf("Your password reset code is: %s. Valid for %d minutes. Do not share this code.", code, uc.validityMinutes)
+func (uc *otpUseCase) CreateVerification(ctx context.Context, req *dto.CreateVerificationRequest) (*dto.VerificationResponse, error) {
+	if req.Purpose == "" {
+		req.Purpose = entities.PurposeVerification
+	}
+
+	recipient, err := entities.NewRecipient(req.PhoneNumber, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.logger.WithFields(logrus.Fields{
+		"recipient": recipient.Address,
+		"purpose":   req.Purpose,
+		"channel":   req.Channel,
+	}).Info("Starting verification")
+
+	attempt, err := uc.screenSend(ctx, recipient, req.ClientIP, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var otp *entities.OTP
+	err = uc.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		otp, err = uc.otpDomainService.StartVerification(ctx, recipient, req.Purpose, req.Channel, req.ClientID)
+		if err != nil {
+			return err
+		}
+		if err := uc.fraudService.RecordSend(ctx, attempt, otp); err != nil {
+			return err
+		}
+		return uc.enqueueMessage(ctx, otp, req.Language)
+	})
+	if err != nil {
+		uc.logger.WithError(err).Error("Failed to start verification")
+		return nil, err
+	}
+
+	uc.logger.WithField("otp_id", otp.ID).Info("Verification OTP queued for delivery")
+
+	resp := newVerificationResponse(otp)
+	resp.Message = "Verification started"
+	return resp, nil
+}
+
+// CheckVerification reports a failed check as an unsuccessful response
+// rather than an error, so the caller sees the session's state.
+func (uc *otpUseCase) CheckVerification(ctx context.Context, id string, req *dto.CheckVerificationRequest) (*dto.VerificationResponse, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, entities.ErrOTPNotFound
+	}
+
+	otp, err := uc.otpDomainService.CheckVerification(ctx, id, req.Code)
+	if otp == nil {
+		return nil, err
+	}
+	if err != nil {
+		uc.logger.WithError(err).WithField("otp_id", id).Warn("Verification check failed")
+		resp := newVerificationResponse(otp)
+		resp.Success = false
+		resp.Message = uc.getErrorMessage(err)
+		return resp, nil
+	}
+
+	uc.logger.WithField("otp_id", id).Info("Verification approved")
+	uc.recordVerification(ctx, otp)
+
+	claims, token, err := uc.issueToken(otp)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := newVerificationResponse(otp)
+	resp.Message = "OTP verified successfully"
+	resp.Token = token
+	resp.TokenType = "Bearer"
+	resp.TokenExpiresIn = int(claims.ExpiresAt.Sub(claims.IssuedAt).Seconds())
+	return resp, nil
+}
+
+func (uc *otpUseCase) GetVerification(ctx context.Context, id string) (*dto.VerificationResponse, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, entities.ErrOTPNotFound
+	}
+
+	otp, err := uc.otpDomainService.GetVerification(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := uc.outboxRepo.FindByOTPID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := newVerificationResponse(otp)
+	resp.DeliveryAttempts = make([]dto.DeliveryAttemptResponse, 0, len(messages))
+	for _, message := range messages {
+		attempt := dto.DeliveryAttemptResponse{
+			Step:     message.EscalationStep,
+			Channel:  message.Channel,
+			Status:   message.Status,
+			Attempts: message.Attempts,
+			SentAt:   message.SentAt,
+		}
+		if message.Status == entities.OutboxPending {
+			attempt.NextAttemptAt = &message.NextAttemptAt
+		}
+		resp.DeliveryAttempts = append(resp.DeliveryAttempts, attempt)
+	}
+	return resp, nil
+}
+
+func (uc *otpUseCase) CancelVerification(ctx context.Context, id string) (*dto.VerificationResponse, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, entities.ErrOTPNotFound
+	}
+
+	otp, err := uc.otpDomainService.CancelVerification(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.logger.WithField("otp_id", id).Info("Verification canceled")
+
+	resp := newVerificationResponse(otp)
+	resp.Message = "Verification canceled"
+	return resp, nil
+}
+
+// screenSend scores a send to a phone number for fraud and logs the
+// decision with its reasons. It returns the allowed attempt to be recorded
+// with the OTP, or the error the send is stopped with.
+func (uc *otpUseCase) screenSend(ctx context.Context, recipient entities.Recipient, clientIP, challengeToken string) (*entities.SendAttempt, error) {
+	if recipient.Type != entities.RecipientPhone {
+		return nil, nil
+	}
+
+	attempt, err := uc.fraudService.Screen(ctx, recipient.Address, entities.SendOrigin{
+		ClientIP:       clientIP,
+		ChallengeToken: challengeToken,
+	})
+	if attempt == nil {
+		return nil, err
+	}
+
+	logEntry := uc.logger.WithFields(logrus.Fields{
+		"recipient": attempt.Recipient,
+		"client_ip": attempt.ClientIP,
+		"prefix":    attempt.Prefix,
+		"region":    attempt.Region,
+		"score":     attempt.Score,
+		"decision":  attempt.Decision,
+		"reasons":   attempt.Reasons,
+	})
+	if attempt.Decision == entities.FraudAllow {
+		logEntry.Info("Send screened")
+	} else {
+		logEntry.Warn("Send stopped by fraud screening")
+	}
+	return attempt, err
+}
+
+// recordVerification counts a verified OTP towards the conversion ratio of
+// its destination. The verification stands even if this fails.
+func (uc *otpUseCase) recordVerification(ctx context.Context, otp *entities.OTP) {
+	if err := uc.fraudService.RecordVerification(ctx, otp); err != nil {
+		uc.logger.WithError(err).WithField("otp_id", otp.ID).Error("Failed to record verification for fraud screening")
+	}
+}
+
+func newVerificationResponse(otp *entities.OTP) *dto.VerificationResponse {
+	return &dto.VerificationResponse{
+		Success:        true,
+		ID:             otp.ID.String(),
+		PhoneNumber:    otp.To().PhoneNumber(),
+		Email:          otp.To().Email(),
+		Purpose:        otp.Purpose,
+		Channel:        otp.Channel,
+		Status:         otp.Status(),
+		Attempts:       otp.Attempts,
+		MaxAttempts:    otp.MaxAttempts,
+		DeliveryStatus: otp.DeliveryStatus,
+		ExpiresAt:      otp.ExpiresAt,
+		CreatedAt:      otp.CreatedAt,
+		VerifiedAt:     otp.VerifiedAt,
+		CanceledAt:     otp.CanceledAt,
+	}
+}
+
+// enqueueMessage writes the OTP message for the OTP's channel, and for its
+// fallback channel if it has one, to the outbox, followed by the escalation
+// steps that come due before the OTP expires. It must run in the same
+// transaction that created the OTP.
+func (uc *otpUseCase) enqueueMessage(ctx context.Context, otp *entities.OTP, language string) error {
+	message := entities.NewOutboxMessage(otp, uc.buildMessage(otp, otp.Channel, language), uc.outboxMaxAttempts)
+	if fallback := otp.Channel.Fallback(); fallback != "" {
+		message.FallbackChannel = fallback
+		message.FallbackMessage = uc.buildMessage(otp, fallback, language)
+	}
+
+	if err := uc.outboxRepo.Create(ctx, message); err != nil {
+		return fmt.Errorf("failed to enqueue %s message: %w", otp.Channel, err)
+	}
+
+	mobile := otp.RecipientType == entities.RecipientPhone && uc.phoneValidator.IsMobile(otp.Recipient)
+	dueAt := time.Now()
+	for i, channel := range uc.escalation.Steps(otp, mobile) {
+		dueAt = dueAt.Add(uc.escalation.Timeout)
+		if !dueAt.Before(otp.ExpiresAt) {
+			break
+		}
+
+		step := entities.NewEscalationMessage(otp, channel, uc.buildMessage(otp, channel, language), i+1, dueAt, uc.outboxMaxAttempts)
+		if err := uc.outboxRepo.Create(ctx, step); err != nil {
+			return fmt.Errorf("failed to enqueue %s escalation: %w", channel, err)
+		}
+	}
+	return nil
+}
+
+// buildMessage renders the message delivered over channel. Messenger apps
+// get the bare code, which they place in their own verification templates,
+// and emails carry the SMS text under the purpose's subject.
+func (uc *otpUseCase) buildMessage(otp *entities.OTP, channel entities.Channel, language string) string {
+	switch channel {
+	case entities.ChannelVoice:
+		return uc.voiceScripts.Build(otp.Code, otp.Purpose, language)
+	case entities.ChannelWhatsApp, entities.ChannelTelegram:
+		return otp.Code
+	case entities.ChannelEmail:
+		return uc.emailComposer.Compose(uc.policies[otp.Purpose].EmailSubject, uc.buildSMSMessage(otp))
 	default:
-		return fmt.Sprintf("Your verification code is: %s. Valid for %d minutes. Do not share this code.", code, uc.validityMinutes)
+		return uc.buildSMSMessage(otp)
 	}
 }
 
+// buildSMSMessage renders the SMS template of the OTP's purpose.
+func (uc *otpUseCase) buildSMSMessage(otp *entities.OTP) string {
+	minutes := int(uc.policies[otp.Purpose].TTL.Minutes())
+
+	if tmpl, ok := uc.templates[otp.Purpose]; ok {
+		var message strings.Builder
+		err := tmpl.Execute(&message, struct {
+			Code    string
+			Minutes int
+			Purpose entities.OTPPurpose
+		}{otp.Code, minutes, otp.Purpose})
+		if err == nil {
+			return message.String()
+		}
+		uc.logger.WithError(err).WithField("purpose", otp.Purpose).Error("Failed to render SMS template")
+	}
+
+	return fmt.Sprintf("Your verification code is: %s. Valid for %d minutes. Do not share this code.", otp.Code, minutes)
+}
+
+// expiresIn returns the lifetime of an OTP issued for purpose, in seconds.
+func (uc *otpUseCase) expiresIn(purpose entities.OTPPurpose) int {
+	return int(uc.policies[purpose].TTL.Seconds())
+}
+
+// retryAfter returns the whole seconds until t, rounded up.
+func retryAfter(t time.Time) int {
+	return int(math.Ceil(max(0, time.Until(t).Seconds())))
+}
+
 func (uc *otpUseCase) getErrorMessage(err error) string {
 	switch err {
 	case entities.ErrOTPExpired:
@@ -165,6 +542,8 @@ func (uc *otpUseCase) getErrorMessage(err error) string {
 		return "Invalid OTP code. Please try again."
 	case entities.ErrOTPNotFound:
 		return "OTP not found. Please request a new one."
+	case entities.ErrOTPCanceled:
+		return "OTP has been canceled. Please request a new one."
 	case services.ErrRateLimitExceeded:
 		return "Too many requests. Please wait before requesting a new OTP."
 	default:
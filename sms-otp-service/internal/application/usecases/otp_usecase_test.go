@@ -0,0 +1,680 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/services"
+	"sms-otp-service/internal/infrastructure/ratelimit"
+	"sms-otp-service/pkg/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testPhone = "+14155550100"
+	testEmail = "user@example.com"
+)
+
+var testPolicy = entities.OTPPolicy{
+	Purpose:       entities.PurposeVerification,
+	CodeLength:    6,
+	Alphabet:      entities.DigitAlphabet,
+	TTL:           5 * time.Minute,
+	MaxAttempts:   3,
+	ResendBackoff: entities.Backoff{Delays: []time.Duration{30 * time.Second}, Reset: time.Hour},
+	SMSTemplate:   "Your code is {{.Code}}",
+	EmailSubject:  "Your code",
+}
+
+// testOTPUseCase is a use case on the real domain service, with fakes that
+// keep OTPs and outbox messages in memory.
+type testOTPUseCase struct {
+	OTPUseCase
+	otps   *fakeOTPRepository
+	outbox *fakeOutbox
+	fraud  *fakeFraudService
+}
+
+func newTestOTPUseCase(t *testing.T) *testOTPUseCase {
+	t.Helper()
+	phoneValidator, err := utils.NewPhoneValidator("")
+	if err != nil {
+		t.Fatalf("NewPhoneValidator: %v", err)
+	}
+	policies := entities.PolicyRegistry{entities.PurposeVerification: testPolicy}
+	txManager := fakeTxManager{}
+	uc := &testOTPUseCase{
+		otps:   &fakeOTPRepository{otps: make(map[string]*entities.OTP)},
+		outbox: &fakeOutbox{},
+		fraud:  &fakeFraudService{},
+	}
+
+	otpService := services.NewOTPDomainService(
+		uc.otps,
+		noNumberRules{},
+		ratelimit.NewMemoryStore(),
+		txManager,
+		&sequenceGenerator{},
+		utils.NewCodeHasher([]byte("test")),
+		phoneValidator,
+		utils.NewEmailValidator(),
+		policies,
+		entities.ChannelRoutes{},
+		entities.Lockout{},
+		10, 10, 100,
+	)
+	uc.OTPUseCase = NewOTPUseCase(
+		otpService,
+		uc.fraud,
+		txManager,
+		uc.outbox,
+		3,
+		policies,
+		entities.EscalationPolicy{},
+		phoneValidator,
+		stubTokenSigner{},
+		nil,
+		stubEmailComposer{},
+		testLogger(),
+	)
+	return uc
+}
+
+func (uc *testOTPUseCase) create(t *testing.T, req dto.CreateVerificationRequest) *dto.VerificationResponse {
+	t.Helper()
+	resp, err := uc.CreateVerification(context.Background(), &req)
+	if err != nil {
+		t.Fatalf("CreateVerification: %v", err)
+	}
+	return resp
+}
+
+func (uc *testOTPUseCase) check(t *testing.T, id, code string) *dto.VerificationResponse {
+	t.Helper()
+	resp, err := uc.CheckVerification(context.Background(), id, &dto.CheckVerificationRequest{Code: code})
+	if err != nil {
+		t.Fatalf("CheckVerification: %v", err)
+	}
+	return resp
+}
+
+func TestCreateVerification(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         dto.CreateVerificationRequest
+		wantChannel entities.Channel
+		wantText    string
+	}{
+		{
+			name:        "phone",
+			req:         dto.CreateVerificationRequest{PhoneNumber: testPhone},
+			wantChannel: entities.ChannelSMS,
+			wantText:    "Your code is 000001",
+		},
+		{
+			name:        "email",
+			req:         dto.CreateVerificationRequest{Email: testEmail},
+			wantChannel: entities.ChannelEmail,
+			wantText:    "Your code\n\nYour code is 000001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := newTestOTPUseCase(t)
+			resp := uc.create(t, tt.req)
+
+			if !resp.Success || resp.Message != "Verification started" {
+				t.Errorf("response = %+v, want a started session", resp)
+			}
+			if _, err := uuid.Parse(resp.ID); err != nil {
+				t.Errorf("ID = %q, want a UUID", resp.ID)
+			}
+			if resp.PhoneNumber != tt.req.PhoneNumber || resp.Email != tt.req.Email {
+				t.Errorf("recipient = %q, %q", resp.PhoneNumber, resp.Email)
+			}
+			if resp.Status != entities.VerificationPending || resp.Purpose != entities.PurposeVerification || resp.Channel != tt.wantChannel {
+				t.Errorf("session is %s for %s over %s, want pending for verification over %s", resp.Status, resp.Purpose, resp.Channel, tt.wantChannel)
+			}
+			if resp.Attempts != 0 || resp.MaxAttempts != testPolicy.MaxAttempts {
+				t.Errorf("attempts = %d of %d", resp.Attempts, resp.MaxAttempts)
+			}
+
+			messages := uc.outbox.byOTP(resp.ID)
+			if len(messages) != 1 || messages[0].Message != tt.wantText || messages[0].Channel != tt.wantChannel {
+				t.Fatalf("outbox = %+v, want one %s message %q", messages, tt.wantChannel, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestCreateVerificationErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  dto.CreateVerificationRequest
+		want error
+	}{
+		{name: "no recipient", req: dto.CreateVerificationRequest{}, want: entities.ErrInvalidRecipient},
+		{name: "two recipients", req: dto.CreateVerificationRequest{PhoneNumber: testPhone, Email: testEmail}, want: entities.ErrInvalidRecipient},
+		{name: "unknown purpose", req: dto.CreateVerificationRequest{PhoneNumber: testPhone, Purpose: "payment"}, want: entities.ErrUnknownPurpose},
+		{name: "channel for email", req: dto.CreateVerificationRequest{Email: testEmail, Channel: entities.ChannelSMS}, want: entities.ErrUnsupportedChannel},
+		{name: "challenged", req: dto.CreateVerificationRequest{PhoneNumber: "+14155550199"}, want: entities.ErrChallengeRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := newTestOTPUseCase(t)
+			uc.fraud.challenge = "+14155550199"
+
+			if resp, err := uc.CreateVerification(context.Background(), &tt.req); !errors.Is(err, tt.want) {
+				t.Fatalf("CreateVerification = %+v, %v, want %v", resp, err, tt.want)
+			}
+			if len(uc.otps.otps) != 0 || len(uc.outbox.messages) != 0 {
+				t.Errorf("a refused session left %d OTPs and %d messages", len(uc.otps.otps), len(uc.outbox.messages))
+			}
+		})
+	}
+}
+
+func TestCheckVerificationParallelSessions(t *testing.T) {
+	uc := newTestOTPUseCase(t)
+
+	// Two sessions for one number, say from two browser tabs, keep their
+	// own codes.
+	first := uc.create(t, dto.CreateVerificationRequest{PhoneNumber: testPhone})
+	second := uc.create(t, dto.CreateVerificationRequest{PhoneNumber: testPhone})
+	if first.ID == second.ID {
+		t.Fatal("both sessions got the same ID")
+	}
+
+	resp := uc.check(t, first.ID, "000002")
+	if resp.Success || resp.Status != entities.VerificationPending || resp.Attempts != 1 || resp.Token != "" {
+		t.Fatalf("first session with the second code = %+v, want a failed attempt", resp)
+	}
+	if resp.Message != "Invalid OTP code. Please try again." {
+		t.Errorf("message = %q", resp.Message)
+	}
+
+	resp = uc.check(t, second.ID, "000002")
+	if !resp.Success || resp.Status != entities.VerificationApproved || resp.VerifiedAt == nil {
+		t.Fatalf("second session = %+v, want approved", resp)
+	}
+	if resp.Token != "signed:"+second.ID || resp.TokenType != "Bearer" || resp.TokenExpiresIn != int(time.Hour.Seconds()) {
+		t.Errorf("token = %q %q %d, want a bearer token for the second session", resp.Token, resp.TokenType, resp.TokenExpiresIn)
+	}
+
+	// Approving the second session leaves the first one open.
+	resp = uc.check(t, first.ID, "000001")
+	if !resp.Success || resp.Status != entities.VerificationApproved || resp.Attempts != 2 {
+		t.Fatalf("first session = %+v, want approved on the second attempt", resp)
+	}
+	if resp.Token != "signed:"+first.ID {
+		t.Errorf("token = %q, want one for the first session", resp.Token)
+	}
+	if got := uc.fraud.verifiedOTPs(); len(got) != 2 || got[0] != second.ID || got[1] != first.ID {
+		t.Errorf("verifications recorded for %v, want both sessions", got)
+	}
+}
+
+func TestCheckVerificationFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		prepare     func(t *testing.T, uc *testOTPUseCase, id string)
+		code        string
+		wantStatus  entities.VerificationStatus
+		wantMessage string
+	}{
+		{
+			name: "attempts used up",
+			prepare: func(t *testing.T, uc *testOTPUseCase, id string) {
+				for range testPolicy.MaxAttempts {
+					uc.check(t, id, "999999")
+				}
+			},
+			code:        "000001",
+			wantStatus:  entities.VerificationFailed,
+			wantMessage: "Maximum verification attempts reached. Please request a new OTP.",
+		},
+		{
+			name: "already approved",
+			prepare: func(t *testing.T, uc *testOTPUseCase, id string) {
+				uc.check(t, id, "000001")
+			},
+			code:        "000001",
+			wantStatus:  entities.VerificationApproved,
+			wantMessage: "OTP has already been used. Please request a new one.",
+		},
+		{
+			name: "canceled",
+			prepare: func(t *testing.T, uc *testOTPUseCase, id string) {
+				if _, err := uc.CancelVerification(context.Background(), id); err != nil {
+					t.Fatalf("CancelVerification: %v", err)
+				}
+			},
+			code:        "000001",
+			wantStatus:  entities.VerificationCanceled,
+			wantMessage: "OTP has been canceled. Please request a new one.",
+		},
+		{
+			name: "expired",
+			prepare: func(t *testing.T, uc *testOTPUseCase, id string) {
+				uc.otps.update(id, func(otp *entities.OTP) { otp.ExpiresAt = time.Now().Add(-time.Second) })
+			},
+			code:        "000001",
+			wantStatus:  entities.VerificationExpired,
+			wantMessage: "OTP has expired. Please request a new one.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := newTestOTPUseCase(t)
+			id := uc.create(t, dto.CreateVerificationRequest{PhoneNumber: testPhone}).ID
+			tt.prepare(t, uc, id)
+
+			resp := uc.check(t, id, tt.code)
+			if resp.Success || resp.Token != "" {
+				t.Errorf("check = %+v, want it refused", resp)
+			}
+			if resp.Status != tt.wantStatus || resp.Message != tt.wantMessage {
+				t.Errorf("check = %s %q, want %s %q", resp.Status, resp.Message, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestCheckVerificationErrors(t *testing.T) {
+	uc := newTestOTPUseCase(t)
+	id := uc.create(t, dto.CreateVerificationRequest{PhoneNumber: testPhone}).ID
+
+	tests := []struct {
+		name string
+		id   string
+		code string
+		want error
+	}{
+		{name: "malformed ID", id: "not-a-uuid", code: "000001", want: entities.ErrOTPNotFound},
+		{name: "unknown ID", id: uuid.NewString(), code: "000001", want: entities.ErrOTPNotFound},
+		{name: "malformed code", id: id, code: "12ab", want: entities.ErrInvalidCodeFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := uc.CheckVerification(context.Background(), tt.id, &dto.CheckVerificationRequest{Code: tt.code})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("CheckVerification = %+v, %v, want %v", resp, err, tt.want)
+			}
+		})
+	}
+
+	// Malformed codes cost no attempt.
+	if resp := uc.check(t, id, "000001"); !resp.Success || resp.Attempts != 1 {
+		t.Errorf("check after a malformed code = %+v, want approved on the first attempt", resp)
+	}
+}
+
+func TestGetVerification(t *testing.T) {
+	uc := newTestOTPUseCase(t)
+	created := uc.create(t, dto.CreateVerificationRequest{PhoneNumber: testPhone})
+	uc.check(t, created.ID, "999999")
+
+	resp, err := uc.GetVerification(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetVerification: %v", err)
+	}
+	if resp.ID != created.ID || resp.Status != entities.VerificationPending || resp.Attempts != 1 || resp.PhoneNumber != testPhone {
+		t.Errorf("session = %+v, want the pending session after one attempt", resp)
+	}
+	if len(resp.DeliveryAttempts) != 1 {
+		t.Fatalf("delivery attempts = %+v, want the queued SMS", resp.DeliveryAttempts)
+	}
+	attempt := resp.DeliveryAttempts[0]
+	if attempt.Step != 0 || attempt.Channel != entities.ChannelSMS || attempt.Status != entities.OutboxPending || attempt.NextAttemptAt == nil {
+		t.Errorf("delivery attempt = %+v, want the first SMS pending", attempt)
+	}
+
+	for _, id := range []string{"not-a-uuid", uuid.NewString()} {
+		if _, err := uc.GetVerification(context.Background(), id); !errors.Is(err, entities.ErrOTPNotFound) {
+			t.Errorf("GetVerification(%s) = %v, want %v", id, err, entities.ErrOTPNotFound)
+		}
+	}
+}
+
+func TestCancelVerification(t *testing.T) {
+	uc := newTestOTPUseCase(t)
+	ctx := context.Background()
+	canceled := uc.create(t, dto.CreateVerificationRequest{PhoneNumber: testPhone})
+	other := uc.create(t, dto.CreateVerificationRequest{PhoneNumber: testPhone})
+
+	resp, err := uc.CancelVerification(ctx, canceled.ID)
+	if err != nil {
+		t.Fatalf("CancelVerification: %v", err)
+	}
+	if resp.Status != entities.VerificationCanceled || resp.CanceledAt == nil || resp.Message != "Verification canceled" {
+		t.Errorf("canceled session = %+v", resp)
+	}
+
+	// Canceling again is harmless, and the other session stays open.
+	again, err := uc.CancelVerification(ctx, canceled.ID)
+	if err != nil || !again.CanceledAt.Equal(*resp.CanceledAt) {
+		t.Errorf("second cancel = %+v, %v, want the first cancellation kept", again, err)
+	}
+	if resp := uc.check(t, other.ID, "000002"); !resp.Success {
+		t.Errorf("other session = %+v, want it approved", resp)
+	}
+
+	// An approved session cannot be canceled.
+	if _, err := uc.CancelVerification(ctx, other.ID); !errors.Is(err, entities.ErrOTPAlreadyUsed) {
+		t.Errorf("cancel of an approved session = %v, want %v", err, entities.ErrOTPAlreadyUsed)
+	}
+	if _, err := uc.CancelVerification(ctx, "not-a-uuid"); !errors.Is(err, entities.ErrOTPNotFound) {
+		t.Errorf("cancel of a malformed ID = %v, want %v", err, entities.ErrOTPNotFound)
+	}
+}
+
+// fakeTxManager runs transactions without isolation and runs their commit
+// or rollback hooks when they end.
+type fakeTxManager struct{}
+
+type fakeTxKey struct{}
+
+type fakeTx struct {
+	onCommit   []func(ctx context.Context)
+	onRollback []func(ctx context.Context)
+}
+
+func (fakeTxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(fakeTxKey{}).(*fakeTx); ok {
+		return fn(ctx)
+	}
+
+	tx := &fakeTx{}
+	err := fn(context.WithValue(ctx, fakeTxKey{}, tx))
+	hooks := tx.onCommit
+	if err != nil {
+		hooks = tx.onRollback
+	}
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+	return err
+}
+
+func (fakeTxManager) AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if tx, ok := ctx.Value(fakeTxKey{}).(*fakeTx); ok {
+		tx.onCommit = append(tx.onCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+func (fakeTxManager) AfterRollback(ctx context.Context, fn func(ctx context.Context)) {
+	if tx, ok := ctx.Value(fakeTxKey{}).(*fakeTx); ok {
+		tx.onRollback = append(tx.onRollback, fn)
+	}
+}
+
+// fakeOTPRepository keeps copies of OTPs by ID.
+type fakeOTPRepository struct {
+	mu   sync.Mutex
+	otps map[string]*entities.OTP
+}
+
+func (r *fakeOTPRepository) store(otp *entities.OTP) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *otp
+	r.otps[otp.ID.String()] = &stored
+}
+
+func (r *fakeOTPRepository) update(id string, change func(*entities.OTP)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(r.otps[id])
+}
+
+func (r *fakeOTPRepository) latest(recipient string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *entities.OTP
+	for _, otp := range r.otps {
+		if otp.Recipient == recipient && otp.Purpose == purpose && (latest == nil || otp.CreatedAt.After(latest.CreatedAt)) {
+			latest = otp
+		}
+	}
+	if latest == nil {
+		return nil, entities.ErrOTPNotFound
+	}
+	found := *latest
+	return &found, nil
+}
+
+func (r *fakeOTPRepository) Create(ctx context.Context, otp *entities.OTP) error {
+	r.store(otp)
+	return nil
+}
+
+func (r *fakeOTPRepository) FindByRecipientAndPurpose(ctx context.Context, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+	return r.latest(recipient, purpose)
+}
+
+func (r *fakeOTPRepository) FindByRecipientAndPurposeForUpdate(ctx context.Context, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+	return r.latest(recipient, purpose)
+}
+
+func (r *fakeOTPRepository) FindByID(ctx context.Context, id string) (*entities.OTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	otp, ok := r.otps[id]
+	if !ok {
+		return nil, entities.ErrOTPNotFound
+	}
+	found := *otp
+	return &found, nil
+}
+
+func (r *fakeOTPRepository) FindByIDForUpdate(ctx context.Context, id string) (*entities.OTP, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *fakeOTPRepository) Update(ctx context.Context, otp *entities.OTP) error {
+	r.store(otp)
+	return nil
+}
+
+func (r *fakeOTPRepository) UpdateVerification(ctx context.Context, otp *entities.OTP) error {
+	r.store(otp)
+	return nil
+}
+
+func (r *fakeOTPRepository) InvalidateActive(ctx context.Context, recipient string, purpose entities.OTPPurpose) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, otp := range r.otps {
+		if otp.Recipient == recipient && otp.Purpose == purpose && otp.IsValid() {
+			otp.Attempts = otp.MaxAttempts
+		}
+	}
+	return nil
+}
+
+func (r *fakeOTPRepository) UpdateDeliveryStatus(ctx context.Context, id string, status entities.DeliveryStatus, provider, messageID string) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) UpdateChannel(ctx context.Context, id string, channel entities.Channel) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) RestartDelivery(ctx context.Context, id string, channel entities.Channel) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) FindByProviderMessageID(ctx context.Context, provider, messageID string) (*entities.OTP, error) {
+	return nil, entities.ErrOTPNotFound
+}
+
+func (r *fakeOTPRepository) Delete(ctx context.Context, id string) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) DeleteExpired(ctx context.Context) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) FindActiveByRecipient(ctx context.Context, recipient string) ([]*entities.OTP, error) {
+	return nil, errors.New("not implemented")
+}
+
+// noNumberRules is a rule repository without rules.
+type noNumberRules struct{}
+
+func (noNumberRules) Create(ctx context.Context, rule *entities.NumberRule) error {
+	return errors.New("not implemented")
+}
+
+func (noNumberRules) FindByID(ctx context.Context, id string) (*entities.NumberRule, error) {
+	return nil, entities.ErrNumberRuleNotFound
+}
+
+func (noNumberRules) FindAll(ctx context.Context, clientID string, action entities.NumberRuleAction) ([]*entities.NumberRule, error) {
+	return nil, nil
+}
+
+func (noNumberRules) FindMatching(ctx context.Context, clientID, phoneNumber, region string) ([]*entities.NumberRule, error) {
+	return nil, nil
+}
+
+func (noNumberRules) HasAllowRules(ctx context.Context, clientID string) (bool, error) {
+	return false, nil
+}
+
+func (noNumberRules) Update(ctx context.Context, rule *entities.NumberRule) error {
+	return errors.New("not implemented")
+}
+
+func (noNumberRules) Delete(ctx context.Context, id string) error {
+	return errors.New("not implemented")
+}
+
+// fakeOutbox keeps the messages the use case queues.
+type fakeOutbox struct {
+	mu       sync.Mutex
+	messages []entities.OutboxMessage
+}
+
+func (r *fakeOutbox) byOTP(otpID string) []*entities.OutboxMessage {
+	messages, _ := r.FindByOTPID(context.Background(), otpID)
+	return messages
+}
+
+func (r *fakeOutbox) Create(ctx context.Context, message *entities.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, *message)
+	return nil
+}
+
+func (r *fakeOutbox) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entities.OutboxMessage, error) {
+	return nil, nil
+}
+
+func (r *fakeOutbox) Update(ctx context.Context, message *entities.OutboxMessage) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeOutbox) FindByOTPID(ctx context.Context, otpID string) ([]*entities.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []*entities.OutboxMessage
+	for _, message := range r.messages {
+		if message.OTPID.String() == otpID {
+			found = append(found, &message)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeOutbox) ReleaseEscalation(ctx context.Context, otpID string) error {
+	return nil
+}
+
+func (r *fakeOutbox) DeleteProcessedBefore(ctx context.Context, cutoff time.Time) error {
+	return nil
+}
+
+// fakeFraudService allows every send except to the challenge number, and
+// records verifications.
+type fakeFraudService struct {
+	challenge string
+
+	mu       sync.Mutex
+	verified []string
+}
+
+func (s *fakeFraudService) Screen(ctx context.Context, phoneNumber string, origin entities.SendOrigin) (*entities.SendAttempt, error) {
+	if phoneNumber == s.challenge {
+		return &entities.SendAttempt{Recipient: phoneNumber, Decision: entities.FraudChallenge}, entities.ErrChallengeRequired
+	}
+	return &entities.SendAttempt{Recipient: phoneNumber, Decision: entities.FraudAllow}, nil
+}
+
+func (s *fakeFraudService) RecordSend(ctx context.Context, attempt *entities.SendAttempt, otp *entities.OTP) error {
+	return nil
+}
+
+func (s *fakeFraudService) RecordVerification(ctx context.Context, otp *entities.OTP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verified = append(s.verified, otp.ID.String())
+	return nil
+}
+
+func (s *fakeFraudService) verifiedOTPs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.verified...)
+}
+
+// stubTokenSigner issues tokens naming the OTP, valid for an hour.
+type stubTokenSigner struct{}
+
+func (stubTokenSigner) Issue(claims *entities.VerificationClaims) (string, error) {
+	claims.IssuedAt = time.Now()
+	claims.ExpiresAt = claims.IssuedAt.Add(time.Hour)
+	return "signed:" + claims.OTPID, nil
+}
+
+func (stubTokenSigner) Parse(token string) (*entities.VerificationClaims, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (stubTokenSigner) Keys() []entities.JSONWebKey {
+	return nil
+}
+
+// stubEmailComposer joins subject and body.
+type stubEmailComposer struct{}
+
+func (stubEmailComposer) Compose(subject, body string) string {
+	return subject + "\n\n" + body
+}
+
+// sequenceGenerator generates 000001, 000002 and so on.
+type sequenceGenerator struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (g *sequenceGenerator) Generate(length int, alphabet string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return fmt.Sprintf("%0*d", length, g.n)
+}
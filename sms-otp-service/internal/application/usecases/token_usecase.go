@@ -0,0 +1,65 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/domain/entities"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TokenSigner issues the verification tokens handed out after a successful
+// verification and validates tokens it issued.
+type TokenSigner interface {
+	Issue(claims *entities.VerificationClaims) (string, error)
+	Parse(token string) (*entities.VerificationClaims, error)
+	Keys() []entities.JSONWebKey
+}
+
+type TokenUseCase interface {
+	Introspect(ctx context.Context, req *dto.IntrospectTokenRequest) (*dto.IntrospectTokenResponse, error)
+	JWKS(ctx context.Context) *dto.JWKSResponse
+}
+
+type tokenUseCase struct {
+	tokenSigner TokenSigner
+	logger      *logrus.Logger
+}
+
+func NewTokenUseCase(tokenSigner TokenSigner, logger *logrus.Logger) TokenUseCase {
+	return &tokenUseCase{
+		tokenSigner: tokenSigner,
+		logger:      logger,
+	}
+}
+
+func (uc *tokenUseCase) Introspect(ctx context.Context, req *dto.IntrospectTokenRequest) (*dto.IntrospectTokenResponse, error) {
+	claims, err := uc.tokenSigner.Parse(req.Token)
+	if errors.Is(err, entities.ErrInvalidToken) || errors.Is(err, entities.ErrTokenExpired) {
+		uc.logger.WithError(err).Debug("Inactive verification token introspected")
+		return &dto.IntrospectTokenResponse{Active: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.IntrospectTokenResponse{
+		Active:      true,
+		TokenID:     claims.TokenID,
+		Issuer:      claims.Issuer,
+		Subject:     claims.Recipient.Address,
+		Audience:    claims.Audience,
+		IssuedAt:    claims.IssuedAt.Unix(),
+		ExpiresAt:   claims.ExpiresAt.Unix(),
+		PhoneNumber: claims.Recipient.PhoneNumber(),
+		Email:       claims.Recipient.Email(),
+		Purpose:     claims.Purpose,
+		OTPID:       claims.OTPID,
+		VerifiedAt:  &claims.VerifiedAt,
+	}, nil
+}
+
+func (uc *tokenUseCase) JWKS(ctx context.Context) *dto.JWKSResponse {
+	return &dto.JWKSResponse{Keys: uc.tokenSigner.Keys()}
+}
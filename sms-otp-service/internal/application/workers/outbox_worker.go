@@ -0,0 +1,274 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"math/rand/v2"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"sms-otp-service/internal/domain/services"
+	"sync"
+	"time"
+)
+
+// Sender delivers a rendered OTP message to a recipient over one channel.
+type Sender interface {
+	Send(ctx context.Context, recipient, message string) (*entities.SendResult, error)
+}
+
+// SenderFunc adapts a provider method such as sms.Service.SendSMS to Sender.
+type SenderFunc func(ctx context.Context, recipient, message string) (*entities.SendResult, error)
+
+func (f SenderFunc) Send(ctx context.Context, recipient, message string) (*entities.SendResult, error) {
+	return f(ctx, recipient, message)
+}
+
+type OutboxWorkerConfig struct {
+	Workers       int
+	BatchSize     int
+	PollInterval  time.Duration
+	LeaseDuration time.Duration
+	BaseBackoff   time.Duration
+	MaxBackoff    time.Duration
+}
+
+// OutboxWorker delivers queued OTP messages with a pool of workers, using the
+// sender registered for each message's channel. A message with a fallback
+// channel moves to it on the first failure; other failures are retried with
+// exponential backoff, and messages that run out of attempts or can never be
+// delivered are dead-lettered. Escalation steps that are no longer needed when
+// they come due are canceled.
+type OutboxWorker struct {
+	outboxRepo      repositories.OutboxRepository
+	deliveryService services.DeliveryService
+	senders         map[entities.Channel]Sender
+	cfg             OutboxWorkerConfig
+	logger          *logrus.Logger
+	wg              sync.WaitGroup
+}
+
+func NewOutboxWorker(
+	outboxRepo repositories.OutboxRepository,
+	deliveryService services.DeliveryService,
+	senders map[entities.Channel]Sender,
+	cfg OutboxWorkerConfig,
+	logger *logrus.Logger,
+) *OutboxWorker {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = cfg.Workers
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = time.Minute
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.BaseBackoff {
+		cfg.MaxBackoff = cfg.BaseBackoff
+	}
+
+	return &OutboxWorker{
+		outboxRepo:      outboxRepo,
+		deliveryService: deliveryService,
+		senders:         senders,
+		cfg:             cfg,
+		logger:          logger,
+	}
+}
+
+// Start launches the poller and the worker pool. Both stop once ctx is
+// cancelled; Wait blocks until in-flight deliveries have finished.
+func (w *OutboxWorker) Start(ctx context.Context) {
+	jobs := make(chan *entities.OutboxMessage)
+
+	for i := 0; i < w.cfg.Workers; i++ {
+		w.wg.Add(1)
+		go w.work(jobs)
+	}
+
+	w.wg.Add(1)
+	go w.poll(ctx, jobs)
+
+	w.logger.WithField("workers", w.cfg.Workers).Info("Starting outbox workers")
+}
+
+func (w *OutboxWorker) Wait() {
+	w.wg.Wait()
+}
+
+func (w *OutboxWorker) poll(ctx context.Context, jobs chan<- *entities.OutboxMessage) {
+	defer w.wg.Done()
+	defer close(jobs)
+
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		messages, err := w.outboxRepo.ClaimDue(ctx, w.cfg.BatchSize, w.cfg.LeaseDuration)
+		if err != nil && ctx.Err() == nil {
+			w.logger.WithError(err).Error("Failed to claim outbox messages")
+		}
+
+		// Claimed messages that are not handed out before shutdown are picked
+		// up again by any instance once their lease expires.
+		for _, message := range messages {
+			select {
+			case jobs <- message:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if len(messages) == w.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *OutboxWorker) work(jobs <-chan *entities.OutboxMessage) {
+	defer w.wg.Done()
+
+	for message := range jobs {
+		w.deliver(message)
+	}
+}
+
+func (w *OutboxWorker) deliver(message *entities.OutboxMessage) {
+	// Deliveries are not tied to the poller context so a shutdown lets
+	// in-flight sends finish and record their outcome.
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.LeaseDuration)
+	defer cancel()
+
+	logEntry := w.logger.WithFields(logrus.Fields{
+		"outbox_id": message.ID,
+		"otp_id":    message.OTPID,
+		"channel":   message.Channel,
+		"attempt":   message.Attempts + 1,
+	})
+
+	if message.IsEscalation() {
+		escalate, err := w.deliveryService.Escalate(ctx, message)
+		if err != nil {
+			// The step is claimed again once its lease expires.
+			logEntry.WithError(err).Error("Failed to check OTP escalation")
+			return
+		}
+		if !escalate {
+			message.Cancel("escalation not needed")
+			logEntry.Info("Canceling escalation of settled OTP")
+			w.finish(ctx, message, "")
+			return
+		}
+		logEntry.Info("Escalating OTP delivery")
+	}
+
+	if message.IsExpired() {
+		message.MarkDead("otp expired before delivery")
+		logEntry.Warn("Dropping outbox message for expired OTP")
+		w.finish(ctx, message, entities.DeliveryExpired)
+		return
+	}
+
+	sender, ok := w.senders[message.Channel]
+	if !ok {
+		reason := "no sender for channel " + string(message.Channel)
+		if message.FallBack(reason) {
+			logEntry.WithField("fallback_channel", message.Channel).Warn("No sender for outbox message channel, falling back")
+			w.fallBack(ctx, message)
+			return
+		}
+		message.MarkDead(reason)
+		logEntry.Error("Dropping outbox message for unsupported channel")
+		w.finish(ctx, message, entities.DeliveryFailed)
+		return
+	}
+
+	result, err := sender.Send(ctx, message.Recipient, message.Message)
+	if err == nil {
+		message.MarkSent(result)
+		logEntry.WithFields(logrus.Fields{
+			"provider":   result.Provider,
+			"message_id": result.MessageID,
+		}).Info("Outbox message delivered")
+		w.finish(ctx, message, entities.DeliverySent)
+		return
+	}
+
+	if message.FallBack(err.Error()) {
+		logEntry.WithError(err).WithField("fallback_channel", message.Channel).Warn("Outbox delivery failed, falling back")
+		w.fallBack(ctx, message)
+		return
+	}
+
+	retryAt := time.Now().Add(w.backoff(message.Attempts + 1))
+	if dead := message.MarkFailed(err.Error(), retryAt, isPermanent(err)); dead {
+		logEntry.WithError(err).Error("Outbox message dead-lettered")
+		w.finish(ctx, message, entities.DeliveryFailed)
+		return
+	}
+
+	logEntry.WithError(err).WithField("retry_at", retryAt).Warn("Outbox delivery failed, retrying")
+	w.finish(ctx, message, "")
+}
+
+func (w *OutboxWorker) finish(ctx context.Context, message *entities.OutboxMessage, status entities.DeliveryStatus) {
+	if err := w.outboxRepo.Update(ctx, message); err != nil {
+		w.logger.WithError(err).WithField("outbox_id", message.ID).Error("Failed to update outbox message")
+	}
+
+	if status == "" {
+		return
+	}
+	if err := w.deliveryService.RecordDispatch(ctx, message, status); err != nil {
+		w.logger.WithError(err).WithField("otp_id", message.OTPID).Error("Failed to record OTP delivery status")
+	}
+}
+
+// fallBack stores a message that moved to its fallback channel. The next
+// poll picks it up again.
+func (w *OutboxWorker) fallBack(ctx context.Context, message *entities.OutboxMessage) {
+	if err := w.outboxRepo.Update(ctx, message); err != nil {
+		w.logger.WithError(err).WithField("outbox_id", message.ID).Error("Failed to update outbox message")
+		return
+	}
+	if err := w.deliveryService.RecordFallback(ctx, message); err != nil {
+		w.logger.WithError(err).WithField("otp_id", message.OTPID).Error("Failed to record OTP channel fallback")
+	}
+}
+
+// backoff returns the delay before the given attempt, doubling from
+// BaseBackoff up to MaxBackoff with jitter so retries from a burst of
+// failures spread out.
+func (w *OutboxWorker) backoff(attempt int) time.Duration {
+	delay := w.cfg.BaseBackoff
+	for i := 1; i < attempt && delay < w.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, w.cfg.MaxBackoff)
+
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int64N(half))
+}
+
+// isPermanent reports whether the provider declared the failure permanent,
+// for example because the destination number does not exist.
+func isPermanent(err error) bool {
+	var permanent interface{ Permanent() bool }
+	return errors.As(err, &permanent) && permanent.Permanent()
+}
@@ -0,0 +1,510 @@
+package workers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sms-otp-service/internal/infrastructure/messenger"
+	"sms-otp-service/internal/infrastructure/messenger/messengertest"
+	"sms-otp-service/internal/infrastructure/sms"
+	"sms-otp-service/internal/infrastructure/sms/smstest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testPhone       = "+14155550100"
+	testCode        = "123456"
+	testSMSText     = "Your verification code is: 123456."
+	testAccountSID  = "AC00000000000000000000000000000001"
+	testAuthToken   = "twilio-token"
+	testAccessToken = "messenger-token"
+)
+
+// fakeOutbox keeps outbox messages in memory. Claims lease messages the way
+// the database does, so a drained outbox holds no due messages.
+type fakeOutbox struct {
+	mu       sync.Mutex
+	messages map[uuid.UUID]entities.OutboxMessage
+}
+
+func newFakeOutbox() *fakeOutbox {
+	return &fakeOutbox{messages: make(map[uuid.UUID]entities.OutboxMessage)}
+}
+
+func (r *fakeOutbox) Create(ctx context.Context, message *entities.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[message.ID] = *message
+	return nil
+}
+
+func (r *fakeOutbox) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entities.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var claimed []*entities.OutboxMessage
+	for id, message := range r.messages {
+		if len(claimed) == limit {
+			break
+		}
+		if message.Status != entities.OutboxPending || message.NextAttemptAt.After(now) {
+			continue
+		}
+		if message.LockedUntil != nil && message.LockedUntil.After(now) {
+			continue
+		}
+		lockedUntil := now.Add(lease)
+		message.LockedUntil = &lockedUntil
+		r.messages[id] = message
+		claimed = append(claimed, &message)
+	}
+	return claimed, nil
+}
+
+func (r *fakeOutbox) Update(ctx context.Context, message *entities.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[message.ID] = *message
+	return nil
+}
+
+func (r *fakeOutbox) FindByOTPID(ctx context.Context, otpID string) ([]*entities.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found []*entities.OutboxMessage
+	for _, message := range r.messages {
+		if message.OTPID.String() == otpID {
+			found = append(found, &message)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeOutbox) ReleaseEscalation(ctx context.Context, otpID string) error {
+	return nil
+}
+
+func (r *fakeOutbox) DeleteProcessedBefore(ctx context.Context, cutoff time.Time) error {
+	return nil
+}
+
+// makeDue moves the next attempt of a message scheduled for retry to now.
+func (r *fakeOutbox) makeDue(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message := r.messages[id]
+	message.NextAttemptAt = time.Now()
+	r.messages[id] = message
+}
+
+func (r *fakeOutbox) get(id uuid.UUID) entities.OutboxMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.messages[id]
+}
+
+// dispatch is an outcome the worker recorded through the delivery service.
+type dispatch struct {
+	channel  entities.Channel
+	status   entities.DeliveryStatus
+	provider string
+}
+
+// fakeDeliveryService records what the worker reports.
+type fakeDeliveryService struct {
+	mu         sync.Mutex
+	dispatches []dispatch
+	fallbacks  []entities.Channel
+}
+
+func (s *fakeDeliveryService) RecordDispatch(ctx context.Context, message *entities.OutboxMessage, status entities.DeliveryStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dispatches = append(s.dispatches, dispatch{channel: message.Channel, status: status, provider: message.Provider})
+	return nil
+}
+
+func (s *fakeDeliveryService) RecordFallback(ctx context.Context, message *entities.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallbacks = append(s.fallbacks, message.Channel)
+	return nil
+}
+
+func (s *fakeDeliveryService) Escalate(ctx context.Context, message *entities.OutboxMessage) (bool, error) {
+	return true, nil
+}
+
+func (s *fakeDeliveryService) ProcessReceipt(ctx context.Context, receipt *entities.DeliveryReceipt) (bool, error) {
+	return false, nil
+}
+
+func (s *fakeDeliveryService) GetHistory(ctx context.Context, otpID string) (*entities.OTP, []*entities.DeliveryEvent, error) {
+	return nil, nil, nil
+}
+
+// testProviders are the stand-ins behind the senders of a test worker.
+type testProviders struct {
+	whatsApp *messengertest.WhatsAppServer
+	telegram *messengertest.TelegramServer
+	twilio   *smstest.TwilioServer
+}
+
+// newTestWorker returns a worker whose WhatsApp, Telegram and SMS senders are
+// wired as in main against local stand-ins.
+func newTestWorker(t *testing.T) (*OutboxWorker, *fakeOutbox, *fakeDeliveryService, testProviders) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	providers := testProviders{
+		whatsApp: messengertest.NewWhatsAppServer(t, "v21.0", "1234567890", testAccessToken),
+		telegram: messengertest.NewTelegramServer(t, testAccessToken),
+		twilio:   smstest.NewTwilioServer(t, testAccountSID, testAuthToken),
+	}
+
+	whatsAppService := messenger.NewWhatsAppCloudService(config.WhatsAppConfig{
+		APIEndpoint:      providers.whatsApp.URL,
+		APIVersion:       "v21.0",
+		PhoneNumberID:    "1234567890",
+		AccessToken:      testAccessToken,
+		TemplateName:     "otp_code",
+		TemplateLanguage: "en_US",
+		Timeout:          5 * time.Second,
+	}, logger)
+	telegramService := messenger.NewTelegramGatewayService(config.TelegramConfig{
+		APIEndpoint: providers.telegram.URL,
+		AccessToken: testAccessToken,
+		Timeout:     5 * time.Second,
+	}, logger)
+	smsService := sms.NewTwilioSMSService(config.SMSConfig{
+		APIKey:      testAccountSID,
+		APISecret:   testAuthToken,
+		SenderName:  "+15005550006",
+		APIEndpoint: providers.twilio.URL,
+		Timeout:     5 * time.Second,
+	}, logger)
+
+	outbox := newFakeOutbox()
+	delivery := &fakeDeliveryService{}
+	worker := NewOutboxWorker(outbox, delivery, map[entities.Channel]Sender{
+		entities.ChannelSMS:      SenderFunc(smsService.SendSMS),
+		entities.ChannelWhatsApp: SenderFunc(whatsAppService.SendCode),
+		entities.ChannelTelegram: SenderFunc(telegramService.SendCode),
+	}, OutboxWorkerConfig{BaseBackoff: time.Minute, MaxBackoff: time.Minute}, logger)
+
+	return worker, outbox, delivery, providers
+}
+
+// enqueue writes the message of an OTP sent over channel to the outbox, with
+// the fallback the use case adds for the channel.
+func enqueue(t *testing.T, outbox *fakeOutbox, channel entities.Channel) uuid.UUID {
+	t.Helper()
+	otp := &entities.OTP{
+		ID:        uuid.New(),
+		Recipient: testPhone,
+		Channel:   channel,
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	}
+
+	message := entities.NewOutboxMessage(otp, testCode, 3)
+	if fallback := channel.Fallback(); fallback != "" {
+		message.FallbackChannel = fallback
+		message.FallbackMessage = testSMSText
+	}
+	if err := outbox.Create(context.Background(), message); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return message.ID
+}
+
+// drain delivers due messages until none are left, as successive polls would.
+func drain(w *OutboxWorker, outbox *fakeOutbox) {
+	for {
+		messages, _ := outbox.ClaimDue(context.Background(), 10, time.Minute)
+		if len(messages) == 0 {
+			return
+		}
+		for _, message := range messages {
+			w.deliver(message)
+		}
+	}
+}
+
+func TestMessengerDelivery(t *testing.T) {
+	t.Run("whatsapp", func(t *testing.T) {
+		worker, outbox, delivery, providers := newTestWorker(t)
+		id := enqueue(t, outbox, entities.ChannelWhatsApp)
+
+		drain(worker, outbox)
+
+		messages := providers.whatsApp.Messages()
+		if len(messages) != 1 {
+			t.Fatalf("WhatsApp got %d messages, want 1", len(messages))
+		}
+		if messages[0].To != "14155550100" || messages[0].Code != testCode || messages[0].Template != "otp_code" {
+			t.Errorf("WhatsApp got %+v", messages[0])
+		}
+		assertSent(t, outbox.get(id), entities.ChannelWhatsApp, messages[0].ID)
+		assertDispatches(t, delivery, dispatch{entities.ChannelWhatsApp, entities.DeliverySent, "whatsapp"})
+		if len(providers.twilio.Messages()) != 0 {
+			t.Errorf("SMS sent alongside a delivered WhatsApp message")
+		}
+	})
+
+	t.Run("telegram", func(t *testing.T) {
+		worker, outbox, delivery, providers := newTestWorker(t)
+		id := enqueue(t, outbox, entities.ChannelTelegram)
+
+		drain(worker, outbox)
+
+		messages := providers.telegram.Messages()
+		if len(messages) != 1 {
+			t.Fatalf("Telegram got %d messages, want 1", len(messages))
+		}
+		if messages[0].PhoneNumber != testPhone || messages[0].Code != testCode {
+			t.Errorf("Telegram got %+v", messages[0])
+		}
+		assertSent(t, outbox.get(id), entities.ChannelTelegram, messages[0].RequestID)
+		assertDispatches(t, delivery, dispatch{entities.ChannelTelegram, entities.DeliverySent, "telegram"})
+		if len(providers.twilio.Messages()) != 0 {
+			t.Errorf("SMS sent alongside a delivered Telegram message")
+		}
+	})
+}
+
+func TestMessengerFallbackToSMS(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel entities.Channel
+		fail    func(testProviders)
+	}{
+		{
+			name:    "whatsapp account missing",
+			channel: entities.ChannelWhatsApp,
+			fail:    func(p testProviders) { p.whatsApp.Unregister(testPhone) },
+		},
+		{
+			name:    "whatsapp throttled",
+			channel: entities.ChannelWhatsApp,
+			fail: func(p testProviders) {
+				p.whatsApp.FailNext(messengertest.WhatsAppError{HTTPStatus: http.StatusTooManyRequests, Code: 130429, Message: "Rate limit hit"})
+			},
+		},
+		{
+			name:    "telegram number invalid",
+			channel: entities.ChannelTelegram,
+			fail: func(p testProviders) {
+				p.telegram.FailNext(messengertest.TelegramError{HTTPStatus: http.StatusBadRequest, Error: "PHONE_NUMBER_INVALID"})
+			},
+		},
+		{
+			name:    "telegram flood wait",
+			channel: entities.ChannelTelegram,
+			fail: func(p testProviders) {
+				p.telegram.FailNext(messengertest.TelegramError{HTTPStatus: http.StatusTooManyRequests, Error: "FLOOD_WAIT_30"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker, outbox, delivery, providers := newTestWorker(t)
+			tt.fail(providers)
+			id := enqueue(t, outbox, tt.channel)
+
+			drain(worker, outbox)
+
+			sent := providers.twilio.Messages()
+			if len(sent) != 1 {
+				t.Fatalf("SMS got %d messages, want 1", len(sent))
+			}
+			if sent[0].To != testPhone || sent[0].Body != testSMSText {
+				t.Errorf("SMS got %+v, want the fallback text", sent[0])
+			}
+
+			message := outbox.get(id)
+			assertSent(t, message, entities.ChannelSMS, sent[0].SID)
+			if message.Attempts != 1 || message.FallbackChannel != "" {
+				t.Errorf("message has %d attempts and fallback %q, want 1 attempt on the fallback channel", message.Attempts, message.FallbackChannel)
+			}
+			if len(delivery.fallbacks) != 1 || delivery.fallbacks[0] != entities.ChannelSMS {
+				t.Errorf("fallbacks = %v, want one to sms", delivery.fallbacks)
+			}
+			assertDispatches(t, delivery, dispatch{entities.ChannelSMS, entities.DeliverySent, "twilio"})
+		})
+	}
+}
+
+func TestMessengerFallbackFails(t *testing.T) {
+	worker, outbox, delivery, providers := newTestWorker(t)
+	providers.whatsApp.Unregister(testPhone)
+	providers.twilio.FailNext(smstest.TwilioError{HTTPStatus: http.StatusBadRequest, Code: 21211, Message: "Invalid 'To' Phone Number"})
+	id := enqueue(t, outbox, entities.ChannelWhatsApp)
+
+	drain(worker, outbox)
+
+	// The fallback has no fallback of its own, and an invalid number is not
+	// worth another attempt.
+	message := outbox.get(id)
+	if message.Status != entities.OutboxDead || message.Channel != entities.ChannelSMS {
+		t.Errorf("message is %s on %s, want dead on sms", message.Status, message.Channel)
+	}
+	if message.Message != "" {
+		t.Errorf("dead message still holds its text")
+	}
+	assertDispatches(t, delivery, dispatch{entities.ChannelSMS, entities.DeliveryFailed, ""})
+}
+
+func TestSMSRetry(t *testing.T) {
+	worker, outbox, delivery, providers := newTestWorker(t)
+	providers.twilio.FailNext(smstest.TwilioError{HTTPStatus: http.StatusServiceUnavailable, Code: 20500, Message: "Service unavailable"})
+	id := enqueue(t, outbox, entities.ChannelSMS)
+
+	before := time.Now()
+	drain(worker, outbox)
+	after := time.Now()
+
+	// The worker backs off for half to all of the one minute base backoff.
+	message := outbox.get(id)
+	if message.Status != entities.OutboxPending || message.Attempts != 1 || message.LastError == "" {
+		t.Fatalf("message is %s after %d attempts with error %q, want pending after 1 failure", message.Status, message.Attempts, message.LastError)
+	}
+	if message.NextAttemptAt.Before(before.Add(30*time.Second)) || !message.NextAttemptAt.Before(after.Add(time.Minute)) {
+		t.Errorf("retry scheduled %v after the attempt, want 30s to 1m", message.NextAttemptAt.Sub(before))
+	}
+	if message.Message != testCode {
+		t.Errorf("message waiting for a retry lost its text")
+	}
+	assertDispatches(t, delivery)
+
+	outbox.makeDue(id)
+	drain(worker, outbox)
+
+	sent := providers.twilio.Messages()
+	if len(sent) != 1 {
+		t.Fatalf("SMS got %d messages, want 1", len(sent))
+	}
+	message = outbox.get(id)
+	assertSent(t, message, entities.ChannelSMS, sent[0].SID)
+	if message.Attempts != 2 || message.LastError != "" {
+		t.Errorf("message has %d attempts and error %q, want 2 attempts and the error cleared", message.Attempts, message.LastError)
+	}
+	assertDispatches(t, delivery, dispatch{entities.ChannelSMS, entities.DeliverySent, "twilio"})
+}
+
+func TestSMSDeadLetter(t *testing.T) {
+	unavailable := smstest.TwilioError{HTTPStatus: http.StatusServiceUnavailable, Code: 20500, Message: "Service unavailable"}
+
+	tests := []struct {
+		name         string
+		failures     []smstest.TwilioError
+		wantAttempts int
+	}{
+		{
+			name:         "out of attempts",
+			failures:     []smstest.TwilioError{unavailable, unavailable, unavailable},
+			wantAttempts: 3,
+		},
+		{
+			name:         "invalid number",
+			failures:     []smstest.TwilioError{{HTTPStatus: http.StatusBadRequest, Code: 21211, Message: "Invalid 'To' Phone Number"}},
+			wantAttempts: 1,
+		},
+		{
+			name:         "permanent after retries",
+			failures:     []smstest.TwilioError{unavailable, {HTTPStatus: http.StatusBadRequest, Code: 21614, Message: "'To' number is not a valid mobile number"}},
+			wantAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker, outbox, delivery, providers := newTestWorker(t)
+			for _, failure := range tt.failures {
+				providers.twilio.FailNext(failure)
+			}
+			id := enqueue(t, outbox, entities.ChannelSMS)
+
+			// Retry each scheduled attempt straight away until the message
+			// settles; a fourth attempt would be sent.
+			for i := 0; i < 4 && outbox.get(id).Status == entities.OutboxPending; i++ {
+				outbox.makeDue(id)
+				drain(worker, outbox)
+			}
+
+			message := outbox.get(id)
+			if message.Status != entities.OutboxDead || message.Attempts != tt.wantAttempts {
+				t.Fatalf("message is %s after %d attempts, want dead after %d", message.Status, message.Attempts, tt.wantAttempts)
+			}
+			if message.Message != "" || message.LastError == "" {
+				t.Errorf("dead message holds text %q and error %q, want the text cleared and the error kept", message.Message, message.LastError)
+			}
+			if len(providers.twilio.Messages()) != 0 {
+				t.Errorf("SMS accepted %d messages, want none", len(providers.twilio.Messages()))
+			}
+			assertDispatches(t, delivery, dispatch{entities.ChannelSMS, entities.DeliveryFailed, ""})
+		})
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	worker := NewOutboxWorker(nil, nil, nil, OutboxWorkerConfig{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}, logrus.New())
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		// Jitter picks a delay in the upper half of the nominal one.
+		for i := 0; i < 100; i++ {
+			got := worker.backoff(tt.attempt)
+			if got < tt.delay/2 || got >= tt.delay {
+				t.Fatalf("backoff(%d) = %v, want [%v, %v)", tt.attempt, got, tt.delay/2, tt.delay)
+			}
+		}
+	}
+}
+
+func assertSent(t *testing.T, message entities.OutboxMessage, channel entities.Channel, providerMessageID string) {
+	t.Helper()
+	if message.Status != entities.OutboxSent || message.Channel != channel {
+		t.Errorf("message is %s on %s, want sent on %s", message.Status, message.Channel, channel)
+	}
+	if message.ProviderMessageID != providerMessageID {
+		t.Errorf("provider message ID = %q, want %q", message.ProviderMessageID, providerMessageID)
+	}
+	if message.Message != "" || message.FallbackMessage != "" {
+		t.Errorf("sent message still holds its code")
+	}
+}
+
+func assertDispatches(t *testing.T, delivery *fakeDeliveryService, want ...dispatch) {
+	t.Helper()
+	delivery.mu.Lock()
+	defer delivery.mu.Unlock()
+	if len(delivery.dispatches) != len(want) {
+		t.Fatalf("dispatches = %+v, want %+v", delivery.dispatches, want)
+	}
+	for i := range want {
+		if delivery.dispatches[i] != want[i] {
+			t.Errorf("dispatch %d = %+v, want %+v", i, delivery.dispatches[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedChannel = errors.New("unsupported delivery channel")
+
+// Channel is the medium an OTP is delivered over.
+type Channel string
+
+const (
+	ChannelSMS Channel = "sms"
+	// ChannelVoice reads the code out in a phone call.
+	ChannelVoice    Channel = "voice"
+	ChannelWhatsApp Channel = "whatsapp"
+	ChannelTelegram Channel = "telegram"
+	ChannelEmail    Channel = "email"
+)
+
+// ParseChannel returns the channel named by name. An empty name yields an
+// empty channel, meaning the caller did not choose one.
+func ParseChannel(name string) (Channel, error) {
+	switch channel := Channel(name); channel {
+	case "", ChannelSMS, ChannelVoice, ChannelWhatsApp, ChannelTelegram, ChannelEmail:
+		return channel, nil
+	default:
+		return "", ErrUnsupportedChannel
+	}
+}
+
+// Accepts reports whether c can deliver to recipients of type t. Email goes
+// to email addresses, every other channel to phone numbers.
+func (c Channel) Accepts(t RecipientType) bool {
+	if c == ChannelEmail {
+		return t == RecipientEmail
+	}
+	return t == RecipientPhone
+}
+
+// RequiresMobile reports whether c can only deliver to mobile numbers. Only
+// voice calls reach landlines; messenger apps fall back to SMS, so they need
+// a mobile number too.
+func (c Channel) RequiresMobile() bool {
+	switch c {
+	case ChannelSMS, ChannelWhatsApp, ChannelTelegram:
+		return true
+	default:
+		return false
+	}
+}
+
+// Fallback returns the channel to deliver over when delivery on c fails, or
+// an empty channel when there is none. Messenger apps fall back to SMS, as
+// not every number is registered with them.
+func (c Channel) Fallback() Channel {
+	switch c {
+	case ChannelWhatsApp, ChannelTelegram:
+		return ChannelSMS
+	default:
+		return ""
+	}
+}
+
+// ChannelRoute selects a channel for phone numbers starting with Prefix,
+// given without the leading "+".
+type ChannelRoute struct {
+	Prefix  string
+	Channel Channel
+}
+
+// ChannelRoutes picks the channel of OTPs to phone numbers that did not ask
+// for one by the country of the phone number.
+type ChannelRoutes struct {
+	Routes  []ChannelRoute
+	Default Channel
+}
+
+// Resolve returns the channel of the longest route prefix matching
+// phoneNumber, or the default channel, which itself defaults to SMS.
+func (r ChannelRoutes) Resolve(phoneNumber string) Channel {
+	number := strings.TrimPrefix(phoneNumber, "+")
+
+	channel, matched := r.Default, -1
+	for _, route := range r.Routes {
+		if len(route.Prefix) > matched && strings.HasPrefix(number, route.Prefix) {
+			channel, matched = route.Channel, len(route.Prefix)
+		}
+	}
+
+	if channel == "" {
+		return ChannelSMS
+	}
+	return channel
+}
+
+// EscalationPolicy re-sends an OTP over further channels when delivery on
+// its channel is not confirmed in time. The n-th step in the chain of the
+// OTP's channel is due n Timeouts after the OTP was queued, or as soon as the
+// step before it is reported failed, and is skipped if the OTP was delivered,
+// used or expired by then. Escalation reuses the OTP, so it does not count
+// against rate limits.
+type EscalationPolicy struct {
+	Chains  map[Channel][]Channel
+	Timeout time.Duration
+}
+
+// Steps returns the channels an OTP escalates to, in order. Channels that
+// cannot reach the recipient or repeat an earlier channel are left out, as
+// are those needing a mobile number unless mobile reports the recipient's
+// number to be one.
+func (p EscalationPolicy) Steps(otp *OTP, mobile bool) []Channel {
+	if p.Timeout <= 0 {
+		return nil
+	}
+
+	seen := map[Channel]bool{otp.Channel: true}
+	var steps []Channel
+	for _, channel := range p.Chains[otp.Channel] {
+		if seen[channel] || !channel.Accepts(otp.RecipientType) || (channel.RequiresMobile() && !mobile) {
+			continue
+		}
+		seen[channel] = true
+		steps = append(steps, channel)
+	}
+	return steps
+}
@@ -0,0 +1,41 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEscalationSteps(t *testing.T) {
+	policy := EscalationPolicy{
+		Chains: map[Channel][]Channel{
+			ChannelWhatsApp: {ChannelSMS, ChannelWhatsApp, ChannelVoice},
+			ChannelVoice:    {ChannelSMS, ChannelVoice, ChannelEmail},
+		},
+		Timeout: 30 * time.Second,
+	}
+
+	tests := []struct {
+		name    string
+		channel Channel
+		mobile  bool
+		want    []Channel
+	}{
+		{name: "mobile", channel: ChannelWhatsApp, mobile: true, want: []Channel{ChannelSMS, ChannelVoice}},
+		{name: "landline skips channels needing a mobile", channel: ChannelVoice, mobile: false, want: nil},
+		{name: "mobile after a call", channel: ChannelVoice, mobile: true, want: []Channel{ChannelSMS}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otp := &OTP{RecipientType: RecipientPhone, Channel: tt.channel}
+			if got := policy.Steps(otp, tt.mobile); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Steps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (EscalationPolicy{Chains: policy.Chains}).Steps(&OTP{RecipientType: RecipientPhone, Channel: ChannelVoice}, true); got != nil {
+		t.Errorf("Steps without a timeout = %v, want none", got)
+	}
+}
@@ -0,0 +1,17 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ClientID identifies the client behind an API key. Keys are hashed, so the
+// ID can be stored and shown without giving the key away. Requests without
+// a key have an empty ID.
+func ClientID(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
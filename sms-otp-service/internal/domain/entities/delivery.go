@@ -0,0 +1,123 @@
+package entities
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrInvalidDeliveryReceipt = errors.New("invalid delivery receipt")
+	ErrUnauthorizedReceipt    = errors.New("delivery receipt failed authentication")
+)
+
+// DeliveryStatus tracks an OTP message from the outbox to the handset.
+type DeliveryStatus string
+
+const (
+	DeliveryQueued    DeliveryStatus = "queued"
+	DeliverySent      DeliveryStatus = "sent"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+	DeliveryExpired   DeliveryStatus = "expired"
+)
+
+var deliveryStatusRank = map[DeliveryStatus]int{
+	DeliveryQueued:    0,
+	DeliverySent:      1,
+	DeliveryDelivered: 2,
+	DeliveryFailed:    2,
+	DeliveryExpired:   2,
+}
+
+func (s DeliveryStatus) IsValid() bool {
+	_, ok := deliveryStatusRank[s]
+	return ok
+}
+
+// IsFinal reports whether no further transitions are possible.
+func (s DeliveryStatus) IsFinal() bool {
+	return deliveryStatusRank[s] == 2
+}
+
+// CanTransitionTo reports whether a message in status s may move to next.
+// Statuses only move forward, so a receipt that arrives late or out of order
+// never overwrites a more specific one.
+func (s DeliveryStatus) CanTransitionTo(next DeliveryStatus) bool {
+	return next.IsValid() && deliveryStatusRank[next] > deliveryStatusRank[s]
+}
+
+// Predecessors returns every status that may transition to s.
+func (s DeliveryStatus) Predecessors() []DeliveryStatus {
+	var statuses []DeliveryStatus
+	for status := range deliveryStatusRank {
+		if status.CanTransitionTo(s) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// DeliveryReceipt is a status report for a message, as sent back by a
+// provider.
+type DeliveryReceipt struct {
+	Provider    string
+	MessageID   string
+	Status      DeliveryStatus
+	ErrorCode   string
+	Description string
+	OccurredAt  time.Time
+}
+
+// DeliveryEvent is one entry in the delivery history of an OTP message. Events
+// outlive the OTP itself so support can still see what happened to a code
+// after it has been cleaned up. Receipts that arrive before the message they
+// refer to is known are stored without an OTP and attached later.
+type DeliveryEvent struct {
+	ID                uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	OTPID             *uuid.UUID     `json:"otp_id,omitempty" gorm:"type:uuid;index"`
+	Recipient         string         `json:"recipient,omitempty" gorm:"type:varchar(255);index"`
+	Status            DeliveryStatus `json:"status" gorm:"type:varchar(20);not null"`
+	Provider          string         `json:"provider,omitempty" gorm:"type:varchar(50)"`
+	ProviderMessageID string         `json:"provider_message_id,omitempty" gorm:"type:varchar(100)"`
+	ErrorCode         string         `json:"error_code,omitempty" gorm:"type:varchar(50)"`
+	Description       string         `json:"description,omitempty" gorm:"type:text"`
+	OccurredAt        time.Time      `json:"occurred_at" gorm:"not null"`
+	CreatedAt         time.Time      `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (DeliveryEvent) TableName() string {
+	return "otp_delivery_events"
+}
+
+func (e *DeliveryEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// NewDeliveryEvent records a receipt against otp, which may be nil when the
+// message it refers to is not known yet.
+func NewDeliveryEvent(receipt *DeliveryReceipt, otp *OTP) *DeliveryEvent {
+	occurredAt := receipt.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+
+	event := &DeliveryEvent{
+		ID:                uuid.New(),
+		Status:            receipt.Status,
+		Provider:          receipt.Provider,
+		ProviderMessageID: receipt.MessageID,
+		ErrorCode:         receipt.ErrorCode,
+		Description:       receipt.Description,
+		OccurredAt:        occurredAt,
+	}
+	if otp != nil {
+		event.OTPID = &otp.ID
+		event.Recipient = otp.Recipient
+	}
+	return event
+}
@@ -0,0 +1,154 @@
+package entities
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSendBlocked       = errors.New("send blocked as likely fraud")
+	ErrChallengeRequired = errors.New("a challenge must be passed before sending")
+)
+
+// FraudDecision is the outcome of screening a send for SMS pumping.
+type FraudDecision string
+
+const (
+	FraudAllow FraudDecision = "allow"
+	// FraudChallenge lets the send through only once the client has passed
+	// a challenge such as a CAPTCHA.
+	FraudChallenge FraudDecision = "challenge"
+	FraudBlock     FraudDecision = "block"
+)
+
+// SendOrigin describes where a send request came from.
+type SendOrigin struct {
+	ClientIP string
+	// ChallengeToken is the response to a challenge the client was given,
+	// if any.
+	ChallengeToken string
+}
+
+// FraudPolicy sets the limits sends are scored against. Volume limits count
+// sends within Window; a zero limit is not checked.
+type FraudPolicy struct {
+	Enabled      bool
+	Window       time.Duration
+	IPLimit      int
+	PrefixLength int
+	PrefixLimit  int
+	CountryLimit int
+	// CountryLimits overrides CountryLimit for some regions.
+	CountryLimits map[string]int
+
+	// Sends made within ConversionWindow, except for the last
+	// ConversionGrace, are checked for the share that was verified once
+	// there are at least ConversionMinSends of them.
+	ConversionWindow   time.Duration
+	ConversionGrace    time.Duration
+	ConversionMinSends int
+	MinConversion      float64
+
+	ChallengeScore int
+	BlockScore     int
+}
+
+// Prefix returns the destination prefix an E.164 phone number is counted
+// under: its first PrefixLength digits, country code included.
+func (p FraudPolicy) Prefix(phoneNumber string) string {
+	digits := strings.TrimPrefix(phoneNumber, "+")
+	if len(digits) > p.PrefixLength {
+		digits = digits[:p.PrefixLength]
+	}
+	return digits
+}
+
+// CountryLimitFor returns the volume limit of region.
+func (p FraudPolicy) CountryLimitFor(region string) int {
+	if limit, ok := p.CountryLimits[region]; ok {
+		return limit
+	}
+	return p.CountryLimit
+}
+
+// Decide maps a score to a decision.
+func (p FraudPolicy) Decide(score int) FraudDecision {
+	switch {
+	case score >= p.BlockScore:
+		return FraudBlock
+	case score >= p.ChallengeScore:
+		return FraudChallenge
+	default:
+		return FraudAllow
+	}
+}
+
+// SendAttempt records a screened send to a phone number, with the score it
+// got and the reasons behind it. Allowed attempts are linked to the OTP they
+// issued and marked verified once it is, which gives the conversion ratio.
+type SendAttempt struct {
+	ID         uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	OTPID      *uuid.UUID    `json:"otp_id,omitempty" gorm:"type:uuid;index"`
+	Recipient  string        `json:"recipient" gorm:"type:varchar(255);not null"`
+	Prefix     string        `json:"prefix" gorm:"type:varchar(15);not null;index:idx_send_attempts_prefix"`
+	Region     string        `json:"region" gorm:"type:varchar(2);not null;index:idx_send_attempts_region"`
+	ClientIP   string        `json:"client_ip" gorm:"type:varchar(45);not null;index:idx_send_attempts_client_ip"`
+	Score      int           `json:"score" gorm:"not null;default:0"`
+	Decision   FraudDecision `json:"decision" gorm:"type:varchar(10);not null"`
+	Reasons    string        `json:"reasons" gorm:"type:text"`
+	VerifiedAt *time.Time    `json:"verified_at,omitempty"`
+	CreatedAt  time.Time     `json:"created_at" gorm:"autoCreateTime;index:idx_send_attempts_prefix;index:idx_send_attempts_region;index:idx_send_attempts_client_ip;index"`
+}
+
+func (SendAttempt) TableName() string {
+	return "send_attempts"
+}
+
+// NewSendAttempt starts the record of a send to an E.164 phone number of
+// region.
+func NewSendAttempt(phoneNumber, prefix, region string, origin SendOrigin) *SendAttempt {
+	return &SendAttempt{
+		ID:        uuid.New(),
+		Recipient: phoneNumber,
+		Prefix:    prefix,
+		Region:    region,
+		ClientIP:  origin.ClientIP,
+		Decision:  FraudAllow,
+		CreatedAt: time.Now(),
+	}
+}
+
+// AddRisk raises the score of the attempt by points for reason.
+func (a *SendAttempt) AddRisk(points int, reason string) {
+	a.Score += points
+	a.addReason(reason)
+}
+
+func (a *SendAttempt) addReason(reason string) {
+	if a.Reasons != "" {
+		a.Reasons += "; "
+	}
+	a.Reasons += reason
+}
+
+// Decide settles the decision, noting why when it differs from the score.
+func (a *SendAttempt) Decide(decision FraudDecision, reason string) {
+	a.Decision = decision
+	if reason != "" {
+		a.addReason(reason)
+	}
+}
+
+// Err returns the error a send with this decision fails with.
+func (a *SendAttempt) Err() error {
+	switch a.Decision {
+	case FraudBlock:
+		return ErrSendBlocked
+	case FraudChallenge:
+		return ErrChallengeRequired
+	default:
+		return nil
+	}
+}
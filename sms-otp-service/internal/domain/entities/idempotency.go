@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was used with a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is in progress")
+)
+
+// IdempotencyRecord holds the response to the first request a client sent
+// with an idempotency key, so retries get the same response instead of
+// repeating the request. A record without a status code is still in flight.
+type IdempotencyRecord struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ClientID     string    `json:"client_id" gorm:"type:varchar(100);not null;uniqueIndex:idx_idempotency_client_key"`
+	Key          string    `json:"key" gorm:"column:idempotency_key;type:varchar(255);not null;uniqueIndex:idx_idempotency_client_key"`
+	RequestHash  string    `json:"-" gorm:"type:varchar(64);not null"`
+	StatusCode   int       `json:"status_code" gorm:"default:0"`
+	ResponseBody []byte    `json:"-" gorm:"type:bytea"`
+	LockedUntil  time.Time `json:"locked_until" gorm:"not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+// NewIdempotencyRecord reserves key for a request. The reservation is
+// abandoned after lockFor if no response is stored, and the stored response
+// is kept for ttl.
+func NewIdempotencyRecord(clientID, key, requestHash string, lockFor, ttl time.Duration) *IdempotencyRecord {
+	now := time.Now()
+	return &IdempotencyRecord{
+		ID:          uuid.New(),
+		ClientID:    clientID,
+		Key:         key,
+		RequestHash: requestHash,
+		LockedUntil: now.Add(lockFor),
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	}
+}
+
+func (r *IdempotencyRecord) IsComplete() bool {
+	return r.StatusCode != 0
+}
@@ -0,0 +1,9 @@
+package entities
+
+// SendResult describes how an outgoing message was handed off for delivery.
+type SendResult struct {
+	Provider string
+	// MessageID is the provider's identifier for the message, used to match
+	// delivery receipts. Empty when the provider does not return one.
+	MessageID string
+}
@@ -0,0 +1,173 @@
+package entities
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNumberBlocked       = errors.New("phone number is blocked")
+	ErrNumberRuleNotFound  = errors.New("number rule not found")
+	ErrInvalidNumberRule   = errors.New("invalid number rule")
+	ErrDuplicateNumberRule = errors.New("a rule for this number, prefix or country already exists")
+)
+
+// NumberRuleAction is what happens to the phone numbers a rule matches.
+type NumberRuleAction string
+
+const (
+	NumberRuleBlock NumberRuleAction = "block"
+	NumberRuleAllow NumberRuleAction = "allow"
+)
+
+// NumberRuleMatch is how a rule's value is compared with a phone number.
+type NumberRuleMatch string
+
+const (
+	// NumberRuleMatchNumber matches one number, given in E.164 form.
+	NumberRuleMatchNumber NumberRuleMatch = "number"
+	// NumberRuleMatchPrefix matches numbers starting with the digits of the
+	// value, country code included, such as "4490" for UK premium rate.
+	NumberRuleMatchPrefix NumberRuleMatch = "prefix"
+	// NumberRuleMatchCountry matches numbers of an ISO 3166-1 alpha-2
+	// region, such as "AZ".
+	NumberRuleMatchCountry NumberRuleMatch = "country"
+)
+
+// NumberRule blocks or allows OTPs to the phone numbers it matches. A rule
+// without a ClientID is global and applies to every request; once there are
+// global allow rules, numbers that none of them matches are blocked for
+// everyone. A rule with a ClientID applies on top of the global rules to the
+// requests of that client, so it can only narrow them: its allow rules
+// cannot unblock a number the global rules block.
+type NumberRule struct {
+	ID uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	// ClientID is the ClientID of the API key the rule applies to.
+	ClientID  string           `json:"client_id" gorm:"type:varchar(32);not null;default:'';uniqueIndex:idx_number_rules_client_rule"`
+	Action    NumberRuleAction `json:"action" gorm:"type:varchar(10);not null"`
+	Match     NumberRuleMatch  `json:"match" gorm:"column:match_type;type:varchar(10);not null;uniqueIndex:idx_number_rules_client_rule"`
+	Value     string           `json:"value" gorm:"type:varchar(20);not null;uniqueIndex:idx_number_rules_client_rule"`
+	Note      string           `json:"note" gorm:"type:varchar(255)"`
+	CreatedAt time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (NumberRule) TableName() string {
+	return "number_rules"
+}
+
+// NewNumberRule returns a rule of clientID with its value in canonical form.
+// Numbers and prefixes may be written with "+" and spaces; countries in
+// either case.
+func NewNumberRule(clientID string, action NumberRuleAction, match NumberRuleMatch, value, note string) (*NumberRule, error) {
+	rule := &NumberRule{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+	}
+	if err := rule.Set(clientID, action, match, value, note); err != nil {
+		return nil, err
+	}
+	rule.UpdatedAt = rule.CreatedAt
+	return rule, nil
+}
+
+// Set checks and replaces the fields of the rule.
+func (r *NumberRule) Set(clientID string, action NumberRuleAction, match NumberRuleMatch, value, note string) error {
+	value, ok := canonicalRuleValue(match, value)
+	if !ok || (action != NumberRuleBlock && action != NumberRuleAllow) || len(note) > 255 {
+		return ErrInvalidNumberRule
+	}
+
+	r.ClientID = clientID
+	r.Action = action
+	r.Match = match
+	r.Value = value
+	r.Note = note
+	return nil
+}
+
+func canonicalRuleValue(match NumberRuleMatch, value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	switch match {
+	case NumberRuleMatchNumber, NumberRuleMatchPrefix:
+		digits := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '-' {
+				return -1
+			}
+			return r
+		}, strings.TrimPrefix(value, "+"))
+		if digits == "" || len(digits) > 15 || strings.Trim(digits, "0123456789") != "" {
+			return "", false
+		}
+		if match == NumberRuleMatchNumber {
+			return "+" + digits, len(digits) >= 7
+		}
+		return digits, true
+	case NumberRuleMatchCountry:
+		region := strings.ToUpper(value)
+		if len(region) != 2 || strings.Trim(region, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return "", false
+		}
+		return region, true
+	default:
+		return "", false
+	}
+}
+
+// Matches reports whether the rule applies to an E.164 phone number of
+// region.
+func (r *NumberRule) Matches(phoneNumber, region string) bool {
+	switch r.Match {
+	case NumberRuleMatchNumber:
+		return r.Value == phoneNumber
+	case NumberRuleMatchPrefix:
+		return strings.HasPrefix(strings.TrimPrefix(phoneNumber, "+"), r.Value)
+	case NumberRuleMatchCountry:
+		return r.Value == region
+	default:
+		return false
+	}
+}
+
+// specificity orders rules from the broadest to the narrowest: countries,
+// then prefixes by length, then single numbers.
+func (r *NumberRule) specificity() int {
+	switch r.Match {
+	case NumberRuleMatchNumber:
+		return 100
+	case NumberRuleMatchPrefix:
+		return 1 + len(r.Value)
+	default:
+		return 0
+	}
+}
+
+// CheckNumberRules decides whether an OTP may be sent to an E.164 phone
+// number of region. The most specific matching rule wins, and block wins
+// over allow between rules equally specific, so a single test number can be
+// allowed inside a blocked range. A number no rule matches is blocked only
+// when allowlist is set, meaning the client has allow rules.
+func CheckNumberRules(rules []*NumberRule, phoneNumber, region string, allowlist bool) error {
+	var decisive *NumberRule
+	for _, rule := range rules {
+		if !rule.Matches(phoneNumber, region) {
+			continue
+		}
+		if decisive == nil ||
+			rule.specificity() > decisive.specificity() ||
+			(rule.specificity() == decisive.specificity() && rule.Action == NumberRuleBlock) {
+			decisive = rule
+		}
+	}
+
+	switch {
+	case decisive != nil && decisive.Action == NumberRuleBlock:
+		return ErrNumberBlocked
+	case decisive == nil && allowlist:
+		return ErrNumberBlocked
+	default:
+		return nil
+	}
+}
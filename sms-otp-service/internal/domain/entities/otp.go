@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"crypto/subtle"
 	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -12,8 +13,13 @@ var (
 	ErrOTPAlreadyUsed     = errors.New("otp has already been used")
 	ErrMaxAttemptsReached = errors.New("maximum verification attempts reached")
 	ErrInvalidOTPCode     = errors.New("invalid otp code")
+	ErrInvalidCodeFormat  = errors.New("otp code has an invalid format")
 	ErrInvalidPhoneNumber = errors.New("invalid phone number")
 	ErrOTPNotFound        = errors.New("otp not found")
+	ErrOTPCanceled        = errors.New("otp has been canceled")
+	// ErrNotMobileNumber means a phone number such as a landline or a
+	// premium-rate number was given for a channel that needs a mobile.
+	ErrNotMobileNumber = errors.New("phone number is not a mobile number")
 )
 
 type OTPPurpose string
@@ -24,18 +30,51 @@ const (
 	PurposeReset        OTPPurpose = "reset"
 )
 
+// VerificationStatus is the state of an OTP as seen through the
+// verification session API.
+type VerificationStatus string
+
+const (
+	VerificationPending  VerificationStatus = "pending"
+	VerificationApproved VerificationStatus = "approved"
+	VerificationCanceled VerificationStatus = "canceled"
+	VerificationExpired  VerificationStatus = "expired"
+	// VerificationFailed means every attempt was used up.
+	VerificationFailed VerificationStatus = "failed"
+)
+
+// CodeHasher derives the value stored in place of an OTP code. It must be
+// keyed so that the small code space cannot be brute-forced from a copy of
+// the database; salt makes equal codes hash differently across OTPs.
+type CodeHasher interface {
+	Hash(salt, code string) string
+}
+
+// OTP is a one-time password issued to a phone number or email address. Only
+// a keyed hash of the code is stored; Code holds the plaintext on a freshly
+// generated OTP so it can be sent to the user, and is never persisted.
 type OTP struct {
-	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	PhoneNumber string     `json:"phone_number" gorm:"type:varchar(20);not null;index"`
-	Code        string     `json:"code" gorm:"type:varchar(6);not null;index"`
-	Purpose     OTPPurpose `json:"purpose" gorm:"type:varchar(50);not null;default:'verification'"`
-	IsVerified  bool       `json:"is_verified" gorm:"default:false"`
-	Attempts    int        `json:"attempts" gorm:"default:0"`
-	MaxAttempts int        `json:"max_attempts" gorm:"default:3"`
-	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null;index"`
-	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
-	VerifiedAt  *time.Time `json:"verified_at,omitempty"`
+	ID                uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	RecipientType     RecipientType  `json:"recipient_type" gorm:"type:varchar(10);not null;default:'phone'"`
+	Recipient         string         `json:"recipient" gorm:"type:varchar(255);not null;index"`
+	Code              string         `json:"-" gorm:"-"`
+	CodeHash          string         `json:"-" gorm:"type:varchar(128);not null;default:''"`
+	Purpose           OTPPurpose     `json:"purpose" gorm:"type:varchar(50);not null;default:'verification'"`
+	Channel           Channel        `json:"channel" gorm:"type:varchar(20);not null;default:'sms'"`
+	IsVerified        bool           `json:"is_verified" gorm:"default:false"`
+	Attempts          int            `json:"attempts" gorm:"default:0"`
+	MaxAttempts       int            `json:"max_attempts" gorm:"default:3"`
+	DeliveryStatus    DeliveryStatus `json:"delivery_status" gorm:"type:varchar(20);not null;default:'queued'"`
+	DeliveryProvider  string         `json:"delivery_provider,omitempty" gorm:"type:varchar(50)"`
+	ProviderMessageID string         `json:"provider_message_id,omitempty" gorm:"type:varchar(100)"`
+	ExpiresAt         time.Time      `json:"expires_at" gorm:"not null;index"`
+	CreatedAt         time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	VerifiedAt        *time.Time     `json:"verified_at,omitempty"`
+	CanceledAt        *time.Time     `json:"canceled_at,omitempty"`
+	// NextResendAt is when the recipient may be sent another OTP for the
+	// purpose, as of issuing this one. It is not persisted.
+	NextResendAt time.Time `json:"-" gorm:"-"`
 }
 
 func (OTP) TableName() string {
@@ -49,22 +88,32 @@ func (o *OTP) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-func NewOTP(phoneNumber, code string, purpose OTPPurpose, validityMinutes int) *OTP {
+func NewOTP(recipient Recipient, code string, channel Channel, policy OTPPolicy, hasher CodeHasher) *OTP {
 	now := time.Now()
+	id := uuid.New()
 	return &OTP{
-		ID:          uuid.New(),
-		PhoneNumber: phoneNumber,
-		Code:        code,
-		Purpose:     purpose,
-		IsVerified:  false,
-		Attempts:    0,
-		MaxAttempts: 3,
-		ExpiresAt:   now.Add(time.Duration(validityMinutes) * time.Minute),
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		ID:             id,
+		RecipientType:  recipient.Type,
+		Recipient:      recipient.Address,
+		Code:           code,
+		CodeHash:       hasher.Hash(id.String(), code),
+		Purpose:        policy.Purpose,
+		Channel:        channel,
+		IsVerified:     false,
+		Attempts:       0,
+		MaxAttempts:    policy.MaxAttempts,
+		DeliveryStatus: DeliveryQueued,
+		ExpiresAt:      now.Add(policy.TTL),
+		CreatedAt:      now,
+		UpdatedAt:      now,
 	}
 }
 
+// To returns who the OTP was issued to.
+func (o *OTP) To() Recipient {
+	return Recipient{Type: o.RecipientType, Address: o.Recipient}
+}
+
 func (o *OTP) IsExpired() bool {
 	return time.Now().After(o.ExpiresAt)
 }
@@ -73,10 +122,13 @@ func (o *OTP) CanAttempt() bool {
 	return o.Attempts < o.MaxAttempts
 }
 
-func (o *OTP) Verify(code string) error {
+func (o *OTP) Verify(code string, hasher CodeHasher) error {
 	if o.IsVerified {
 		return ErrOTPAlreadyUsed
 	}
+	if o.CanceledAt != nil {
+		return ErrOTPCanceled
+	}
 	if o.IsExpired() {
 		return ErrOTPExpired
 	}
@@ -87,7 +139,8 @@ func (o *OTP) Verify(code string) error {
 	o.Attempts++
 	o.UpdatedAt = time.Now()
 
-	if o.Code != code {
+	candidate := hasher.Hash(o.ID.String(), code)
+	if subtle.ConstantTimeCompare([]byte(candidate), []byte(o.CodeHash)) != 1 {
 		return ErrInvalidOTPCode
 	}
 
@@ -97,6 +150,34 @@ func (o *OTP) Verify(code string) error {
 	return nil
 }
 
+// Cancel stops the OTP from being verified. Canceling twice is a no-op.
+func (o *OTP) Cancel() error {
+	if o.IsVerified {
+		return ErrOTPAlreadyUsed
+	}
+	if o.CanceledAt == nil {
+		now := time.Now()
+		o.CanceledAt = &now
+		o.UpdatedAt = now
+	}
+	return nil
+}
+
+func (o *OTP) Status() VerificationStatus {
+	switch {
+	case o.IsVerified:
+		return VerificationApproved
+	case o.CanceledAt != nil:
+		return VerificationCanceled
+	case o.IsExpired():
+		return VerificationExpired
+	case !o.CanAttempt():
+		return VerificationFailed
+	default:
+		return VerificationPending
+	}
+}
+
 func (o *OTP) IsValid() bool {
-	return !o.IsExpired() && !o.IsVerified && o.CanAttempt()
+	return o.Status() == VerificationPending
 }
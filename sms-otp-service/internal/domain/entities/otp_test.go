@@ -0,0 +1,74 @@
+package entities
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testHasher stands in for the keyed HMAC; only equality matters here.
+type testHasher struct{}
+
+func (testHasher) Hash(salt, code string) string {
+	return salt + ":" + code
+}
+
+func TestOTPVerify(t *testing.T) {
+	policy := OTPPolicy{Purpose: PurposeLogin, MaxAttempts: 3, TTL: 5 * time.Minute}
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name         string
+		setup        func(*OTP)
+		code         string
+		want         error
+		wantAttempts int
+		wantVerified bool
+	}{
+		{name: "right code", code: "123456", wantAttempts: 1, wantVerified: true},
+		{name: "wrong code", code: "654321", want: ErrInvalidOTPCode, wantAttempts: 1},
+		{name: "last attempt", setup: func(o *OTP) { o.Attempts = 2 }, code: "123456", wantAttempts: 3, wantVerified: true},
+		{name: "out of attempts", setup: func(o *OTP) { o.Attempts = 3 }, code: "123456", want: ErrMaxAttemptsReached, wantAttempts: 3},
+		{name: "expired", setup: func(o *OTP) { o.ExpiresAt = past }, code: "123456", want: ErrOTPExpired},
+		{name: "canceled", setup: func(o *OTP) { o.CanceledAt = &past }, code: "123456", want: ErrOTPCanceled},
+		{name: "already used", setup: func(o *OTP) { o.IsVerified = true }, code: "123456", want: ErrOTPAlreadyUsed, wantVerified: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otp := NewOTP(Recipient{Type: RecipientPhone, Address: "+14155550100"}, "123456", ChannelSMS, policy, testHasher{})
+			if tt.setup != nil {
+				tt.setup(otp)
+			}
+
+			err := otp.Verify(tt.code, testHasher{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+			if otp.Attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", otp.Attempts, tt.wantAttempts)
+			}
+			if otp.IsVerified != tt.wantVerified {
+				t.Errorf("verified = %v, want %v", otp.IsVerified, tt.wantVerified)
+			}
+			if tt.want == nil && otp.VerifiedAt == nil {
+				t.Error("VerifiedAt not set")
+			}
+		})
+	}
+}
+
+func TestNewOTPHashesCode(t *testing.T) {
+	otp := NewOTP(Recipient{Type: RecipientPhone, Address: "+14155550100"}, "123456", ChannelSMS, OTPPolicy{MaxAttempts: 3, TTL: time.Minute}, testHasher{})
+
+	// The OTP's ID salts the hash, so equal codes hash differently.
+	if want := otp.ID.String() + ":123456"; otp.CodeHash != want {
+		t.Errorf("CodeHash = %q, want %q", otp.CodeHash, want)
+	}
+
+	// A hash made under another key never verifies.
+	otp.CodeHash = "other key"
+	if err := otp.Verify("123456", testHasher{}); !errors.Is(err, ErrInvalidOTPCode) {
+		t.Errorf("Verify against a foreign hash = %v, want %v", err, ErrInvalidOTPCode)
+	}
+}
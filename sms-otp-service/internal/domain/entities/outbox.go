@@ -0,0 +1,193 @@
+package entities
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending    OutboxStatus = "pending"
+	OutboxProcessing OutboxStatus = "processing"
+	OutboxSent       OutboxStatus = "sent"
+	OutboxDead       OutboxStatus = "dead"
+	// OutboxCanceled marks an escalation step that was not needed.
+	OutboxCanceled OutboxStatus = "canceled"
+)
+
+// OutboxMessage is an OTP message waiting to be delivered over its channel:
+// an SMS text, a voice call script, an email, or the bare code for messenger
+// apps that render it themselves. It is written in the same transaction as
+// its OTP so a crash can never leave an OTP without a message, and delivered
+// asynchronously by the outbox workers. The message holds the plaintext code,
+// so it is stored encrypted and cleared as soon as the message is sent or
+// dead-lettered.
+//
+// A message with a fallback channel switches to it, with the fallback
+// message, when delivery on its channel fails. Messages with an escalation
+// step re-send the OTP over another channel and are held until the step is
+// due; step 0 is the OTP's first message.
+type OutboxMessage struct {
+	ID                uuid.UUID    `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	OTPID             uuid.UUID    `json:"otp_id" gorm:"type:uuid;not null;index"`
+	Recipient         string       `json:"recipient" gorm:"type:varchar(255);not null"`
+	Channel           Channel      `json:"channel" gorm:"type:varchar(20);not null;default:'sms'"`
+	Message           string       `json:"-" gorm:"type:text;not null"`
+	FallbackChannel   Channel      `json:"fallback_channel,omitempty" gorm:"type:varchar(20)"`
+	FallbackMessage   string       `json:"-" gorm:"type:text"`
+	EscalationStep    int          `json:"escalation_step" gorm:"not null;default:0"`
+	Status            OutboxStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	Attempts          int          `json:"attempts" gorm:"default:0"`
+	MaxAttempts       int          `json:"max_attempts" gorm:"default:5"`
+	NextAttemptAt     time.Time    `json:"next_attempt_at" gorm:"not null"`
+	LockedUntil       *time.Time   `json:"locked_until,omitempty"`
+	LastError         string       `json:"last_error,omitempty" gorm:"type:text"`
+	Provider          string       `json:"provider,omitempty" gorm:"type:varchar(50)"`
+	ProviderMessageID string       `json:"provider_message_id,omitempty" gorm:"type:varchar(100)"`
+	ExpiresAt         time.Time    `json:"expires_at" gorm:"not null"`
+	CreatedAt         time.Time    `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time    `json:"updated_at" gorm:"autoUpdateTime"`
+	SentAt            *time.Time   `json:"sent_at,omitempty"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "sms_outbox"
+}
+
+func (m *OutboxMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+func NewOutboxMessage(otp *OTP, message string, maxAttempts int) *OutboxMessage {
+	now := time.Now()
+	return &OutboxMessage{
+		ID:            uuid.New(),
+		OTPID:         otp.ID,
+		Recipient:     otp.Recipient,
+		Channel:       otp.Channel,
+		Message:       message,
+		Status:        OutboxPending,
+		MaxAttempts:   maxAttempts,
+		NextAttemptAt: now,
+		ExpiresAt:     otp.ExpiresAt,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// NewEscalationMessage queues message for delivery over channel as the
+// given escalation step of otp, due at dueAt.
+func NewEscalationMessage(otp *OTP, channel Channel, message string, step int, dueAt time.Time, maxAttempts int) *OutboxMessage {
+	m := NewOutboxMessage(otp, message, maxAttempts)
+	m.Channel = channel
+	m.EscalationStep = step
+	m.NextAttemptAt = dueAt
+	return m
+}
+
+// IsEscalation reports whether the message re-sends its OTP over another
+// channel.
+func (m *OutboxMessage) IsEscalation() bool {
+	return m.EscalationStep > 0
+}
+
+// IsExpired reports whether the OTP in the message is no longer usable, in
+// which case sending it would only confuse the user.
+func (m *OutboxMessage) IsExpired() bool {
+	return time.Now().After(m.ExpiresAt)
+}
+
+func (m *OutboxMessage) MarkSent(result *SendResult) {
+	now := time.Now()
+	m.Attempts++
+	m.Status = OutboxSent
+	m.Provider = result.Provider
+	m.ProviderMessageID = result.MessageID
+	m.SentAt = &now
+	m.LockedUntil = nil
+	m.LastError = ""
+	m.Message = ""
+	m.FallbackMessage = ""
+}
+
+// MarkFailed records a failed attempt and schedules a retry at retryAt, or
+// dead-letters the message when the failure is permanent or it is out of
+// attempts. It reports whether the message was dead-lettered.
+func (m *OutboxMessage) MarkFailed(reason string, retryAt time.Time, permanent bool) bool {
+	m.Attempts++
+	m.LastError = reason
+	m.LockedUntil = nil
+
+	if permanent || m.Attempts >= m.MaxAttempts {
+		m.Status = OutboxDead
+		m.Message = ""
+		m.FallbackMessage = ""
+		return true
+	}
+
+	m.Status = OutboxPending
+	m.NextAttemptAt = retryAt
+	return false
+}
+
+// MarkDead dead-letters the message without attempting delivery.
+func (m *OutboxMessage) MarkDead(reason string) {
+	m.Status = OutboxDead
+	m.LastError = reason
+	m.LockedUntil = nil
+	m.Message = ""
+	m.FallbackMessage = ""
+}
+
+// Cancel drops an escalation step that is no longer needed.
+func (m *OutboxMessage) Cancel(reason string) {
+	m.Status = OutboxCanceled
+	m.LastError = reason
+	m.LockedUntil = nil
+	m.Message = ""
+	m.FallbackMessage = ""
+}
+
+// FallBack abandons the current channel after a failure and queues the
+// fallback message for immediate delivery on the fallback channel, with a
+// fresh set of attempts. It reports false when the message has no fallback.
+func (m *OutboxMessage) FallBack(reason string) bool {
+	if m.FallbackChannel == "" {
+		return false
+	}
+
+	m.LastError = fmt.Sprintf("%s delivery failed, falling back to %s: %s", m.Channel, m.FallbackChannel, reason)
+	m.Channel = m.FallbackChannel
+	m.Message = m.FallbackMessage
+	m.FallbackChannel = ""
+	m.FallbackMessage = ""
+	m.Status = OutboxPending
+	m.Attempts = 0
+	m.NextAttemptAt = time.Now()
+	m.LockedUntil = nil
+	m.Provider = ""
+	m.ProviderMessageID = ""
+	return true
+}
+
+// DeliveryEvent records the outcome of the latest delivery attempt in the
+// OTP's delivery history.
+func (m *OutboxMessage) DeliveryEvent(status DeliveryStatus) *DeliveryEvent {
+	otpID := m.OTPID
+	return &DeliveryEvent{
+		ID:                uuid.New(),
+		OTPID:             &otpID,
+		Recipient:         m.Recipient,
+		Status:            status,
+		Provider:          m.Provider,
+		ProviderMessageID: m.ProviderMessageID,
+		Description:       m.LastError,
+		OccurredAt:        time.Now(),
+	}
+}
@@ -0,0 +1,63 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrUnknownPurpose = errors.New("unknown otp purpose")
+
+const DigitAlphabet = "0123456789"
+
+// OTPPolicy holds the rules an OTP purpose is issued and verified under.
+type OTPPolicy struct {
+	Purpose     OTPPurpose
+	CodeLength  int
+	Alphabet    string
+	TTL         time.Duration
+	MaxAttempts int
+	// ResendBackoff sets the growing waits between the OTPs sent to one
+	// recipient for the purpose.
+	ResendBackoff Backoff
+	// SMSTemplate is a text/template rendered with Code, Minutes and
+	// Purpose.
+	SMSTemplate string
+	// EmailSubject is the subject of OTP emails, whose body is the rendered
+	// SMSTemplate.
+	EmailSubject string
+}
+
+// NormalizeCode adapts user input to the alphabet: codes drawn from an
+// upper-case alphabet are accepted in any case.
+func (p OTPPolicy) NormalizeCode(code string) string {
+	code = strings.TrimSpace(code)
+	if strings.ToUpper(p.Alphabet) == p.Alphabet {
+		return strings.ToUpper(code)
+	}
+	return code
+}
+
+// IsWellFormed reports whether code could have been issued under the policy.
+func (p OTPPolicy) IsWellFormed(code string) bool {
+	if len(code) != p.CodeLength {
+		return false
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(p.Alphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// PolicyRegistry holds the policy of every purpose the service accepts.
+type PolicyRegistry map[OTPPurpose]OTPPolicy
+
+func (r PolicyRegistry) Lookup(purpose OTPPurpose) (OTPPolicy, error) {
+	policy, ok := r[purpose]
+	if !ok {
+		return OTPPolicy{}, ErrUnknownPurpose
+	}
+	return policy, nil
+}
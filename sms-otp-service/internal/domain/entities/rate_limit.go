@@ -0,0 +1,133 @@
+package entities
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RateLimitAlgorithm is how a rate limit counts requests.
+type RateLimitAlgorithm string
+
+const (
+	// RateLimitTokenBucket allows bursts of up to Limit requests, refilled
+	// evenly over Window.
+	RateLimitTokenBucket RateLimitAlgorithm = "token_bucket"
+	// RateLimitSlidingWindow allows Limit requests in any Window, estimated
+	// from the counts of the current and previous fixed windows.
+	RateLimitSlidingWindow RateLimitAlgorithm = "sliding_window"
+)
+
+// RateLimitScope is what requests are counted by.
+type RateLimitScope string
+
+const (
+	RateLimitByIP     RateLimitScope = "ip"
+	RateLimitByAPIKey RateLimitScope = "api_key"
+	RateLimitByPhone  RateLimitScope = "phone"
+	// RateLimitByPrefix counts requests by the leading digits of the
+	// destination phone number.
+	RateLimitByPrefix RateLimitScope = "prefix"
+	RateLimitGlobal   RateLimitScope = "global"
+)
+
+// RateLimit allows Limit requests per Window.
+type RateLimit struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+}
+
+// Policy describes the limit in the RateLimit-Policy header format, such as
+// "20;w=60".
+func (l RateLimit) Policy() string {
+	return fmt.Sprintf("%d;w=%d", l.Limit, int(l.Window.Seconds()))
+}
+
+// RateLimitResult is the outcome of counting a request against a limit.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the full limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long until a denied request would be allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitKey builds the key a request is counted under, such as
+// "ip:203.0.113.7".
+func RateLimitKey(scope RateLimitScope, parts ...string) string {
+	return strings.Join(append([]string{string(scope)}, parts...), ":")
+}
+
+// Backoff is a series of cooldowns that grow with each step, such as the
+// waits between resends. The last delay repeats, and the series starts over
+// once Reset passes after a cooldown ends without another one starting.
+type Backoff struct {
+	Delays []time.Duration
+	Reset  time.Duration
+}
+
+// Delay returns the cooldown of step, counted from 0.
+func (b Backoff) Delay(step int) time.Duration {
+	if len(b.Delays) == 0 {
+		return 0
+	}
+	return b.Delays[min(step, len(b.Delays)-1)]
+}
+
+// BackoffResult is the state of a backoff.
+type BackoffResult struct {
+	// Step is how many cooldowns the series has had, the running one
+	// included.
+	Step int
+	// RetryAfter is how long until the running cooldown ends.
+	RetryAfter time.Duration
+}
+
+// Lockout locks a key for Duration(locks) once Threshold failures are
+// recorded within Window of the first. Each lock doubles the next, up to
+// MaxDuration, until Reset passes after a lock ends without another one. A
+// zero Threshold disables lockouts.
+type Lockout struct {
+	Threshold    int
+	Window       time.Duration
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	Reset        time.Duration
+}
+
+// Enabled reports whether failures lead to locks.
+func (l Lockout) Enabled() bool {
+	return l.Threshold > 0
+}
+
+// Duration returns how long the lock after locks earlier ones lasts.
+func (l Lockout) Duration(locks int) time.Duration {
+	d := l.BaseDuration
+	for range locks {
+		if d >= l.MaxDuration/2 {
+			return l.MaxDuration
+		}
+		d *= 2
+	}
+	return min(d, l.MaxDuration)
+}
+
+// LockoutState is the state of a lockout key.
+type LockoutState struct {
+	// Failures is how many failures count toward the next lock.
+	Failures int
+	// Locks is how many locks the key has had since it was last reset,
+	// the running one included.
+	Locks int
+	// RetryAfter is how long until the running lock ends, or zero when the
+	// key is not locked.
+	RetryAfter time.Duration
+}
+
+// Locked reports whether a lock is running.
+func (s LockoutState) Locked() bool {
+	return s.RetryAfter > 0
+}
@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrInvalidRecipient means a request named no recipient, or both a
+	// phone number and an email address.
+	ErrInvalidRecipient = errors.New("exactly one of phone number and email is required")
+)
+
+type RecipientType string
+
+const (
+	RecipientPhone RecipientType = "phone"
+	RecipientEmail RecipientType = "email"
+)
+
+// Recipient is who an OTP is sent to: a phone number in E.164 form or an
+// email address. Both are stored in one column, as the two can never look
+// alike.
+type Recipient struct {
+	Type    RecipientType
+	Address string
+}
+
+func PhoneRecipient(phoneNumber string) Recipient {
+	return Recipient{Type: RecipientPhone, Address: phoneNumber}
+}
+
+func EmailRecipient(email string) Recipient {
+	return Recipient{Type: RecipientEmail, Address: email}
+}
+
+// NewRecipient returns the recipient of a request that names exactly one of
+// a phone number and an email address.
+func NewRecipient(phoneNumber, email string) (Recipient, error) {
+	phoneNumber = strings.TrimSpace(phoneNumber)
+	email = strings.TrimSpace(email)
+
+	switch {
+	case phoneNumber != "" && email == "":
+		return PhoneRecipient(phoneNumber), nil
+	case email != "" && phoneNumber == "":
+		return EmailRecipient(email), nil
+	default:
+		return Recipient{}, ErrInvalidRecipient
+	}
+}
+
+// PhoneNumber returns the address of a phone recipient, or an empty string.
+func (r Recipient) PhoneNumber() string {
+	if r.Type == RecipientPhone {
+		return r.Address
+	}
+	return ""
+}
+
+// Email returns the address of an email recipient, or an empty string.
+func (r Recipient) Email() string {
+	if r.Type == RecipientEmail {
+		return r.Address
+	}
+	return ""
+}
+
+func (r Recipient) String() string {
+	return r.Address
+}
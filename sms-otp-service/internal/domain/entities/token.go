@@ -0,0 +1,54 @@
+package entities
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid verification token")
+	ErrTokenExpired = errors.New("verification token has expired")
+)
+
+// VerificationClaims is the proof of possession of a phone number or email
+// address carried by a verification token.
+type VerificationClaims struct {
+	TokenID    string
+	Issuer     string
+	Audience   string
+	Recipient  Recipient
+	Purpose    OTPPurpose
+	OTPID      string
+	VerifiedAt time.Time
+	IssuedAt   time.Time
+	ExpiresAt  time.Time
+}
+
+// NewVerificationClaims returns the claims for a verified OTP. The token
+// signer fills in the token ID, issuer, audience and lifetime.
+func NewVerificationClaims(otp *OTP) *VerificationClaims {
+	verifiedAt := time.Now()
+	if otp.VerifiedAt != nil {
+		verifiedAt = *otp.VerifiedAt
+	}
+
+	return &VerificationClaims{
+		Recipient:  otp.To(),
+		Purpose:    otp.Purpose,
+		OTPID:      otp.ID.String(),
+		VerifiedAt: verifiedAt,
+	}
+}
+
+// JSONWebKey is a public verification key as published in a JWKS document
+// (RFC 7517).
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+}
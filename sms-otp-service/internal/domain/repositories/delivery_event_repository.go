@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"sms-otp-service/internal/domain/entities"
+	"time"
+)
+
+type DeliveryEventRepository interface {
+	Create(ctx context.Context, event *entities.DeliveryEvent) error
+
+	FindByOTPID(ctx context.Context, otpID string) ([]*entities.DeliveryEvent, error)
+
+	// AttachUnmatched assigns events received for provider and messageID
+	// before the message was known to the given OTP, and returns them in the
+	// order they occurred.
+	AttachUnmatched(ctx context.Context, provider, messageID string, otpID uuid.UUID, recipient string) ([]*entities.DeliveryEvent, error)
+
+	DeleteBefore(ctx context.Context, cutoff time.Time) error
+}
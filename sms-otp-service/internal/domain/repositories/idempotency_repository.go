@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+	"sms-otp-service/internal/domain/entities"
+)
+
+type IdempotencyRepository interface {
+	// Reserve stores record unless its client already holds a live record
+	// for the key, in which case that record is returned instead. Expired
+	// records and abandoned reservations are replaced.
+	Reserve(ctx context.Context, record *entities.IdempotencyRecord) (*entities.IdempotencyRecord, error)
+
+	// FindCompleted returns the live record of a completed request made by
+	// the client with the key, or nil if there is none.
+	FindCompleted(ctx context.Context, clientID, key string) (*entities.IdempotencyRecord, error)
+
+	// Complete stores the response to the reserved request.
+	Complete(ctx context.Context, id string, statusCode int, body []byte) error
+
+	// Release drops a reservation so the request can be retried.
+	Release(ctx context.Context, id string) error
+
+	DeleteExpired(ctx context.Context) error
+}
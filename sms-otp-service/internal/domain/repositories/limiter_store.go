@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"sms-otp-service/internal/domain/entities"
+)
+
+// LimiterStore keeps the counters of rate limits, the cooldowns between
+// requests and the lockouts after repeated failures. Its operations are
+// atomic, so replicas sharing a store can make the same decisions.
+type LimiterStore interface {
+	// Take counts one request under key against limit and reports whether
+	// it is allowed. Denied requests are not counted.
+	Take(ctx context.Context, key string, limit entities.RateLimit) (entities.RateLimitResult, error)
+	// Release gives back a request counted by Take, such as one whose work
+	// was rolled back.
+	Release(ctx context.Context, key string, limit entities.RateLimit) error
+	// Backoff starts the next cooldown of backoff under key, replacing a
+	// running one.
+	Backoff(ctx context.Context, key string, backoff entities.Backoff) (entities.BackoffResult, error)
+	// Cooldown returns the backoff under key without changing it. RetryAfter
+	// is zero when no cooldown is running.
+	Cooldown(ctx context.Context, key string) (entities.BackoffResult, error)
+	// RecordFailure counts a failure under key against lockout and returns
+	// the new state, locked if the failure crossed the threshold. Failures
+	// while a lock is running are not counted.
+	RecordFailure(ctx context.Context, key string, lockout entities.Lockout) (entities.LockoutState, error)
+	// Lockout returns the state of the lockout under key.
+	Lockout(ctx context.Context, key string) (entities.LockoutState, error)
+	// ClearLockout drops the lockout under key, lifting a running lock and
+	// forgetting earlier failures and locks.
+	ClearLockout(ctx context.Context, key string) error
+}
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+	"sms-otp-service/internal/domain/entities"
+)
+
+type NumberRuleRepository interface {
+	// Create stores rule, or returns ErrDuplicateNumberRule if a rule of
+	// the same client with the same match and value exists.
+	Create(ctx context.Context, rule *entities.NumberRule) error
+
+	FindByID(ctx context.Context, id string) (*entities.NumberRule, error)
+
+	// FindAll returns the rules of clientID with action, oldest first. An
+	// empty clientID or action matches every client or action.
+	FindAll(ctx context.Context, clientID string, action entities.NumberRuleAction) ([]*entities.NumberRule, error)
+
+	// FindMatching returns the rules applying to the requests of clientID,
+	// its own and those of every client, that match an E.164 phone number
+	// of region.
+	FindMatching(ctx context.Context, clientID, phoneNumber, region string) ([]*entities.NumberRule, error)
+
+	// HasAllowRules reports whether clientID has allow rules of its own,
+	// which turns its rules into an allowlist. An empty clientID stands for
+	// the global rules.
+	HasAllowRules(ctx context.Context, clientID string) (bool, error)
+
+	// Update stores the changed rule, or returns ErrDuplicateNumberRule if
+	// another rule of the client has the same match and value.
+	Update(ctx context.Context, rule *entities.NumberRule) error
+
+	Delete(ctx context.Context, id string) error
+}
@@ -7,17 +7,46 @@ import (
 
 type OTPRepository interface {
 	Create(ctx context.Context, otp *entities.OTP) error
-	FindByPhoneAndPurpose(ctx context.Context, phoneNumber string, purpose entities.OTPPurpose) (*entities.OTP, error)
+	FindByRecipientAndPurpose(ctx context.Context, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error)
+
+	// FindByRecipientAndPurposeForUpdate is FindByRecipientAndPurpose that
+	// also locks the row until the surrounding transaction ends, so
+	// concurrent verifications of one OTP run one after another.
+	FindByRecipientAndPurposeForUpdate(ctx context.Context, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error)
 
 	FindByID(ctx context.Context, id string) (*entities.OTP, error)
 
+	// FindByIDForUpdate is FindByID that also locks the row until the
+	// surrounding transaction ends.
+	FindByIDForUpdate(ctx context.Context, id string) (*entities.OTP, error)
+
 	Update(ctx context.Context, otp *entities.OTP) error
 
+	// UpdateVerification stores the attempt count, verification and
+	// cancellation state of the OTP, leaving its other columns untouched.
+	UpdateVerification(ctx context.Context, otp *entities.OTP) error
+
+	// InvalidateActive uses up the remaining attempts of every usable OTP for
+	// the recipient and purpose.
+	InvalidateActive(ctx context.Context, recipient string, purpose entities.OTPPurpose) error
+
+	// UpdateDeliveryStatus moves the OTP to status if that is a forward
+	// transition from its current status, and reports whether it did.
+	// Empty provider and messageID leave the stored values unchanged.
+	UpdateDeliveryStatus(ctx context.Context, id string, status entities.DeliveryStatus, provider, messageID string) (bool, error)
+
+	// UpdateChannel records the channel the OTP is now delivered over.
+	UpdateChannel(ctx context.Context, id string, channel entities.Channel) error
+
+	// RestartDelivery moves the OTP to channel and back to queued, clearing
+	// its provider, when delivery escalates to another channel.
+	RestartDelivery(ctx context.Context, id string, channel entities.Channel) error
+
+	FindByProviderMessageID(ctx context.Context, provider, messageID string) (*entities.OTP, error)
+
 	Delete(ctx context.Context, id string) error
 
 	DeleteExpired(ctx context.Context) error
 
-	FindActiveByPhone(ctx context.Context, phoneNumber string) ([]*entities.OTP, error)
-
-	CountRecentOTPs(ctx context.Context, phoneNumber string, minutes int) (int64, error)
+	FindActiveByRecipient(ctx context.Context, recipient string) ([]*entities.OTP, error)
 }
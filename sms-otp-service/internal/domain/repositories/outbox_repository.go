@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"context"
+	"sms-otp-service/internal/domain/entities"
+	"time"
+)
+
+type OutboxRepository interface {
+	Create(ctx context.Context, message *entities.OutboxMessage) error
+
+	// ClaimDue locks up to limit messages that are due for delivery, or whose
+	// previous claim expired, and leases them to the caller until lease
+	// elapses.
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entities.OutboxMessage, error)
+
+	Update(ctx context.Context, message *entities.OutboxMessage) error
+
+	// FindByOTPID returns the messages of an OTP, the first message before
+	// its escalation steps.
+	FindByOTPID(ctx context.Context, otpID string) ([]*entities.OutboxMessage, error)
+
+	// ReleaseEscalation makes the next pending escalation step of an OTP due
+	// immediately.
+	ReleaseEscalation(ctx context.Context, otpID string) error
+
+	DeleteProcessedBefore(ctx context.Context, cutoff time.Time) error
+}
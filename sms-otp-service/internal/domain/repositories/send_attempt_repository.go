@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+	"sms-otp-service/internal/domain/entities"
+	"time"
+)
+
+// SendConversion counts the allowed sends in a period and how many of them
+// were verified.
+type SendConversion struct {
+	Sent     int64
+	Verified int64
+}
+
+type SendAttemptRepository interface {
+	Create(ctx context.Context, attempt *entities.SendAttempt) error
+
+	// CountByClientIP, CountByPrefix and CountByRegion count the attempts
+	// made since the given time, whatever their decision.
+	CountByClientIP(ctx context.Context, clientIP string, since time.Time) (int64, error)
+	CountByPrefix(ctx context.Context, prefix string, since time.Time) (int64, error)
+	CountByRegion(ctx context.Context, region string, since time.Time) (int64, error)
+
+	// ConversionByPrefix and ConversionByRegion count the allowed attempts
+	// made between from and to, and how many of them were verified.
+	ConversionByPrefix(ctx context.Context, prefix string, from, to time.Time) (SendConversion, error)
+	ConversionByRegion(ctx context.Context, region string, from, to time.Time) (SendConversion, error)
+
+	// MarkVerified records that the OTP issued by an attempt was verified.
+	MarkVerified(ctx context.Context, otpID string, verifiedAt time.Time) error
+
+	DeleteBefore(ctx context.Context, before time.Time) error
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import "context"
+
+// TransactionManager runs fn in a database transaction. Repositories called
+// with the context passed to fn take part in that transaction.
+type TransactionManager interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// AfterCommit runs fn once the transaction in ctx commits, so effects
+	// outside the database only happen for work that was kept. Outside a
+	// transaction fn runs at once.
+	AfterCommit(ctx context.Context, fn func(ctx context.Context))
+	// AfterRollback runs fn if the transaction in ctx rolls back, to undo
+	// effects outside the database. Outside a transaction fn never runs.
+	AfterRollback(ctx context.Context, fn func(ctx context.Context))
+}
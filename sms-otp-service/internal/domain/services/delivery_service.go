@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+)
+
+type DeliveryService interface {
+	// RecordDispatch stores the outcome of handing an outbox message to a
+	// provider.
+	RecordDispatch(ctx context.Context, message *entities.OutboxMessage, status entities.DeliveryStatus) error
+	// RecordFallback stores that the outbox message failed on its channel
+	// and moved to its fallback channel.
+	RecordFallback(ctx context.Context, message *entities.OutboxMessage) error
+	// Escalate prepares the OTP of a due escalation step for delivery over
+	// the step's channel. It reports false when the step is no longer needed
+	// because the OTP was delivered, used, canceled or expired.
+	Escalate(ctx context.Context, message *entities.OutboxMessage) (bool, error)
+	// ProcessReceipt applies a delivery receipt reported by a provider. It
+	// reports whether the receipt matched a known message.
+	ProcessReceipt(ctx context.Context, receipt *entities.DeliveryReceipt) (bool, error)
+	// GetHistory returns the OTP, if it still exists, and its delivery events.
+	GetHistory(ctx context.Context, otpID string) (*entities.OTP, []*entities.DeliveryEvent, error)
+}
+
+type deliveryService struct {
+	otpRepo    repositories.OTPRepository
+	eventRepo  repositories.DeliveryEventRepository
+	outboxRepo repositories.OutboxRepository
+	txManager  repositories.TransactionManager
+}
+
+func NewDeliveryService(
+	otpRepo repositories.OTPRepository,
+	eventRepo repositories.DeliveryEventRepository,
+	outboxRepo repositories.OutboxRepository,
+	txManager repositories.TransactionManager,
+) DeliveryService {
+	return &deliveryService{
+		otpRepo:    otpRepo,
+		eventRepo:  eventRepo,
+		outboxRepo: outboxRepo,
+		txManager:  txManager,
+	}
+}
+
+func (s *deliveryService) RecordDispatch(ctx context.Context, message *entities.OutboxMessage, status entities.DeliveryStatus) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		otpID := message.OTPID.String()
+		if _, err := s.otpRepo.UpdateDeliveryStatus(ctx, otpID, status, message.Provider, message.ProviderMessageID); err != nil {
+			return err
+		}
+		if err := s.eventRepo.Create(ctx, message.DeliveryEvent(status)); err != nil {
+			return err
+		}
+
+		// A failed channel need not wait out the escalation timeout.
+		if status == entities.DeliveryFailed {
+			return s.outboxRepo.ReleaseEscalation(ctx, otpID)
+		}
+
+		if status != entities.DeliverySent || message.ProviderMessageID == "" {
+			return nil
+		}
+
+		// A fast provider can report delivery before the send result was
+		// stored. Those receipts were kept unmatched and are applied now.
+		early, err := s.eventRepo.AttachUnmatched(ctx, message.Provider, message.ProviderMessageID, message.OTPID, message.Recipient)
+		if err != nil {
+			return err
+		}
+		for _, event := range early {
+			if _, err := s.otpRepo.UpdateDeliveryStatus(ctx, otpID, event.Status, "", ""); err != nil {
+				return err
+			}
+			if event.Status == entities.DeliveryFailed {
+				if err := s.outboxRepo.ReleaseEscalation(ctx, otpID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *deliveryService) RecordFallback(ctx context.Context, message *entities.OutboxMessage) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.otpRepo.UpdateChannel(ctx, message.OTPID.String(), message.Channel); err != nil {
+			return err
+		}
+		// The event records the failed attempt; the OTP itself stays queued
+		// for delivery on the fallback channel.
+		return s.eventRepo.Create(ctx, message.DeliveryEvent(entities.DeliveryFailed))
+	})
+}
+
+func (s *deliveryService) Escalate(ctx context.Context, message *entities.OutboxMessage) (bool, error) {
+	escalate := false
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		otp, err := s.otpRepo.FindByIDForUpdate(ctx, message.OTPID.String())
+		if errors.Is(err, entities.ErrOTPNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !otp.IsValid() {
+			return nil
+		}
+
+		// A retried step has already moved the OTP to its channel.
+		if message.Attempts > 0 {
+			escalate = true
+			return nil
+		}
+		if otp.DeliveryStatus == entities.DeliveryDelivered || otp.Channel == message.Channel {
+			return nil
+		}
+
+		escalate = true
+		if err := s.otpRepo.RestartDelivery(ctx, otp.ID.String(), message.Channel); err != nil {
+			return err
+		}
+		event := message.DeliveryEvent(entities.DeliveryQueued)
+		event.Description = fmt.Sprintf("%s delivery not confirmed, escalating to %s", otp.Channel, message.Channel)
+		return s.eventRepo.Create(ctx, event)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return escalate, nil
+}
+
+func (s *deliveryService) ProcessReceipt(ctx context.Context, receipt *entities.DeliveryReceipt) (bool, error) {
+	if receipt.MessageID == "" || !receipt.Status.IsValid() {
+		return false, entities.ErrInvalidDeliveryReceipt
+	}
+
+	matched := false
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		otp, err := s.otpRepo.FindByProviderMessageID(ctx, receipt.Provider, receipt.MessageID)
+		if err != nil && !errors.Is(err, entities.ErrOTPNotFound) {
+			return err
+		}
+
+		if otp != nil {
+			matched = true
+			if _, err := s.otpRepo.UpdateDeliveryStatus(ctx, otp.ID.String(), receipt.Status, "", ""); err != nil {
+				return err
+			}
+			if receipt.Status == entities.DeliveryFailed {
+				if err := s.outboxRepo.ReleaseEscalation(ctx, otp.ID.String()); err != nil {
+					return err
+				}
+			}
+		}
+
+		return s.eventRepo.Create(ctx, entities.NewDeliveryEvent(receipt, otp))
+	})
+
+	return matched, err
+}
+
+func (s *deliveryService) GetHistory(ctx context.Context, otpID string) (*entities.OTP, []*entities.DeliveryEvent, error) {
+	otp, err := s.otpRepo.FindByID(ctx, otpID)
+	if err != nil && !errors.Is(err, entities.ErrOTPNotFound) {
+		return nil, nil, err
+	}
+
+	events, err := s.eventRepo.FindByOTPID(ctx, otpID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if otp == nil && len(events) == 0 {
+		return nil, nil, entities.ErrOTPNotFound
+	}
+	return otp, events, nil
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"time"
+)
+
+// Risk points added by each signal. A signal more than twice past its
+// threshold adds them twice.
+const (
+	ipVolumeRisk          = 40
+	prefixVolumeRisk      = 40
+	countryVolumeRisk     = 30
+	prefixConversionRisk  = 50
+	countryConversionRisk = 30
+)
+
+// ChallengeVerifier checks the responses to challenges, such as CAPTCHAs,
+// that clients are given before a risky send goes through.
+type ChallengeVerifier interface {
+	// Verify reports whether token is a valid response to a challenge solved
+	// by the client at clientIP.
+	Verify(ctx context.Context, token, clientIP string) (bool, error)
+}
+
+// FraudService screens sends to phone numbers for SMS pumping, where
+// attackers drive OTPs to number ranges they earn termination fees on. Sends
+// are scored on their volume per client IP, destination prefix and country,
+// and on how few sends to the prefix and country get verified.
+type FraudService interface {
+	// Screen scores a send to an E.164 phone number. A blocked or
+	// challenged attempt is stored right away and returned along with
+	// ErrSendBlocked or ErrChallengeRequired. An allowed attempt is returned
+	// for RecordSend to store once its OTP is issued. With screening
+	// disabled Screen returns nil.
+	Screen(ctx context.Context, phoneNumber string, origin entities.SendOrigin) (*entities.SendAttempt, error)
+	// RecordSend stores an allowed attempt with the OTP it issued. It must
+	// run in the transaction that creates the OTP.
+	RecordSend(ctx context.Context, attempt *entities.SendAttempt, otp *entities.OTP) error
+	// RecordVerification marks the attempt that issued a verified OTP.
+	RecordVerification(ctx context.Context, otp *entities.OTP) error
+}
+
+type fraudService struct {
+	attemptRepo    repositories.SendAttemptRepository
+	phoneValidator PhoneValidator
+	challenges     ChallengeVerifier
+	policy         entities.FraudPolicy
+}
+
+// NewFraudService returns a fraud service scoring sends against policy.
+// Without a challenge verifier, sends that would be challenged are blocked.
+func NewFraudService(
+	attemptRepo repositories.SendAttemptRepository,
+	phoneValidator PhoneValidator,
+	challenges ChallengeVerifier,
+	policy entities.FraudPolicy,
+) FraudService {
+	return &fraudService{
+		attemptRepo:    attemptRepo,
+		phoneValidator: phoneValidator,
+		challenges:     challenges,
+		policy:         policy,
+	}
+}
+
+func (s *fraudService) Screen(ctx context.Context, phoneNumber string, origin entities.SendOrigin) (*entities.SendAttempt, error) {
+	if !s.policy.Enabled {
+		return nil, nil
+	}
+
+	region, err := s.phoneValidator.Region(phoneNumber)
+	if err != nil {
+		return nil, entities.ErrInvalidPhoneNumber
+	}
+
+	attempt := entities.NewSendAttempt(phoneNumber, s.policy.Prefix(phoneNumber), region, origin)
+	if err := s.score(ctx, attempt); err != nil {
+		return nil, err
+	}
+
+	decision, reason := s.policy.Decide(attempt.Score), ""
+	if decision == entities.FraudChallenge {
+		decision, reason, err = s.challenge(ctx, origin)
+		if err != nil {
+			return nil, err
+		}
+	}
+	attempt.Decide(decision, reason)
+
+	if decision != entities.FraudAllow {
+		if err := s.attemptRepo.Create(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+	return attempt, attempt.Err()
+}
+
+// score adds the risk of every signal past its threshold to attempt.
+func (s *fraudService) score(ctx context.Context, attempt *entities.SendAttempt) error {
+	now := time.Now()
+	since := now.Add(-s.policy.Window)
+
+	if attempt.ClientIP != "" && s.policy.IPLimit > 0 {
+		count, err := s.attemptRepo.CountByClientIP(ctx, attempt.ClientIP, since)
+		if err != nil {
+			return err
+		}
+		s.checkVolume(attempt, ipVolumeRisk, "client IP "+attempt.ClientIP, count, s.policy.IPLimit)
+	}
+
+	if s.policy.PrefixLimit > 0 {
+		count, err := s.attemptRepo.CountByPrefix(ctx, attempt.Prefix, since)
+		if err != nil {
+			return err
+		}
+		s.checkVolume(attempt, prefixVolumeRisk, "prefix +"+attempt.Prefix, count, s.policy.PrefixLimit)
+	}
+
+	if limit := s.policy.CountryLimitFor(attempt.Region); limit > 0 {
+		count, err := s.attemptRepo.CountByRegion(ctx, attempt.Region, since)
+		if err != nil {
+			return err
+		}
+		s.checkVolume(attempt, countryVolumeRisk, "country "+attempt.Region, count, limit)
+	}
+
+	if s.policy.ConversionMinSends > 0 {
+		from, to := now.Add(-s.policy.ConversionWindow), now.Add(-s.policy.ConversionGrace)
+
+		conversion, err := s.attemptRepo.ConversionByPrefix(ctx, attempt.Prefix, from, to)
+		if err != nil {
+			return err
+		}
+		s.checkConversion(attempt, prefixConversionRisk, "prefix +"+attempt.Prefix, conversion)
+
+		conversion, err = s.attemptRepo.ConversionByRegion(ctx, attempt.Region, from, to)
+		if err != nil {
+			return err
+		}
+		s.checkConversion(attempt, countryConversionRisk, "country "+attempt.Region, conversion)
+	}
+	return nil
+}
+
+// checkVolume adds risk when count earlier sends reach limit.
+func (s *fraudService) checkVolume(attempt *entities.SendAttempt, risk int, source string, count int64, limit int) {
+	if count < int64(limit) {
+		return
+	}
+	if count >= 2*int64(limit) {
+		risk *= 2
+	}
+	attempt.AddRisk(risk, fmt.Sprintf("%s made %d sends in the last %s, limit %d", source, count, s.policy.Window, limit))
+}
+
+// checkConversion adds risk when too few sends were verified.
+func (s *fraudService) checkConversion(attempt *entities.SendAttempt, risk int, source string, conversion repositories.SendConversion) {
+	if conversion.Sent < int64(s.policy.ConversionMinSends) {
+		return
+	}
+
+	ratio := float64(conversion.Verified) / float64(conversion.Sent)
+	if ratio >= s.policy.MinConversion {
+		return
+	}
+	if ratio < s.policy.MinConversion/2 {
+		risk *= 2
+	}
+	attempt.AddRisk(risk, fmt.Sprintf("%s had %d of %d sends verified (%.0f%%), minimum %.0f%%",
+		source, conversion.Verified, conversion.Sent, ratio*100, s.policy.MinConversion*100))
+}
+
+// challenge decides a send that scored in the challenge range, letting it
+// through if the client passed a challenge.
+func (s *fraudService) challenge(ctx context.Context, origin entities.SendOrigin) (entities.FraudDecision, string, error) {
+	switch {
+	case s.challenges == nil:
+		return entities.FraudBlock, "blocked since no challenge provider is configured", nil
+	case origin.ChallengeToken == "":
+		return entities.FraudChallenge, "", nil
+	}
+
+	ok, err := s.challenges.Verify(ctx, origin.ChallengeToken, origin.ClientIP)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to verify challenge token: %w", err)
+	}
+	if !ok {
+		return entities.FraudChallenge, "challenge token rejected", nil
+	}
+	return entities.FraudAllow, "challenge passed", nil
+}
+
+func (s *fraudService) RecordSend(ctx context.Context, attempt *entities.SendAttempt, otp *entities.OTP) error {
+	if attempt == nil {
+		return nil
+	}
+	attempt.OTPID = &otp.ID
+	return s.attemptRepo.Create(ctx, attempt)
+}
+
+func (s *fraudService) RecordVerification(ctx context.Context, otp *entities.OTP) error {
+	if !s.policy.Enabled || otp.VerifiedAt == nil {
+		return nil
+	}
+	return s.attemptRepo.MarkVerified(ctx, otp.ID.String(), *otp.VerifiedAt)
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testFraudPolicy = entities.FraudPolicy{
+	Enabled:            true,
+	Window:             time.Hour,
+	IPLimit:            10,
+	PrefixLength:       4,
+	PrefixLimit:        100,
+	CountryLimit:       1000,
+	ConversionWindow:   24 * time.Hour,
+	ConversionGrace:    10 * time.Minute,
+	ConversionMinSends: 50,
+	MinConversion:      0.4,
+	ChallengeScore:     40,
+	BlockScore:         80,
+}
+
+const testClientIP = "192.0.2.1"
+
+// fakeSendAttempts answers counts and conversions from its fields, records
+// the periods it was asked about and keeps the attempts stored.
+type fakeSendAttempts struct {
+	byIP, byPrefix, byRegion           int64
+	prefixConversion, regionConversion repositories.SendConversion
+
+	since, from, to time.Time
+	created         []*entities.SendAttempt
+	verified        []string
+}
+
+func (r *fakeSendAttempts) Create(ctx context.Context, attempt *entities.SendAttempt) error {
+	r.created = append(r.created, attempt)
+	return nil
+}
+
+func (r *fakeSendAttempts) CountByClientIP(ctx context.Context, clientIP string, since time.Time) (int64, error) {
+	r.since = since
+	return r.byIP, nil
+}
+
+func (r *fakeSendAttempts) CountByPrefix(ctx context.Context, prefix string, since time.Time) (int64, error) {
+	r.since = since
+	return r.byPrefix, nil
+}
+
+func (r *fakeSendAttempts) CountByRegion(ctx context.Context, region string, since time.Time) (int64, error) {
+	r.since = since
+	return r.byRegion, nil
+}
+
+func (r *fakeSendAttempts) ConversionByPrefix(ctx context.Context, prefix string, from, to time.Time) (repositories.SendConversion, error) {
+	r.from, r.to = from, to
+	return r.prefixConversion, nil
+}
+
+func (r *fakeSendAttempts) ConversionByRegion(ctx context.Context, region string, from, to time.Time) (repositories.SendConversion, error) {
+	r.from, r.to = from, to
+	return r.regionConversion, nil
+}
+
+func (r *fakeSendAttempts) MarkVerified(ctx context.Context, otpID string, verifiedAt time.Time) error {
+	r.verified = append(r.verified, otpID)
+	return nil
+}
+
+func (r *fakeSendAttempts) DeleteBefore(ctx context.Context, before time.Time) error {
+	return errors.New("not implemented")
+}
+
+// stubChallenges accepts the token "passed" and fails with err if set.
+type stubChallenges struct {
+	err      error
+	calls    int
+	clientIP string
+}
+
+func (c *stubChallenges) Verify(ctx context.Context, token, clientIP string) (bool, error) {
+	c.calls++
+	c.clientIP = clientIP
+	return token == "passed", c.err
+}
+
+func TestFraudScreenScoring(t *testing.T) {
+	tests := []struct {
+		name         string
+		attempts     fakeSendAttempts
+		policy       func(*entities.FraudPolicy)
+		wantScore    int
+		wantDecision entities.FraudDecision
+	}{
+		{name: "quiet", wantDecision: entities.FraudAllow},
+		{name: "IP below limit", attempts: fakeSendAttempts{byIP: 9}, wantDecision: entities.FraudAllow},
+		{name: "IP at limit", attempts: fakeSendAttempts{byIP: 10}, wantScore: 40, wantDecision: entities.FraudChallenge},
+		{name: "IP below twice the limit", attempts: fakeSendAttempts{byIP: 19}, wantScore: 40, wantDecision: entities.FraudChallenge},
+		{name: "IP at twice the limit", attempts: fakeSendAttempts{byIP: 20}, wantScore: 80, wantDecision: entities.FraudBlock},
+		{name: "prefix at limit", attempts: fakeSendAttempts{byPrefix: 100}, wantScore: 40, wantDecision: entities.FraudChallenge},
+		{name: "prefix at twice the limit", attempts: fakeSendAttempts{byPrefix: 200}, wantScore: 80, wantDecision: entities.FraudBlock},
+		{name: "country at limit", attempts: fakeSendAttempts{byRegion: 1000}, wantScore: 30, wantDecision: entities.FraudAllow},
+		{name: "country at twice the limit", attempts: fakeSendAttempts{byRegion: 2000}, wantScore: 60, wantDecision: entities.FraudChallenge},
+		{
+			name:         "country limit override",
+			attempts:     fakeSendAttempts{byRegion: 5},
+			policy:       func(p *entities.FraudPolicy) { p.CountryLimits = map[string]int{"US": 5} },
+			wantScore:    30,
+			wantDecision: entities.FraudAllow,
+		},
+		{
+			name:         "IP limit off",
+			attempts:     fakeSendAttempts{byIP: 1000},
+			policy:       func(p *entities.FraudPolicy) { p.IPLimit = 0 },
+			wantDecision: entities.FraudAllow,
+		},
+		{
+			name:         "signals add up",
+			attempts:     fakeSendAttempts{byIP: 10, byRegion: 1000},
+			wantScore:    70,
+			wantDecision: entities.FraudChallenge,
+		},
+		{
+			name:         "prefix conversion at minimum",
+			attempts:     fakeSendAttempts{prefixConversion: repositories.SendConversion{Sent: 50, Verified: 20}},
+			wantDecision: entities.FraudAllow,
+		},
+		{
+			name:         "prefix conversion low",
+			attempts:     fakeSendAttempts{prefixConversion: repositories.SendConversion{Sent: 50, Verified: 19}},
+			wantScore:    50,
+			wantDecision: entities.FraudChallenge,
+		},
+		{
+			name:         "prefix conversion below half the minimum",
+			attempts:     fakeSendAttempts{prefixConversion: repositories.SendConversion{Sent: 50, Verified: 9}},
+			wantScore:    100,
+			wantDecision: entities.FraudBlock,
+		},
+		{
+			name:         "too few sends to judge conversion",
+			attempts:     fakeSendAttempts{prefixConversion: repositories.SendConversion{Sent: 49}},
+			wantDecision: entities.FraudAllow,
+		},
+		{
+			name:         "country conversion low",
+			attempts:     fakeSendAttempts{regionConversion: repositories.SendConversion{Sent: 100, Verified: 30}},
+			wantScore:    30,
+			wantDecision: entities.FraudAllow,
+		},
+		{
+			name:         "country conversion below half the minimum",
+			attempts:     fakeSendAttempts{regionConversion: repositories.SendConversion{Sent: 100, Verified: 10}},
+			wantScore:    60,
+			wantDecision: entities.FraudChallenge,
+		},
+		{
+			name:         "conversion off",
+			attempts:     fakeSendAttempts{prefixConversion: repositories.SendConversion{Sent: 1000}},
+			policy:       func(p *entities.FraudPolicy) { p.ConversionMinSends = 0 },
+			wantDecision: entities.FraudAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testFraudPolicy
+			if tt.policy != nil {
+				tt.policy(&policy)
+			}
+			attempts := tt.attempts
+			service := NewFraudService(&attempts, fakePhoneValidator{}, &stubChallenges{}, policy)
+
+			attempt, err := service.Screen(context.Background(), testPhone, entities.SendOrigin{ClientIP: testClientIP})
+			if attempt == nil {
+				t.Fatalf("Screen = nil, %v", err)
+			}
+			if attempt.Score != tt.wantScore || attempt.Decision != tt.wantDecision {
+				t.Errorf("attempt scored %d and decided %s, want %d and %s (%s)",
+					attempt.Score, attempt.Decision, tt.wantScore, tt.wantDecision, attempt.Reasons)
+			}
+			if !errors.Is(err, attempt.Err()) || (err == nil) != (tt.wantDecision == entities.FraudAllow) {
+				t.Errorf("Screen error = %v with decision %s", err, attempt.Decision)
+			}
+			if (attempt.Reasons == "") != (tt.wantScore == 0) {
+				t.Errorf("reasons = %q with score %d", attempt.Reasons, attempt.Score)
+			}
+			if attempt.Recipient != testPhone || attempt.Prefix != "1415" || attempt.Region != "US" || attempt.ClientIP != testClientIP {
+				t.Errorf("attempt = %+v", attempt)
+			}
+
+			// Challenged and blocked attempts are stored right away, allowed
+			// ones once their OTP is issued.
+			if stored := len(attempts.created) == 1 && attempts.created[0] == attempt; stored == (tt.wantDecision == entities.FraudAllow) {
+				t.Errorf("%s attempt stored = %v", attempt.Decision, stored)
+			}
+		})
+	}
+}
+
+func TestFraudScreenWindows(t *testing.T) {
+	attempts := &fakeSendAttempts{}
+	service := NewFraudService(attempts, fakePhoneValidator{}, nil, testFraudPolicy)
+
+	before := time.Now()
+	if _, err := service.Screen(context.Background(), testPhone, entities.SendOrigin{ClientIP: testClientIP}); err != nil {
+		t.Fatalf("Screen: %v", err)
+	}
+	after := time.Now()
+
+	within := func(got time.Time, offset time.Duration) bool {
+		return !got.Before(before.Add(offset)) && !got.After(after.Add(offset))
+	}
+	if !within(attempts.since, -time.Hour) {
+		t.Errorf("volume counted since %v before now, want the 1h window", before.Sub(attempts.since))
+	}
+
+	// The most recent sends have not had time to be verified yet.
+	if !within(attempts.from, -24*time.Hour) || !within(attempts.to, -10*time.Minute) {
+		t.Errorf("conversion checked from %v to %v before now, want 24h to 10m",
+			before.Sub(attempts.from), before.Sub(attempts.to))
+	}
+}
+
+func TestFraudScreenChallenge(t *testing.T) {
+	errCaptcha := errors.New("captcha provider unavailable")
+
+	tests := []struct {
+		name         string
+		byIP         int64
+		challenges   *stubChallenges
+		token        string
+		wantDecision entities.FraudDecision
+		wantErr      error
+		wantReason   string
+		wantVerify   bool
+	}{
+		{
+			name:         "no challenge provider",
+			byIP:         10,
+			wantDecision: entities.FraudBlock,
+			wantErr:      entities.ErrSendBlocked,
+			wantReason:   "no challenge provider is configured",
+		},
+		{
+			name:         "no token",
+			byIP:         10,
+			challenges:   &stubChallenges{},
+			wantDecision: entities.FraudChallenge,
+			wantErr:      entities.ErrChallengeRequired,
+		},
+		{
+			name:         "token rejected",
+			byIP:         10,
+			challenges:   &stubChallenges{},
+			token:        "forged",
+			wantDecision: entities.FraudChallenge,
+			wantErr:      entities.ErrChallengeRequired,
+			wantReason:   "challenge token rejected",
+			wantVerify:   true,
+		},
+		{
+			name:         "challenge passed",
+			byIP:         10,
+			challenges:   &stubChallenges{},
+			token:        "passed",
+			wantDecision: entities.FraudAllow,
+			wantReason:   "challenge passed",
+			wantVerify:   true,
+		},
+		{
+			name:         "blocked despite a passed challenge",
+			byIP:         20,
+			challenges:   &stubChallenges{},
+			token:        "passed",
+			wantDecision: entities.FraudBlock,
+			wantErr:      entities.ErrSendBlocked,
+		},
+		{
+			name:         "allowed without a challenge",
+			challenges:   &stubChallenges{},
+			token:        "passed",
+			wantDecision: entities.FraudAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := &fakeSendAttempts{byIP: tt.byIP}
+			var challenges ChallengeVerifier
+			if tt.challenges != nil {
+				challenges = tt.challenges
+			}
+			service := NewFraudService(attempts, fakePhoneValidator{}, challenges, testFraudPolicy)
+
+			attempt, err := service.Screen(context.Background(), testPhone, entities.SendOrigin{ClientIP: testClientIP, ChallengeToken: tt.token})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Screen error = %v, want %v", err, tt.wantErr)
+			}
+			if attempt.Decision != tt.wantDecision {
+				t.Errorf("decision = %s, want %s", attempt.Decision, tt.wantDecision)
+			}
+			if !strings.Contains(attempt.Reasons, tt.wantReason) {
+				t.Errorf("reasons = %q, want %q", attempt.Reasons, tt.wantReason)
+			}
+			if stored := len(attempts.created) == 1; stored == (tt.wantDecision == entities.FraudAllow) {
+				t.Errorf("%s attempt stored = %v", attempt.Decision, stored)
+			}
+			if tt.challenges != nil {
+				if verified := tt.challenges.calls == 1; verified != tt.wantVerify {
+					t.Errorf("token verified = %v, want %v", verified, tt.wantVerify)
+				}
+				if tt.wantVerify && tt.challenges.clientIP != testClientIP {
+					t.Errorf("token verified for %q, want %q", tt.challenges.clientIP, testClientIP)
+				}
+			}
+		})
+	}
+
+	t.Run("provider error", func(t *testing.T) {
+		attempts := &fakeSendAttempts{byIP: 10}
+		service := NewFraudService(attempts, fakePhoneValidator{}, &stubChallenges{err: errCaptcha}, testFraudPolicy)
+
+		attempt, err := service.Screen(context.Background(), testPhone, entities.SendOrigin{ClientIP: testClientIP, ChallengeToken: "passed"})
+		if attempt != nil || !errors.Is(err, errCaptcha) {
+			t.Fatalf("Screen = %+v, %v, want %v", attempt, err, errCaptcha)
+		}
+		if len(attempts.created) != 0 {
+			t.Errorf("attempt stored although it was never decided")
+		}
+	})
+}
+
+func TestFraudScreenDisabled(t *testing.T) {
+	attempts := &fakeSendAttempts{byIP: 1000}
+	policy := testFraudPolicy
+	policy.Enabled = false
+	service := NewFraudService(attempts, fakePhoneValidator{}, nil, policy)
+
+	attempt, err := service.Screen(context.Background(), testPhone, entities.SendOrigin{ClientIP: testClientIP})
+	if attempt != nil || err != nil {
+		t.Errorf("Screen = %+v, %v, want nothing with screening disabled", attempt, err)
+	}
+}
+
+func TestFraudRecord(t *testing.T) {
+	ctx := context.Background()
+	attempts := &fakeSendAttempts{}
+	service := NewFraudService(attempts, fakePhoneValidator{}, nil, testFraudPolicy)
+
+	attempt, err := service.Screen(ctx, testPhone, entities.SendOrigin{ClientIP: testClientIP})
+	if err != nil {
+		t.Fatalf("Screen: %v", err)
+	}
+	otp := &entities.OTP{ID: uuid.New()}
+	if err := service.RecordSend(ctx, attempt, otp); err != nil {
+		t.Fatalf("RecordSend: %v", err)
+	}
+	if len(attempts.created) != 1 || attempts.created[0].OTPID == nil || *attempts.created[0].OTPID != otp.ID {
+		t.Fatalf("stored %+v, want the attempt linked to its OTP", attempts.created)
+	}
+
+	// Sends to email addresses are not screened and have no attempt.
+	if err := service.RecordSend(ctx, nil, otp); err != nil || len(attempts.created) != 1 {
+		t.Errorf("RecordSend without an attempt = %v, stored %d", err, len(attempts.created))
+	}
+
+	if err := service.RecordVerification(ctx, otp); err != nil || len(attempts.verified) != 0 {
+		t.Errorf("RecordVerification of an unverified OTP = %v, marked %v", err, attempts.verified)
+	}
+	verifiedAt := time.Now()
+	otp.VerifiedAt = &verifiedAt
+	if err := service.RecordVerification(ctx, otp); err != nil || len(attempts.verified) != 1 || attempts.verified[0] != otp.ID.String() {
+		t.Errorf("RecordVerification = %v, marked %v, want the OTP's attempt", err, attempts.verified)
+	}
+}
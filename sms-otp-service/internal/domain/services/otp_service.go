@@ -5,121 +5,513 @@ import (
 	"errors"
 	"sms-otp-service/internal/domain/entities"
 	"sms-otp-service/internal/domain/repositories"
+	"time"
 )
 
 var (
-	ErrRateLimitExceeded = errors.New("rate limit exceeded")
-	ErrInvalidRequest    = errors.New("invalid request")
+	ErrRateLimitExceeded  = errors.New("rate limit exceeded")
+	ErrInvalidRequest     = errors.New("invalid request")
+	ErrVerificationLocked = errors.New("verification locked")
+)
+
+// RateLimitError is ErrRateLimitExceeded along with when to retry.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	// NextResendAt is when an OTP may be sent again, for limits on sending.
+	NextResendAt *time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrRateLimitExceeded.Error()
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimitExceeded
+}
+
+// sendLimitError returns the error for a send refused for retryAfter.
+func sendLimitError(retryAfter time.Duration) *RateLimitError {
+	nextResendAt := time.Now().Add(retryAfter)
+	return &RateLimitError{RetryAfter: retryAfter, NextResendAt: &nextResendAt}
+}
+
+// LockedError is ErrVerificationLocked along with when the lock ends.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return ErrVerificationLocked.Error()
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrVerificationLocked
+}
+
+// issueKind is how an OTP is requested.
+type issueKind int
+
+const (
+	// issueSend supersedes earlier OTPs for the recipient and purpose.
+	issueSend issueKind = iota
+	// issueResend supersedes them too, but only once the resend cooldown
+	// is over.
+	issueResend
+	// issueSession leaves earlier OTPs alone, so parallel verification
+	// sessions do not interfere.
+	issueSession
 )
 
 type OTPDomainService interface {
-	GenerateOTP(ctx context.Context, phoneNumber string, purpose entities.OTPPurpose) (*entities.OTP, error)
-	VerifyOTP(ctx context.Context, phoneNumber, code string, purpose entities.OTPPurpose) error
-	ResendOTP(ctx context.Context, phoneNumber string, purpose entities.OTPPurpose) (*entities.OTP, error)
+	// GenerateOTP issues an OTP requested by the client clientID, whose
+	// number rules apply along with those of every client.
+	GenerateOTP(ctx context.Context, recipient entities.Recipient, purpose entities.OTPPurpose, channel entities.Channel, clientID string) (*entities.OTP, error)
+	// VerifyOTP checks code and returns the verified OTP.
+	VerifyOTP(ctx context.Context, recipient entities.Recipient, code string, purpose entities.OTPPurpose) (*entities.OTP, error)
+	// ResendOTP works like GenerateOTP, but fails with a RateLimitError
+	// while the resend cooldown of the recipient and purpose is running.
+	ResendOTP(ctx context.Context, recipient entities.Recipient, purpose entities.OTPPurpose, channel entities.Channel, clientID string) (*entities.OTP, error)
+
+	// StartVerification issues an OTP for a verification session. Unlike
+	// GenerateOTP it leaves other OTPs for the recipient untouched, so
+	// parallel sessions do not interfere.
+	StartVerification(ctx context.Context, recipient entities.Recipient, purpose entities.OTPPurpose, channel entities.Channel, clientID string) (*entities.OTP, error)
+	// CheckVerification checks code against the OTP with the given ID. When
+	// the check fails, the OTP is returned along with the reason, such as
+	// ErrInvalidOTPCode or ErrOTPCanceled.
+	CheckVerification(ctx context.Context, id, code string) (*entities.OTP, error)
+	GetVerification(ctx context.Context, id string) (*entities.OTP, error)
+	CancelVerification(ctx context.Context, id string) (*entities.OTP, error)
+
+	// Lockout returns the failed verifications and locks of recipient for
+	// purpose, counted across their OTPs.
+	Lockout(ctx context.Context, recipient string, purpose entities.OTPPurpose) (entities.LockoutState, error)
+	// ClearLockout lifts the lock of recipient for purpose and forgets their
+	// failed verifications.
+	ClearLockout(ctx context.Context, recipient string, purpose entities.OTPPurpose) error
 }
 
 type otpDomainService struct {
-	otpRepo          repositories.OTPRepository
-	otpGenerator     OTPGenerator
-	phoneValidator   PhoneValidator
-	rateLimitMinutes int
-	maxOTPsPerPeriod int
-	validityMinutes  int
+	otpRepo        repositories.OTPRepository
+	numberRuleRepo repositories.NumberRuleRepository
+	limiterStore   repositories.LimiterStore
+	txManager      repositories.TransactionManager
+	otpGenerator   OTPGenerator
+	codeHasher     entities.CodeHasher
+	phoneValidator PhoneValidator
+	emailValidator EmailValidator
+	policies       entities.PolicyRegistry
+	channelRoutes  entities.ChannelRoutes
+	sendQuota      entities.RateLimit
+	verifyQuota    entities.RateLimit
+	lockout        entities.Lockout
 }
 
 type OTPGenerator interface {
-	Generate() string
+	Generate(length int, alphabet string) string
 }
 
 type PhoneValidator interface {
 	Validate(phoneNumber string) error
+	// IsMobile reports whether a valid phone number may be able to receive
+	// SMS.
+	IsMobile(phoneNumber string) bool
+	// Region returns the ISO 3166-1 alpha-2 code of the region of a valid
+	// phone number.
+	Region(phoneNumber string) (string, error)
+}
+
+type EmailValidator interface {
+	Validate(email string) error
 }
 
+// NewOTPDomainService returns the OTP domain service. Each recipient may be
+// sent maxOTPsPerPeriod OTPs, and may check maxVerifiesPerPeriod codes per
+// purpose, within rateLimitMinutes. Wrong codes for a recipient and purpose
+// count toward lockout whichever OTP they were checked against. The quotas,
+// resend cooldowns and lockouts are kept in limiterStore.
 func NewOTPDomainService(
 	otpRepo repositories.OTPRepository,
+	numberRuleRepo repositories.NumberRuleRepository,
+	limiterStore repositories.LimiterStore,
+	txManager repositories.TransactionManager,
 	otpGenerator OTPGenerator,
+	codeHasher entities.CodeHasher,
 	phoneValidator PhoneValidator,
-	rateLimitMinutes, maxOTPsPerPeriod, validityMinutes int,
+	emailValidator EmailValidator,
+	policies entities.PolicyRegistry,
+	channelRoutes entities.ChannelRoutes,
+	lockout entities.Lockout,
+	rateLimitMinutes, maxOTPsPerPeriod, maxVerifiesPerPeriod int,
 ) OTPDomainService {
+	period := time.Duration(rateLimitMinutes) * time.Minute
 	return &otpDomainService{
-		otpRepo:          otpRepo,
-		otpGenerator:     otpGenerator,
-		phoneValidator:   phoneValidator,
-		rateLimitMinutes: rateLimitMinutes,
-		maxOTPsPerPeriod: maxOTPsPerPeriod,
-		validityMinutes:  validityMinutes,
+		otpRepo:        otpRepo,
+		numberRuleRepo: numberRuleRepo,
+		limiterStore:   limiterStore,
+		txManager:      txManager,
+		otpGenerator:   otpGenerator,
+		codeHasher:     codeHasher,
+		phoneValidator: phoneValidator,
+		emailValidator: emailValidator,
+		policies:       policies,
+		channelRoutes:  channelRoutes,
+		sendQuota:      entities.RateLimit{Algorithm: entities.RateLimitSlidingWindow, Limit: maxOTPsPerPeriod, Window: period},
+		verifyQuota:    entities.RateLimit{Algorithm: entities.RateLimitSlidingWindow, Limit: maxVerifiesPerPeriod, Window: period},
+		lockout:        lockout,
 	}
 }
 
-func (s *otpDomainService) GenerateOTP(ctx context.Context, phoneNumber string, purpose entities.OTPPurpose) (*entities.OTP, error) {
-	if err := s.phoneValidator.Validate(phoneNumber); err != nil {
-		return nil, entities.ErrInvalidPhoneNumber
-	}
+// Keys of the quotas and cooldowns in the limiter store.
+func sendQuotaKey(recipient string) string {
+	return "otp:send:" + recipient
+}
+
+func verifyQuotaKey(recipient string, purpose entities.OTPPurpose) string {
+	return "otp:verify:" + recipient + ":" + string(purpose)
+}
+
+func resendCooldownKey(recipient string, purpose entities.OTPPurpose) string {
+	return "otp:resend:" + recipient + ":" + string(purpose)
+}
 
-	recentCount, err := s.otpRepo.CountRecentOTPs(ctx, phoneNumber, s.rateLimitMinutes)
+func lockoutKey(recipient string, purpose entities.OTPPurpose) string {
+	return "otp:lock:" + recipient + ":" + string(purpose)
+}
+
+func (s *otpDomainService) GenerateOTP(ctx context.Context, recipient entities.Recipient, purpose entities.OTPPurpose, channel entities.Channel, clientID string) (*entities.OTP, error) {
+	return s.issue(ctx, recipient, purpose, channel, clientID, issueSend)
+}
+
+func (s *otpDomainService) StartVerification(ctx context.Context, recipient entities.Recipient, purpose entities.OTPPurpose, channel entities.Channel, clientID string) (*entities.OTP, error) {
+	return s.issue(ctx, recipient, purpose, channel, clientID, issueSession)
+}
+
+// issue creates an OTP for purpose to be delivered over channel, subject to
+// the number rules applying to clientID. Without a
+// channel, email addresses get email and phone numbers the channel routed to
+// their country. Every committed OTP moves the recipient to the next, longer
+// resend cooldown of the purpose; only resends have to wait for the running
+// one.
+func (s *otpDomainService) issue(ctx context.Context, recipient entities.Recipient, purpose entities.OTPPurpose, channel entities.Channel, clientID string, kind issueKind) (*entities.OTP, error) {
+	policy, err := s.policies.Lookup(purpose)
 	if err != nil {
 		return nil, err
 	}
 
-	if recentCount >= int64(s.maxOTPsPerPeriod) {
-		return nil, ErrRateLimitExceeded
+	if err := s.validateRecipient(recipient); err != nil {
+		return nil, err
+	}
+	if recipient.Type == entities.RecipientPhone {
+		if err := s.checkNumberRules(ctx, clientID, recipient.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	channel, err = entities.ParseChannel(string(channel))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case channel != "":
+	case recipient.Type == entities.RecipientEmail:
+		channel = entities.ChannelEmail
+	default:
+		channel = s.channelRoutes.Resolve(recipient.Address)
+	}
+	if !channel.Accepts(recipient.Type) {
+		return nil, entities.ErrUnsupportedChannel
+	}
+	if recipient.Type == entities.RecipientPhone && channel.RequiresMobile() && !s.phoneValidator.IsMobile(recipient.Address) {
+		return nil, entities.ErrNotMobileNumber
 	}
 
-	existingOTPs, err := s.otpRepo.FindActiveByPhone(ctx, phoneNumber)
+	// A running cooldown is only read here: the next one starts once the
+	// OTP is committed, and the quota is given back if it is not, so a
+	// rolled-back send costs the recipient nothing.
+	cooldownKey := resendCooldownKey(recipient.Address, purpose)
+	cooldown, err := s.limiterStore.Cooldown(ctx, cooldownKey)
 	if err != nil {
 		return nil, err
 	}
+	if kind == issueResend && cooldown.RetryAfter > 0 {
+		return nil, sendLimitError(cooldown.RetryAfter)
+	}
 
-	for _, existingOTP := range existingOTPs {
-		if existingOTP.Purpose == purpose {
-			existingOTP.Attempts = existingOTP.MaxAttempts
-			if err := s.otpRepo.Update(ctx, existingOTP); err != nil {
-				return nil, err
+	var otp *entities.OTP
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		quotaKey := sendQuotaKey(recipient.Address)
+		quota, err := s.limiterStore.Take(ctx, quotaKey, s.sendQuota)
+		if err != nil {
+			return err
+		}
+		if !quota.Allowed {
+			return sendLimitError(max(quota.RetryAfter, cooldown.RetryAfter))
+		}
+		s.txManager.AfterRollback(ctx, func(ctx context.Context) {
+			// At worst the recipient is short of one send until the
+			// window moves on.
+			_ = s.limiterStore.Release(ctx, quotaKey, s.sendQuota)
+		})
+
+		if kind != issueSession {
+			if err := s.otpRepo.InvalidateActive(ctx, recipient.Address, purpose); err != nil {
+				return err
 			}
 		}
-	}
 
-	code := s.otpGenerator.Generate()
-	otp := entities.NewOTP(phoneNumber, code, purpose, s.validityMinutes)
+		code := s.otpGenerator.Generate(policy.CodeLength, policy.Alphabet)
+		otp = entities.NewOTP(recipient, code, channel, policy, s.codeHasher)
+		otp.NextResendAt = time.Now().Add(policy.ResendBackoff.Delay(cooldown.Step))
+		if err := s.otpRepo.Create(ctx, otp); err != nil {
+			return err
+		}
 
-	if err := s.otpRepo.Create(ctx, otp); err != nil {
+		s.txManager.AfterCommit(ctx, func(ctx context.Context) {
+			// Without the cooldown the next resend is only allowed
+			// sooner, so the OTP stands even if it cannot be started.
+			next, err := s.limiterStore.Backoff(ctx, cooldownKey, policy.ResendBackoff)
+			if err == nil {
+				otp.NextResendAt = time.Now().Add(next.RetryAfter)
+			}
+		})
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return otp, nil
 }
 
-func (s *otpDomainService) VerifyOTP(ctx context.Context, phoneNumber, code string, purpose entities.OTPPurpose) error {
-	otp, err := s.otpRepo.FindByPhoneAndPurpose(ctx, phoneNumber, purpose)
+func (s *otpDomainService) validateRecipient(recipient entities.Recipient) error {
+	switch recipient.Type {
+	case entities.RecipientPhone:
+		if err := s.phoneValidator.Validate(recipient.Address); err != nil {
+			return entities.ErrInvalidPhoneNumber
+		}
+	case entities.RecipientEmail:
+		if err := s.emailValidator.Validate(recipient.Address); err != nil {
+			return entities.ErrInvalidEmail
+		}
+	default:
+		return entities.ErrInvalidRecipient
+	}
+	return nil
+}
+
+// checkNumberRules returns ErrNumberBlocked if the global rules, or the
+// rules of clientID on top of them, forbid sending to phoneNumber.
+func (s *otpDomainService) checkNumberRules(ctx context.Context, clientID, phoneNumber string) error {
+	region, err := s.phoneValidator.Region(phoneNumber)
+	if err != nil {
+		return entities.ErrInvalidPhoneNumber
+	}
+
+	rules, err := s.numberRuleRepo.FindMatching(ctx, clientID, phoneNumber, region)
+	if err != nil {
+		return err
+	}
+	var globalRules, clientRules []*entities.NumberRule
+	for _, rule := range rules {
+		if rule.ClientID == "" {
+			globalRules = append(globalRules, rule)
+		} else {
+			clientRules = append(clientRules, rule)
+		}
+	}
+
+	allowlist, err := s.numberRuleRepo.HasAllowRules(ctx, "")
+	if err != nil {
+		return err
+	}
+	if err := entities.CheckNumberRules(globalRules, phoneNumber, region, allowlist); err != nil || clientID == "" {
+		return err
+	}
+
+	allowlist, err = s.numberRuleRepo.HasAllowRules(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	return entities.CheckNumberRules(clientRules, phoneNumber, region, allowlist)
+}
+
+func (s *otpDomainService) VerifyOTP(ctx context.Context, recipient entities.Recipient, code string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+	policy, err := s.policies.Lookup(purpose)
 	if err != nil {
-		return entities.ErrOTPNotFound
+		return nil, err
+	}
+
+	// Malformed codes are rejected without costing the OTP an attempt.
+	code = policy.NormalizeCode(code)
+	if !policy.IsWellFormed(code) {
+		return nil, entities.ErrInvalidCodeFormat
+	}
+	if err := s.checkLockout(ctx, recipient.Address, purpose); err != nil {
+		return nil, err
+	}
+	if err := s.checkVerifyQuota(ctx, recipient.Address, purpose); err != nil {
+		return nil, err
 	}
 
-	if err := otp.Verify(code); err != nil {
-		if updateErr := s.otpRepo.Update(ctx, otp); updateErr != nil {
-			return updateErr
+	// The OTP row stays locked from read to write, so concurrent requests
+	// cannot each spend the same attempt or verify one code twice. A failed
+	// verification still commits, since the spent attempt must be kept.
+	var otp *entities.OTP
+	var verifyErr error
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		otp, err = s.otpRepo.FindByRecipientAndPurposeForUpdate(ctx, recipient.Address, purpose)
+		if errors.Is(err, entities.ErrOTPNotFound) {
+			verifyErr = err
+			return nil
 		}
+		if err != nil {
+			return err
+		}
+
+		verifyErr = otp.Verify(code, s.codeHasher)
+		return s.otpRepo.UpdateVerification(ctx, otp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.settleLockout(ctx, recipient.Address, purpose, verifyErr); err != nil {
+		return nil, err
+	}
+	if verifyErr != nil {
+		return nil, verifyErr
+	}
+
+	return otp, nil
+}
+
+// checkVerifyQuota counts a code check for recipient and purpose, across
+// their OTPs, and returns ErrRateLimitExceeded once they are out of checks.
+func (s *otpDomainService) checkVerifyQuota(ctx context.Context, recipient string, purpose entities.OTPPurpose) error {
+	quota, err := s.limiterStore.Take(ctx, verifyQuotaKey(recipient, purpose), s.verifyQuota)
+	if err != nil {
 		return err
 	}
+	if !quota.Allowed {
+		return &RateLimitError{RetryAfter: quota.RetryAfter}
+	}
+	return nil
+}
 
-	if err := s.otpRepo.Update(ctx, otp); err != nil {
+// checkLockout returns a LockedError while recipient is locked out of
+// verifying codes for purpose.
+func (s *otpDomainService) checkLockout(ctx context.Context, recipient string, purpose entities.OTPPurpose) error {
+	if !s.lockout.Enabled() {
+		return nil
+	}
+	state, err := s.limiterStore.Lockout(ctx, lockoutKey(recipient, purpose))
+	if err != nil {
 		return err
 	}
+	if state.Locked() {
+		return &LockedError{RetryAfter: state.RetryAfter}
+	}
+	return nil
+}
+
+// settleLockout updates the lockout of recipient and purpose after a code
+// check ended with verifyErr. A wrong code counts as a failure and returns a
+// LockedError if it crossed the threshold; a right one clears the lockout.
+func (s *otpDomainService) settleLockout(ctx context.Context, recipient string, purpose entities.OTPPurpose, verifyErr error) error {
+	if !s.lockout.Enabled() {
+		return nil
+	}
+	key := lockoutKey(recipient, purpose)
 
+	switch {
+	case verifyErr == nil:
+		// A stale failure count only brings the next lock sooner, so the
+		// verification stands even if it cannot be cleared.
+		_ = s.limiterStore.ClearLockout(ctx, key)
+	case errors.Is(verifyErr, entities.ErrInvalidOTPCode):
+		state, err := s.limiterStore.RecordFailure(ctx, key, s.lockout)
+		if err != nil {
+			return err
+		}
+		if state.Locked() {
+			return &LockedError{RetryAfter: state.RetryAfter}
+		}
+	}
 	return nil
 }
 
-func (s *otpDomainService) ResendOTP(ctx context.Context, phoneNumber string, purpose entities.OTPPurpose) (*entities.OTP, error) {
-	existingOTP, err := s.otpRepo.FindByPhoneAndPurpose(ctx, phoneNumber, purpose)
-	if err == nil && !existingOTP.IsExpired() {
-		recentCount, err := s.otpRepo.CountRecentOTPs(ctx, phoneNumber, 1)
+func (s *otpDomainService) ResendOTP(ctx context.Context, recipient entities.Recipient, purpose entities.OTPPurpose, channel entities.Channel, clientID string) (*entities.OTP, error) {
+	return s.issue(ctx, recipient, purpose, channel, clientID, issueResend)
+}
+
+func (s *otpDomainService) CheckVerification(ctx context.Context, id, code string) (*entities.OTP, error) {
+	var otp *entities.OTP
+	var verifyErr error
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		otp, err = s.otpRepo.FindByIDForUpdate(ctx, id)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		policy, err := s.policies.Lookup(otp.Purpose)
+		if err != nil {
+			return err
+		}
+
+		// As in VerifyOTP, malformed codes cost no attempt.
+		code = policy.NormalizeCode(code)
+		if !policy.IsWellFormed(code) {
+			return entities.ErrInvalidCodeFormat
+		}
+		if err := s.checkLockout(ctx, otp.Recipient, otp.Purpose); err != nil {
+			return err
+		}
+		if err := s.checkVerifyQuota(ctx, otp.Recipient, otp.Purpose); err != nil {
+			return err
+		}
+
+		verifyErr = otp.Verify(code, s.codeHasher)
+		return s.otpRepo.UpdateVerification(ctx, otp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.settleLockout(ctx, otp.Recipient, otp.Purpose, verifyErr); err != nil {
+		return nil, err
+	}
+
+	return otp, verifyErr
+}
+
+func (s *otpDomainService) GetVerification(ctx context.Context, id string) (*entities.OTP, error) {
+	return s.otpRepo.FindByID(ctx, id)
+}
+
+func (s *otpDomainService) CancelVerification(ctx context.Context, id string) (*entities.OTP, error) {
+	var otp *entities.OTP
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		otp, err = s.otpRepo.FindByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
 		}
-		if recentCount > 0 {
-			return nil, ErrRateLimitExceeded
+		if err := otp.Cancel(); err != nil {
+			return err
 		}
+		return s.otpRepo.UpdateVerification(ctx, otp)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return s.GenerateOTP(ctx, phoneNumber, purpose)
+	return otp, nil
+}
+
+func (s *otpDomainService) Lockout(ctx context.Context, recipient string, purpose entities.OTPPurpose) (entities.LockoutState, error) {
+	return s.limiterStore.Lockout(ctx, lockoutKey(recipient, purpose))
+}
+
+func (s *otpDomainService) ClearLockout(ctx context.Context, recipient string, purpose entities.OTPPurpose) error {
+	return s.limiterStore.ClearLockout(ctx, lockoutKey(recipient, purpose))
 }
@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/ratelimit"
+	"sms-otp-service/pkg/utils"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = entities.OTPPolicy{
+	Purpose:       entities.PurposeVerification,
+	CodeLength:    6,
+	Alphabet:      entities.DigitAlphabet,
+	TTL:           5 * time.Minute,
+	MaxAttempts:   3,
+	ResendBackoff: entities.Backoff{Delays: []time.Duration{30 * time.Second, time.Minute}, Reset: time.Hour},
+}
+
+const (
+	testPhone = "+14155550100"
+	testCode  = "123456"
+	wrongCode = "654321"
+)
+
+// newTestService returns the service on fakes that keep OTPs in memory and
+// lock their rows as Postgres would.
+func newTestService(t *testing.T) (*otpDomainService, *fakeOTPRepository) {
+	t.Helper()
+	txManager := &fakeTxManager{}
+	otps := &fakeOTPRepository{t: t, otps: make(map[string]*entities.OTP)}
+	service := NewOTPDomainService(
+		otps,
+		fakeNumberRules(nil),
+		ratelimit.NewMemoryStore(),
+		txManager,
+		fixedGenerator(testCode),
+		utils.NewCodeHasher([]byte("test")),
+		fakePhoneValidator{},
+		nil,
+		entities.PolicyRegistry{entities.PurposeVerification: testPolicy},
+		entities.ChannelRoutes{},
+		entities.Lockout{},
+		10, 5, 1000,
+	)
+	return service.(*otpDomainService), otps
+}
+
+// verifyConcurrently checks each code against one OTP at once and returns
+// how many checks succeeded.
+func verifyConcurrently(codes []string, verify func(code string) error) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	verified := 0
+	start := make(chan struct{})
+	for _, code := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if verify(code) == nil {
+				mu.Lock()
+				verified++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return verified
+}
+
+func TestConcurrentVerifications(t *testing.T) {
+	repeat := func(code string, n int) []string {
+		codes := make([]string, n)
+		for i := range codes {
+			codes[i] = code
+		}
+		return codes
+	}
+	mixed := append(repeat(wrongCode, 10), repeat(testCode, 10)...)
+
+	tests := []struct {
+		name         string
+		codes        []string
+		wantVerified int
+		wantAttempts int
+	}{
+		{name: "right code verifies once", codes: repeat(testCode, 20), wantVerified: 1, wantAttempts: 1},
+		{name: "wrong codes spend the attempts once", codes: repeat(wrongCode, 20), wantVerified: 0, wantAttempts: testPolicy.MaxAttempts},
+		{name: "mixed codes stay within the attempts", codes: mixed, wantVerified: -1, wantAttempts: -1},
+	}
+
+	verifiers := []struct {
+		name   string
+		verify func(s *otpDomainService, otp *entities.OTP, code string) error
+	}{
+		{
+			name: "VerifyOTP",
+			verify: func(s *otpDomainService, otp *entities.OTP, code string) error {
+				_, err := s.VerifyOTP(context.Background(), otp.To(), code, otp.Purpose)
+				return err
+			},
+		},
+		{
+			name: "CheckVerification",
+			verify: func(s *otpDomainService, otp *entities.OTP, code string) error {
+				_, err := s.CheckVerification(context.Background(), otp.ID.String(), code)
+				return err
+			},
+		},
+	}
+
+	for _, v := range verifiers {
+		for _, tt := range tests {
+			t.Run(v.name+"/"+tt.name, func(t *testing.T) {
+				service, otps := newTestService(t)
+				otp := entities.NewOTP(entities.Recipient{Type: entities.RecipientPhone, Address: testPhone}, testCode, entities.ChannelSMS, testPolicy, service.codeHasher)
+				otps.add(otp)
+
+				verified := verifyConcurrently(tt.codes, func(code string) error {
+					return v.verify(service, otp, code)
+				})
+
+				stored := otps.get(otp.ID.String())
+				if verified > 1 {
+					t.Errorf("%d verifications succeeded, want at most 1", verified)
+				}
+				if stored.Attempts > stored.MaxAttempts {
+					t.Errorf("attempts = %d, above the maximum of %d", stored.Attempts, stored.MaxAttempts)
+				}
+				if stored.IsVerified != (verified == 1) {
+					t.Errorf("verified = %v after %d successful verifications", stored.IsVerified, verified)
+				}
+				if tt.wantVerified >= 0 && verified != tt.wantVerified {
+					t.Errorf("%d verifications succeeded, want %d", verified, tt.wantVerified)
+				}
+				if tt.wantAttempts >= 0 && stored.Attempts != tt.wantAttempts {
+					t.Errorf("attempts = %d, want %d", stored.Attempts, tt.wantAttempts)
+				}
+			})
+		}
+	}
+}
+
+func TestIssueSpendsLimitsOnlyWhenCommitted(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(t)
+	recipient := entities.Recipient{Type: entities.RecipientPhone, Address: testPhone}
+	cooldownKey := resendCooldownKey(testPhone, entities.PurposeVerification)
+	errOutbox := errors.New("outbox insert failed")
+
+	// A send whose transaction rolls back leaves no trace.
+	err := service.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := service.GenerateOTP(ctx, recipient, entities.PurposeVerification, entities.ChannelSMS, ""); err != nil {
+			return err
+		}
+		return errOutbox
+	})
+	if !errors.Is(err, errOutbox) {
+		t.Fatalf("rolled back send: got %v, want %v", err, errOutbox)
+	}
+	if cooldown, _ := service.limiterStore.Cooldown(ctx, cooldownKey); cooldown != (entities.BackoffResult{}) {
+		t.Errorf("cooldown after a rolled back send = %+v, want none", cooldown)
+	}
+
+	// A committed send starts the first cooldown and spends quota.
+	otp, err := service.GenerateOTP(ctx, recipient, entities.PurposeVerification, entities.ChannelSMS, "")
+	if err != nil {
+		t.Fatalf("GenerateOTP: %v", err)
+	}
+	if wait := time.Until(otp.NextResendAt); wait < 29*time.Second || wait > 30*time.Second {
+		t.Errorf("next resend in %v, want 30s", wait)
+	}
+
+	// Resends wait for it without escalating it.
+	if _, err := service.ResendOTP(ctx, recipient, entities.PurposeVerification, entities.ChannelSMS, ""); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("resend during the cooldown: got %v, want %v", err, ErrRateLimitExceeded)
+	}
+
+	// Sends beyond the quota are refused without escalating it either.
+	for range service.sendQuota.Limit - 1 {
+		if _, err := service.GenerateOTP(ctx, recipient, entities.PurposeVerification, entities.ChannelSMS, ""); err != nil {
+			t.Fatalf("GenerateOTP within the quota: %v", err)
+		}
+	}
+	if _, err := service.GenerateOTP(ctx, recipient, entities.PurposeVerification, entities.ChannelSMS, ""); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("send beyond the quota: got %v, want %v", err, ErrRateLimitExceeded)
+	}
+	cooldown, err := service.limiterStore.Cooldown(ctx, cooldownKey)
+	if err != nil {
+		t.Fatalf("Cooldown: %v", err)
+	}
+	if cooldown.Step != service.sendQuota.Limit {
+		t.Errorf("cooldown step = %d, want one per committed send, %d", cooldown.Step, service.sendQuota.Limit)
+	}
+}
+
+func TestNumberRulesPerClient(t *testing.T) {
+	rule := func(clientID string, action entities.NumberRuleAction, number string) *entities.NumberRule {
+		r, err := entities.NewNumberRule(clientID, action, entities.NumberRuleMatchNumber, number, "")
+		if err != nil {
+			t.Fatalf("NewNumberRule: %v", err)
+		}
+		return r
+	}
+	blocklist := fakeNumberRules{
+		// Client a only sends to its test number, and no one to
+		// +14155550123.
+		rule("a", entities.NumberRuleAllow, "+14155550199"),
+		rule("", entities.NumberRuleBlock, "+14155550123"),
+	}
+	allowlist := fakeNumberRules{
+		// Everyone is limited to three numbers, and client a to two of
+		// them. Client b cannot allow itself a fourth.
+		rule("", entities.NumberRuleAllow, testPhone),
+		rule("", entities.NumberRuleAllow, "+14155550199"),
+		rule("", entities.NumberRuleAllow, "+14155550123"),
+		rule("a", entities.NumberRuleAllow, testPhone),
+		rule("a", entities.NumberRuleAllow, "+14155550199"),
+		rule("b", entities.NumberRuleAllow, "+14155550188"),
+	}
+
+	tests := []struct {
+		rules    fakeNumberRules
+		clientID string
+		phone    string
+		wantErr  error
+	}{
+		{rules: blocklist, clientID: "a", phone: "+14155550199"},
+		{rules: blocklist, clientID: "a", phone: testPhone, wantErr: entities.ErrNumberBlocked},
+		{rules: blocklist, clientID: "b", phone: testPhone},
+		{rules: blocklist, clientID: "", phone: testPhone},
+		{rules: blocklist, clientID: "b", phone: "+14155550123", wantErr: entities.ErrNumberBlocked},
+		{rules: blocklist, clientID: "", phone: "+14155550123", wantErr: entities.ErrNumberBlocked},
+
+		{rules: allowlist, clientID: "", phone: "+14155550123"},
+		{rules: allowlist, clientID: "", phone: "+14155550177", wantErr: entities.ErrNumberBlocked},
+		{rules: allowlist, clientID: "c", phone: "+14155550177", wantErr: entities.ErrNumberBlocked},
+		{rules: allowlist, clientID: "a", phone: testPhone},
+		{rules: allowlist, clientID: "a", phone: "+14155550123", wantErr: entities.ErrNumberBlocked},
+		{rules: allowlist, clientID: "b", phone: "+14155550188", wantErr: entities.ErrNumberBlocked},
+		{rules: allowlist, clientID: "b", phone: testPhone, wantErr: entities.ErrNumberBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.clientID+" to "+tt.phone, func(t *testing.T) {
+			service, _ := newTestService(t)
+			service.numberRuleRepo = tt.rules
+			recipient := entities.Recipient{Type: entities.RecipientPhone, Address: tt.phone}
+
+			_, err := service.GenerateOTP(context.Background(), recipient, entities.PurposeVerification, entities.ChannelSMS, tt.clientID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GenerateOTP: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeTxManager runs transactions without isolation, but holds the row
+// locks taken in them until they end.
+type fakeTxManager struct{}
+
+type fakeTxKey struct{}
+
+type fakeTx struct {
+	locks      []*sync.Mutex
+	onCommit   []func(ctx context.Context)
+	onRollback []func(ctx context.Context)
+}
+
+func (m *fakeTxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(fakeTxKey{}).(*fakeTx); ok {
+		return fn(ctx)
+	}
+
+	tx := &fakeTx{}
+	err := fn(context.WithValue(ctx, fakeTxKey{}, tx))
+	for _, lock := range tx.locks {
+		lock.Unlock()
+	}
+	hooks := tx.onCommit
+	if err != nil {
+		hooks = tx.onRollback
+	}
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+	return err
+}
+
+func (m *fakeTxManager) AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if tx, ok := ctx.Value(fakeTxKey{}).(*fakeTx); ok {
+		tx.onCommit = append(tx.onCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+func (m *fakeTxManager) AfterRollback(ctx context.Context, fn func(ctx context.Context)) {
+	if tx, ok := ctx.Value(fakeTxKey{}).(*fakeTx); ok {
+		tx.onRollback = append(tx.onRollback, fn)
+	}
+}
+
+// fakeOTPRepository keeps copies of OTPs, so callers only see each other's
+// changes once they are stored. The ForUpdate lookups lock the row until the
+// transaction ends, as SELECT ... FOR UPDATE does, and take a while, so
+// callers that skip the lock would overlap.
+type fakeOTPRepository struct {
+	t    *testing.T
+	mu   sync.Mutex
+	otps map[string]*entities.OTP
+	rows sync.Map // OTP ID to *sync.Mutex
+}
+
+func (r *fakeOTPRepository) add(otp *entities.OTP) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *otp
+	r.otps[otp.ID.String()] = &stored
+}
+
+func (r *fakeOTPRepository) get(id string) *entities.OTP {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	otp, ok := r.otps[id]
+	if !ok {
+		return nil
+	}
+	found := *otp
+	return &found
+}
+
+// latest returns the ID of the newest OTP for recipient and purpose.
+func (r *fakeOTPRepository) latest(recipient string, purpose entities.OTPPurpose) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *entities.OTP
+	for _, otp := range r.otps {
+		if otp.Recipient == recipient && otp.Purpose == purpose && (latest == nil || otp.CreatedAt.After(latest.CreatedAt)) {
+			latest = otp
+		}
+	}
+	if latest == nil {
+		return "", false
+	}
+	return latest.ID.String(), true
+}
+
+// lock takes the row lock and reads the row.
+func (r *fakeOTPRepository) lockAndGet(ctx context.Context, id string) *entities.OTP {
+	tx, ok := ctx.Value(fakeTxKey{}).(*fakeTx)
+	if !ok {
+		r.t.Error("row locked outside a transaction")
+	} else {
+		row, _ := r.rows.LoadOrStore(id, &sync.Mutex{})
+		row.(*sync.Mutex).Lock()
+		tx.locks = append(tx.locks, row.(*sync.Mutex))
+	}
+	otp := r.get(id)
+	time.Sleep(time.Millisecond)
+	return otp
+}
+
+func (r *fakeOTPRepository) Create(ctx context.Context, otp *entities.OTP) error {
+	r.add(otp)
+	return nil
+}
+
+func (r *fakeOTPRepository) FindByRecipientAndPurpose(ctx context.Context, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+	id, ok := r.latest(recipient, purpose)
+	if !ok {
+		return nil, entities.ErrOTPNotFound
+	}
+	return r.get(id), nil
+}
+
+func (r *fakeOTPRepository) FindByRecipientAndPurposeForUpdate(ctx context.Context, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+	id, ok := r.latest(recipient, purpose)
+	if !ok {
+		return nil, entities.ErrOTPNotFound
+	}
+	return r.lockAndGet(ctx, id), nil
+}
+
+func (r *fakeOTPRepository) FindByID(ctx context.Context, id string) (*entities.OTP, error) {
+	otp := r.get(id)
+	if otp == nil {
+		return nil, entities.ErrOTPNotFound
+	}
+	return otp, nil
+}
+
+func (r *fakeOTPRepository) FindByIDForUpdate(ctx context.Context, id string) (*entities.OTP, error) {
+	if r.get(id) == nil {
+		return nil, entities.ErrOTPNotFound
+	}
+	return r.lockAndGet(ctx, id), nil
+}
+
+func (r *fakeOTPRepository) Update(ctx context.Context, otp *entities.OTP) error {
+	r.add(otp)
+	return nil
+}
+
+func (r *fakeOTPRepository) UpdateVerification(ctx context.Context, otp *entities.OTP) error {
+	r.add(otp)
+	return nil
+}
+
+func (r *fakeOTPRepository) InvalidateActive(ctx context.Context, recipient string, purpose entities.OTPPurpose) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, otp := range r.otps {
+		if otp.Recipient == recipient && otp.Purpose == purpose && otp.IsValid() {
+			otp.Attempts = otp.MaxAttempts
+		}
+	}
+	return nil
+}
+
+func (r *fakeOTPRepository) UpdateDeliveryStatus(ctx context.Context, id string, status entities.DeliveryStatus, provider, messageID string) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) UpdateChannel(ctx context.Context, id string, channel entities.Channel) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) RestartDelivery(ctx context.Context, id string, channel entities.Channel) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) FindByProviderMessageID(ctx context.Context, provider, messageID string) (*entities.OTP, error) {
+	return nil, entities.ErrOTPNotFound
+}
+
+func (r *fakeOTPRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.otps, id)
+	return nil
+}
+
+func (r *fakeOTPRepository) DeleteExpired(ctx context.Context) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeOTPRepository) FindActiveByRecipient(ctx context.Context, recipient string) ([]*entities.OTP, error) {
+	return nil, errors.New("not implemented")
+}
+
+// fakeNumberRules holds rules in memory.
+type fakeNumberRules []*entities.NumberRule
+
+func (r fakeNumberRules) Create(ctx context.Context, rule *entities.NumberRule) error {
+	return errors.New("not implemented")
+}
+
+func (r fakeNumberRules) FindByID(ctx context.Context, id string) (*entities.NumberRule, error) {
+	return nil, entities.ErrNumberRuleNotFound
+}
+
+func (r fakeNumberRules) FindAll(ctx context.Context, clientID string, action entities.NumberRuleAction) ([]*entities.NumberRule, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r fakeNumberRules) FindMatching(ctx context.Context, clientID, phoneNumber, region string) ([]*entities.NumberRule, error) {
+	var matching []*entities.NumberRule
+	for _, rule := range r {
+		if (rule.ClientID == "" || rule.ClientID == clientID) && rule.Matches(phoneNumber, region) {
+			matching = append(matching, rule)
+		}
+	}
+	return matching, nil
+}
+
+func (r fakeNumberRules) HasAllowRules(ctx context.Context, clientID string) (bool, error) {
+	for _, rule := range r {
+		if rule.ClientID == clientID && rule.Action == entities.NumberRuleAllow {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r fakeNumberRules) Update(ctx context.Context, rule *entities.NumberRule) error {
+	return errors.New("not implemented")
+}
+
+func (r fakeNumberRules) Delete(ctx context.Context, id string) error {
+	return errors.New("not implemented")
+}
+
+// fakePhoneValidator takes every number for a US mobile number.
+type fakePhoneValidator struct{}
+
+func (fakePhoneValidator) Validate(phoneNumber string) error { return nil }
+
+func (fakePhoneValidator) IsMobile(phoneNumber string) bool { return true }
+
+func (fakePhoneValidator) Region(phoneNumber string) (string, error) { return "US", nil }
+
+// fixedGenerator generates the same code every time.
+type fixedGenerator string
+
+func (g fixedGenerator) Generate(length int, alphabet string) string {
+	return string(g)
+}
@@ -1,19 +1,38 @@
 package config
 
 import (
+	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
+	"unicode"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	SMS      SMSConfig
-	OTP      OTPConfig
-	Logger   LoggerConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	SMS         SMSConfig
+	OTP         OTPConfig
+	Outbox      OutboxConfig
+	Voice       VoiceConfig
+	Phone       PhoneConfig
+	Channels    ChannelConfig
+	Escalation  EscalationConfig
+	WhatsApp    WhatsAppConfig
+	Telegram    TelegramConfig
+	Email       EmailConfig
+	Token       TokenConfig
+	Idempotency IdempotencyConfig
+	Fraud       FraudConfig
+	RateLimit   RateLimitConfig
+	Limiter     LimiterConfig
+	Admin       AdminConfig
+	Logger      LoggerConfig
 }
 
 type ServerConfig struct {
@@ -22,6 +41,9 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	// APIKeys are the X-API-Key values of the known clients. Number rules
+	// created for other keys are never applied.
+	APIKeys []string
 }
 
 type DatabaseConfig struct {
@@ -40,15 +62,299 @@ type SMSConfig struct {
 	APISecret   string
 	SenderName  string
 	APIEndpoint string
+	Timeout     time.Duration
+	SMPP        SMPPConfig
+	Webhook     WebhookConfig
+	Failover    FailoverConfig
+	Routes      []SMSRoute
+	DLR         DLRConfig
+}
+
+type SMPPConfig struct {
+	Address             string
+	SystemID            string
+	Password            string
+	SystemType          string
+	EnquireLinkInterval time.Duration
+	ResponseTimeout     time.Duration
+	ReconnectMinBackoff time.Duration
+	ReconnectMaxBackoff time.Duration
+}
+
+type WebhookConfig struct {
+	Method             string
+	URLTemplate        string
+	Headers            map[string]string
+	BodyTemplate       string
+	BodyFormat         string
+	SuccessStatusCodes []int
+	SuccessJSONPath    string
+	SuccessValue       string
+	MessageIDJSONPath  string
+	ReceiptIDPath      string
+	ReceiptStatusPath  string
+	ReceiptErrorPath   string
+	ReceiptStatusMap   map[string]string
+}
+
+// DLRConfig configures the delivery receipt webhook.
+type DLRConfig struct {
+	// PublicURL is the externally reachable base URL of this service, used to
+	// build status callback URLs and to verify signed callbacks.
+	PublicURL string
+	// Token is the shared secret required on receipts from providers that do
+	// not sign their callbacks.
+	Token          string
+	EventRetention time.Duration
+}
+
+type FailoverConfig struct {
+	Providers        []string
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	HalfOpenProbes   int
+}
+
+// SMSRoute maps a destination prefix to weighted providers. An empty prefix
+// is the default route.
+type SMSRoute struct {
+	Prefix  string
+	Targets []SMSRouteTarget
+}
+
+type SMSRouteTarget struct {
+	Provider string
+	Weight   int
 }
 
 type OTPConfig struct {
 	ValidityMinutes  int
 	RateLimitMinutes int
 	MaxOTPsPerPeriod int
-	CodeLength       int
-	MaxAttempts      int
-	CleanupInterval  time.Duration
+	// MaxVerifiesPerPeriod is how many codes may be checked for one
+	// recipient and purpose within RateLimitMinutes.
+	MaxVerifiesPerPeriod int
+	CodeLength           int
+	MaxAttempts          int
+	CleanupInterval      time.Duration
+	// HashKey is the secret used to hash OTP codes at rest. It is required,
+	// and every instance must use the same key.
+	HashKey  string
+	Purposes []PurposeConfig
+	Lockout  LockoutConfig
+}
+
+// LockoutConfig locks a recipient out of verifying codes for a purpose once
+// Threshold wrong codes are checked within Window, across their OTPs. Locks
+// last Duration, doubling with each one up to MaxDuration, and the doubling
+// starts over once Reset passes without a lock. A zero Threshold disables
+// lockouts.
+type LockoutConfig struct {
+	Threshold   int
+	Window      time.Duration
+	Duration    time.Duration
+	MaxDuration time.Duration
+	Reset       time.Duration
+}
+
+// PurposeConfig is the OTP policy of one purpose. Unset values fall back to
+// the global OTP settings.
+type PurposeConfig struct {
+	Name        string
+	CodeLength  int
+	Alphabet    string
+	TTL         time.Duration
+	MaxAttempts int
+	// ResendCooldowns are the waits before each further resend, the last
+	// one repeating. They start over once ResendCooldownReset passes after
+	// the last wait without a new OTP.
+	ResendCooldowns     []time.Duration
+	ResendCooldownReset time.Duration
+	SMSTemplate         string
+	EmailSubject        string
+}
+
+// PhoneConfig controls how phone numbers are parsed.
+type PhoneConfig struct {
+	// DefaultRegion is the ISO 3166-1 alpha-2 code of the region numbers
+	// without a country code belong to, such as "AZ". Empty requires every
+	// number to include its country code.
+	DefaultRegion string
+}
+
+// VoiceConfig controls OTP delivery by phone call.
+type VoiceConfig struct {
+	Provider        string
+	DefaultLanguage string
+	// Repeat is how many times the code is read out.
+	Repeat int
+}
+
+// ChannelConfig picks the delivery channel of requests that do not name one.
+type ChannelConfig struct {
+	Default string
+	Routes  []ChannelRoute
+}
+
+// ChannelRoute maps a destination prefix, without the leading "+", to a
+// delivery channel.
+type ChannelRoute struct {
+	Prefix  string
+	Channel string
+}
+
+// EscalationConfig controls re-sending OTPs over other channels when
+// delivery is not confirmed in time.
+type EscalationConfig struct {
+	// Timeout is how long each channel gets before the next one is tried.
+	// Zero disables escalation.
+	Timeout time.Duration
+	// Chains lists, per channel, the channels to escalate to in order.
+	Chains map[string][]string
+}
+
+// WhatsAppConfig configures OTP delivery with WhatsApp Cloud API
+// authentication templates.
+type WhatsAppConfig struct {
+	Provider      string
+	APIEndpoint   string
+	APIVersion    string
+	PhoneNumberID string
+	AccessToken   string
+	// TemplateName is an approved template of the authentication category
+	// in TemplateLanguage.
+	TemplateName     string
+	TemplateLanguage string
+	Timeout          time.Duration
+}
+
+// TelegramConfig configures OTP delivery with the Telegram Gateway API.
+type TelegramConfig struct {
+	Provider       string
+	APIEndpoint    string
+	AccessToken    string
+	SenderUsername string
+	Timeout        time.Duration
+}
+
+// EmailConfig configures OTP delivery by email.
+type EmailConfig struct {
+	Provider string
+	SMTPHost string
+	SMTPPort int
+	Username string
+	Password string
+	// From is the sender address, optionally with a display name such as
+	// "Acme <no-reply@acme.example>".
+	From string
+	// TLS is "starttls", "implicit" or "none".
+	TLS     string
+	Timeout time.Duration
+}
+
+type OutboxConfig struct {
+	Workers       int
+	BatchSize     int
+	PollInterval  time.Duration
+	LeaseDuration time.Duration
+	MaxAttempts   int
+	BaseBackoff   time.Duration
+	MaxBackoff    time.Duration
+	Retention     time.Duration
+}
+
+// TokenConfig controls the verification tokens issued after a successful
+// verification.
+type TokenConfig struct {
+	// Algorithm is HS256, RS256 or EdDSA.
+	Algorithm string
+	// Secret is the HS256 signing key.
+	Secret string
+	// PrivateKey is a PEM encoded RS256 or EdDSA signing key, given inline
+	// or read from PrivateKeyFile.
+	PrivateKey     string
+	PrivateKeyFile string
+	KeyID          string
+	Issuer         string
+	Audience       string
+	TTL            time.Duration
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long responses are kept for replay.
+	TTL time.Duration
+}
+
+// FraudConfig controls the screening of sends for SMS pumping. Volume
+// limits count sends within Window; zero disables a limit.
+type FraudConfig struct {
+	Enabled      bool
+	Window       time.Duration
+	IPLimit      int
+	PrefixLength int
+	PrefixLimit  int
+	CountryLimit int
+	// CountryLimits overrides CountryLimit per ISO 3166-1 alpha-2 region.
+	CountryLimits map[string]int
+	// ConversionWindow is how far back the share of verified sends is
+	// measured, leaving out the last ConversionGrace, which recipients may
+	// still be verifying. ConversionMinSends of zero disables the check.
+	ConversionWindow   time.Duration
+	ConversionGrace    time.Duration
+	ConversionMinSends int
+	MinConversion      float64
+	ChallengeScore     int
+	BlockScore         int
+	// Retention is how long send attempts are kept.
+	Retention time.Duration
+	Challenge ChallengeConfig
+}
+
+// RateLimitConfig controls the request rate limits applied to the endpoints
+// that send OTPs.
+type RateLimitConfig struct {
+	Rules []RateLimitRule
+	// PrefixLength is how many leading digits of a phone number, country
+	// code included, make up its prefix.
+	PrefixLength int
+}
+
+// LimiterConfig selects where rate limit counters and cooldowns are kept:
+// "memory" for each replica on its own, or "redis" to share them.
+type LimiterConfig struct {
+	Store string
+	Redis RedisConfig
+}
+
+type RedisConfig struct {
+	// URL is such as "redis://:password@localhost:6379/0", or rediss:// for
+	// TLS.
+	URL       string
+	KeyPrefix string
+	PoolSize  int
+	Timeout   time.Duration
+}
+
+// RateLimitRule allows Limit requests per Window for each value of Scope.
+type RateLimitRule struct {
+	Scope     string
+	Algorithm string
+	Limit     int
+	Window    time.Duration
+}
+
+// ChallengeConfig selects the CAPTCHA provider that risky sends are
+// challenged with.
+type ChallengeConfig struct {
+	Provider string
+	Secret   string
+	Endpoint string
+}
+
+type AdminConfig struct {
+	// Token is the bearer token the admin API requires. The admin API is
+	// disabled without one.
+	Token string
 }
 
 type LoggerConfig struct {
@@ -68,6 +374,7 @@ func Load() (*Config, error) {
 			ReadTimeout:  parseDuration(getEnv("SERVER_READ_TIMEOUT", "30s")),
 			WriteTimeout: parseDuration(getEnv("SERVER_WRITE_TIMEOUT", "30s")),
 			IdleTimeout:  parseDuration(getEnv("SERVER_IDLE_TIMEOUT", "120s")),
+			APIKeys:      parseList(getEnv("API_KEYS", "")),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -83,14 +390,164 @@ func Load() (*Config, error) {
 			APISecret:   getEnv("SMS_API_SECRET", ""),
 			SenderName:  getEnv("SMS_SENDER_NAME", "OTPService"),
 			APIEndpoint: getEnv("SMS_API_ENDPOINT", ""),
+			Timeout:     parseDuration(getEnv("SMS_TIMEOUT", "10s")),
+			SMPP: SMPPConfig{
+				Address:             getEnv("SMPP_ADDRESS", "localhost:2775"),
+				SystemID:            getEnv("SMPP_SYSTEM_ID", ""),
+				Password:            getEnv("SMPP_PASSWORD", ""),
+				SystemType:          getEnv("SMPP_SYSTEM_TYPE", ""),
+				EnquireLinkInterval: parseDuration(getEnv("SMPP_ENQUIRE_LINK_INTERVAL", "30s")),
+				ResponseTimeout:     parseDuration(getEnv("SMPP_RESPONSE_TIMEOUT", "10s")),
+				ReconnectMinBackoff: parseDuration(getEnv("SMPP_RECONNECT_MIN_BACKOFF", "1s")),
+				ReconnectMaxBackoff: parseDuration(getEnv("SMPP_RECONNECT_MAX_BACKOFF", "1m")),
+			},
+			Webhook: WebhookConfig{
+				Method:             getEnv("SMS_WEBHOOK_METHOD", "POST"),
+				URLTemplate:        getEnv("SMS_WEBHOOK_URL", ""),
+				Headers:            parseHeaders(getEnv("SMS_WEBHOOK_HEADERS", "")),
+				BodyTemplate:       getEnv("SMS_WEBHOOK_BODY", ""),
+				BodyFormat:         getEnv("SMS_WEBHOOK_BODY_FORMAT", "json"),
+				SuccessStatusCodes: parseIntList(getEnv("SMS_WEBHOOK_SUCCESS_STATUS", "")),
+				SuccessJSONPath:    getEnv("SMS_WEBHOOK_SUCCESS_JSON_PATH", ""),
+				SuccessValue:       getEnv("SMS_WEBHOOK_SUCCESS_VALUE", ""),
+				MessageIDJSONPath:  getEnv("SMS_WEBHOOK_MESSAGE_ID_PATH", ""),
+				ReceiptIDPath:      getEnv("SMS_WEBHOOK_DLR_ID_PATH", "message_id"),
+				ReceiptStatusPath:  getEnv("SMS_WEBHOOK_DLR_STATUS_PATH", "status"),
+				ReceiptErrorPath:   getEnv("SMS_WEBHOOK_DLR_ERROR_PATH", "error_code"),
+				ReceiptStatusMap:   parseMap(getEnv("SMS_WEBHOOK_DLR_STATUS_MAP", "")),
+			},
+			Failover: FailoverConfig{
+				Providers:        parseList(getEnv("SMS_FAILOVER_PROVIDERS", "")),
+				FailureThreshold: parseInt(getEnv("SMS_BREAKER_FAILURE_THRESHOLD", "5")),
+				OpenTimeout:      parseDuration(getEnv("SMS_BREAKER_OPEN_TIMEOUT", "30s")),
+				HalfOpenProbes:   parseInt(getEnv("SMS_BREAKER_HALF_OPEN_PROBES", "1")),
+			},
+			Routes: parseRoutes(getEnv("SMS_ROUTES", ""), getEnv("SMS_DEFAULT_ROUTE", "")),
+			DLR: DLRConfig{
+				PublicURL:      getEnv("SMS_DLR_PUBLIC_URL", ""),
+				Token:          getEnv("SMS_DLR_TOKEN", ""),
+				EventRetention: parseDuration(getEnv("SMS_DLR_EVENT_RETENTION", "720h")),
+			},
 		},
 		OTP: OTPConfig{
-			ValidityMinutes:  parseInt(getEnv("OTP_VALIDITY_MINUTES", "5")),
-			RateLimitMinutes: parseInt(getEnv("OTP_RATE_LIMIT_MINUTES", "10")),
-			MaxOTPsPerPeriod: parseInt(getEnv("OTP_MAX_PER_PERIOD", "3")),
-			CodeLength:       parseInt(getEnv("OTP_CODE_LENGTH", "6")),
-			MaxAttempts:      parseInt(getEnv("OTP_MAX_ATTEMPTS", "3")),
-			CleanupInterval:  parseDuration(getEnv("OTP_CLEANUP_INTERVAL", "1h")),
+			ValidityMinutes:      parseInt(getEnv("OTP_VALIDITY_MINUTES", "5")),
+			RateLimitMinutes:     parseInt(getEnv("OTP_RATE_LIMIT_MINUTES", "10")),
+			MaxOTPsPerPeriod:     parseInt(getEnv("OTP_MAX_PER_PERIOD", "3")),
+			MaxVerifiesPerPeriod: parseInt(getEnv("OTP_MAX_VERIFIES_PER_PERIOD", "10")),
+			CodeLength:           parseInt(getEnv("OTP_CODE_LENGTH", "6")),
+			MaxAttempts:          parseInt(getEnv("OTP_MAX_ATTEMPTS", "3")),
+			CleanupInterval:      parseDuration(getEnv("OTP_CLEANUP_INTERVAL", "1h")),
+			HashKey:              getEnv("OTP_HASH_KEY", ""),
+			Lockout: LockoutConfig{
+				Threshold:   parseInt(getEnv("OTP_LOCKOUT_THRESHOLD", "5")),
+				Window:      parseDuration(getEnv("OTP_LOCKOUT_WINDOW", "1h")),
+				Duration:    parseDuration(getEnv("OTP_LOCKOUT_DURATION", "5m")),
+				MaxDuration: parseDuration(getEnv("OTP_LOCKOUT_MAX_DURATION", "24h")),
+				Reset:       parseDuration(getEnv("OTP_LOCKOUT_RESET", "24h")),
+			},
+		},
+		Outbox: OutboxConfig{
+			Workers:       parseInt(getEnv("OUTBOX_WORKERS", "4")),
+			BatchSize:     parseInt(getEnv("OUTBOX_BATCH_SIZE", "20")),
+			PollInterval:  parseDuration(getEnv("OUTBOX_POLL_INTERVAL", "1s")),
+			LeaseDuration: parseDuration(getEnv("OUTBOX_LEASE_DURATION", "1m")),
+			MaxAttempts:   parseInt(getEnv("OUTBOX_MAX_ATTEMPTS", "5")),
+			BaseBackoff:   parseDuration(getEnv("OUTBOX_BASE_BACKOFF", "2s")),
+			MaxBackoff:    parseDuration(getEnv("OUTBOX_MAX_BACKOFF", "2m")),
+			Retention:     parseDuration(getEnv("OUTBOX_RETENTION", "24h")),
+		},
+		Voice: VoiceConfig{
+			Provider:        getEnv("VOICE_PROVIDER", "mock"),
+			DefaultLanguage: getEnv("VOICE_DEFAULT_LANGUAGE", "en"),
+			Repeat:          parseInt(getEnv("VOICE_REPEAT", "2")),
+		},
+		Phone: PhoneConfig{
+			DefaultRegion: getEnv("PHONE_DEFAULT_REGION", "AZ"),
+		},
+		Channels: ChannelConfig{
+			Default: getEnv("CHANNEL_DEFAULT", "sms"),
+			Routes:  parseChannelRoutes(getEnv("CHANNEL_ROUTES", "")),
+		},
+		Escalation: EscalationConfig{
+			Timeout: parseDuration(getEnv("ESCALATION_TIMEOUT", "0s")),
+			Chains:  parseEscalationChains(getEnv("ESCALATION_CHAINS", "sms=voice; whatsapp=voice; telegram=voice")),
+		},
+		WhatsApp: WhatsAppConfig{
+			Provider:         getEnv("WHATSAPP_PROVIDER", "mock"),
+			APIEndpoint:      getEnv("WHATSAPP_API_ENDPOINT", ""),
+			APIVersion:       getEnv("WHATSAPP_API_VERSION", "v21.0"),
+			PhoneNumberID:    getEnv("WHATSAPP_PHONE_NUMBER_ID", ""),
+			AccessToken:      getEnv("WHATSAPP_ACCESS_TOKEN", ""),
+			TemplateName:     getEnv("WHATSAPP_TEMPLATE_NAME", ""),
+			TemplateLanguage: getEnv("WHATSAPP_TEMPLATE_LANGUAGE", "en_US"),
+			Timeout:          parseDuration(getEnv("WHATSAPP_TIMEOUT", "10s")),
+		},
+		Telegram: TelegramConfig{
+			Provider:       getEnv("TELEGRAM_PROVIDER", "mock"),
+			APIEndpoint:    getEnv("TELEGRAM_API_ENDPOINT", ""),
+			AccessToken:    getEnv("TELEGRAM_ACCESS_TOKEN", ""),
+			SenderUsername: getEnv("TELEGRAM_SENDER_USERNAME", ""),
+			Timeout:        parseDuration(getEnv("TELEGRAM_TIMEOUT", "10s")),
+		},
+		Email: EmailConfig{
+			Provider: getEnv("EMAIL_PROVIDER", "mock"),
+			SMTPHost: getEnv("SMTP_HOST", ""),
+			SMTPPort: parseInt(getEnv("SMTP_PORT", "587")),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("EMAIL_FROM", ""),
+			TLS:      getEnv("SMTP_TLS", "starttls"),
+			Timeout:  parseDuration(getEnv("SMTP_TIMEOUT", "10s")),
+		},
+		Token: TokenConfig{
+			Algorithm:      getEnv("TOKEN_ALGORITHM", "HS256"),
+			Secret:         getEnv("TOKEN_SECRET", ""),
+			PrivateKey:     getEnv("TOKEN_PRIVATE_KEY", ""),
+			PrivateKeyFile: getEnv("TOKEN_PRIVATE_KEY_FILE", ""),
+			KeyID:          getEnv("TOKEN_KEY_ID", ""),
+			Issuer:         getEnv("TOKEN_ISSUER", "sms-otp-service"),
+			Audience:       getEnv("TOKEN_AUDIENCE", ""),
+			TTL:            parseDuration(getEnv("TOKEN_TTL", "5m")),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: parseDuration(getEnv("IDEMPOTENCY_TTL", "24h")),
+		},
+		Fraud: FraudConfig{
+			Enabled:            parseBool(getEnv("FRAUD_ENABLED", "true")),
+			Window:             parseDuration(getEnv("FRAUD_WINDOW", "1h")),
+			IPLimit:            parseInt(getEnv("FRAUD_IP_LIMIT", "30")),
+			PrefixLength:       parseInt(getEnv("FRAUD_PREFIX_LENGTH", "7")),
+			PrefixLimit:        parseInt(getEnv("FRAUD_PREFIX_LIMIT", "100")),
+			CountryLimit:       parseInt(getEnv("FRAUD_COUNTRY_LIMIT", "500")),
+			CountryLimits:      parseIntMap(getEnv("FRAUD_COUNTRY_LIMITS", "")),
+			ConversionWindow:   parseDuration(getEnv("FRAUD_CONVERSION_WINDOW", "6h")),
+			ConversionGrace:    parseDuration(getEnv("FRAUD_CONVERSION_GRACE", "10m")),
+			ConversionMinSends: parseInt(getEnv("FRAUD_CONVERSION_MIN_SENDS", "20")),
+			MinConversion:      parseFloat(getEnv("FRAUD_MIN_CONVERSION", "0.3")),
+			ChallengeScore:     parseInt(getEnv("FRAUD_CHALLENGE_SCORE", "40")),
+			BlockScore:         parseInt(getEnv("FRAUD_BLOCK_SCORE", "80")),
+			Retention:          parseDuration(getEnv("FRAUD_RETENTION", "168h")),
+			Challenge: ChallengeConfig{
+				Provider: getEnv("FRAUD_CHALLENGE_PROVIDER", "none"),
+				Secret:   getEnv("FRAUD_CHALLENGE_SECRET", ""),
+				Endpoint: getEnv("FRAUD_CHALLENGE_ENDPOINT", ""),
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Rules:        parseRateLimits(getEnv("RATE_LIMITS", "global=token_bucket:100/1s; ip=token_bucket:20/1m; prefix=sliding_window:300/1h; phone=sliding_window:5/10m")),
+			PrefixLength: parseInt(getEnv("RATE_LIMIT_PREFIX_LENGTH", "7")),
+		},
+		Limiter: LimiterConfig{
+			Store: getEnv("LIMITER_STORE", "memory"),
+			Redis: RedisConfig{
+				URL:       getEnv("REDIS_URL", "redis://localhost:6379/0"),
+				KeyPrefix: getEnv("REDIS_KEY_PREFIX", "sms-otp:"),
+				PoolSize:  parseInt(getEnv("REDIS_POOL_SIZE", "10")),
+				Timeout:   parseDuration(getEnv("REDIS_TIMEOUT", "2s")),
+			},
+		},
+		Admin: AdminConfig{
+			Token: getEnv("ADMIN_API_TOKEN", ""),
 		},
 		Logger: LoggerConfig{
 			Level:  getEnv("LOG_LEVEL", "info"),
@@ -99,10 +556,301 @@ func Load() (*Config, error) {
 	}
 
 	cfg.Database.DSN = buildDSN(cfg.Database)
+	cfg.OTP.Purposes = loadPurposes(cfg.OTP)
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
 
+func (c *Config) validate() error {
+	switch c.Token.Algorithm {
+	case "HS256", "RS256", "EdDSA":
+	default:
+		return fmt.Errorf("TOKEN_ALGORITHM must be HS256, RS256 or EdDSA, got %q", c.Token.Algorithm)
+	}
+	if c.Token.TTL <= 0 {
+		return fmt.Errorf("TOKEN_TTL must be positive")
+	}
+	if c.Idempotency.TTL <= 0 {
+		return fmt.Errorf("IDEMPOTENCY_TTL must be positive")
+	}
+	if c.OTP.HashKey == "" {
+		// Codes hashed under a throwaway key would not verify after a
+		// restart, and the migration of legacy plaintext codes would make
+		// them unverifiable for good.
+		return fmt.Errorf("OTP_HASH_KEY must be set")
+	}
+
+	if err := c.Fraud.validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := c.Limiter.validate(); err != nil {
+		return err
+	}
+
+	if !isChannel(c.Channels.Default) {
+		return fmt.Errorf("CHANNEL_DEFAULT must be sms, voice, whatsapp or telegram, got %q", c.Channels.Default)
+	}
+	for _, route := range c.Channels.Routes {
+		if !isChannel(route.Channel) {
+			return fmt.Errorf("CHANNEL_ROUTES has unknown channel %q for prefix +%s", route.Channel, route.Prefix)
+		}
+	}
+	if c.Escalation.Timeout < 0 {
+		return fmt.Errorf("ESCALATION_TIMEOUT must not be negative")
+	}
+	for from, chain := range c.Escalation.Chains {
+		if !isChannel(from) {
+			return fmt.Errorf("ESCALATION_CHAINS has unknown channel %q", from)
+		}
+		for _, to := range chain {
+			if !isChannel(to) {
+				return fmt.Errorf("ESCALATION_CHAINS has unknown channel %q for %s", to, from)
+			}
+		}
+	}
+	if c.WhatsApp.Provider == "cloud" && (c.WhatsApp.PhoneNumberID == "" || c.WhatsApp.AccessToken == "" || c.WhatsApp.TemplateName == "") {
+		return fmt.Errorf("WHATSAPP_PROVIDER=cloud requires WHATSAPP_PHONE_NUMBER_ID, WHATSAPP_ACCESS_TOKEN and WHATSAPP_TEMPLATE_NAME")
+	}
+	if c.Telegram.Provider == "gateway" && c.Telegram.AccessToken == "" {
+		return fmt.Errorf("TELEGRAM_PROVIDER=gateway requires TELEGRAM_ACCESS_TOKEN")
+	}
+
+	switch c.Email.TLS {
+	case "starttls", "implicit", "none":
+	default:
+		return fmt.Errorf("SMTP_TLS must be starttls, implicit or none, got %q", c.Email.TLS)
+	}
+	if c.Email.Provider == "smtp" && (c.Email.SMTPHost == "" || c.Email.From == "") {
+		return fmt.Errorf("EMAIL_PROVIDER=smtp requires SMTP_HOST and EMAIL_FROM")
+	}
+
+	if c.OTP.RateLimitMinutes < 1 || c.OTP.MaxOTPsPerPeriod < 1 || c.OTP.MaxVerifiesPerPeriod < 1 {
+		return fmt.Errorf("OTP_RATE_LIMIT_MINUTES, OTP_MAX_PER_PERIOD and OTP_MAX_VERIFIES_PER_PERIOD must be positive")
+	}
+	if err := c.OTP.Lockout.validate(); err != nil {
+		return err
+	}
+	if len(c.OTP.Purposes) == 0 {
+		return fmt.Errorf("OTP_PURPOSES must list at least one purpose")
+	}
+
+	for _, purpose := range c.OTP.Purposes {
+		prefix := purposeEnvPrefix(purpose.Name)
+		if len(purpose.Name) > 50 {
+			return fmt.Errorf("OTP purpose %q is longer than 50 characters", purpose.Name)
+		}
+		if purpose.CodeLength < 4 || purpose.CodeLength > 10 {
+			return fmt.Errorf("%sCODE_LENGTH must be between 4 and 10, got %d", prefix, purpose.CodeLength)
+		}
+		if err := validateAlphabet(purpose.Alphabet); err != nil {
+			return fmt.Errorf("%sALPHABET %w", prefix, err)
+		}
+		if purpose.TTL <= 0 {
+			return fmt.Errorf("%sTTL must be positive", prefix)
+		}
+		if purpose.MaxAttempts < 1 {
+			return fmt.Errorf("%sMAX_ATTEMPTS must be at least 1, got %d", prefix, purpose.MaxAttempts)
+		}
+		if len(purpose.ResendCooldowns) == 0 || slices.ContainsFunc(purpose.ResendCooldowns, func(d time.Duration) bool { return d < 0 }) {
+			return fmt.Errorf("%sRESEND_COOLDOWNS must list one or more durations, none negative", prefix)
+		}
+		if purpose.ResendCooldownReset < 0 {
+			return fmt.Errorf("%sRESEND_COOLDOWN_RESET must not be negative", prefix)
+		}
+		if _, err := template.New(purpose.Name).Parse(purpose.SMSTemplate); err != nil {
+			return fmt.Errorf("%sSMS_TEMPLATE is invalid: %w", prefix, err)
+		}
+	}
+	return nil
+}
+
+func (c LockoutConfig) validate() error {
+	if c.Threshold < 0 {
+		return fmt.Errorf("OTP_LOCKOUT_THRESHOLD must not be negative, got %d", c.Threshold)
+	}
+	if c.Threshold == 0 {
+		return nil
+	}
+	if c.Window <= 0 || c.Duration <= 0 || c.Reset <= 0 {
+		return fmt.Errorf("OTP_LOCKOUT_WINDOW, OTP_LOCKOUT_DURATION and OTP_LOCKOUT_RESET must be positive")
+	}
+	if c.MaxDuration < c.Duration {
+		return fmt.Errorf("OTP_LOCKOUT_MAX_DURATION must be at least OTP_LOCKOUT_DURATION")
+	}
+	return nil
+}
+
+func (c FraudConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Window <= 0 {
+		return fmt.Errorf("FRAUD_WINDOW must be positive")
+	}
+	if c.PrefixLength < 1 || c.PrefixLength > 15 {
+		return fmt.Errorf("FRAUD_PREFIX_LENGTH must be between 1 and 15, got %d", c.PrefixLength)
+	}
+	if c.IPLimit < 0 || c.PrefixLimit < 0 || c.CountryLimit < 0 || c.ConversionMinSends < 0 {
+		return fmt.Errorf("FRAUD_*_LIMIT and FRAUD_CONVERSION_MIN_SENDS must not be negative")
+	}
+	for region, limit := range c.CountryLimits {
+		if len(region) != 2 || limit < 0 {
+			return fmt.Errorf("FRAUD_COUNTRY_LIMITS has invalid entry %s=%d", region, limit)
+		}
+	}
+	if c.ConversionGrace < 0 || c.ConversionWindow <= c.ConversionGrace {
+		return fmt.Errorf("FRAUD_CONVERSION_WINDOW must be longer than FRAUD_CONVERSION_GRACE")
+	}
+	if c.MinConversion < 0 || c.MinConversion > 1 {
+		return fmt.Errorf("FRAUD_MIN_CONVERSION must be between 0 and 1, got %g", c.MinConversion)
+	}
+	if c.ChallengeScore < 1 || c.BlockScore < c.ChallengeScore {
+		return fmt.Errorf("FRAUD_CHALLENGE_SCORE must be positive and at most FRAUD_BLOCK_SCORE")
+	}
+	if c.Retention < c.Window || c.Retention < c.ConversionWindow {
+		return fmt.Errorf("FRAUD_RETENTION must cover FRAUD_WINDOW and FRAUD_CONVERSION_WINDOW")
+	}
+
+	switch c.Challenge.Provider {
+	case "none":
+	case "recaptcha", "hcaptcha", "turnstile":
+		if c.Challenge.Secret == "" {
+			return fmt.Errorf("FRAUD_CHALLENGE_PROVIDER=%s requires FRAUD_CHALLENGE_SECRET", c.Challenge.Provider)
+		}
+	default:
+		return fmt.Errorf("FRAUD_CHALLENGE_PROVIDER must be none, recaptcha, hcaptcha or turnstile, got %q", c.Challenge.Provider)
+	}
+	return nil
+}
+
+func (c RateLimitConfig) validate() error {
+	if c.PrefixLength < 1 || c.PrefixLength > 15 {
+		return fmt.Errorf("RATE_LIMIT_PREFIX_LENGTH must be between 1 and 15, got %d", c.PrefixLength)
+	}
+	for _, rule := range c.Rules {
+		switch rule.Scope {
+		case "global", "ip", "api_key", "phone", "prefix":
+		default:
+			return fmt.Errorf("RATE_LIMITS has unknown scope %q", rule.Scope)
+		}
+		switch rule.Algorithm {
+		case "token_bucket", "sliding_window":
+		default:
+			return fmt.Errorf("RATE_LIMITS has unknown algorithm %q for %s", rule.Algorithm, rule.Scope)
+		}
+		if rule.Limit < 1 || rule.Window < time.Second {
+			return fmt.Errorf("RATE_LIMITS needs a positive limit and a window of at least 1s for %s", rule.Scope)
+		}
+	}
+	return nil
+}
+
+func (c LimiterConfig) validate() error {
+	switch c.Store {
+	case "memory":
+	case "redis":
+		if c.Redis.URL == "" {
+			return fmt.Errorf("LIMITER_STORE=redis requires REDIS_URL")
+		}
+		if c.Redis.PoolSize < 1 {
+			return fmt.Errorf("REDIS_POOL_SIZE must be positive, got %d", c.Redis.PoolSize)
+		}
+		if c.Redis.Timeout <= 0 {
+			return fmt.Errorf("REDIS_TIMEOUT must be positive")
+		}
+	default:
+		return fmt.Errorf("LIMITER_STORE must be memory or redis, got %q", c.Store)
+	}
+	return nil
+}
+
+// defaultSMSTemplates are the messages of the built-in purposes.
+var defaultSMSTemplates = map[string]string{
+	"verification": "Your verification code is: {{.Code}}. Valid for {{.Minutes}} minutes. Do not share this code.",
+	"login":        "Your login code is: {{.Code}}. Valid for {{.Minutes}} minutes. Do not share this code.",
+	"reset":        "Your password reset code is: {{.Code}}. Valid for {{.Minutes}} minutes. Do not share this code.",
+}
+
+// defaultEmailSubjects are the email subjects of the built-in purposes.
+var defaultEmailSubjects = map[string]string{
+	"verification": "Your verification code",
+	"login":        "Your login code",
+	"reset":        "Your password reset code",
+}
+
+// loadPurposes builds the policy of every purpose listed in OTP_PURPOSES.
+// Each one can be tuned with OTP_PURPOSE_<NAME>_* variables, for example
+// OTP_PURPOSE_LOGIN_CODE_LENGTH=8.
+func loadPurposes(otp OTPConfig) []PurposeConfig {
+	alphabet := getEnv("OTP_ALPHABET", "0123456789")
+	// OTP_RESEND_COOLDOWN is the single cooldown of earlier versions.
+	cooldowns := getEnv("OTP_RESEND_COOLDOWNS", getEnv("OTP_RESEND_COOLDOWN", "30s,60s,120s,300s"))
+	cooldownReset := getEnv("OTP_RESEND_COOLDOWN_RESET", "1h")
+
+	var purposes []PurposeConfig
+	for _, name := range parseList(getEnv("OTP_PURPOSES", "verification,login,reset")) {
+		name = strings.ToLower(name)
+		prefix := purposeEnvPrefix(name)
+
+		smsTemplate, ok := defaultSMSTemplates[name]
+		if !ok {
+			smsTemplate = defaultSMSTemplates["verification"]
+		}
+
+		emailSubject, ok := defaultEmailSubjects[name]
+		if !ok {
+			emailSubject = defaultEmailSubjects["verification"]
+		}
+
+		purposes = append(purposes, PurposeConfig{
+			Name:                name,
+			CodeLength:          parseInt(getEnv(prefix+"CODE_LENGTH", strconv.Itoa(otp.CodeLength))),
+			Alphabet:            getEnv(prefix+"ALPHABET", alphabet),
+			TTL:                 parseDuration(getEnv(prefix+"TTL", strconv.Itoa(otp.ValidityMinutes)+"m")),
+			MaxAttempts:         parseInt(getEnv(prefix+"MAX_ATTEMPTS", strconv.Itoa(otp.MaxAttempts))),
+			ResendCooldowns:     parseDurations(getEnv(prefix+"RESEND_COOLDOWNS", getEnv(prefix+"RESEND_COOLDOWN", cooldowns))),
+			ResendCooldownReset: parseDuration(getEnv(prefix+"RESEND_COOLDOWN_RESET", cooldownReset)),
+			SMSTemplate:         getEnv(prefix+"SMS_TEMPLATE", smsTemplate),
+			EmailSubject:        getEnv(prefix+"EMAIL_SUBJECT", emailSubject),
+		})
+	}
+	return purposes
+}
+
+func purposeEnvPrefix(name string) string {
+	upper := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+	return "OTP_PURPOSE_" + upper + "_"
+}
+
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return fmt.Errorf("must contain at least two characters")
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, r := range alphabet {
+		if r > unicode.MaxASCII || unicode.IsSpace(r) {
+			return fmt.Errorf("must only contain printable ASCII characters")
+		}
+		if seen[r] {
+			return fmt.Errorf("contains %q more than once", r)
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -118,6 +866,22 @@ func parseInt(s string) int {
 	return i
 }
 
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
 func parseDuration(s string) time.Duration {
 	d, err := time.ParseDuration(s)
 	if err != nil {
@@ -126,6 +890,188 @@ func parseDuration(s string) time.Duration {
 	return d
 }
 
+// parseDurations parses a comma separated list of durations such as
+// "30s,1m". Invalid entries become -1, which validation rejects.
+func parseDurations(s string) []time.Duration {
+	var durations []time.Duration
+	for _, part := range parseList(s) {
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			d = -1
+		}
+		durations = append(durations, d)
+	}
+	return durations
+}
+
+// parseList parses a comma separated list, dropping empty entries.
+func parseList(s string) []string {
+	var values []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// parseRoutes parses a routing table such as
+// "+994=smpp; +90=twilio:70,webhook:30". Targets without a weight get 1.
+// The default route, if set, matches every destination.
+func parseRoutes(s, defaultRoute string) []SMSRoute {
+	var routes []SMSRoute
+	for _, entry := range strings.Split(s, ";") {
+		prefix, targets, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		route := SMSRoute{
+			Prefix:  strings.TrimPrefix(strings.TrimSpace(prefix), "+"),
+			Targets: parseRouteTargets(targets),
+		}
+		if route.Prefix != "" && len(route.Targets) > 0 {
+			routes = append(routes, route)
+		}
+	}
+
+	if targets := parseRouteTargets(defaultRoute); len(targets) > 0 {
+		routes = append(routes, SMSRoute{Targets: targets})
+	}
+
+	return routes
+}
+
+func isChannel(name string) bool {
+	switch name {
+	case "sms", "voice", "whatsapp", "telegram":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseChannelRoutes parses a channel routing table such as
+// "+994=whatsapp; +7=telegram".
+func parseChannelRoutes(s string) []ChannelRoute {
+	var routes []ChannelRoute
+	for _, entry := range strings.Split(s, ";") {
+		prefix, channel, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		route := ChannelRoute{
+			Prefix:  strings.TrimPrefix(strings.TrimSpace(prefix), "+"),
+			Channel: strings.TrimSpace(channel),
+		}
+		if route.Prefix != "" && route.Channel != "" {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// parseEscalationChains parses escalation chains such as
+// "sms=voice; whatsapp=sms,voice".
+func parseEscalationChains(s string) map[string][]string {
+	chains := make(map[string][]string)
+	for _, entry := range strings.Split(s, ";") {
+		from, to, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		if from = strings.TrimSpace(from); from != "" {
+			chains[from] = parseList(to)
+		}
+	}
+	return chains
+}
+
+func parseRouteTargets(s string) []SMSRouteTarget {
+	var targets []SMSRouteTarget
+	for _, part := range parseList(s) {
+		provider, weight, hasWeight := strings.Cut(part, ":")
+		target := SMSRouteTarget{Provider: strings.TrimSpace(provider), Weight: 1}
+		if hasWeight {
+			target.Weight = parseInt(strings.TrimSpace(weight))
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// parseIntList parses a comma separated list such as "200,201,202".
+func parseIntList(s string) []int {
+	var values []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if i, err := strconv.Atoi(part); err == nil {
+			values = append(values, i)
+		}
+	}
+	return values
+}
+
+// parseMap parses "key=value" pairs separated by commas.
+func parseMap(s string) map[string]string {
+	values := make(map[string]string)
+	for _, part := range parseList(s) {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+// parseRateLimits parses rate limit rules such as
+// "ip=token_bucket:20/1m; phone=sliding_window:5/10m". Entries that cannot
+// be read get a zero limit, which validation rejects.
+func parseRateLimits(s string) []RateLimitRule {
+	var rules []RateLimitRule
+	for _, entry := range strings.Split(s, ";") {
+		scope, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		algorithm, rate, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		limit, window, _ := strings.Cut(rate, "/")
+		rules = append(rules, RateLimitRule{
+			Scope:     strings.TrimSpace(scope),
+			Algorithm: strings.TrimSpace(algorithm),
+			Limit:     parseInt(strings.TrimSpace(limit)),
+			Window:    parseDuration(strings.TrimSpace(window)),
+		})
+	}
+	return rules
+}
+
+// parseIntMap parses "KEY=number" pairs separated by commas, such as
+// "AZ=5000,GB=1000". Keys are upper-cased.
+func parseIntMap(s string) map[string]int {
+	values := make(map[string]int)
+	for key, value := range parseMap(s) {
+		values[strings.ToUpper(key)] = parseInt(value)
+	}
+	return values
+}
+
+// parseHeaders parses "Name: value" pairs separated by semicolons.
+func parseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
 func buildDSN(cfg DatabaseConfig) string {
 	return "host=" + cfg.Host +
 		" port=" + cfg.Port +
@@ -57,13 +57,27 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	return &Database{DB: db}, nil
 }
 
-func (d *Database) AutoMigrate() error {
+func (d *Database) AutoMigrate(codeHasher entities.CodeHasher) error {
 	logrus.Info("Starting database migration...")
 
-	if err := d.DB.AutoMigrate(&entities.OTP{}); err != nil {
+	if err := d.renamePhoneColumns(); err != nil {
+		return fmt.Errorf("failed to rename phone number columns: %w", err)
+	}
+
+	// Number rules became unique per client; the index without the client
+	// is replaced.
+	if err := d.DB.Exec("DROP INDEX IF EXISTS idx_number_rules_rule").Error; err != nil {
+		return fmt.Errorf("failed to drop number rule index: %w", err)
+	}
+
+	if err := d.DB.AutoMigrate(&entities.OTP{}, &entities.OutboxMessage{}, &entities.DeliveryEvent{}, &entities.IdempotencyRecord{}, &entities.NumberRule{}, &entities.SendAttempt{}); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := d.hashPlaintextCodes(codeHasher); err != nil {
+		return fmt.Errorf("failed to hash plaintext otp codes: %w", err)
+	}
+
 	if err := d.createIndexes(); err != nil {
 		return fmt.Errorf("failed to create indexes: %w", err)
 	}
@@ -72,12 +86,84 @@ func (d *Database) AutoMigrate() error {
 	return nil
 }
 
+// hashPlaintextCodes migrates OTPs created before codes were hashed: it
+// hashes the legacy plaintext code column into code_hash and drops it, so
+// codes issued before the upgrade can still be verified.
+func (d *Database) hashPlaintextCodes(codeHasher entities.CodeHasher) error {
+	if !d.DB.Migrator().HasColumn(&entities.OTP{}, "code") {
+		return nil
+	}
+
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		type legacyOTP struct {
+			ID   string
+			Code string
+		}
+
+		var batch []legacyOTP
+		migrated := 0
+		err := tx.Table("otps").
+			Select("id, code").
+			Where("code IS NOT NULL AND code_hash = ''").
+			FindInBatches(&batch, 500, func(batchTx *gorm.DB, _ int) error {
+				for _, otp := range batch {
+					err := tx.Table("otps").
+						Where("id = ?", otp.ID).
+						Update("code_hash", codeHasher.Hash(otp.ID, otp.Code)).Error
+					if err != nil {
+						return err
+					}
+				}
+				migrated += len(batch)
+				return nil
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Migrator().DropColumn(&entities.OTP{}, "code"); err != nil {
+			return err
+		}
+
+		logrus.WithField("otps", migrated).Info("Hashed plaintext OTP codes")
+		return nil
+	})
+}
+
+// renamePhoneColumns migrates tables created before OTPs could be sent to
+// email addresses: their phone_number column becomes recipient, and existing
+// OTPs keep the default phone recipient type.
+func (d *Database) renamePhoneColumns() error {
+	tables := []any{&entities.OTP{}, &entities.OutboxMessage{}, &entities.DeliveryEvent{}}
+
+	migrator := d.DB.Migrator()
+	for _, table := range tables {
+		if !migrator.HasTable(table) || !migrator.HasColumn(table, "phone_number") || migrator.HasColumn(table, "recipient") {
+			continue
+		}
+		if err := migrator.RenameColumn(table, "phone_number", "recipient"); err != nil {
+			return err
+		}
+	}
+
+	for _, index := range []string{"idx_otps_phone_purpose", "idx_otps_phone_expires"} {
+		if err := d.DB.Exec("DROP INDEX IF EXISTS " + index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *Database) createIndexes() error {
 	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_otps_phone_purpose ON otps(phone_number, purpose)",
-		"CREATE INDEX IF NOT EXISTS idx_otps_phone_expires ON otps(phone_number, expires_at)",
+		"CREATE INDEX IF NOT EXISTS idx_otps_recipient_purpose ON otps(recipient, purpose)",
+		"CREATE INDEX IF NOT EXISTS idx_otps_recipient_expires ON otps(recipient, expires_at)",
 		"CREATE INDEX IF NOT EXISTS idx_otps_created_at ON otps(created_at)",
 		"CREATE INDEX IF NOT EXISTS idx_otps_verified ON otps(is_verified, expires_at)",
+		"CREATE INDEX IF NOT EXISTS idx_sms_outbox_due ON sms_outbox(status, next_attempt_at)",
+		"CREATE INDEX IF NOT EXISTS idx_otps_provider_message ON otps(delivery_provider, provider_message_id)",
+		"CREATE INDEX IF NOT EXISTS idx_delivery_events_message ON otp_delivery_events(provider, provider_message_id)",
+		"CREATE INDEX IF NOT EXISTS idx_delivery_events_occurred_at ON otp_delivery_events(occurred_at)",
 	}
 
 	for _, index := range indexes {
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"os"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/pkg/utils"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDatabase connects to the database in TEST_DATABASE_DSN, skipping
+// the test without one. The database is disposable: tests drop its tables.
+func openTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	d := &Database{DB: db}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestHashPlaintextCodes(t *testing.T) {
+	d := openTestDatabase(t)
+	if err := d.DB.Migrator().DropTable(&entities.OTP{}); err != nil {
+		t.Fatalf("drop otps: %v", err)
+	}
+
+	// A table from before codes were hashed.
+	if err := d.DB.AutoMigrate(&entities.OTP{}); err != nil {
+		t.Fatalf("migrate otps: %v", err)
+	}
+	if err := d.DB.Exec("ALTER TABLE otps ADD COLUMN code varchar(10)").Error; err != nil {
+		t.Fatalf("add code column: %v", err)
+	}
+	codes := map[uuid.UUID]string{uuid.New(): "123456", uuid.New(): "654321"}
+	for id, code := range codes {
+		err := d.DB.Exec("INSERT INTO otps (id, recipient, code, expires_at) VALUES (?, ?, ?, now() + interval '5 minutes')",
+			id, "+14155550100", code).Error
+		if err != nil {
+			t.Fatalf("insert legacy otp: %v", err)
+		}
+	}
+
+	hasher := utils.NewCodeHasher([]byte("test"))
+	if err := d.AutoMigrate(hasher); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	if d.DB.Migrator().HasColumn(&entities.OTP{}, "code") {
+		t.Error("plaintext code column was not dropped")
+	}
+
+	for id, code := range codes {
+		var otp entities.OTP
+		if err := d.DB.First(&otp, "id = ?", id).Error; err != nil {
+			t.Fatalf("load otp: %v", err)
+		}
+		if err := otp.Verify(code, hasher); err != nil {
+			t.Errorf("Verify(%s) after the migration = %v", code, err)
+		}
+	}
+
+	// Later startups find nothing left to migrate.
+	if err := d.AutoMigrate(hasher); err != nil {
+		t.Fatalf("AutoMigrate again: %v", err)
+	}
+}
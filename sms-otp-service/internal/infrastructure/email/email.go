@@ -0,0 +1,84 @@
+// Package email delivers OTP codes by email. Messages are composed when the
+// OTP is issued and queued as a header block and body; the sender adds the
+// envelope headers at delivery. Failures are reported with the typed errors
+// of package sms so the outbox treats every channel alike.
+package email
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"mime"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"strings"
+)
+
+// Service sends a composed message to an email address.
+type Service interface {
+	SendEmail(ctx context.Context, to, message string) (*entities.SendResult, error)
+}
+
+func NewEmailService(cfg config.EmailConfig, logger *logrus.Logger) (Service, error) {
+	switch cfg.Provider {
+	case "mock":
+		return NewMockService(logger), nil
+	case "smtp":
+		return NewSMTPService(cfg, logger)
+	default:
+		return nil, fmt.Errorf("unknown email provider %q", cfg.Provider)
+	}
+}
+
+// Composer renders OTP emails as plain text.
+type Composer struct{}
+
+func NewComposer() *Composer {
+	return &Composer{}
+}
+
+// Compose returns a message with the given subject and a UTF-8 text body.
+// The subject is encoded as needed, so it cannot inject headers.
+func (c *Composer) Compose(subject, body string) string {
+	var message strings.Builder
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(toCRLF(body))
+	message.WriteString("\r\n")
+	return message.String()
+}
+
+// toCRLF converts every line ending of s to CRLF, as SMTP requires.
+func toCRLF(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+type mockService struct {
+	logger *logrus.Logger
+}
+
+// NewMockService logs emails instead of sending them.
+func NewMockService(logger *logrus.Logger) Service {
+	return &mockService{
+		logger: logger,
+	}
+}
+
+func (s *mockService) SendEmail(ctx context.Context, to, message string) (*entities.SendResult, error) {
+	s.logger.WithFields(logrus.Fields{
+		"email": to,
+	}).Info("📧 Mock email sent")
+
+	fmt.Printf("\n=== MOCK EMAIL ===\n")
+	fmt.Printf("To: %s\n", to)
+	fmt.Printf("%s", strings.ReplaceAll(message, "\r\n", "\n"))
+	fmt.Printf("==================\n\n")
+
+	return &entities.SendResult{Provider: "mock-email", MessageID: uuid.NewString()}, nil
+}
@@ -0,0 +1,349 @@
+// Package emailtest provides a local stand-in for SMTP servers so the
+// service in package email, and the OTP flows built on it, can be exercised
+// without network access.
+package emailtest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"sms-otp-service/internal/testutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Message is an email accepted by the SMTPServer.
+type Message struct {
+	From    string
+	To      []string
+	Header  mail.Header
+	Subject string
+	Body    string
+	// TLS reports whether the message was sent after STARTTLS.
+	TLS bool
+}
+
+// SMTPError is a canned reply returned by the SMTPServer, such as
+// {Code: 452, Message: "4.5.3 Too many recipients"}.
+type SMTPError struct {
+	Code    int
+	Message string
+}
+
+// SMTPServer is an in-process SMTP server on the loopback interface. It
+// speaks enough of the protocol for net/smtp: EHLO, STARTTLS, AUTH PLAIN,
+// MAIL, RCPT, DATA, RSET, NOOP and QUIT. STARTTLS is only offered after
+// OfferSTARTTLS. Queued failures are replies to the next MAIL command.
+type SMTPServer struct {
+	testutil.Recorder[Message, SMTPError]
+
+	Host string
+	Port int
+
+	listener net.Listener
+	username string
+	password string
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	tlsConfig *tls.Config
+	rejected  map[string]bool
+	conns     map[net.Conn]bool
+}
+
+// NewSMTPServer starts a stand-in, shut down when t ends, that requires
+// AUTH PLAIN with the given credentials, or no authentication if username
+// is empty.
+func NewSMTPServer(t testing.TB, username, password string) *SMTPServer {
+	listener := testutil.Listen(t)
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &SMTPServer{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		listener: listener,
+		username: username,
+		password: password,
+		rejected: make(map[string]bool),
+		conns:    make(map[net.Conn]bool),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close stops the server and drops open sessions.
+func (s *SMTPServer) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// OfferSTARTTLS makes the server offer STARTTLS with a self-signed
+// certificate for its loopback address, and hold back AUTH until the session
+// is encrypted. It returns the pool clients must trust to verify the
+// certificate.
+func (s *SMTPServer) OfferSTARTTLS(t testing.TB) *x509.CertPool {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "emailtest"},
+		IPAddresses:           []net.IP{net.ParseIP(s.Host)},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	s.mu.Lock()
+	s.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}},
+	}
+	s.mu.Unlock()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return pool
+}
+
+// RejectRecipient makes the server refuse RCPT for address with 550, as for
+// a mailbox that does not exist.
+func (s *SMTPServer) RejectRecipient(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[strings.ToLower(address)] = true
+}
+
+func (s *SMTPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.session(conn)
+		}()
+	}
+}
+
+type session struct {
+	encrypted     bool
+	authenticated bool
+	from          string
+	to            []string
+}
+
+func (s *SMTPServer) session(raw net.Conn) {
+	conn := textproto.NewConn(raw)
+	reply := func(code int, message string) {
+		conn.PrintfLine("%d %s", code, message)
+	}
+
+	s.mu.Lock()
+	tlsConfig := s.tlsConfig
+	s.mu.Unlock()
+
+	reply(220, "emailtest ESMTP ready")
+
+	var state session
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			conn.PrintfLine("250-emailtest")
+			if tlsConfig != nil && !state.encrypted {
+				conn.PrintfLine("250-STARTTLS")
+				reply(250, "8BITMIME")
+				continue
+			}
+			conn.PrintfLine("250-8BITMIME")
+			reply(250, "AUTH PLAIN")
+		case "HELO":
+			reply(250, "emailtest")
+		case "STARTTLS":
+			if tlsConfig == nil || state.encrypted {
+				reply(502, "5.5.2 Command not implemented")
+				continue
+			}
+			reply(220, "2.0.0 Ready to start TLS")
+			tlsConn := tls.Server(raw, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			// The session starts over on the encrypted connection.
+			conn = textproto.NewConn(tlsConn)
+			state = session{encrypted: true}
+		case "AUTH":
+			if tlsConfig != nil && !state.encrypted {
+				reply(538, "5.7.11 Encryption required for requested authentication mechanism")
+				continue
+			}
+			if s.authenticate(arg) {
+				state.authenticated = true
+				reply(235, "2.7.0 Authentication successful")
+			} else {
+				reply(535, "5.7.8 Authentication credentials invalid")
+			}
+		case "MAIL":
+			if s.username != "" && !state.authenticated {
+				reply(530, "5.7.0 Authentication required")
+				continue
+			}
+			if failure, ok := s.NextFailure(); ok {
+				reply(failure.Code, failure.Message)
+				continue
+			}
+			state = session{encrypted: state.encrypted, authenticated: state.authenticated, from: parsePath(arg, "FROM:")}
+			reply(250, "2.1.0 OK")
+		case "RCPT":
+			if state.from == "" {
+				reply(503, "5.5.1 MAIL first")
+				continue
+			}
+			to := parsePath(arg, "TO:")
+			if s.isRejected(to) {
+				reply(550, "5.1.1 Mailbox does not exist")
+				continue
+			}
+			state.to = append(state.to, to)
+			reply(250, "2.1.5 OK")
+		case "DATA":
+			if len(state.to) == 0 {
+				reply(503, "5.5.1 RCPT first")
+				continue
+			}
+			reply(354, "Start mail input; end with <CRLF>.<CRLF>")
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			if err := s.record(state, data); err != nil {
+				reply(554, "5.6.0 "+err.Error())
+			} else {
+				reply(250, "2.0.0 OK queued")
+			}
+			state = session{encrypted: state.encrypted, authenticated: state.authenticated}
+		case "RSET":
+			state = session{encrypted: state.encrypted, authenticated: state.authenticated}
+			reply(250, "2.0.0 OK")
+		case "NOOP":
+			reply(250, "2.0.0 OK")
+		case "QUIT":
+			reply(221, "2.0.0 Bye")
+			return
+		default:
+			reply(502, "5.5.2 Command not implemented")
+		}
+	}
+}
+
+// authenticate checks an AUTH PLAIN command with an initial response.
+func (s *SMTPServer) authenticate(arg string) bool {
+	mechanism, response, _ := strings.Cut(arg, " ")
+	if !strings.EqualFold(mechanism, "PLAIN") {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(response)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(string(decoded), "\x00")
+	return len(parts) == 3 && parts[1] == s.username && parts[2] == s.password
+}
+
+func (s *SMTPServer) isRejected(address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rejected[strings.ToLower(address)]
+}
+
+func (s *SMTPServer) record(state session, data []byte) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return err
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return err
+	}
+
+	s.Record(func(int) Message {
+		return Message{
+			From:    state.from,
+			To:      state.to,
+			Header:  msg.Header,
+			Subject: subject,
+			Body:    string(body),
+			TLS:     state.encrypted,
+		}
+	})
+	return nil
+}
+
+// parsePath extracts the address of a "FROM:<address>" or "TO:<address>"
+// argument, ignoring any parameters after it.
+func parsePath(arg, prefix string) string {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return ""
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if end := strings.IndexByte(path, '>'); strings.HasPrefix(path, "<") && end > 0 {
+		return path[1:end]
+	}
+	return path
+}
@@ -0,0 +1,199 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sms-otp-service/internal/infrastructure/sms"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type smtpService struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     *mail.Address
+	tls      string
+	timeout  time.Duration
+	logger   *logrus.Logger
+
+	// tlsConfig holds the TLS settings before the server name is set. It is
+	// nil in production; tests set it to trust their own certificates.
+	tlsConfig *tls.Config
+}
+
+// NewSMTPService sends emails through an SMTP submission server. TLS
+// "starttls" requires the server to offer STARTTLS, "implicit" connects over
+// TLS, and "none" sends in the clear, which is only meant for local relays.
+func NewSMTPService(cfg config.EmailConfig, logger *logrus.Logger) (Service, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_FROM %q: %w", cfg.From, err)
+	}
+
+	return &smtpService{
+		addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		host:     cfg.SMTPHost,
+		username: cfg.Username,
+		password: cfg.Password,
+		from:     from,
+		tls:      cfg.TLS,
+		timeout:  cfg.Timeout,
+		logger:   logger,
+	}, nil
+}
+
+func (s *smtpService) SendEmail(ctx context.Context, to, message string) (*entities.SendResult, error) {
+	messageID := s.newMessageID()
+	headers := strings.Join([]string{
+		"From: " + s.from.String(),
+		"To: " + (&mail.Address{Address: to}).String(),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + messageID + ">",
+	}, "\r\n") + "\r\n"
+
+	if err := s.send(ctx, to, []byte(headers+message)); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"email":      to,
+		"message_id": messageID,
+	}).Info("Email accepted by SMTP server")
+
+	return &entities.SendResult{Provider: "smtp", MessageID: messageID}, nil
+}
+
+func (s *smtpService) send(ctx context.Context, to string, message []byte) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return &sms.ProviderError{Provider: "smtp", Message: err.Error(), Err: sms.ErrProviderUnavailable}
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return smtpError("connect", err)
+	}
+	defer client.Close()
+
+	if s.tls == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return &sms.ProviderError{Provider: "smtp", Message: "server does not support STARTTLS", Err: sms.ErrProviderUnavailable}
+		}
+		if err := client.StartTLS(s.newTLSConfig()); err != nil {
+			return smtpError("starttls", err)
+		}
+	}
+
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return smtpError("auth", err)
+		}
+	}
+
+	if err := client.Mail(s.from.Address); err != nil {
+		return smtpError("mail", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return smtpError("rcpt", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return smtpError("data", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return smtpError("data", err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError("data", err)
+	}
+
+	// The message is accepted once DATA completes, so a failed QUIT is not
+	// reported.
+	_ = client.Quit()
+	return nil
+}
+
+// dial connects to the server, bounding the whole session by the timeout
+// and the context deadline.
+func (s *smtpService) dial(ctx context.Context) (net.Conn, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if s.tls == "implicit" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.newTLSConfig()}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// newTLSConfig returns the TLS settings that verify the server as host.
+func (s *smtpService) newTLSConfig() *tls.Config {
+	cfg := &tls.Config{}
+	if s.tlsConfig != nil {
+		cfg = s.tlsConfig.Clone()
+	}
+	cfg.ServerName = s.host
+	return cfg
+}
+
+func (s *smtpService) newMessageID() string {
+	domain := s.host
+	if _, d, ok := strings.Cut(s.from.Address, "@"); ok {
+		domain = d
+	}
+	return uuid.NewString() + "@" + domain
+}
+
+// smtpError maps an SMTP reply to a typed error. Mailbox errors are only
+// permanent when the server rejects the recipient.
+func smtpError(stage string, err error) error {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return &sms.ProviderError{Provider: "smtp", Message: stage + ": " + err.Error(), Err: sms.ErrProviderUnavailable}
+	}
+
+	providerErr := &sms.ProviderError{Provider: "smtp", Code: strconv.Itoa(reply.Code), Message: stage + ": " + reply.Msg}
+	switch {
+	case reply.Code == 530 || reply.Code == 534 || reply.Code == 535 || reply.Code == 538:
+		providerErr.Err = sms.ErrAuthentication
+	case stage == "rcpt" && (reply.Code == 550 || reply.Code == 551 || reply.Code == 553):
+		providerErr.Err = sms.ErrInvalidNumber
+	case reply.Code == 421:
+		providerErr.Err = sms.ErrProviderUnavailable
+	case reply.Code >= 400 && reply.Code < 500:
+		providerErr.Err = sms.ErrThrottled
+	default:
+		providerErr.Err = sms.ErrRejected
+	}
+	return providerErr
+}
@@ -0,0 +1,284 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"sms-otp-service/internal/infrastructure/config"
+	"sms-otp-service/internal/infrastructure/email/emailtest"
+	"sms-otp-service/internal/infrastructure/sms"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testUser      = "otp"
+	testPassword  = "secret"
+	testFrom      = "Acme <no-reply@acme.example>"
+	testRecipient = "user@example.com"
+)
+
+// newTestService returns an SMTP service for server that trusts roots, or
+// the system roots when roots is nil.
+func newTestService(t *testing.T, server *emailtest.SMTPServer, tlsMode, username, password string, roots *x509.CertPool) *smtpService {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	service, err := NewSMTPService(config.EmailConfig{
+		SMTPHost: server.Host,
+		SMTPPort: server.Port,
+		Username: username,
+		Password: password,
+		From:     testFrom,
+		TLS:      tlsMode,
+		Timeout:  5 * time.Second,
+	}, logger)
+	if err != nil {
+		t.Fatalf("NewSMTPService: %v", err)
+	}
+
+	s := service.(*smtpService)
+	if roots != nil {
+		s.tlsConfig = &tls.Config{RootCAs: roots}
+	}
+	return s
+}
+
+func TestSMTPSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      string
+		username string
+	}{
+		{name: "plain relay", tls: "none"},
+		{name: "plain with auth", tls: "none", username: testUser},
+		{name: "starttls with auth", tls: "starttls", username: testUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := emailtest.NewSMTPServer(t, tt.username, testPassword)
+			var roots *x509.CertPool
+			if tt.tls == "starttls" {
+				roots = server.OfferSTARTTLS(t)
+			}
+			service := newTestService(t, server, tt.tls, tt.username, testPassword, roots)
+
+			message := NewComposer().Compose("Your Acme code", "Your verification code is: 123456.")
+			result, err := service.SendEmail(context.Background(), testRecipient, message)
+			if err != nil {
+				t.Fatalf("SendEmail: %v", err)
+			}
+
+			messages := server.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("server got %d messages, want 1", len(messages))
+			}
+			msg := messages[0]
+			if msg.TLS != (tt.tls == "starttls") {
+				t.Errorf("sent over TLS = %v with TLS mode %s", msg.TLS, tt.tls)
+			}
+			if msg.From != "no-reply@acme.example" || len(msg.To) != 1 || msg.To[0] != testRecipient {
+				t.Errorf("envelope from %q to %v", msg.From, msg.To)
+			}
+			if msg.Subject != "Your Acme code" || msg.Body != "Your verification code is: 123456.\n" {
+				t.Errorf("server got subject %q and body %q", msg.Subject, msg.Body)
+			}
+
+			if result.Provider != "smtp" {
+				t.Errorf("provider = %q, want smtp", result.Provider)
+			}
+			if want := "<" + result.MessageID + ">"; msg.Header.Get("Message-Id") != want {
+				t.Errorf("Message-ID header = %q, want %q", msg.Header.Get("Message-Id"), want)
+			}
+			if !strings.HasSuffix(result.MessageID, "@acme.example") {
+				t.Errorf("message ID %q is not in the sender's domain", result.MessageID)
+			}
+		})
+	}
+}
+
+func TestSMTPHeaders(t *testing.T) {
+	server := emailtest.NewSMTPServer(t, "", "")
+	service := newTestService(t, server, "none", "", "", nil)
+
+	// A subject cannot smuggle in headers, and bare line feeds in the body
+	// are sent as CRLF, which the server reads back as lines.
+	message := NewComposer().Compose("Código\r\nBcc: attacker@evil.example", "line one\nline two")
+	before := time.Now().Truncate(time.Second)
+	if _, err := service.SendEmail(context.Background(), testRecipient, message); err != nil {
+		t.Fatalf("SendEmail: %v", err)
+	}
+
+	header := server.Messages()[0].Header
+	from, err := header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != "Acme" || from[0].Address != "no-reply@acme.example" {
+		t.Errorf("From = %v, %v", from, err)
+	}
+	to, err := header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Address != testRecipient {
+		t.Errorf("To = %v, %v", to, err)
+	}
+	if date, err := header.Date(); err != nil || date.Before(before) {
+		t.Errorf("Date = %v, %v", date, err)
+	}
+	if header.Get("Bcc") != "" {
+		t.Errorf("subject injected a Bcc header")
+	}
+	if header.Get("Content-Type") != "text/plain; charset=utf-8" || header.Get("Mime-Version") != "1.0" {
+		t.Errorf("content headers = %q, %q", header.Get("Content-Type"), header.Get("Mime-Version"))
+	}
+
+	msg := server.Messages()[0]
+	if msg.Subject != "Código\r\nBcc: attacker@evil.example" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	if msg.Body != "line one\nline two\n" {
+		t.Errorf("body = %q", msg.Body)
+	}
+}
+
+func TestSMTPErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		tls       string
+		username  string
+		password  string
+		starttls  bool
+		trust     bool
+		setup     func(*emailtest.SMTPServer)
+		want      error
+		wantCode  string
+		permanent bool
+	}{
+		{
+			name:     "wrong password",
+			tls:      "none",
+			username: testUser,
+			password: "wrong",
+			want:     sms.ErrAuthentication,
+			wantCode: "535",
+		},
+		{
+			name:     "credentials missing",
+			tls:      "none",
+			want:     sms.ErrAuthentication,
+			wantCode: "530",
+		},
+		{
+			name:     "starttls not offered",
+			tls:      "starttls",
+			username: testUser,
+			password: testPassword,
+			want:     sms.ErrProviderUnavailable,
+		},
+		{
+			name:     "untrusted certificate",
+			tls:      "starttls",
+			username: testUser,
+			password: testPassword,
+			starttls: true,
+			want:     sms.ErrProviderUnavailable,
+		},
+		{
+			name:     "auth held back until starttls",
+			tls:      "none",
+			username: testUser,
+			password: testPassword,
+			starttls: true,
+			trust:    true,
+			want:     sms.ErrAuthentication,
+			wantCode: "538",
+		},
+		{
+			name:      "unknown mailbox",
+			tls:       "starttls",
+			username:  testUser,
+			password:  testPassword,
+			starttls:  true,
+			trust:     true,
+			setup:     func(s *emailtest.SMTPServer) { s.RejectRecipient(testRecipient) },
+			want:      sms.ErrInvalidNumber,
+			wantCode:  "550",
+			permanent: true,
+		},
+		{
+			name:     "greylisted",
+			tls:      "none",
+			username: testUser,
+			password: testPassword,
+			setup: func(s *emailtest.SMTPServer) {
+				s.FailNext(emailtest.SMTPError{Code: 451, Message: "4.7.1 Try again later"})
+			},
+			want:     sms.ErrThrottled,
+			wantCode: "451",
+		},
+		{
+			name:     "shutting down",
+			tls:      "none",
+			username: testUser,
+			password: testPassword,
+			setup: func(s *emailtest.SMTPServer) {
+				s.FailNext(emailtest.SMTPError{Code: 421, Message: "4.3.2 Service shutting down"})
+			},
+			want:     sms.ErrProviderUnavailable,
+			wantCode: "421",
+		},
+		{
+			name:     "sender rejected",
+			tls:      "none",
+			username: testUser,
+			password: testPassword,
+			setup: func(s *emailtest.SMTPServer) {
+				s.FailNext(emailtest.SMTPError{Code: 550, Message: "5.7.1 Sender not allowed"})
+			},
+			want:     sms.ErrRejected,
+			wantCode: "550",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := emailtest.NewSMTPServer(t, testUser, testPassword)
+			var roots *x509.CertPool
+			if tt.starttls {
+				roots = server.OfferSTARTTLS(t)
+			}
+			if !tt.trust {
+				roots = nil
+			}
+			if tt.setup != nil {
+				tt.setup(server)
+			}
+			service := newTestService(t, server, tt.tls, tt.username, tt.password, roots)
+
+			_, err := service.SendEmail(context.Background(), testRecipient, NewComposer().Compose("Code", "123456"))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SendEmail error = %v, want %v", err, tt.want)
+			}
+			var providerErr *sms.ProviderError
+			if !errors.As(err, &providerErr) || providerErr.Provider != "smtp" || providerErr.Code != tt.wantCode {
+				t.Errorf("SendEmail error = %+v, want smtp code %q", err, tt.wantCode)
+			}
+			if providerErr != nil && providerErr.Permanent() != tt.permanent {
+				t.Errorf("permanent = %v, want %v", providerErr.Permanent(), tt.permanent)
+			}
+			if len(server.Messages()) != 0 {
+				t.Errorf("server accepted %d messages, want none", len(server.Messages()))
+			}
+		})
+	}
+}
+
+func TestNewSMTPServiceInvalidFrom(t *testing.T) {
+	_, err := NewSMTPService(config.EmailConfig{SMTPHost: "localhost", SMTPPort: 25, From: "not an address"}, logrus.New())
+	if err == nil {
+		t.Fatal("NewSMTPService accepted an invalid sender address")
+	}
+}
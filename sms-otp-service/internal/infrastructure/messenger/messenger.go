@@ -0,0 +1,69 @@
+// Package messenger delivers OTP codes through messenger apps. Unlike SMS,
+// the apps render the code into their own verification templates, so the
+// services take the bare code. Failures are reported with the typed errors
+// of package sms so the outbox treats every channel alike.
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+)
+
+// Service sends an OTP code to the messenger account of a phone number.
+type Service interface {
+	SendCode(ctx context.Context, phoneNumber, code string) (*entities.SendResult, error)
+}
+
+func NewWhatsAppService(cfg config.WhatsAppConfig, logger *logrus.Logger) (Service, error) {
+	switch cfg.Provider {
+	case "mock":
+		return NewMockService("whatsapp", logger), nil
+	case "cloud":
+		return NewWhatsAppCloudService(cfg, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown whatsapp provider %q", cfg.Provider)
+	}
+}
+
+func NewTelegramService(cfg config.TelegramConfig, logger *logrus.Logger) (Service, error) {
+	switch cfg.Provider {
+	case "mock":
+		return NewMockService("telegram", logger), nil
+	case "gateway":
+		return NewTelegramGatewayService(cfg, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown telegram provider %q", cfg.Provider)
+	}
+}
+
+type mockService struct {
+	channel string
+	logger  *logrus.Logger
+}
+
+// NewMockService logs codes instead of sending them.
+func NewMockService(channel string, logger *logrus.Logger) Service {
+	return &mockService{
+		channel: channel,
+		logger:  logger,
+	}
+}
+
+func (s *mockService) SendCode(ctx context.Context, phoneNumber, code string) (*entities.SendResult, error) {
+	s.logger.WithFields(logrus.Fields{
+		"channel":      s.channel,
+		"phone_number": phoneNumber,
+		"code":         code,
+	}).Info("💬 Mock messenger code sent")
+
+	fmt.Printf("\n=== MOCK %s ===\n", s.channel)
+	fmt.Printf("To: %s\n", phoneNumber)
+	fmt.Printf("Code: %s\n", code)
+	fmt.Printf("================\n\n")
+
+	return &entities.SendResult{Provider: "mock-" + s.channel, MessageID: uuid.NewString()}, nil
+}
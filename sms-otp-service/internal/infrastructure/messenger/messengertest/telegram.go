@@ -0,0 +1,125 @@
+package messengertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sms-otp-service/internal/testutil"
+	"testing"
+)
+
+var (
+	e164Pattern         = regexp.MustCompile(`^\+?[1-9]\d{7,14}$`)
+	telegramCodePattern = regexp.MustCompile(`^\d{4,8}$`)
+)
+
+// TelegramMessage is a verification message accepted by the TelegramServer.
+type TelegramMessage struct {
+	RequestID      string
+	PhoneNumber    string
+	Code           string
+	SenderUsername string
+}
+
+// TelegramError is a canned error returned by the TelegramServer, such as
+// "PHONE_NUMBER_INVALID" or "FLOOD_WAIT_30".
+type TelegramError struct {
+	HTTPStatus int
+	Error      string
+}
+
+// TelegramServer emulates sendVerificationMessage of the Telegram Gateway
+// API.
+type TelegramServer struct {
+	testutil.Recorder[TelegramMessage, TelegramError]
+
+	URL string
+
+	server      *httptest.Server
+	accessToken string
+}
+
+// NewTelegramServer starts a stand-in, shut down when t ends, that accepts
+// requests with the given access token.
+func NewTelegramServer(t testing.TB, accessToken string) *TelegramServer {
+	s := &TelegramServer{
+		accessToken: accessToken,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close shuts the server down.
+func (s *TelegramServer) Close() {
+	s.server.Close()
+}
+
+func (s *TelegramServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/sendVerificationMessage" {
+		writeTelegramError(w, TelegramError{HTTPStatus: http.StatusNotFound, Error: "METHOD_NOT_FOUND"})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.accessToken {
+		writeTelegramError(w, TelegramError{HTTPStatus: http.StatusUnauthorized, Error: "ACCESS_TOKEN_INVALID"})
+		return
+	}
+
+	if failure, ok := s.NextFailure(); ok {
+		writeTelegramError(w, failure)
+		return
+	}
+
+	var req struct {
+		PhoneNumber    string `json:"phone_number"`
+		Code           string `json:"code"`
+		SenderUsername string `json:"sender_username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeTelegramError(w, TelegramError{HTTPStatus: http.StatusBadRequest, Error: "BAD_REQUEST"})
+		return
+	}
+	if !e164Pattern.MatchString(req.PhoneNumber) {
+		writeTelegramError(w, TelegramError{HTTPStatus: http.StatusBadRequest, Error: "PHONE_NUMBER_INVALID"})
+		return
+	}
+	if !telegramCodePattern.MatchString(req.Code) {
+		writeTelegramError(w, TelegramError{HTTPStatus: http.StatusBadRequest, Error: "CODE_INVALID"})
+		return
+	}
+
+	msg := s.Record(func(seq int) TelegramMessage {
+		return TelegramMessage{
+			RequestID:      fmt.Sprintf("%016x", seq),
+			PhoneNumber:    req.PhoneNumber,
+			Code:           req.Code,
+			SenderUsername: req.SenderUsername,
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ok": true,
+		"result": map[string]any{
+			"request_id":   msg.RequestID,
+			"phone_number": msg.PhoneNumber,
+			"request_cost": 0.01,
+			"delivery_status": map[string]any{
+				"status": "sent",
+			},
+		},
+	})
+}
+
+func writeTelegramError(w http.ResponseWriter, failure TelegramError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(failure.HTTPStatus)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ok":    false,
+		"error": failure.Error,
+	})
+}
@@ -0,0 +1,194 @@
+// Package messengertest provides local stand-ins for messenger APIs so the
+// services in package messenger, and the OTP flows built on them, can be
+// exercised without network access.
+package messengertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sms-otp-service/internal/testutil"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var waIDPattern = regexp.MustCompile(`^[1-9]\d{7,14}$`)
+
+// WhatsAppMessage is an authentication template message accepted by the
+// WhatsAppServer.
+type WhatsAppMessage struct {
+	ID       string
+	To       string
+	Template string
+	Language string
+	Code     string
+}
+
+// WhatsAppError is a canned Graph API error returned by the WhatsAppServer.
+type WhatsAppError struct {
+	HTTPStatus int
+	Code       int
+	Message    string
+}
+
+// WhatsAppServer emulates the messages endpoint of the WhatsApp Cloud API
+// for a single business phone number.
+type WhatsAppServer struct {
+	testutil.Recorder[WhatsAppMessage, WhatsAppError]
+
+	URL string
+
+	server        *httptest.Server
+	apiVersion    string
+	phoneNumberID string
+	accessToken   string
+
+	mu           sync.Mutex
+	unregistered map[string]bool
+}
+
+// NewWhatsAppServer starts a stand-in, shut down when t ends, that serves
+// the given API version and phone number ID and accepts requests with the
+// given access token.
+func NewWhatsAppServer(t testing.TB, apiVersion, phoneNumberID, accessToken string) *WhatsAppServer {
+	s := &WhatsAppServer{
+		apiVersion:    apiVersion,
+		phoneNumberID: phoneNumberID,
+		accessToken:   accessToken,
+		unregistered:  make(map[string]bool),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close shuts the server down.
+func (s *WhatsAppServer) Close() {
+	s.server.Close()
+}
+
+// Unregister makes messages to phoneNumber fail as undeliverable, as they do
+// for numbers without a WhatsApp account.
+func (s *WhatsAppServer) Unregister(phoneNumber string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unregistered[strings.TrimPrefix(phoneNumber, "+")] = true
+}
+
+type whatsAppRequest struct {
+	MessagingProduct string `json:"messaging_product"`
+	To               string `json:"to"`
+	Type             string `json:"type"`
+	Template         struct {
+		Name     string `json:"name"`
+		Language struct {
+			Code string `json:"code"`
+		} `json:"language"`
+		Components []struct {
+			Type       string `json:"type"`
+			SubType    string `json:"sub_type"`
+			Parameters []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"parameters"`
+		} `json:"components"`
+	} `json:"template"`
+}
+
+func (s *WhatsAppServer) handle(w http.ResponseWriter, r *http.Request) {
+	path := fmt.Sprintf("/%s/%s/messages", s.apiVersion, s.phoneNumberID)
+	if r.Method != http.MethodPost || r.URL.Path != path {
+		writeWhatsAppError(w, WhatsAppError{HTTPStatus: http.StatusBadRequest, Code: 100, Message: "Unsupported post request"})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.accessToken {
+		writeWhatsAppError(w, WhatsAppError{HTTPStatus: http.StatusUnauthorized, Code: 190, Message: "Invalid OAuth access token"})
+		return
+	}
+
+	if failure, ok := s.NextFailure(); ok {
+		writeWhatsAppError(w, failure)
+		return
+	}
+
+	var req whatsAppRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeWhatsAppError(w, WhatsAppError{HTTPStatus: http.StatusBadRequest, Code: 100, Message: "Invalid JSON payload"})
+		return
+	}
+	if req.MessagingProduct != "whatsapp" || req.Type != "template" || req.Template.Name == "" || req.Template.Language.Code == "" {
+		writeWhatsAppError(w, WhatsAppError{HTTPStatus: http.StatusBadRequest, Code: 100, Message: "Invalid parameter"})
+		return
+	}
+
+	to := strings.TrimPrefix(req.To, "+")
+	if !waIDPattern.MatchString(to) {
+		writeWhatsAppError(w, WhatsAppError{HTTPStatus: http.StatusBadRequest, Code: 100, Message: "Invalid parameter: to"})
+		return
+	}
+
+	// Authentication templates carry the code in the body and the copy code
+	// button, and both must match.
+	var bodyCode, buttonCode string
+	for _, component := range req.Template.Components {
+		if len(component.Parameters) != 1 {
+			continue
+		}
+		switch component.Type {
+		case "body":
+			bodyCode = component.Parameters[0].Text
+		case "button":
+			buttonCode = component.Parameters[0].Text
+		}
+	}
+	if bodyCode == "" || bodyCode != buttonCode {
+		writeWhatsAppError(w, WhatsAppError{
+			HTTPStatus: http.StatusBadRequest,
+			Code:       132000,
+			Message:    "Number of parameters does not match the expected number of params",
+		})
+		return
+	}
+
+	s.mu.Lock()
+	unregistered := s.unregistered[to]
+	s.mu.Unlock()
+	if unregistered {
+		writeWhatsAppError(w, WhatsAppError{HTTPStatus: http.StatusBadRequest, Code: 131026, Message: "Message Undeliverable"})
+		return
+	}
+	msg := s.Record(func(seq int) WhatsAppMessage {
+		return WhatsAppMessage{
+			ID:       fmt.Sprintf("wamid.%032x", seq),
+			To:       to,
+			Template: req.Template.Name,
+			Language: req.Template.Language.Code,
+			Code:     bodyCode,
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"messaging_product": "whatsapp",
+		"contacts":          []map[string]string{{"input": req.To, "wa_id": to}},
+		"messages":          []map[string]string{{"id": msg.ID, "message_status": "accepted"}},
+	})
+}
+
+func writeWhatsAppError(w http.ResponseWriter, failure WhatsAppError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(failure.HTTPStatus)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"message":    failure.Message,
+			"type":       "OAuthException",
+			"code":       failure.Code,
+			"fbtrace_id": "messengertest",
+		},
+	})
+}
@@ -0,0 +1,167 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sms-otp-service/internal/infrastructure/sms"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTelegramEndpoint = "https://gatewayapi.telegram.org"
+
+// Telegram Gateway errors, see https://core.telegram.org/gateway/api.
+var telegramErrors = map[string]error{
+	"ACCESS_TOKEN_INVALID":  sms.ErrAuthentication,
+	"ACCESS_TOKEN_REQUIRED": sms.ErrAuthentication,
+	"PHONE_NUMBER_INVALID":  sms.ErrInvalidNumber,
+	"CODE_INVALID":          sms.ErrRejected,
+	"BALANCE_NOT_ENOUGH":    sms.ErrRejected,
+}
+
+type telegramGatewayService struct {
+	endpoint       string
+	accessToken    string
+	senderUsername string
+	client         *http.Client
+	logger         *logrus.Logger
+}
+
+type telegramSendRequest struct {
+	PhoneNumber    string `json:"phone_number"`
+	Code           string `json:"code"`
+	SenderUsername string `json:"sender_username,omitempty"`
+}
+
+type telegramResponse struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error"`
+	Result struct {
+		RequestID string `json:"request_id"`
+	} `json:"result"`
+}
+
+// NewTelegramGatewayService sends codes as Telegram verification messages
+// through the Telegram Gateway API. APIEndpoint overrides the API base URL,
+// which allows pointing the service at a local stand-in.
+func NewTelegramGatewayService(cfg config.TelegramConfig, logger *logrus.Logger) Service {
+	endpoint := cfg.APIEndpoint
+	if endpoint == "" {
+		endpoint = defaultTelegramEndpoint
+	}
+
+	return &telegramGatewayService{
+		endpoint:       strings.TrimRight(endpoint, "/") + "/sendVerificationMessage",
+		accessToken:    cfg.AccessToken,
+		senderUsername: cfg.SenderUsername,
+		client:         &http.Client{Timeout: cfg.Timeout},
+		logger:         logger,
+	}
+}
+
+func (s *telegramGatewayService) SendCode(ctx context.Context, phoneNumber, code string) (*entities.SendResult, error) {
+	// The gateway only accepts numeric codes of 4 to 8 digits.
+	if !isTelegramCode(code) {
+		return nil, &sms.ProviderError{Provider: "telegram", Code: "CODE_INVALID", Message: "code must be 4 to 8 digits", Err: sms.ErrRejected}
+	}
+
+	payload, err := json.Marshal(telegramSendRequest{
+		PhoneNumber:    phoneNumber,
+		Code:           code,
+		SenderUsername: s.senderUsername,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode telegram request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build telegram request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, &sms.ProviderError{Provider: "telegram", Message: err.Error(), Err: sms.ErrProviderUnavailable}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, &sms.ProviderError{Provider: "telegram", Message: err.Error(), Err: sms.ErrProviderUnavailable}
+	}
+
+	var result telegramResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		if resp.StatusCode >= 500 {
+			return nil, &sms.ProviderError{Provider: "telegram", Code: strconv.Itoa(resp.StatusCode), Message: http.StatusText(resp.StatusCode), Err: sms.ErrProviderUnavailable}
+		}
+		return nil, fmt.Errorf("failed to decode telegram response: %w", err)
+	}
+
+	if !result.OK {
+		return nil, telegramError(resp.StatusCode, result.Error)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"phone_number": phoneNumber,
+		"request_id":   result.Result.RequestID,
+	}).Info("Telegram code accepted")
+
+	return &entities.SendResult{Provider: "telegram", MessageID: result.Result.RequestID}, nil
+}
+
+func telegramError(statusCode int, code string) error {
+	providerErr := &sms.ProviderError{Provider: "telegram", Code: code, Message: code}
+	if code == "" {
+		providerErr.Code = strconv.Itoa(statusCode)
+		providerErr.Message = http.StatusText(statusCode)
+	}
+
+	if mapped, ok := telegramErrors[code]; ok {
+		providerErr.Err = mapped
+		return providerErr
+	}
+
+	// Flood control is reported as FLOOD_WAIT_<seconds>.
+	if seconds, ok := strings.CutPrefix(code, "FLOOD_WAIT_"); ok {
+		providerErr.Err = sms.ErrThrottled
+		if n, err := strconv.Atoi(seconds); err == nil {
+			providerErr.RetryAfter = time.Duration(n) * time.Second
+		}
+		return providerErr
+	}
+
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		providerErr.Err = sms.ErrThrottled
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		providerErr.Err = sms.ErrAuthentication
+	case statusCode >= 500:
+		providerErr.Err = sms.ErrProviderUnavailable
+	default:
+		providerErr.Err = sms.ErrRejected
+	}
+	return providerErr
+}
+
+func isTelegramCode(code string) bool {
+	if len(code) < 4 || len(code) > 8 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,211 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sms-otp-service/internal/infrastructure/sms"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultWhatsAppEndpoint = "https://graph.facebook.com"
+
+// WhatsApp Cloud API error codes, see
+// https://developers.facebook.com/docs/whatsapp/cloud-api/support/error-codes.
+var whatsAppErrorCodes = map[int]error{
+	190:    sms.ErrAuthentication,
+	10:     sms.ErrAuthentication,
+	200:    sms.ErrAuthentication,
+	4:      sms.ErrThrottled,
+	80007:  sms.ErrThrottled,
+	130429: sms.ErrThrottled,
+	131048: sms.ErrThrottled,
+	131056: sms.ErrThrottled,
+	131026: sms.ErrUnreachable,
+	131030: sms.ErrRejected,
+	132000: sms.ErrRejected,
+	132001: sms.ErrRejected,
+	1:      sms.ErrProviderUnavailable,
+	2:      sms.ErrProviderUnavailable,
+	131000: sms.ErrProviderUnavailable,
+	131016: sms.ErrProviderUnavailable,
+}
+
+type whatsAppCloudService struct {
+	endpoint         string
+	accessToken      string
+	templateName     string
+	templateLanguage string
+	client           *http.Client
+	logger           *logrus.Logger
+}
+
+type whatsAppMessageRequest struct {
+	MessagingProduct string           `json:"messaging_product"`
+	RecipientType    string           `json:"recipient_type"`
+	To               string           `json:"to"`
+	Type             string           `json:"type"`
+	Template         whatsAppTemplate `json:"template"`
+}
+
+type whatsAppTemplate struct {
+	Name       string              `json:"name"`
+	Language   whatsAppLanguage    `json:"language"`
+	Components []whatsAppComponent `json:"components"`
+}
+
+type whatsAppLanguage struct {
+	Code string `json:"code"`
+}
+
+type whatsAppComponent struct {
+	Type       string              `json:"type"`
+	SubType    string              `json:"sub_type,omitempty"`
+	Index      string              `json:"index,omitempty"`
+	Parameters []whatsAppParameter `json:"parameters"`
+}
+
+type whatsAppParameter struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type whatsAppMessageResponse struct {
+	Messages []struct {
+		ID string `json:"id"`
+	} `json:"messages"`
+}
+
+type whatsAppErrorResponse struct {
+	Error struct {
+		Message   string `json:"message"`
+		Type      string `json:"type"`
+		Code      int    `json:"code"`
+		FBTraceID string `json:"fbtrace_id"`
+	} `json:"error"`
+}
+
+// NewWhatsAppCloudService sends codes with an authentication template
+// through the WhatsApp Cloud API. The code fills both the template body and
+// its copy code button. APIEndpoint overrides the Graph API base URL, which
+// allows pointing the service at a local stand-in.
+func NewWhatsAppCloudService(cfg config.WhatsAppConfig, logger *logrus.Logger) Service {
+	endpoint := cfg.APIEndpoint
+	if endpoint == "" {
+		endpoint = defaultWhatsAppEndpoint
+	}
+
+	return &whatsAppCloudService{
+		endpoint: fmt.Sprintf("%s/%s/%s/messages",
+			strings.TrimRight(endpoint, "/"), url.PathEscape(cfg.APIVersion), url.PathEscape(cfg.PhoneNumberID)),
+		accessToken:      cfg.AccessToken,
+		templateName:     cfg.TemplateName,
+		templateLanguage: cfg.TemplateLanguage,
+		client:           &http.Client{Timeout: cfg.Timeout},
+		logger:           logger,
+	}
+}
+
+func (s *whatsAppCloudService) SendCode(ctx context.Context, phoneNumber, code string) (*entities.SendResult, error) {
+	codeParameter := []whatsAppParameter{{Type: "text", Text: code}}
+	payload, err := json.Marshal(whatsAppMessageRequest{
+		MessagingProduct: "whatsapp",
+		RecipientType:    "individual",
+		To:               strings.TrimPrefix(phoneNumber, "+"),
+		Type:             "template",
+		Template: whatsAppTemplate{
+			Name:     s.templateName,
+			Language: whatsAppLanguage{Code: s.templateLanguage},
+			Components: []whatsAppComponent{
+				{Type: "body", Parameters: codeParameter},
+				{Type: "button", SubType: "url", Index: "0", Parameters: codeParameter},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode whatsapp request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build whatsapp request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, &sms.ProviderError{Provider: "whatsapp", Message: err.Error(), Err: sms.ErrProviderUnavailable}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, &sms.ProviderError{Provider: "whatsapp", Message: err.Error(), Err: sms.ErrProviderUnavailable}
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var msg whatsAppMessageResponse
+		if err := json.Unmarshal(body, &msg); err != nil || len(msg.Messages) == 0 {
+			return nil, fmt.Errorf("failed to decode whatsapp response: %s", body)
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"phone_number": phoneNumber,
+			"message_id":   msg.Messages[0].ID,
+		}).Info("WhatsApp code accepted")
+
+		return &entities.SendResult{Provider: "whatsapp", MessageID: msg.Messages[0].ID}, nil
+	}
+
+	return nil, s.parseError(resp, body)
+}
+
+func (s *whatsAppCloudService) parseError(resp *http.Response, body []byte) error {
+	var apiErr whatsAppErrorResponse
+	_ = json.Unmarshal(body, &apiErr)
+
+	providerErr := &sms.ProviderError{
+		Provider: "whatsapp",
+		Code:     strconv.Itoa(apiErr.Error.Code),
+		Message:  apiErr.Error.Message,
+	}
+	if apiErr.Error.Code == 0 {
+		providerErr.Code = strconv.Itoa(resp.StatusCode)
+	}
+	if providerErr.Message == "" {
+		providerErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	if mapped, ok := whatsAppErrorCodes[apiErr.Error.Code]; ok {
+		providerErr.Err = mapped
+	} else {
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			providerErr.Err = sms.ErrThrottled
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			providerErr.Err = sms.ErrAuthentication
+		case resp.StatusCode >= 500:
+			providerErr.Err = sms.ErrProviderUnavailable
+		default:
+			providerErr.Err = sms.ErrRejected
+		}
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			providerErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return providerErr
+}
@@ -0,0 +1,310 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle counters, backoffs and lockouts are
+// dropped.
+const sweepInterval = time.Minute
+
+type memoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	backoffs  map[string]*backoff
+	lockouts  map[string]*lockout
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// counter is the state of one key. Token buckets use tokens and updated;
+// sliding windows use windowStart, current and previous.
+type counter struct {
+	tokens      float64
+	updated     time.Time
+	windowStart time.Time
+	current     int
+	previous    int
+	// idleAt is when the counter is back to its initial state and can be
+	// dropped.
+	idleAt time.Time
+}
+
+// backoff is the state of one backoff.
+type backoff struct {
+	step   int
+	endsAt time.Time
+	// idleAt is when the backoff starts over.
+	idleAt time.Time
+}
+
+// lockout is the state of one lockout key.
+type lockout struct {
+	failures    int
+	windowEnds  time.Time
+	locks       int
+	lockedUntil time.Time
+	// idleAt is when the failures and locks are forgotten.
+	idleAt time.Time
+}
+
+// NewMemoryStore returns a limiter store that keeps counters in process.
+// Each instance counts on its own, so limits apply per replica.
+func NewMemoryStore() repositories.LimiterStore {
+	return &memoryStore{
+		counters: make(map[string]*counter),
+		backoffs: make(map[string]*backoff),
+		lockouts: make(map[string]*lockout),
+		now:      time.Now,
+	}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit entities.RateLimit) (entities.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	c, ok := s.counters[key]
+	if !ok {
+		c = &counter{tokens: float64(limit.Limit), updated: now}
+		s.counters[key] = c
+	}
+
+	switch limit.Algorithm {
+	case entities.RateLimitSlidingWindow:
+		return c.takeSlidingWindow(now, limit), nil
+	default:
+		return c.takeTokenBucket(now, limit), nil
+	}
+}
+
+func (s *memoryStore) Release(ctx context.Context, key string, limit entities.RateLimit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok {
+		return nil
+	}
+	switch limit.Algorithm {
+	case entities.RateLimitSlidingWindow:
+		c.current = max(0, c.current-1)
+	default:
+		c.tokens = math.Min(float64(limit.Limit), c.tokens+1)
+	}
+	return nil
+}
+
+func (s *memoryStore) Backoff(ctx context.Context, key string, policy entities.Backoff) (entities.BackoffResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.backoffs[key]
+	if !ok || !now.Before(b.idleAt) {
+		b = &backoff{}
+		s.backoffs[key] = b
+	}
+
+	delay := policy.Delay(b.step)
+	b.step++
+	b.endsAt = now.Add(delay)
+	b.idleAt = b.endsAt.Add(policy.Reset)
+	return entities.BackoffResult{Step: b.step, RetryAfter: delay}, nil
+}
+
+func (s *memoryStore) Cooldown(ctx context.Context, key string) (entities.BackoffResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.backoffs[key]
+	if !ok || !now.Before(b.idleAt) {
+		return entities.BackoffResult{}, nil
+	}
+	return entities.BackoffResult{Step: b.step, RetryAfter: max(0, b.endsAt.Sub(now))}, nil
+}
+
+func (s *memoryStore) RecordFailure(ctx context.Context, key string, policy entities.Lockout) (entities.LockoutState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	l, ok := s.lockouts[key]
+	if !ok || !now.Before(l.idleAt) {
+		l = &lockout{}
+		s.lockouts[key] = l
+	}
+	if now.Before(l.lockedUntil) {
+		return l.state(now), nil
+	}
+
+	if !now.Before(l.windowEnds) {
+		l.failures = 0
+		l.windowEnds = now.Add(policy.Window)
+	}
+	l.failures++
+	if l.failures >= policy.Threshold {
+		l.lockedUntil = now.Add(policy.Duration(l.locks))
+		l.locks++
+		l.failures = 0
+		l.windowEnds = now
+	}
+
+	l.idleAt = l.windowEnds
+	if l.locks > 0 && l.lockedUntil.Add(policy.Reset).After(l.idleAt) {
+		l.idleAt = l.lockedUntil.Add(policy.Reset)
+	}
+	return l.state(now), nil
+}
+
+func (s *memoryStore) Lockout(ctx context.Context, key string) (entities.LockoutState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	l, ok := s.lockouts[key]
+	if !ok || !now.Before(l.idleAt) {
+		return entities.LockoutState{}, nil
+	}
+	return l.state(now), nil
+}
+
+func (s *memoryStore) ClearLockout(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lockouts, key)
+	return nil
+}
+
+func (s *memoryStore) sweep(now time.Time) {
+	for key, c := range s.counters {
+		if now.After(c.idleAt) {
+			delete(s.counters, key)
+		}
+	}
+	for key, b := range s.backoffs {
+		if !now.Before(b.idleAt) {
+			delete(s.backoffs, key)
+		}
+	}
+	for key, l := range s.lockouts {
+		if !now.Before(l.idleAt) {
+			delete(s.lockouts, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (l *lockout) state(now time.Time) entities.LockoutState {
+	state := entities.LockoutState{Locks: l.locks}
+	if now.Before(l.windowEnds) {
+		state.Failures = l.failures
+	}
+	if now.Before(l.lockedUntil) {
+		state.RetryAfter = l.lockedUntil.Sub(now)
+	}
+	return state
+}
+
+// takeTokenBucket refills the bucket for the time since it was last used
+// and takes a token from it.
+func (c *counter) takeTokenBucket(now time.Time, limit entities.RateLimit) entities.RateLimitResult {
+	capacity := float64(limit.Limit)
+	rate := capacity / limit.Window.Seconds()
+
+	c.tokens = math.Min(capacity, c.tokens+now.Sub(c.updated).Seconds()*rate)
+	c.updated = now
+
+	result := entities.RateLimitResult{Limit: limit.Limit}
+	if c.tokens >= 1 {
+		c.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - c.tokens) / rate)
+	}
+
+	result.Remaining = int(c.tokens)
+	result.Reset = seconds((capacity - c.tokens) / rate)
+	c.idleAt = now.Add(result.Reset)
+	return result
+}
+
+// takeSlidingWindow weighs the count of the previous fixed window by how
+// much of it still overlaps the sliding window ending now.
+func (c *counter) takeSlidingWindow(now time.Time, limit entities.RateLimit) entities.RateLimitResult {
+	start := now.Truncate(limit.Window)
+	switch {
+	case start.Equal(c.windowStart):
+	case start.Sub(c.windowStart) == limit.Window:
+		c.previous, c.current = c.current, 0
+		c.windowStart = start
+	default:
+		c.previous, c.current = 0, 0
+		c.windowStart = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - elapsed.Seconds()/limit.Window.Seconds()
+	count := float64(c.previous)*weight + float64(c.current)
+
+	result := entities.RateLimitResult{Limit: limit.Limit}
+	if count+1 <= float64(limit.Limit) {
+		c.current++
+		count++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = slidingRetryAfter(limit, c.previous, c.current, elapsed)
+	}
+
+	result.Remaining = max(0, limit.Limit-int(math.Ceil(count)))
+	result.Reset = limit.Window - elapsed
+	if c.current > 0 {
+		result.Reset += limit.Window
+	}
+	c.idleAt = now.Add(result.Reset)
+	return result
+}
+
+// slidingRetryAfter returns how long until the weighted count leaves room
+// for one more request, assuming no other requests arrive meanwhile.
+func slidingRetryAfter(limit entities.RateLimit, previous, current int, elapsed time.Duration) time.Duration {
+	window := limit.Window.Seconds()
+	room := float64(limit.Limit - current - 1)
+	if room >= 0 && previous > 0 {
+		// previous * (1 - t/window) <= room
+		t := window * (1 - room/float64(previous))
+		return seconds(t - elapsed.Seconds())
+	}
+
+	// The current window is full on its own, so it has to become the
+	// previous one and fade enough.
+	untilNext := window - elapsed.Seconds()
+	room = float64(limit.Limit - 1)
+	if current == 0 || room >= float64(current) {
+		return seconds(untilNext)
+	}
+	return seconds(untilNext + window*(1-room/float64(current)))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(0, s) * float64(time.Second))
+}
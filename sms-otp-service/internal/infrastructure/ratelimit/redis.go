@@ -0,0 +1,349 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"sms-otp-service/internal/infrastructure/config"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The scripts the Redis store runs. Each one reads and updates a single key
+// atomically, with the time taken from the Redis server so replicas with
+// skewed clocks agree. Durations are in milliseconds.
+var (
+	// tokenBucketScript takes a token from the bucket in KEYS[1] holding
+	// ARGV[1] tokens refilled over ARGV[2]. It returns {allowed, remaining,
+	// reset, retry after}.
+	tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = time[1] * 1000 + math.floor(time[2] / 1000)
+local rate = capacity / window
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or capacity
+local updated = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - updated) * rate)
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), reset, retry}
+`)
+
+	// slidingWindowScript counts a request in KEYS[1] against ARGV[1]
+	// requests per ARGV[2], weighing the previous fixed window by its
+	// overlap with the sliding one. It returns {allowed, remaining, reset,
+	// retry after}.
+	slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = time[1] * 1000 + math.floor(time[2] / 1000)
+local start = now - now % window
+
+local state = redis.call('HMGET', KEYS[1], 'start', 'current', 'previous')
+local current = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+if tonumber(state[1]) ~= start then
+	if tonumber(state[1]) == start - window then
+		previous = current
+	else
+		previous = 0
+	end
+	current = 0
+end
+
+local elapsed = now - start
+local count = previous * (1 - elapsed / window) + current
+
+local allowed, retry = 0, 0
+if count + 1 <= limit then
+	current = current + 1
+	count = count + 1
+	allowed = 1
+else
+	local room = limit - current - 1
+	if room >= 0 and previous > 0 then
+		retry = window * (1 - room / previous) - elapsed
+	else
+		retry = window - elapsed
+		room = limit - 1
+		if current > 0 and room < current then
+			retry = retry + window * (1 - room / current)
+		end
+	end
+	retry = math.max(0, math.ceil(retry))
+end
+
+local reset = window - elapsed
+if current > 0 then
+	reset = reset + window
+end
+redis.call('HSET', KEYS[1], 'start', start, 'current', current, 'previous', previous)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, math.max(0, limit - math.ceil(count)), reset, retry}
+`)
+
+	// releaseScript gives back a request to the counter in KEYS[1], a token
+	// bucket holding up to ARGV[1] tokens or a sliding window. It returns
+	// {}.
+	releaseScript = redis.NewScript(`
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'current')
+if state[1] then
+	redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(tonumber(ARGV[1]), tonumber(state[1]) + 1)))
+elseif state[2] then
+	redis.call('HSET', KEYS[1], 'current', math.max(0, tonumber(state[2]) - 1))
+end
+return {}
+`)
+
+	// backoffScript starts the next cooldown of the backoff in KEYS[1].
+	// ARGV[1] is the reset and the rest are the delays. It returns {step,
+	// retry after}.
+	backoffScript = redis.NewScript(`
+local reset = tonumber(ARGV[1])
+local time = redis.call('TIME')
+local now = time[1] * 1000 + math.floor(time[2] / 1000)
+
+local step = tonumber(redis.call('HGET', KEYS[1], 'step')) or 0
+local delay = tonumber(ARGV[2 + math.min(step, #ARGV - 2)])
+step = step + 1
+redis.call('HSET', KEYS[1], 'step', step, 'ends', now + delay)
+redis.call('PEXPIRE', KEYS[1], math.max(delay + reset, 1))
+return {step, delay}
+`)
+
+	// cooldownScript reads the backoff in KEYS[1]. It returns {step, retry
+	// after}.
+	cooldownScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = time[1] * 1000 + math.floor(time[2] / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'step', 'ends')
+return {tonumber(state[1]) or 0, math.max(0, (tonumber(state[2]) or 0) - now)}
+`)
+
+	// recordFailureScript counts a failure in the lockout in KEYS[1] with
+	// a threshold of ARGV[1] failures per ARGV[2], locking for ARGV[3]
+	// doubled with each earlier lock up to ARGV[4]. Earlier locks are
+	// forgotten ARGV[5] after the last one ends. It returns {failures,
+	// locks, retry after}.
+	recordFailureScript = redis.NewScript(`
+local threshold = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local base = tonumber(ARGV[3])
+local cap = tonumber(ARGV[4])
+local reset = tonumber(ARGV[5])
+local time = redis.call('TIME')
+local now = time[1] * 1000 + math.floor(time[2] / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'failures', 'window', 'locks', 'locked')
+local failures = tonumber(state[1]) or 0
+local ends = tonumber(state[2]) or 0
+local locks = tonumber(state[3]) or 0
+local locked = tonumber(state[4]) or 0
+if ends <= now then
+	failures = 0
+end
+if locked > now then
+	return {failures, locks, locked - now}
+end
+
+if failures == 0 then
+	ends = now + window
+end
+failures = failures + 1
+if failures >= threshold then
+	locked = now + math.floor(math.min(cap, base * 2 ^ locks))
+	locks = locks + 1
+	failures = 0
+	ends = now
+end
+
+local idle = ends
+if locks > 0 then
+	idle = math.max(idle, locked + reset)
+end
+redis.call('HSET', KEYS[1], 'failures', failures, 'window', ends, 'locks', locks, 'locked', locked)
+redis.call('PEXPIRE', KEYS[1], math.max(idle - now, 1))
+return {failures, locks, math.max(0, locked - now)}
+`)
+
+	// lockoutScript reads the lockout in KEYS[1]. It returns {failures,
+	// locks, retry after}.
+	lockoutScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = time[1] * 1000 + math.floor(time[2] / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'failures', 'window', 'locks', 'locked')
+local failures = tonumber(state[1]) or 0
+if (tonumber(state[2]) or 0) <= now then
+	failures = 0
+end
+return {failures, tonumber(state[3]) or 0, math.max(0, (tonumber(state[4]) or 0) - now)}
+`)
+)
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore returns a limiter store that keeps counters in Redis 5 or
+// later, so that every replica counts against the same limits. Keys are
+// prefixed with cfg.KeyPrefix. The server is pinged once to check the
+// configuration.
+func NewRedisStore(cfg config.RedisConfig) (repositories.LimiterStore, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	opts.PoolSize = cfg.PoolSize
+	opts.DialTimeout = cfg.Timeout
+	opts.ReadTimeout = cfg.Timeout
+	opts.WriteTimeout = cfg.Timeout
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &redisStore{client: client, prefix: cfg.KeyPrefix}, nil
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, limit entities.RateLimit) (entities.RateLimitResult, error) {
+	script := tokenBucketScript
+	if limit.Algorithm == entities.RateLimitSlidingWindow {
+		script = slidingWindowScript
+	}
+
+	ints, err := s.evalInts(ctx, script, key, 4, strconv.Itoa(limit.Limit), milliseconds(limit.Window))
+	if err != nil {
+		return entities.RateLimitResult{}, err
+	}
+
+	return entities.RateLimitResult{
+		Allowed:    ints[0] == 1,
+		Limit:      limit.Limit,
+		Remaining:  int(ints[1]),
+		Reset:      time.Duration(ints[2]) * time.Millisecond,
+		RetryAfter: time.Duration(ints[3]) * time.Millisecond,
+	}, nil
+}
+
+func (s *redisStore) Release(ctx context.Context, key string, limit entities.RateLimit) error {
+	_, err := s.evalInts(ctx, releaseScript, key, 0, strconv.Itoa(limit.Limit))
+	return err
+}
+
+func (s *redisStore) Backoff(ctx context.Context, key string, backoff entities.Backoff) (entities.BackoffResult, error) {
+	args := []string{milliseconds(backoff.Reset)}
+	for step := range max(1, len(backoff.Delays)) {
+		args = append(args, milliseconds(backoff.Delay(step)))
+	}
+
+	ints, err := s.evalInts(ctx, backoffScript, key, 2, args...)
+	if err != nil {
+		return entities.BackoffResult{}, err
+	}
+	return backoffResult(ints), nil
+}
+
+func (s *redisStore) Cooldown(ctx context.Context, key string) (entities.BackoffResult, error) {
+	ints, err := s.evalInts(ctx, cooldownScript, key, 2)
+	if err != nil {
+		return entities.BackoffResult{}, err
+	}
+	return backoffResult(ints), nil
+}
+
+// backoffResult reads the {step, retry after} reply of the backoff
+// scripts.
+func backoffResult(ints []int64) entities.BackoffResult {
+	return entities.BackoffResult{
+		Step:       int(ints[0]),
+		RetryAfter: time.Duration(ints[1]) * time.Millisecond,
+	}
+}
+
+func (s *redisStore) RecordFailure(ctx context.Context, key string, lockout entities.Lockout) (entities.LockoutState, error) {
+	ints, err := s.evalInts(ctx, recordFailureScript, key, 3,
+		strconv.Itoa(lockout.Threshold),
+		milliseconds(lockout.Window),
+		milliseconds(lockout.BaseDuration),
+		milliseconds(lockout.MaxDuration),
+		milliseconds(lockout.Reset),
+	)
+	if err != nil {
+		return entities.LockoutState{}, err
+	}
+	return lockoutState(ints), nil
+}
+
+func (s *redisStore) Lockout(ctx context.Context, key string) (entities.LockoutState, error) {
+	ints, err := s.evalInts(ctx, lockoutScript, key, 3)
+	if err != nil {
+		return entities.LockoutState{}, err
+	}
+	return lockoutState(ints), nil
+}
+
+func (s *redisStore) ClearLockout(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// lockoutState reads the {failures, locks, retry after} reply of the
+// lockout scripts.
+func lockoutState(ints []int64) entities.LockoutState {
+	return entities.LockoutState{
+		Failures:   int(ints[0]),
+		Locks:      int(ints[1]),
+		RetryAfter: time.Duration(ints[2]) * time.Millisecond,
+	}
+}
+
+// Close closes the pooled connections.
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
+
+// evalInts runs script on key, sending the source only when the server
+// does not have it cached yet, and returns the n integers it replies with.
+func (s *redisStore) evalInts(ctx context.Context, script *redis.Script, key string, n int, args ...string) ([]int64, error) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+
+	ints, err := script.Run(ctx, s.client, []string{s.prefix + key}, values...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(ints) != n {
+		return nil, fmt.Errorf("unexpected script reply %v", ints)
+	}
+	return ints, nil
+}
+
+// milliseconds formats d as whole milliseconds, at least one.
+func milliseconds(d time.Duration) string {
+	return strconv.FormatInt(max(1, d.Milliseconds()), 10)
+}
@@ -0,0 +1,19 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sms-otp-service/internal/domain/repositories"
+	"sms-otp-service/internal/infrastructure/config"
+)
+
+// NewStore returns the limiter store selected by cfg.Store.
+func NewStore(cfg config.LimiterConfig) (repositories.LimiterStore, error) {
+	switch cfg.Store {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "redis":
+		return NewRedisStore(cfg.Redis)
+	default:
+		return nil, fmt.Errorf("unknown limiter store: %s", cfg.Store)
+	}
+}
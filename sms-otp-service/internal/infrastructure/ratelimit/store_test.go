@@ -0,0 +1,303 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"sms-otp-service/internal/infrastructure/config"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testStore is a limiter store with a clock the test moves.
+type testStore struct {
+	name    string
+	store   repositories.LimiterStore
+	advance func(d time.Duration)
+}
+
+// testStores returns a memory store and a Redis store backed by miniredis,
+// which runs the Lua scripts, both starting at the same time.
+func testStores(t *testing.T) []testStore {
+	t.Helper()
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	memory := NewMemoryStore().(*memoryStore)
+	memoryNow := start
+	memory.now = func() time.Time { return memoryNow }
+
+	server := miniredis.RunT(t)
+	server.SetTime(start)
+	redisStore, err := NewRedisStore(config.RedisConfig{
+		URL:       "redis://" + server.Addr() + "/0",
+		KeyPrefix: "test:",
+		PoolSize:  2,
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { redisStore.(io.Closer).Close() })
+	redisNow := start
+
+	return []testStore{
+		{
+			name:    "memory",
+			store:   memory,
+			advance: func(d time.Duration) { memoryNow = memoryNow.Add(d) },
+		},
+		{
+			name:  "redis",
+			store: redisStore,
+			advance: func(d time.Duration) {
+				redisNow = redisNow.Add(d)
+				server.SetTime(redisNow)
+				server.FastForward(d)
+			},
+		},
+	}
+}
+
+// step is one operation on a store and the outcome it must have.
+type step struct {
+	advance time.Duration
+	op      func(ctx context.Context, store repositories.LimiterStore) (any, error)
+	want    any
+}
+
+func take(limit entities.RateLimit, want entities.RateLimitResult) step {
+	return step{
+		op: func(ctx context.Context, store repositories.LimiterStore) (any, error) {
+			return store.Take(ctx, "key", limit)
+		},
+		want: want,
+	}
+}
+
+func release(limit entities.RateLimit) step {
+	return step{
+		op: func(ctx context.Context, store repositories.LimiterStore) (any, error) {
+			return nil, store.Release(ctx, "key", limit)
+		},
+	}
+}
+
+func startBackoff(policy entities.Backoff, want entities.BackoffResult) step {
+	return step{
+		op: func(ctx context.Context, store repositories.LimiterStore) (any, error) {
+			return store.Backoff(ctx, "key", policy)
+		},
+		want: want,
+	}
+}
+
+func readCooldown(want entities.BackoffResult) step {
+	return step{
+		op: func(ctx context.Context, store repositories.LimiterStore) (any, error) {
+			return store.Cooldown(ctx, "key")
+		},
+		want: want,
+	}
+}
+
+func recordFailure(policy entities.Lockout, want entities.LockoutState) step {
+	return step{
+		op: func(ctx context.Context, store repositories.LimiterStore) (any, error) {
+			return store.RecordFailure(ctx, "key", policy)
+		},
+		want: want,
+	}
+}
+
+func readLockout(want entities.LockoutState) step {
+	return step{
+		op: func(ctx context.Context, store repositories.LimiterStore) (any, error) {
+			return store.Lockout(ctx, "key")
+		},
+		want: want,
+	}
+}
+
+func clearLockout() step {
+	return step{
+		op: func(ctx context.Context, store repositories.LimiterStore) (any, error) {
+			return nil, store.ClearLockout(ctx, "key")
+		},
+	}
+}
+
+// after delays s by d.
+func after(d time.Duration, s step) step {
+	s.advance = d
+	return s
+}
+
+func TestLimiterStores(t *testing.T) {
+	tokenBucket := entities.RateLimit{Algorithm: entities.RateLimitTokenBucket, Limit: 3, Window: 3 * time.Second}
+	slidingWindow := entities.RateLimit{Algorithm: entities.RateLimitSlidingWindow, Limit: 2, Window: 10 * time.Second}
+	resend := entities.Backoff{Delays: []time.Duration{time.Second, 2 * time.Second}, Reset: 10 * time.Second}
+	locks := entities.Lockout{
+		Threshold:    3,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  3 * time.Minute,
+		Reset:        time.Hour,
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "token bucket allows a burst and refills evenly",
+			steps: []step{
+				take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}),
+				take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 1, Reset: 2 * time.Second}),
+				take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}),
+				take(tokenBucket, entities.RateLimitResult{Limit: 3, Remaining: 0, Reset: 3 * time.Second, RetryAfter: time.Second}),
+				after(time.Second, take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second})),
+				after(3*time.Second, take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second})),
+			},
+		},
+		{
+			name: "sliding window weighs the previous window",
+			steps: []step{
+				take(slidingWindow, entities.RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: 20 * time.Second}),
+				take(slidingWindow, entities.RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: 20 * time.Second}),
+				take(slidingWindow, entities.RateLimitResult{Limit: 2, Remaining: 0, Reset: 20 * time.Second, RetryAfter: 15 * time.Second}),
+				after(15*time.Second, take(slidingWindow, entities.RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: 15 * time.Second})),
+				take(slidingWindow, entities.RateLimitResult{Limit: 2, Remaining: 0, Reset: 15 * time.Second, RetryAfter: 5 * time.Second}),
+			},
+		},
+		{
+			name: "released requests can be taken again",
+			steps: []step{
+				take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}),
+				take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 1, Reset: 2 * time.Second}),
+				take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}),
+				release(tokenBucket),
+				take(tokenBucket, entities.RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}),
+				take(tokenBucket, entities.RateLimitResult{Limit: 3, Remaining: 0, Reset: 3 * time.Second, RetryAfter: time.Second}),
+			},
+		},
+		{
+			name: "released sliding window requests can be taken again",
+			steps: []step{
+				take(slidingWindow, entities.RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: 20 * time.Second}),
+				take(slidingWindow, entities.RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: 20 * time.Second}),
+				release(slidingWindow),
+				take(slidingWindow, entities.RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: 20 * time.Second}),
+				take(slidingWindow, entities.RateLimitResult{Limit: 2, Remaining: 0, Reset: 20 * time.Second, RetryAfter: 15 * time.Second}),
+			},
+		},
+		{
+			name: "backoff escalates, repeats its last delay and starts over",
+			steps: []step{
+				readCooldown(entities.BackoffResult{}),
+				startBackoff(resend, entities.BackoffResult{Step: 1, RetryAfter: time.Second}),
+				readCooldown(entities.BackoffResult{Step: 1, RetryAfter: time.Second}),
+				startBackoff(resend, entities.BackoffResult{Step: 2, RetryAfter: 2 * time.Second}),
+				after(2*time.Second, readCooldown(entities.BackoffResult{Step: 2})),
+				startBackoff(resend, entities.BackoffResult{Step: 3, RetryAfter: 2 * time.Second}),
+				after(12*time.Second, readCooldown(entities.BackoffResult{})),
+				startBackoff(resend, entities.BackoffResult{Step: 1, RetryAfter: time.Second}),
+			},
+		},
+		{
+			name: "lockout doubles up to its maximum and resets",
+			steps: []step{
+				recordFailure(locks, entities.LockoutState{Failures: 1}),
+				recordFailure(locks, entities.LockoutState{Failures: 2}),
+				recordFailure(locks, entities.LockoutState{Locks: 1, RetryAfter: time.Minute}),
+				recordFailure(locks, entities.LockoutState{Locks: 1, RetryAfter: time.Minute}),
+				after(30*time.Second, readLockout(entities.LockoutState{Locks: 1, RetryAfter: 30 * time.Second})),
+				after(30*time.Second, recordFailure(locks, entities.LockoutState{Failures: 1, Locks: 1})),
+				recordFailure(locks, entities.LockoutState{Failures: 2, Locks: 1}),
+				recordFailure(locks, entities.LockoutState{Locks: 2, RetryAfter: 2 * time.Minute}),
+				after(2*time.Minute, recordFailure(locks, entities.LockoutState{Failures: 1, Locks: 2})),
+				recordFailure(locks, entities.LockoutState{Failures: 2, Locks: 2}),
+				recordFailure(locks, entities.LockoutState{Locks: 3, RetryAfter: 3 * time.Minute}),
+				after(3*time.Minute+time.Hour, readLockout(entities.LockoutState{})),
+			},
+		},
+		{
+			name: "lockout forgets failures outside its window",
+			steps: []step{
+				recordFailure(locks, entities.LockoutState{Failures: 1}),
+				recordFailure(locks, entities.LockoutState{Failures: 2}),
+				after(time.Minute, readLockout(entities.LockoutState{})),
+				recordFailure(locks, entities.LockoutState{Failures: 1}),
+			},
+		},
+		{
+			name: "cleared lockout starts over",
+			steps: []step{
+				recordFailure(locks, entities.LockoutState{Failures: 1}),
+				recordFailure(locks, entities.LockoutState{Failures: 2}),
+				recordFailure(locks, entities.LockoutState{Locks: 1, RetryAfter: time.Minute}),
+				clearLockout(),
+				readLockout(entities.LockoutState{}),
+				recordFailure(locks, entities.LockoutState{Failures: 1}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, ts := range testStores(t) {
+			t.Run(tt.name+"/"+ts.name, func(t *testing.T) {
+				ctx := context.Background()
+				for i, s := range tt.steps {
+					ts.advance(s.advance)
+					got, err := s.op(ctx, ts.store)
+					if err != nil {
+						t.Fatalf("step %d: %v", i, err)
+					}
+					if s.want != nil && got != s.want {
+						t.Errorf("step %d: got %+v, want %+v", i, got, s.want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestNewRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	if _, err := NewRedisStore(config.RedisConfig{URL: "redis://:wrong@" + server.Addr(), PoolSize: 1, Timeout: time.Second}); err == nil {
+		t.Error("NewRedisStore with a wrong password: got no error")
+	}
+	if _, err := NewRedisStore(config.RedisConfig{URL: "http://" + server.Addr(), PoolSize: 1, Timeout: time.Second}); err == nil {
+		t.Error("NewRedisStore with an http URL: got no error")
+	}
+
+	store, err := NewRedisStore(config.RedisConfig{URL: "redis://:secret@" + server.Addr() + "/2", KeyPrefix: "otp:", PoolSize: 1, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	defer store.(io.Closer).Close()
+
+	limit := entities.RateLimit{Algorithm: entities.RateLimitTokenBucket, Limit: 1, Window: time.Minute}
+	if _, err := store.Take(context.Background(), "key", limit); err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	server.Select(2)
+	if !server.Exists("otp:key") {
+		t.Errorf("keys in database 2: %v, want otp:key", server.Keys())
+	}
+
+	// Scripts are sent again once the server forgets them.
+	admin := redis.NewClient(&redis.Options{Addr: server.Addr(), Password: "secret"})
+	defer admin.Close()
+	if err := admin.ScriptFlush(context.Background()).Err(); err != nil {
+		t.Fatalf("SCRIPT FLUSH: %v", err)
+	}
+	if _, err := store.Take(context.Background(), "key", limit); err != nil {
+		t.Errorf("Take after SCRIPT FLUSH: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"sort"
+	"time"
+)
+
+type gormDeliveryEventRepository struct {
+	db *gorm.DB
+}
+
+func NewGormDeliveryEventRepository(db *gorm.DB) repositories.DeliveryEventRepository {
+	return &gormDeliveryEventRepository{db: db}
+}
+
+func (r *gormDeliveryEventRepository) Create(ctx context.Context, event *entities.DeliveryEvent) error {
+	return dbFromContext(ctx, r.db).Create(event).Error
+}
+
+func (r *gormDeliveryEventRepository) FindByOTPID(ctx context.Context, otpID string) ([]*entities.DeliveryEvent, error) {
+	var events []*entities.DeliveryEvent
+	err := dbFromContext(ctx, r.db).
+		Where("otp_id = ?", otpID).
+		Order("occurred_at, created_at").
+		Find(&events).Error
+
+	return events, err
+}
+
+func (r *gormDeliveryEventRepository) AttachUnmatched(ctx context.Context, provider, messageID string, otpID uuid.UUID, recipient string) ([]*entities.DeliveryEvent, error) {
+	var events []*entities.DeliveryEvent
+	err := dbFromContext(ctx, r.db).
+		Model(&events).
+		Clauses(clause.Returning{}).
+		Where("otp_id IS NULL AND provider = ? AND provider_message_id = ?", provider, messageID).
+		Updates(map[string]interface{}{
+			"otp_id":    otpID,
+			"recipient": recipient,
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].OccurredAt.Before(events[j].OccurredAt)
+	})
+	return events, nil
+}
+
+func (r *gormDeliveryEventRepository) DeleteBefore(ctx context.Context, cutoff time.Time) error {
+	return dbFromContext(ctx, r.db).
+		Where("occurred_at < ?", cutoff).
+		Delete(&entities.DeliveryEvent{}).Error
+}
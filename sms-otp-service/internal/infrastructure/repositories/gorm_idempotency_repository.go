@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"time"
+)
+
+type gormIdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewGormIdempotencyRepository(db *gorm.DB) repositories.IdempotencyRepository {
+	return &gormIdempotencyRepository{db: db}
+}
+
+func (r *gormIdempotencyRepository) Reserve(ctx context.Context, record *entities.IdempotencyRecord) (*entities.IdempotencyRecord, error) {
+	db := dbFromContext(ctx, r.db)
+	now := time.Now()
+
+	err := db.
+		Where("client_id = ? AND idempotency_key = ? AND (expires_at < ? OR (status_code = 0 AND locked_until < ?))",
+			record.ClientID, record.Key, now, now).
+		Delete(&entities.IdempotencyRecord{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil, nil
+	}
+
+	var existing entities.IdempotencyRecord
+	err = db.
+		Where("client_id = ? AND idempotency_key = ?", record.ClientID, record.Key).
+		First(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &existing, nil
+}
+
+func (r *gormIdempotencyRepository) FindCompleted(ctx context.Context, clientID, key string) (*entities.IdempotencyRecord, error) {
+	var records []entities.IdempotencyRecord
+	err := dbFromContext(ctx, r.db).
+		Where("client_id = ? AND idempotency_key = ? AND status_code <> 0 AND expires_at >= ?", clientID, key, time.Now()).
+		Limit(1).
+		Find(&records).Error
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[0], nil
+}
+
+func (r *gormIdempotencyRepository) Complete(ctx context.Context, id string, statusCode int, body []byte) error {
+	return dbFromContext(ctx, r.db).
+		Model(&entities.IdempotencyRecord{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"response_body": body,
+		}).Error
+}
+
+func (r *gormIdempotencyRepository) Release(ctx context.Context, id string) error {
+	return dbFromContext(ctx, r.db).
+		Where("id = ? AND status_code = 0", id).
+		Delete(&entities.IdempotencyRecord{}).Error
+}
+
+func (r *gormIdempotencyRepository) DeleteExpired(ctx context.Context) error {
+	return dbFromContext(ctx, r.db).
+		Where("expires_at < ?", time.Now()).
+		Delete(&entities.IdempotencyRecord{}).Error
+}
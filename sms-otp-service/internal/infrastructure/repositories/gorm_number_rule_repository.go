@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"strings"
+	"time"
+)
+
+type gormNumberRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewGormNumberRuleRepository(db *gorm.DB) repositories.NumberRuleRepository {
+	return &gormNumberRuleRepository{db: db}
+}
+
+func (r *gormNumberRuleRepository) Create(ctx context.Context, rule *entities.NumberRule) error {
+	result := dbFromContext(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(rule)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entities.ErrDuplicateNumberRule
+	}
+	return nil
+}
+
+func (r *gormNumberRuleRepository) FindByID(ctx context.Context, id string) (*entities.NumberRule, error) {
+	var rule entities.NumberRule
+	err := dbFromContext(ctx, r.db).Where("id = ?", id).First(&rule).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entities.ErrNumberRuleNotFound
+		}
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (r *gormNumberRuleRepository) FindAll(ctx context.Context, clientID string, action entities.NumberRuleAction) ([]*entities.NumberRule, error) {
+	db := dbFromContext(ctx, r.db)
+	if clientID != "" {
+		db = db.Where("client_id = ?", clientID)
+	}
+	if action != "" {
+		db = db.Where("action = ?", action)
+	}
+
+	var rules []*entities.NumberRule
+	err := db.Order("created_at").Find(&rules).Error
+	return rules, err
+}
+
+func (r *gormNumberRuleRepository) FindMatching(ctx context.Context, clientID, phoneNumber, region string) ([]*entities.NumberRule, error) {
+	var rules []*entities.NumberRule
+	err := dbFromContext(ctx, r.db).
+		Where("client_id IN ?", []string{"", clientID}).
+		Where("(match_type = ? AND value = ?) OR (match_type = ? AND ? LIKE value || '%') OR (match_type = ? AND value = ?)",
+			entities.NumberRuleMatchNumber, phoneNumber,
+			entities.NumberRuleMatchPrefix, strings.TrimPrefix(phoneNumber, "+"),
+			entities.NumberRuleMatchCountry, region).
+		Find(&rules).Error
+
+	return rules, err
+}
+
+func (r *gormNumberRuleRepository) HasAllowRules(ctx context.Context, clientID string) (bool, error) {
+	var count int64
+	err := dbFromContext(ctx, r.db).
+		Model(&entities.NumberRule{}).
+		Where("client_id = ? AND action = ?", clientID, entities.NumberRuleAllow).
+		Limit(1).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+func (r *gormNumberRuleRepository) Update(ctx context.Context, rule *entities.NumberRule) error {
+	var count int64
+	err := dbFromContext(ctx, r.db).
+		Model(&entities.NumberRule{}).
+		Where("client_id = ? AND match_type = ? AND value = ? AND id <> ?", rule.ClientID, rule.Match, rule.Value, rule.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return entities.ErrDuplicateNumberRule
+	}
+
+	rule.UpdatedAt = time.Now()
+	return dbFromContext(ctx, r.db).Save(rule).Error
+}
+
+func (r *gormNumberRuleRepository) Delete(ctx context.Context, id string) error {
+	result := dbFromContext(ctx, r.db).Delete(&entities.NumberRule{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entities.ErrNumberRuleNotFound
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"sms-otp-service/internal/domain/entities"
 	"sms-otp-service/internal/domain/repositories"
 	"time"
@@ -18,13 +19,21 @@ func NewGormOTPRepository(db *gorm.DB) repositories.OTPRepository {
 }
 
 func (r *gormOTPRepository) Create(ctx context.Context, otp *entities.OTP) error {
-	return r.db.WithContext(ctx).Create(otp).Error
+	return dbFromContext(ctx, r.db).Create(otp).Error
 }
 
-func (r *gormOTPRepository) FindByPhoneAndPurpose(ctx context.Context, phoneNumber string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+func (r *gormOTPRepository) FindByRecipientAndPurpose(ctx context.Context, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+	return r.findLatest(dbFromContext(ctx, r.db), recipient, purpose)
+}
+
+func (r *gormOTPRepository) FindByRecipientAndPurposeForUpdate(ctx context.Context, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error) {
+	return r.findLatest(dbFromContext(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}), recipient, purpose)
+}
+
+func (r *gormOTPRepository) findLatest(db *gorm.DB, recipient string, purpose entities.OTPPurpose) (*entities.OTP, error) {
 	var otp entities.OTP
-	err := r.db.WithContext(ctx).
-		Where("phone_number = ? AND purpose = ?", phoneNumber, purpose).
+	err := db.
+		Where("recipient = ? AND purpose = ?", recipient, purpose).
 		Order("created_at DESC").
 		First(&otp).Error
 
@@ -39,8 +48,16 @@ func (r *gormOTPRepository) FindByPhoneAndPurpose(ctx context.Context, phoneNumb
 }
 
 func (r *gormOTPRepository) FindByID(ctx context.Context, id string) (*entities.OTP, error) {
+	return r.findByID(dbFromContext(ctx, r.db), id)
+}
+
+func (r *gormOTPRepository) FindByIDForUpdate(ctx context.Context, id string) (*entities.OTP, error) {
+	return r.findByID(dbFromContext(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+func (r *gormOTPRepository) findByID(db *gorm.DB, id string) (*entities.OTP, error) {
 	var otp entities.OTP
-	err := r.db.WithContext(ctx).Where("id = ?", id).First(&otp).Error
+	err := db.Where("id = ?", id).First(&otp).Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -54,38 +71,104 @@ func (r *gormOTPRepository) FindByID(ctx context.Context, id string) (*entities.
 
 func (r *gormOTPRepository) Update(ctx context.Context, otp *entities.OTP) error {
 	otp.UpdatedAt = time.Now()
-	return r.db.WithContext(ctx).Save(otp).Error
+	return dbFromContext(ctx, r.db).Save(otp).Error
+}
+
+func (r *gormOTPRepository) UpdateVerification(ctx context.Context, otp *entities.OTP) error {
+	otp.UpdatedAt = time.Now()
+	return dbFromContext(ctx, r.db).
+		Model(otp).
+		Select("attempts", "is_verified", "verified_at", "canceled_at", "updated_at").
+		Updates(otp).Error
+}
+
+func (r *gormOTPRepository) InvalidateActive(ctx context.Context, recipient string, purpose entities.OTPPurpose) error {
+	return dbFromContext(ctx, r.db).
+		Model(&entities.OTP{}).
+		Where("recipient = ? AND purpose = ? AND expires_at > ? AND is_verified = false AND attempts < max_attempts",
+			recipient, purpose, time.Now()).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("max_attempts"),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+func (r *gormOTPRepository) UpdateDeliveryStatus(ctx context.Context, id string, status entities.DeliveryStatus, provider, messageID string) (bool, error) {
+	updates := map[string]interface{}{
+		"delivery_status": status,
+		"updated_at":      time.Now(),
+	}
+	if provider != "" {
+		updates["delivery_provider"] = provider
+	}
+	if messageID != "" {
+		updates["provider_message_id"] = messageID
+	}
+
+	result := dbFromContext(ctx, r.db).
+		Model(&entities.OTP{}).
+		Where("id = ? AND delivery_status IN ?", id, status.Predecessors()).
+		Updates(updates)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormOTPRepository) UpdateChannel(ctx context.Context, id string, channel entities.Channel) error {
+	return dbFromContext(ctx, r.db).
+		Model(&entities.OTP{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"channel":    channel,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+func (r *gormOTPRepository) RestartDelivery(ctx context.Context, id string, channel entities.Channel) error {
+	return dbFromContext(ctx, r.db).
+		Model(&entities.OTP{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"channel":             channel,
+			"delivery_status":     entities.DeliveryQueued,
+			"delivery_provider":   "",
+			"provider_message_id": "",
+			"updated_at":          time.Now(),
+		}).Error
+}
+
+func (r *gormOTPRepository) FindByProviderMessageID(ctx context.Context, provider, messageID string) (*entities.OTP, error) {
+	var otp entities.OTP
+	err := dbFromContext(ctx, r.db).
+		Where("delivery_provider = ? AND provider_message_id = ?", provider, messageID).
+		First(&otp).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entities.ErrOTPNotFound
+		}
+		return nil, err
+	}
+
+	return &otp, nil
 }
 
 func (r *gormOTPRepository) Delete(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Delete(&entities.OTP{}, "id = ?", id).Error
+	return dbFromContext(ctx, r.db).Delete(&entities.OTP{}, "id = ?", id).Error
 }
 
 func (r *gormOTPRepository) DeleteExpired(ctx context.Context) error {
-	return r.db.WithContext(ctx).
+	return dbFromContext(ctx, r.db).
 		Where("expires_at < ?", time.Now()).
 		Delete(&entities.OTP{}).Error
 }
 
-func (r *gormOTPRepository) FindActiveByPhone(ctx context.Context, phoneNumber string) ([]*entities.OTP, error) {
+func (r *gormOTPRepository) FindActiveByRecipient(ctx context.Context, recipient string) ([]*entities.OTP, error) {
 	var otps []*entities.OTP
-	err := r.db.WithContext(ctx).
-		Where("phone_number = ? AND expires_at > ? AND is_verified = false AND attempts < max_attempts",
-			phoneNumber, time.Now()).
+	err := dbFromContext(ctx, r.db).
+		Where("recipient = ? AND expires_at > ? AND is_verified = false AND canceled_at IS NULL AND attempts < max_attempts",
+			recipient, time.Now()).
 		Order("created_at DESC").
 		Find(&otps).Error
 
 	return otps, err
 }
-
-func (r *gormOTPRepository) CountRecentOTPs(ctx context.Context, phoneNumber string, minutes int) (int64, error) {
-	var count int64
-	cutoffTime := time.Now().Add(-time.Duration(minutes) * time.Minute)
-
-	err := r.db.WithContext(ctx).
-		Model(&entities.OTP{}).
-		Where("phone_number = ? AND created_at > ?", phoneNumber, cutoffTime).
-		Count(&count).Error
-
-	return count, err
-}
@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"sms-otp-service/pkg/utils"
+	"time"
+)
+
+// undecryptableError is recorded on messages that can no longer be opened,
+// such as after the key changed.
+const undecryptableError = "message cannot be decrypted"
+
+// gormOutboxRepository stores the message texts sealed, as they hold the
+// plaintext code until the message is sent, and opens them on the way out.
+type gormOutboxRepository struct {
+	db     *gorm.DB
+	sealer *utils.Sealer
+}
+
+func NewGormOutboxRepository(db *gorm.DB, sealer *utils.Sealer) repositories.OutboxRepository {
+	return &gormOutboxRepository{db: db, sealer: sealer}
+}
+
+func (r *gormOutboxRepository) Create(ctx context.Context, message *entities.OutboxMessage) error {
+	if message.ID == uuid.Nil {
+		message.ID = uuid.New()
+	}
+	defer r.seal(message)()
+	return dbFromContext(ctx, r.db).Create(message).Error
+}
+
+func (r *gormOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entities.OutboxMessage, error) {
+	var messages []*entities.OutboxMessage
+	now := time.Now()
+	lockedUntil := now.Add(lease)
+
+	err := dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				entities.OutboxPending, now, entities.OutboxProcessing, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		// Messages that cannot be opened are dead-lettered rather than
+		// claimed, so they do not come back on every poll.
+		var ids, undecryptable []string
+		claimed := messages[:0]
+		for _, message := range messages {
+			if !r.open(message) {
+				undecryptable = append(undecryptable, message.ID.String())
+				continue
+			}
+			ids = append(ids, message.ID.String())
+			message.Status = entities.OutboxProcessing
+			message.LockedUntil = &lockedUntil
+			claimed = append(claimed, message)
+		}
+		messages = claimed
+
+		if len(undecryptable) > 0 {
+			err := tx.Model(&entities.OutboxMessage{}).
+				Where("id IN ?", undecryptable).
+				Updates(map[string]interface{}{
+					"status":           entities.OutboxDead,
+					"last_error":       undecryptableError,
+					"message":          "",
+					"fallback_message": "",
+					"updated_at":       now,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		return tx.Model(&entities.OutboxMessage{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":       entities.OutboxProcessing,
+				"locked_until": lockedUntil,
+				"updated_at":   now,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *gormOutboxRepository) FindByOTPID(ctx context.Context, otpID string) ([]*entities.OutboxMessage, error) {
+	var messages []*entities.OutboxMessage
+	err := dbFromContext(ctx, r.db).
+		Where("otp_id = ?", otpID).
+		Order("escalation_step, created_at").
+		Find(&messages).Error
+	for _, message := range messages {
+		r.open(message)
+	}
+	return messages, err
+}
+
+func (r *gormOutboxRepository) ReleaseEscalation(ctx context.Context, otpID string) error {
+	now := time.Now()
+	next := dbFromContext(ctx, r.db).
+		Model(&entities.OutboxMessage{}).
+		Select("id").
+		Where("otp_id = ? AND escalation_step > 0 AND status = ?", otpID, entities.OutboxPending).
+		Order("escalation_step").
+		Limit(1)
+
+	return dbFromContext(ctx, r.db).
+		Model(&entities.OutboxMessage{}).
+		Where("id IN (?) AND next_attempt_at > ?", next, now).
+		Updates(map[string]interface{}{
+			"next_attempt_at": now,
+			"updated_at":      now,
+		}).Error
+}
+
+func (r *gormOutboxRepository) Update(ctx context.Context, message *entities.OutboxMessage) error {
+	message.UpdatedAt = time.Now()
+	defer r.seal(message)()
+	return dbFromContext(ctx, r.db).Save(message).Error
+}
+
+func (r *gormOutboxRepository) DeleteProcessedBefore(ctx context.Context, cutoff time.Time) error {
+	return dbFromContext(ctx, r.db).
+		Where("status IN ? AND updated_at < ?", []entities.OutboxStatus{entities.OutboxSent, entities.OutboxDead, entities.OutboxCanceled}, cutoff).
+		Delete(&entities.OutboxMessage{}).Error
+}
+
+// seal replaces the message texts with their sealed form for storage, bound
+// to the message ID, and returns a func that puts the plaintext back.
+func (r *gormOutboxRepository) seal(message *entities.OutboxMessage) (restore func()) {
+	text, fallback := message.Message, message.FallbackMessage
+	message.Message = r.sealer.Seal(text, message.ID.String())
+	message.FallbackMessage = r.sealer.Seal(fallback, message.ID.String())
+	return func() {
+		message.Message, message.FallbackMessage = text, fallback
+	}
+}
+
+// open decrypts the texts of a loaded message in place. It reports false,
+// leaving them empty, when they cannot be opened.
+func (r *gormOutboxRepository) open(message *entities.OutboxMessage) bool {
+	text, err := r.sealer.Open(message.Message, message.ID.String())
+	if err != nil {
+		message.Message, message.FallbackMessage = "", ""
+		return false
+	}
+	fallback, err := r.sealer.Open(message.FallbackMessage, message.ID.String())
+	if err != nil {
+		message.Message, message.FallbackMessage = "", ""
+		return false
+	}
+	message.Message, message.FallbackMessage = text, fallback
+	return true
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/pkg/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the database in TEST_DATABASE_DSN, skipping the
+// test without one, and recreates the outbox table in it.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.Migrator().DropTable(&entities.OutboxMessage{}); err != nil {
+		t.Fatalf("drop outbox: %v", err)
+	}
+	if err := db.AutoMigrate(&entities.OutboxMessage{}); err != nil {
+		t.Fatalf("migrate outbox: %v", err)
+	}
+	return db
+}
+
+func TestOutboxMessagesSealedAtRest(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewGormOutboxRepository(db, utils.NewSealer([]byte("secret"), "outbox"))
+	ctx := context.Background()
+
+	otp := &entities.OTP{Recipient: "+14155550100", Channel: entities.ChannelSMS, ExpiresAt: time.Now().Add(time.Minute)}
+	message := entities.NewOutboxMessage(otp, "Your code is 123456", 3)
+	message.FallbackChannel = entities.ChannelVoice
+	message.FallbackMessage = "Your code is 1 2 3 4 5 6"
+	if err := repo.Create(ctx, message); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if message.Message != "Your code is 123456" {
+		t.Errorf("Create left the message as %q, want the plaintext", message.Message)
+	}
+
+	var stored struct{ Message, FallbackMessage string }
+	db.Table("sms_outbox").Select("message, fallback_message").Where("id = ?", message.ID).Scan(&stored)
+	if strings.Contains(stored.Message, "123456") || strings.Contains(stored.FallbackMessage, "1 2 3") {
+		t.Errorf("outbox row holds the plaintext code: %+v", stored)
+	}
+
+	claimed, err := repo.ClaimDue(ctx, 10, time.Minute)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimDue = %v, %v, want the message", claimed, err)
+	}
+	if claimed[0].Message != message.Message || claimed[0].FallbackMessage != message.FallbackMessage {
+		t.Errorf("claimed %q and %q, want the plaintext back", claimed[0].Message, claimed[0].FallbackMessage)
+	}
+}
+
+func TestOutboxClaimDeadLettersUndecryptable(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	otp := &entities.OTP{Recipient: "+14155550100", Channel: entities.ChannelSMS, ExpiresAt: time.Now().Add(time.Minute)}
+	message := entities.NewOutboxMessage(otp, "Your code is 123456", 3)
+	if err := NewGormOutboxRepository(db, utils.NewSealer([]byte("old"), "outbox")).Create(ctx, message); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	repo := NewGormOutboxRepository(db, utils.NewSealer([]byte("new"), "outbox"))
+	claimed, err := repo.ClaimDue(ctx, 10, time.Minute)
+	if err != nil || len(claimed) != 0 {
+		t.Fatalf("ClaimDue = %v, %v, want nothing claimed", claimed, err)
+	}
+
+	var stored entities.OutboxMessage
+	if err := db.First(&stored, "id = ?", message.ID).Error; err != nil {
+		t.Fatalf("load message: %v", err)
+	}
+	if stored.Status != entities.OutboxDead || stored.LastError != undecryptableError || stored.Message != "" {
+		t.Errorf("message = %+v, want it dead-lettered and cleared", stored)
+	}
+}
+
+func TestOutboxClaimDue(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewGormOutboxRepository(db, utils.NewSealer([]byte("secret"), "outbox"))
+	ctx := context.Background()
+	now := time.Now()
+	otp := &entities.OTP{Recipient: "+14155550100", Channel: entities.ChannelSMS, ExpiresAt: now.Add(time.Minute)}
+
+	create := func(status entities.OutboxStatus, nextAttemptAt time.Time, lockedUntil *time.Time) *entities.OutboxMessage {
+		t.Helper()
+		message := entities.NewOutboxMessage(otp, "Your code is 123456", 3)
+		message.Status = status
+		message.NextAttemptAt = nextAttemptAt
+		message.LockedUntil = lockedUntil
+		if err := repo.Create(ctx, message); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return message
+	}
+	expired, live := now.Add(-time.Second), now.Add(time.Minute)
+
+	due := create(entities.OutboxPending, now.Add(-time.Minute), nil)
+	reclaimed := create(entities.OutboxProcessing, now.Add(-2*time.Minute), &expired)
+	create(entities.OutboxPending, now.Add(time.Minute), nil)
+	create(entities.OutboxProcessing, now.Add(-3*time.Minute), &live)
+	create(entities.OutboxSent, now.Add(-4*time.Minute), nil)
+	create(entities.OutboxDead, now.Add(-5*time.Minute), nil)
+
+	claimed, err := repo.ClaimDue(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimDue: %v", err)
+	}
+	// Messages come oldest first, so the one whose lease expired leads.
+	if len(claimed) != 2 || claimed[0].ID != reclaimed.ID || claimed[1].ID != due.ID {
+		t.Fatalf("claimed %v, want the expired lease and the due message", claimed)
+	}
+	for _, message := range claimed {
+		if message.Status != entities.OutboxProcessing || message.LockedUntil == nil || !message.LockedUntil.After(now) {
+			t.Errorf("claimed message is %s locked until %v, want a fresh lease", message.Status, message.LockedUntil)
+		}
+	}
+
+	// Claimed messages stay leased until their lease expires.
+	if again, err := repo.ClaimDue(ctx, 10, time.Minute); err != nil || len(again) != 0 {
+		t.Errorf("second ClaimDue = %v, %v, want nothing while leased", again, err)
+	}
+
+	db.Model(&entities.OutboxMessage{}).Where("id = ?", due.ID).Update("locked_until", expired)
+	again, err := repo.ClaimDue(ctx, 1, time.Minute)
+	if err != nil || len(again) != 1 || again[0].ID != due.ID {
+		t.Errorf("ClaimDue after the lease expired = %v, %v, want the message reclaimed", again, err)
+	}
+}
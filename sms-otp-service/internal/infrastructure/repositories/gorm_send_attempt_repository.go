@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"time"
+)
+
+type gormSendAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSendAttemptRepository(db *gorm.DB) repositories.SendAttemptRepository {
+	return &gormSendAttemptRepository{db: db}
+}
+
+func (r *gormSendAttemptRepository) Create(ctx context.Context, attempt *entities.SendAttempt) error {
+	return dbFromContext(ctx, r.db).Create(attempt).Error
+}
+
+func (r *gormSendAttemptRepository) CountByClientIP(ctx context.Context, clientIP string, since time.Time) (int64, error) {
+	return r.countSince(ctx, "client_ip", clientIP, since)
+}
+
+func (r *gormSendAttemptRepository) CountByPrefix(ctx context.Context, prefix string, since time.Time) (int64, error) {
+	return r.countSince(ctx, "prefix", prefix, since)
+}
+
+func (r *gormSendAttemptRepository) CountByRegion(ctx context.Context, region string, since time.Time) (int64, error) {
+	return r.countSince(ctx, "region", region, since)
+}
+
+func (r *gormSendAttemptRepository) countSince(ctx context.Context, column, value string, since time.Time) (int64, error) {
+	var count int64
+	err := dbFromContext(ctx, r.db).
+		Model(&entities.SendAttempt{}).
+		Where(column+" = ? AND created_at > ?", value, since).
+		Count(&count).Error
+
+	return count, err
+}
+
+func (r *gormSendAttemptRepository) ConversionByPrefix(ctx context.Context, prefix string, from, to time.Time) (repositories.SendConversion, error) {
+	return r.conversion(ctx, "prefix", prefix, from, to)
+}
+
+func (r *gormSendAttemptRepository) ConversionByRegion(ctx context.Context, region string, from, to time.Time) (repositories.SendConversion, error) {
+	return r.conversion(ctx, "region", region, from, to)
+}
+
+func (r *gormSendAttemptRepository) conversion(ctx context.Context, column, value string, from, to time.Time) (repositories.SendConversion, error) {
+	var conversion repositories.SendConversion
+	err := dbFromContext(ctx, r.db).
+		Model(&entities.SendAttempt{}).
+		Select("COUNT(*) AS sent, COUNT(verified_at) AS verified").
+		Where(column+" = ? AND decision = ? AND created_at > ? AND created_at <= ?",
+			value, entities.FraudAllow, from, to).
+		Scan(&conversion).Error
+
+	return conversion, err
+}
+
+func (r *gormSendAttemptRepository) MarkVerified(ctx context.Context, otpID string, verifiedAt time.Time) error {
+	return dbFromContext(ctx, r.db).
+		Model(&entities.SendAttempt{}).
+		Where("otp_id = ? AND verified_at IS NULL", otpID).
+		Update("verified_at", verifiedAt).Error
+}
+
+func (r *gormSendAttemptRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	return dbFromContext(ctx, r.db).
+		Where("created_at < ?", before).
+		Delete(&entities.SendAttempt{}).Error
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"sms-otp-service/internal/domain/repositories"
+)
+
+type txContextKey struct{}
+
+// txState is the transaction stored in the context by WithinTransaction,
+// along with the hooks to run when it ends.
+type txState struct {
+	tx         *gorm.DB
+	onCommit   []func(ctx context.Context)
+	onRollback []func(ctx context.Context)
+}
+
+type gormTransactionManager struct {
+	db *gorm.DB
+}
+
+func NewGormTransactionManager(db *gorm.DB) repositories.TransactionManager {
+	return &gormTransactionManager{db: db}
+}
+
+// WithinTransaction nests transactions with savepoints. The hooks of a
+// nested transaction that commits are handed to the enclosing one, since
+// its work can still be rolled back; those of one that rolls back run at
+// once.
+func (m *gormTransactionManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	state := &txState{}
+	err := dbFromContext(ctx, m.db).Transaction(func(tx *gorm.DB) error {
+		state.tx = tx
+		return fn(context.WithValue(ctx, txContextKey{}, state))
+	})
+
+	outer, nested := ctx.Value(txContextKey{}).(*txState)
+	switch {
+	case err != nil:
+		runHooks(ctx, state.onRollback)
+	case nested:
+		outer.onCommit = append(outer.onCommit, state.onCommit...)
+		outer.onRollback = append(outer.onRollback, state.onRollback...)
+	default:
+		runHooks(ctx, state.onCommit)
+	}
+	return err
+}
+
+func (m *gormTransactionManager) AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		state.onCommit = append(state.onCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+func (m *gormTransactionManager) AfterRollback(ctx context.Context, fn func(ctx context.Context)) {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		state.onRollback = append(state.onRollback, fn)
+	}
+}
+
+func runHooks(ctx context.Context, hooks []func(ctx context.Context)) {
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+}
+
+// dbFromContext returns the transaction stored in ctx by WithinTransaction,
+// or db when ctx carries none.
+func dbFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return state.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
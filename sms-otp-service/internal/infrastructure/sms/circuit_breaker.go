@@ -0,0 +1,157 @@
+package sms
+
+import (
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting probes
+	// through.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is both the number of concurrent probes allowed while
+	// half-open and the number of successful probes needed to close again.
+	HalfOpenProbes int
+}
+
+// circuitBreaker guards a single provider. A closed breaker lets every call
+// through; after FailureThreshold consecutive failures it opens and rejects
+// calls for OpenTimeout, then moves to half-open and admits a limited number
+// of probes. Enough successful probes close it, any failed probe reopens it.
+type circuitBreaker struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu             sync.Mutex
+	state          BreakerState
+	failures       int
+	openedAt       time.Time
+	probesInFlight int
+	probeSuccesses int
+}
+
+func newCircuitBreaker(cfg BreakerConfig) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+
+	return &circuitBreaker{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by exactly one of Success, Failure or Release.
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probesInFlight = 0
+		b.probeSuccesses = 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probesInFlight >= b.cfg.HalfOpenProbes {
+			return false
+		}
+		b.probesInFlight++
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state != BreakerHalfOpen {
+		return
+	}
+
+	if b.probesInFlight > 0 {
+		b.probesInFlight--
+	}
+	b.probeSuccesses++
+	if b.probeSuccesses >= b.cfg.HalfOpenProbes {
+		b.state = BreakerClosed
+	}
+}
+
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.open()
+	case BreakerClosed:
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.open()
+		}
+	}
+}
+
+// Release ends a call whose outcome says nothing about provider health.
+func (b *circuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probesInFlight > 0 {
+		b.probesInFlight--
+	}
+}
+
+func (b *circuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+func (b *circuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+	b.failures = 0
+	b.probesInFlight = 0
+	b.probeSuccesses = 0
+}
@@ -0,0 +1,184 @@
+package sms
+
+import (
+	"testing"
+	"time"
+)
+
+// testClock is a settable time source for circuit breakers.
+type testClock struct {
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestBreaker(clock *testClock, cfg BreakerConfig) *circuitBreaker {
+	b := newCircuitBreaker(cfg)
+	b.now = clock.Now
+	return b
+}
+
+// tripBreaker drives b from closed to open.
+func tripBreaker(t *testing.T, b *circuitBreaker) {
+	t.Helper()
+	for i := 0; i < b.cfg.FailureThreshold; i++ {
+		if !b.Allow() {
+			t.Fatalf("call %d rejected before the breaker opened", i+1)
+		}
+		b.Failure()
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s after %d failures, want open", b.State(), b.cfg.FailureThreshold)
+	}
+}
+
+func TestCircuitBreakerFailureThreshold(t *testing.T) {
+	clock := newTestClock()
+	b := newTestBreaker(clock, BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute})
+
+	// Failures only count while consecutive.
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	b.Failure()
+	if b.State() != BreakerClosed || !b.Allow() {
+		t.Fatalf("state = %s after a success broke the run of failures, want closed", b.State())
+	}
+
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s after 3 consecutive failures, want open", b.State())
+	}
+	if b.Allow() {
+		t.Error("open breaker allowed a call")
+	}
+}
+
+func TestCircuitBreakerDefaults(t *testing.T) {
+	b := newCircuitBreaker(BreakerConfig{})
+	if b.cfg.FailureThreshold != 5 || b.cfg.OpenTimeout != 30*time.Second || b.cfg.HalfOpenProbes != 1 {
+		t.Errorf("defaults = %+v", b.cfg)
+	}
+}
+
+func TestCircuitBreakerOpenTimeout(t *testing.T) {
+	clock := newTestClock()
+	b := newTestBreaker(clock, BreakerConfig{FailureThreshold: 1, OpenTimeout: 30 * time.Second})
+	tripBreaker(t, b)
+
+	clock.Advance(30*time.Second - time.Nanosecond)
+	if b.State() != BreakerOpen || b.Allow() {
+		t.Fatalf("breaker let a call through before OpenTimeout")
+	}
+
+	clock.Advance(time.Nanosecond)
+	if b.State() != BreakerHalfOpen {
+		t.Errorf("state = %s once OpenTimeout passed, want half-open", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("half-open breaker rejected the first probe")
+	}
+	b.Success()
+	if b.State() != BreakerClosed {
+		t.Errorf("state = %s after a successful probe, want closed", b.State())
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbes(t *testing.T) {
+	clock := newTestClock()
+	b := newTestBreaker(clock, BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenProbes: 2})
+	tripBreaker(t, b)
+	clock.Advance(time.Second)
+
+	if !b.Allow() || !b.Allow() {
+		t.Fatal("half-open breaker rejected one of its two probes")
+	}
+	if b.Allow() {
+		t.Fatal("half-open breaker allowed a third concurrent probe")
+	}
+
+	// A finished probe frees its slot, and one success is not enough to
+	// close the breaker.
+	b.Success()
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s after one of two probes succeeded, want half-open", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("half-open breaker did not free the slot of a finished probe")
+	}
+
+	b.Success()
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s after two successful probes, want closed", b.State())
+	}
+	b.Success()
+	if b.State() != BreakerClosed || !b.Allow() {
+		t.Errorf("closed breaker rejected a call")
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	clock := newTestClock()
+	b := newTestBreaker(clock, BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute, HalfOpenProbes: 2})
+	tripBreaker(t, b)
+	clock.Advance(time.Minute)
+
+	if !b.Allow() || !b.Allow() {
+		t.Fatal("half-open breaker rejected a probe")
+	}
+	b.Success()
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s after a failed probe, want open", b.State())
+	}
+
+	// The open timeout starts again from the failed probe.
+	clock.Advance(time.Minute - time.Nanosecond)
+	if b.Allow() {
+		t.Fatal("reopened breaker let a call through before OpenTimeout")
+	}
+	clock.Advance(time.Nanosecond)
+	if !b.Allow() {
+		t.Fatal("reopened breaker rejected a probe after OpenTimeout")
+	}
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	clock := newTestClock()
+	b := newTestBreaker(clock, BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenProbes: 1})
+
+	// Released calls neither count as failures nor reset them.
+	b.Failure()
+	b.Allow()
+	b.Release()
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s, want a released call to leave the failure count alone", b.State())
+	}
+
+	clock.Advance(time.Minute)
+	if !b.Allow() {
+		t.Fatal("half-open breaker rejected the probe")
+	}
+	if b.Allow() {
+		t.Fatal("half-open breaker allowed a second probe")
+	}
+	b.Release()
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s after a released probe, want half-open", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("released probe did not free its slot")
+	}
+	b.Success()
+	if b.State() != BreakerClosed {
+		t.Errorf("state = %s after a successful probe, want closed", b.State())
+	}
+}
@@ -0,0 +1,132 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sms-otp-service/internal/domain/entities"
+)
+
+var ErrAllProvidersFailed = errors.New("sms: all providers failed")
+
+// BreakerReporter is implemented by providers that guard others with circuit
+// breakers.
+type BreakerReporter interface {
+	// BreakerStates returns the circuit state of every guarded provider by
+	// name.
+	BreakerStates() map[string]BreakerState
+}
+
+// NamedService pairs a provider with the name it is configured under.
+type NamedService struct {
+	Name    string
+	Service Service
+}
+
+type failoverProvider struct {
+	name    string
+	service Service
+	breaker *circuitBreaker
+}
+
+type failoverSMSService struct {
+	providers []*failoverProvider
+	logger    *logrus.Logger
+}
+
+// NewFailoverSMSService tries providers in order until one accepts the
+// message. Each provider sits behind its own circuit breaker so a vendor
+// outage is skipped quickly instead of adding a timeout to every send.
+func NewFailoverSMSService(providers []NamedService, breaker BreakerConfig, logger *logrus.Logger) Service {
+	s := &failoverSMSService{logger: logger}
+	for _, p := range providers {
+		s.providers = append(s.providers, &failoverProvider{
+			name:    p.Name,
+			service: p.Service,
+			breaker: newCircuitBreaker(breaker),
+		})
+	}
+	return s
+}
+
+func (s *failoverSMSService) SendSMS(ctx context.Context, phoneNumber, message string) (*entities.SendResult, error) {
+	var lastErr error
+	for _, p := range s.providers {
+		if !p.breaker.Allow() {
+			s.logger.WithField("provider", p.name).Debug("Skipping SMS provider with open circuit")
+			continue
+		}
+
+		result, err := p.service.SendSMS(ctx, phoneNumber, message)
+		if err == nil {
+			p.breaker.Success()
+			if result == nil {
+				result = &entities.SendResult{}
+			}
+			if result.Provider == "" {
+				result.Provider = p.name
+			}
+			s.logger.WithFields(logrus.Fields{
+				"provider":     p.name,
+				"phone_number": phoneNumber,
+			}).Info("SMS delivered via failover chain")
+			return result, nil
+		}
+
+		lastErr = err
+		logEntry := s.logger.WithError(err).WithField("provider", p.name)
+
+		switch {
+		case errors.Is(err, ErrInvalidNumber):
+			// Every provider would reject the number the same way.
+			p.breaker.Release()
+			return nil, err
+		case ctx.Err() != nil:
+			p.breaker.Release()
+			return nil, err
+		case errors.Is(err, ErrUnreachable), errors.Is(err, ErrRejected):
+			// The provider is healthy, it just can't take this message.
+			p.breaker.Release()
+			logEntry.Warn("SMS provider refused message, trying next provider")
+		default:
+			p.breaker.Failure()
+			logEntry.WithField("circuit", p.breaker.State()).Warn("SMS provider failed, trying next provider")
+		}
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("%w: every circuit is open", ErrAllProvidersFailed)
+	}
+	return nil, fmt.Errorf("%w: %w", ErrAllProvidersFailed, lastErr)
+}
+
+// BreakerStates reports the circuit state of every provider in the chain.
+func (s *failoverSMSService) BreakerStates() map[string]BreakerState {
+	states := make(map[string]BreakerState, len(s.providers))
+	for _, p := range s.providers {
+		states[p.name] = p.breaker.State()
+	}
+	return states
+}
+
+// OnReceipt registers handler with every provider in the chain that reports
+// delivery receipts itself.
+func (s *failoverSMSService) OnReceipt(handler ReceiptHandler) {
+	for _, p := range s.providers {
+		if source, ok := p.service.(ReceiptSource); ok {
+			source.OnReceipt(handler)
+		}
+	}
+}
+
+func (s *failoverSMSService) Close() error {
+	var errs []error
+	for _, p := range s.providers {
+		if closer, ok := p.service.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
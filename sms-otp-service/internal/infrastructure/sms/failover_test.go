@@ -0,0 +1,199 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"sms-otp-service/internal/domain/entities"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubService answers SendSMS with err, or a result without a provider name
+// when err is nil, and counts its calls.
+type stubService struct {
+	err   error
+	calls int
+}
+
+func (s *stubService) SendSMS(ctx context.Context, phoneNumber, message string) (*entities.SendResult, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &entities.SendResult{MessageID: "msg-1"}, nil
+}
+
+func newTestFailover(clock *testClock, cfg BreakerConfig, names []string, services ...*stubService) *failoverSMSService {
+	var providers []NamedService
+	for i, service := range services {
+		providers = append(providers, NamedService{Name: names[i], Service: service})
+	}
+	s := NewFailoverSMSService(providers, cfg, testLogger()).(*failoverSMSService)
+	for _, p := range s.providers {
+		p.breaker.now = clock.Now
+	}
+	return s
+}
+
+func TestFailoverOrder(t *testing.T) {
+	unavailable := &ProviderError{Provider: "primary", Code: "503", Err: ErrProviderUnavailable}
+
+	tests := []struct {
+		name         string
+		primary      error
+		secondary    error
+		want         error
+		wantProvider string
+		wantCalls    [2]int
+		wantPrimary  BreakerState
+	}{
+		{
+			name:         "primary succeeds",
+			wantProvider: "primary",
+			wantCalls:    [2]int{1, 0},
+			wantPrimary:  BreakerClosed,
+		},
+		{
+			name:         "primary unavailable",
+			primary:      unavailable,
+			wantProvider: "secondary",
+			wantCalls:    [2]int{1, 1},
+			wantPrimary:  BreakerOpen,
+		},
+		{
+			name:         "primary refuses the message",
+			primary:      ErrRejected,
+			wantProvider: "secondary",
+			wantCalls:    [2]int{1, 1},
+			wantPrimary:  BreakerClosed,
+		},
+		{
+			name:         "primary cannot reach the handset",
+			primary:      ErrUnreachable,
+			wantProvider: "secondary",
+			wantCalls:    [2]int{1, 1},
+			wantPrimary:  BreakerClosed,
+		},
+		{
+			name:        "invalid number",
+			primary:     &ProviderError{Provider: "primary", Code: "21211", Err: ErrInvalidNumber},
+			want:        ErrInvalidNumber,
+			wantCalls:   [2]int{1, 0},
+			wantPrimary: BreakerClosed,
+		},
+		{
+			name:        "every provider fails",
+			primary:     unavailable,
+			secondary:   ErrThrottled,
+			want:        ErrThrottled,
+			wantCalls:   [2]int{1, 1},
+			wantPrimary: BreakerOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubService{err: tt.primary}
+			secondary := &stubService{err: tt.secondary}
+			s := newTestFailover(newTestClock(), BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute},
+				[]string{"primary", "secondary"}, primary, secondary)
+
+			result, err := s.SendSMS(context.Background(), testRecipient, "Your code is 123456")
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("SendSMS error = %v, want %v", err, tt.want)
+				}
+			} else if err != nil {
+				t.Fatalf("SendSMS: %v", err)
+			} else if result.Provider != tt.wantProvider || result.MessageID != "msg-1" {
+				t.Errorf("result = %+v, want provider %s", result, tt.wantProvider)
+			}
+
+			if calls := [2]int{primary.calls, secondary.calls}; calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if state := s.BreakerStates()["primary"]; state != tt.wantPrimary {
+				t.Errorf("primary circuit = %s, want %s", state, tt.wantPrimary)
+			}
+		})
+	}
+}
+
+func TestFailoverAllProvidersFailed(t *testing.T) {
+	primary := &stubService{err: ErrProviderUnavailable}
+	secondary := &stubService{err: ErrThrottled}
+	s := newTestFailover(newTestClock(), BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute},
+		[]string{"primary", "secondary"}, primary, secondary)
+
+	_, err := s.SendSMS(context.Background(), testRecipient, "Your code is 123456")
+	if !errors.Is(err, ErrAllProvidersFailed) || !errors.Is(err, ErrThrottled) {
+		t.Fatalf("SendSMS error = %v, want %v wrapping the last error", err, ErrAllProvidersFailed)
+	}
+
+	_, err = s.SendSMS(context.Background(), testRecipient, "Your code is 123456")
+	if !errors.Is(err, ErrAllProvidersFailed) || !strings.Contains(err.Error(), "every circuit is open") {
+		t.Fatalf("SendSMS error = %v, want every circuit open", err)
+	}
+	if primary.calls != 1 || secondary.calls != 1 {
+		t.Errorf("calls = %d, %d, want open circuits skipped", primary.calls, secondary.calls)
+	}
+}
+
+func TestFailoverSkipsOpenCircuit(t *testing.T) {
+	clock := newTestClock()
+	primary := &stubService{err: ErrProviderUnavailable}
+	secondary := &stubService{}
+	s := newTestFailover(clock, BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute},
+		[]string{"primary", "secondary"}, primary, secondary)
+
+	send := func() string {
+		t.Helper()
+		result, err := s.SendSMS(context.Background(), testRecipient, "Your code is 123456")
+		if err != nil {
+			t.Fatalf("SendSMS: %v", err)
+		}
+		return result.Provider
+	}
+
+	send()
+	send()
+	if primary.calls != 2 || s.BreakerStates()["primary"] != BreakerOpen {
+		t.Fatalf("primary called %d times, circuit %s, want open after 2 failures", primary.calls, s.BreakerStates()["primary"])
+	}
+
+	if provider := send(); provider != "secondary" || primary.calls != 2 {
+		t.Fatalf("sent via %s with %d primary calls, want the open primary skipped", provider, primary.calls)
+	}
+
+	// Once the open timeout passes the primary gets a probe, and a
+	// successful probe puts it back in front.
+	clock.Advance(time.Minute)
+	primary.err = nil
+	if provider := send(); provider != "primary" {
+		t.Fatalf("sent via %s after OpenTimeout, want the primary probed", provider)
+	}
+	if s.BreakerStates()["primary"] != BreakerClosed {
+		t.Errorf("primary circuit = %s after a successful probe, want closed", s.BreakerStates()["primary"])
+	}
+}
+
+func TestFailoverCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	primary := &stubService{err: context.Canceled}
+	secondary := &stubService{}
+	s := newTestFailover(newTestClock(), BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute},
+		[]string{"primary", "secondary"}, primary, secondary)
+
+	if _, err := s.SendSMS(ctx, testRecipient, "Your code is 123456"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendSMS error = %v, want %v", err, context.Canceled)
+	}
+	if secondary.calls != 0 {
+		t.Error("failover tried the next provider after the caller gave up")
+	}
+	if s.BreakerStates()["primary"] != BreakerClosed {
+		t.Errorf("primary circuit = %s, want a canceled call not counted", s.BreakerStates()["primary"])
+	}
+}
@@ -0,0 +1,249 @@
+package sms
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReceiptPath is the path, relative to the public URL, that providers post
+// delivery receipts to. The provider name is appended.
+const ReceiptPath = "/api/v1/dlr/"
+
+// ReceiptHandler receives delivery receipts that arrive over a provider's own
+// connection rather than the DLR webhook.
+type ReceiptHandler func(receipt *entities.DeliveryReceipt)
+
+// ReceiptSource is implemented by providers that report delivery receipts
+// themselves, such as an SMPP bind.
+type ReceiptSource interface {
+	OnReceipt(handler ReceiptHandler)
+}
+
+// ReceiptDecoder parses the delivery receipt callbacks a provider posts to
+// the DLR webhook. Callbacks that carry only an intermediate status decode to
+// no receipts.
+type ReceiptDecoder interface {
+	DecodeReceipts(requestURL string, header http.Header, body []byte) ([]*entities.DeliveryReceipt, error)
+}
+
+// NewReceiptDecoders returns the receipt decoders of the configured
+// providers that report delivery over HTTP, keyed by provider name. Every
+// decoder authenticates its callbacks: Twilio's by their signature, the
+// others by the shared DLR token. A provider without the secret its
+// callbacks are checked with gets no decoder, so its receipts are refused.
+func NewReceiptDecoders(cfg config.SMSConfig, logger *logrus.Logger) map[string]ReceiptDecoder {
+	decoders := make(map[string]ReceiptDecoder)
+	for _, name := range configuredProviders(cfg) {
+		var decoder ReceiptDecoder
+		secret, secretEnv := cfg.DLR.Token, "SMS_DLR_TOKEN"
+		switch name {
+		case "twilio":
+			decoder = newTwilioReceiptDecoder(cfg)
+			secret, secretEnv = cfg.APISecret, "SMS_API_SECRET"
+		case "webhook":
+			decoder = &fieldReceiptDecoder{
+				provider:   "webhook",
+				idPath:     cfg.Webhook.ReceiptIDPath,
+				statusPath: cfg.Webhook.ReceiptStatusPath,
+				errorPath:  cfg.Webhook.ReceiptErrorPath,
+				statusMap:  cfg.Webhook.ReceiptStatusMap,
+				token:      cfg.DLR.Token,
+			}
+		case "mock":
+			decoder = &fieldReceiptDecoder{
+				provider:   "mock",
+				idPath:     "message_id",
+				statusPath: "status",
+				errorPath:  "error_code",
+				token:      cfg.DLR.Token,
+			}
+		default:
+			continue
+		}
+
+		if secret == "" {
+			logger.WithField("provider", name).Warn(secretEnv + " is not set, refusing delivery receipts")
+			continue
+		}
+		decoders[name] = decoder
+	}
+	return decoders
+}
+
+// configuredProviders returns the names of the providers SMS_PROVIDER sends
+// through, looking into failover and routing providers.
+func configuredProviders(cfg config.SMSConfig) []string {
+	switch cfg.Provider {
+	case "failover":
+		return cfg.Failover.Providers
+	case "routing":
+		var names []string
+		for _, route := range cfg.Routes {
+			for _, target := range route.Targets {
+				names = append(names, target.Provider)
+			}
+		}
+		return names
+	default:
+		return []string{cfg.Provider}
+	}
+}
+
+// receiptStatuses maps the status words used by common providers and by SMPP
+// receipts (DELIVRD, UNDELIV, ...) to delivery statuses. Statuses that only
+// say the message is still on its way to the carrier are left out.
+var receiptStatuses = map[string]entities.DeliveryStatus{
+	"sent":        entities.DeliverySent,
+	"enroute":     entities.DeliverySent,
+	"delivered":   entities.DeliveryDelivered,
+	"delivrd":     entities.DeliveryDelivered,
+	"acceptd":     entities.DeliveryDelivered,
+	"read":        entities.DeliveryDelivered,
+	"failed":      entities.DeliveryFailed,
+	"undelivered": entities.DeliveryFailed,
+	"undeliv":     entities.DeliveryFailed,
+	"rejected":    entities.DeliveryFailed,
+	"rejectd":     entities.DeliveryFailed,
+	"deleted":     entities.DeliveryFailed,
+	"canceled":    entities.DeliveryFailed,
+	"expired":     entities.DeliveryExpired,
+}
+
+// normalizeReceiptStatus maps a provider status to a delivery status,
+// consulting overrides first. It reports false for statuses that carry no
+// delivery information.
+func normalizeReceiptStatus(raw string, overrides map[string]string) (entities.DeliveryStatus, bool) {
+	raw = strings.TrimSpace(raw)
+	for key, value := range overrides {
+		if strings.EqualFold(key, raw) {
+			status := entities.DeliveryStatus(strings.ToLower(value))
+			return status, status.IsValid() && status != entities.DeliveryQueued
+		}
+	}
+
+	status, ok := receiptStatuses[strings.ToLower(raw)]
+	return status, ok
+}
+
+// fieldReceiptDecoder reads receipts whose fields sit at configurable paths
+// in a JSON or form encoded body. A JSON array carries several receipts.
+// Callers authenticate with the shared DLR token, sent in the X-DLR-Token
+// header or the token query parameter.
+type fieldReceiptDecoder struct {
+	provider   string
+	idPath     string
+	statusPath string
+	errorPath  string
+	statusMap  map[string]string
+	token      string
+}
+
+func (d *fieldReceiptDecoder) DecodeReceipts(requestURL string, header http.Header, body []byte) ([]*entities.DeliveryReceipt, error) {
+	if err := checkReceiptToken(d.token, requestURL, header); err != nil {
+		return nil, err
+	}
+
+	var documents []any
+	if strings.HasPrefix(header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", entities.ErrInvalidDeliveryReceipt, err)
+		}
+		document := make(map[string]any, len(values))
+		for key := range values {
+			document[key] = values.Get(key)
+		}
+		documents = append(documents, document)
+	} else {
+		var decoded any
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			return nil, fmt.Errorf("%w: %v", entities.ErrInvalidDeliveryReceipt, err)
+		}
+		if list, ok := decoded.([]any); ok {
+			documents = list
+		} else {
+			documents = append(documents, decoded)
+		}
+	}
+
+	var receipts []*entities.DeliveryReceipt
+	for _, document := range documents {
+		id, _ := lookupJSONPath(document, d.idPath)
+		rawStatus, _ := lookupJSONPath(document, d.statusPath)
+		if jsonString(id) == "" {
+			return nil, fmt.Errorf("%w: missing message id at %s", entities.ErrInvalidDeliveryReceipt, d.idPath)
+		}
+
+		status, ok := normalizeReceiptStatus(jsonString(rawStatus), d.statusMap)
+		if !ok {
+			continue
+		}
+
+		receipt := &entities.DeliveryReceipt{
+			Provider:    d.provider,
+			MessageID:   jsonString(id),
+			Status:      status,
+			Description: jsonString(rawStatus),
+		}
+		if d.errorPath != "" {
+			if code, found := lookupJSONPath(document, d.errorPath); found {
+				receipt.ErrorCode = jsonString(code)
+			}
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, nil
+}
+
+func checkReceiptToken(token, requestURL string, header http.Header) error {
+	if token == "" {
+		return entities.ErrUnauthorizedReceipt
+	}
+
+	presented := header.Get("X-DLR-Token")
+	if presented == "" {
+		if parsed, err := url.Parse(requestURL); err == nil {
+			presented = parsed.Query().Get("token")
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+		return entities.ErrUnauthorizedReceipt
+	}
+	return nil
+}
+
+// receiptDispatcher holds the handler registered through OnReceipt. Providers
+// start receiving before the application has wired the handler, so receipts
+// that arrive in between are reported as dropped.
+type receiptDispatcher struct {
+	mu      sync.RWMutex
+	handler ReceiptHandler
+}
+
+func (d *receiptDispatcher) OnReceipt(handler ReceiptHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handler = handler
+}
+
+func (d *receiptDispatcher) dispatch(receipt *entities.DeliveryReceipt) bool {
+	d.mu.RLock()
+	handler := d.handler
+	d.mu.RUnlock()
+
+	if handler == nil {
+		return false
+	}
+	handler(receipt)
+	return true
+}
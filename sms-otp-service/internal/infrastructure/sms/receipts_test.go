@@ -0,0 +1,116 @@
+package sms
+
+import (
+	"errors"
+	"net/http"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewReceiptDecoders(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SMSConfig
+		want []string
+	}{
+		{
+			name: "mock without a token",
+			cfg:  config.SMSConfig{Provider: "mock"},
+		},
+		{
+			name: "mock",
+			cfg:  config.SMSConfig{Provider: "mock", DLR: config.DLRConfig{Token: "dlr"}},
+			want: []string{"mock"},
+		},
+		{
+			name: "twilio",
+			cfg:  config.SMSConfig{Provider: "twilio", APISecret: testAuthToken, DLR: config.DLRConfig{Token: "dlr"}},
+			want: []string{"twilio"},
+		},
+		{
+			name: "twilio without an auth token",
+			cfg:  config.SMSConfig{Provider: "twilio", DLR: config.DLRConfig{Token: "dlr"}},
+		},
+		{
+			name: "smpp reports over the bind",
+			cfg:  config.SMSConfig{Provider: "smpp", DLR: config.DLRConfig{Token: "dlr"}},
+		},
+		{
+			name: "failover",
+			cfg: config.SMSConfig{
+				Provider:  "failover",
+				APISecret: testAuthToken,
+				Failover:  config.FailoverConfig{Providers: []string{"twilio", "smpp", "webhook"}},
+				DLR:       config.DLRConfig{Token: "dlr"},
+			},
+			want: []string{"twilio", "webhook"},
+		},
+		{
+			name: "routing without a token",
+			cfg: config.SMSConfig{
+				Provider:  "routing",
+				APISecret: testAuthToken,
+				Routes: []config.SMSRoute{
+					{Prefix: "994", Targets: []config.SMSRouteTarget{{Provider: "webhook", Weight: 1}}},
+					{Targets: []config.SMSRouteTarget{{Provider: "twilio", Weight: 1}}},
+				},
+			},
+			want: []string{"twilio"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for name := range NewReceiptDecoders(tt.cfg, testLogger()) {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("decoders = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldReceiptToken(t *testing.T) {
+	const (
+		requestURL = "http://localhost" + ReceiptPath + "webhook"
+		body       = `{"message_id": "m1", "status": "DELIVRD"}`
+	)
+
+	tests := []struct {
+		name   string
+		token  string
+		url    string
+		header string
+		want   error
+	}{
+		{name: "header", token: "dlr", url: requestURL, header: "dlr"},
+		{name: "query parameter", token: "dlr", url: requestURL + "?token=dlr"},
+		{name: "missing", token: "dlr", url: requestURL, want: entities.ErrUnauthorizedReceipt},
+		{name: "wrong", token: "dlr", url: requestURL + "?token=guess", header: "guess", want: entities.ErrUnauthorizedReceipt},
+		{name: "none configured", url: requestURL, want: entities.ErrUnauthorizedReceipt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := &fieldReceiptDecoder{provider: "webhook", idPath: "message_id", statusPath: "status", token: tt.token}
+			header := http.Header{"Content-Type": {"application/json"}}
+			if tt.header != "" {
+				header.Set("X-DLR-Token", tt.header)
+			}
+
+			receipts, err := decoder.DecodeReceipts(tt.url, header, []byte(body))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("DecodeReceipts = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (len(receipts) != 1 || receipts[0].Status != entities.DeliveryDelivered) {
+				t.Errorf("receipts = %+v, want one delivered receipt", receipts)
+			}
+		})
+	}
+}
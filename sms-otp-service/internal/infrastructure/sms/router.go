@@ -0,0 +1,167 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"math/rand/v2"
+	"sms-otp-service/internal/domain/entities"
+	"sort"
+	"strings"
+)
+
+var ErrNoRoute = errors.New("sms: no route for destination")
+
+// Route sends numbers starting with Prefix (E.164 digits without the plus)
+// to one of Targets, picked at random in proportion to their weights. An
+// empty prefix matches every number and acts as the default route.
+type Route struct {
+	Prefix  string
+	Targets []RouteTarget
+}
+
+type RouteTarget struct {
+	Name    string
+	Service Service
+	Weight  int
+}
+
+type routingSMSService struct {
+	routes []Route
+	intN   func(n int) int
+	logger *logrus.Logger
+}
+
+// NewRoutingSMSService picks a provider per message by the longest matching
+// destination prefix, splitting traffic between the route's targets by
+// weight.
+func NewRoutingSMSService(routes []Route, logger *logrus.Logger) Service {
+	sorted := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		route.Prefix = strings.TrimPrefix(route.Prefix, "+")
+
+		var targets []RouteTarget
+		for _, target := range route.Targets {
+			if target.Weight > 0 {
+				targets = append(targets, target)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		route.Targets = targets
+		sorted = append(sorted, route)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	return &routingSMSService{
+		routes: sorted,
+		intN:   rand.IntN,
+		logger: logger,
+	}
+}
+
+func (s *routingSMSService) SendSMS(ctx context.Context, phoneNumber, message string) (*entities.SendResult, error) {
+	route, ok := s.match(phoneNumber)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoRoute, phoneNumber)
+	}
+
+	target := s.pick(route.Targets)
+	s.logger.WithFields(logrus.Fields{
+		"prefix":   route.Prefix,
+		"provider": target.Name,
+	}).Debug("Routing SMS")
+
+	result, err := target.Service.SendSMS(ctx, phoneNumber, message)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = &entities.SendResult{}
+	}
+	if result.Provider == "" {
+		result.Provider = target.Name
+	}
+
+	return result, nil
+}
+
+func (s *routingSMSService) match(phoneNumber string) (Route, bool) {
+	digits := strings.TrimPrefix(phoneNumber, "+")
+	for _, route := range s.routes {
+		if strings.HasPrefix(digits, route.Prefix) {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+func (s *routingSMSService) pick(targets []RouteTarget) RouteTarget {
+	if len(targets) == 1 {
+		return targets[0]
+	}
+
+	total := 0
+	for _, target := range targets {
+		total += target.Weight
+	}
+
+	n := s.intN(total)
+	for _, target := range targets {
+		if n < target.Weight {
+			return target
+		}
+		n -= target.Weight
+	}
+	return targets[len(targets)-1]
+}
+
+// OnReceipt registers handler with every routed provider that reports
+// delivery receipts itself.
+func (s *routingSMSService) OnReceipt(handler ReceiptHandler) {
+	for _, route := range s.routes {
+		for _, target := range route.Targets {
+			if source, ok := target.Service.(ReceiptSource); ok {
+				source.OnReceipt(handler)
+			}
+		}
+	}
+}
+
+// BreakerStates reports the circuit states of every routed failover chain.
+func (s *routingSMSService) BreakerStates() map[string]BreakerState {
+	states := make(map[string]BreakerState)
+	for _, route := range s.routes {
+		for _, target := range route.Targets {
+			if reporter, ok := target.Service.(BreakerReporter); ok {
+				for name, state := range reporter.BreakerStates() {
+					states[name] = state
+				}
+			}
+		}
+	}
+	return states
+}
+
+func (s *routingSMSService) Close() error {
+	closed := make(map[Service]bool)
+	var errs []error
+	for _, route := range s.routes {
+		for _, target := range route.Targets {
+			if closed[target.Service] {
+				continue
+			}
+			closed[target.Service] = true
+			if closer, ok := target.Service.(io.Closer); ok {
+				errs = append(errs, closer.Close())
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
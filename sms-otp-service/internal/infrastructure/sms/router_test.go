@@ -0,0 +1,131 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newTestRouter returns a router over routes whose random source answers n
+// and records the bound it was asked for.
+func newTestRouter(routes []Route, n *int, bound *int) *routingSMSService {
+	s := NewRoutingSMSService(routes, testLogger()).(*routingSMSService)
+	s.intN = func(total int) int {
+		*bound = total
+		return *n
+	}
+	return s
+}
+
+func TestRouterLongestPrefix(t *testing.T) {
+	target := func(name string) []RouteTarget {
+		return []RouteTarget{{Name: name, Service: &stubService{}, Weight: 1}}
+	}
+	routes := []Route{
+		{Prefix: "", Targets: target("default")},
+		{Prefix: "+994", Targets: target("azerbaijan")},
+		{Prefix: "99450", Targets: target("azercell")},
+		{Prefix: "7", Targets: target("russia")},
+		{Prefix: "77", Targets: target("kazakhstan")},
+		{Prefix: "90", Targets: []RouteTarget{{Name: "disabled", Service: &stubService{}, Weight: 0}}},
+	}
+
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"+994501234567", "azercell"},
+		{"+994551234567", "azerbaijan"},
+		{"+994123456789", "azerbaijan"},
+		{"+79123456789", "russia"},
+		{"+77012345678", "kazakhstan"},
+		{"+905321234567", "default"},
+		{"+14155550100", "default"},
+	}
+
+	var n, bound int
+	s := newTestRouter(routes, &n, &bound)
+	for _, tt := range tests {
+		result, err := s.SendSMS(context.Background(), tt.number, "Your code is 123456")
+		if err != nil {
+			t.Errorf("SendSMS(%s): %v", tt.number, err)
+			continue
+		}
+		if result.Provider != tt.want {
+			t.Errorf("SendSMS(%s) routed to %s, want %s", tt.number, result.Provider, tt.want)
+		}
+	}
+}
+
+func TestRouterNoRoute(t *testing.T) {
+	var n, bound int
+	s := newTestRouter([]Route{
+		{Prefix: "994", Targets: []RouteTarget{{Name: "azerbaijan", Service: &stubService{}, Weight: 1}}},
+		{Prefix: "", Targets: []RouteTarget{{Name: "disabled", Service: &stubService{}, Weight: 0}}},
+	}, &n, &bound)
+
+	if _, err := s.SendSMS(context.Background(), "+14155550100", "Your code is 123456"); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("SendSMS error = %v, want %v", err, ErrNoRoute)
+	}
+}
+
+func TestRouterWeightedSplit(t *testing.T) {
+	targets := []RouteTarget{
+		{Name: "twilio", Service: &stubService{}, Weight: 1},
+		{Name: "disabled", Service: &stubService{}, Weight: 0},
+		{Name: "smpp", Service: &stubService{}, Weight: 3},
+	}
+
+	// The random draw falls in [0, 4); the first unit goes to twilio and
+	// the next three to smpp.
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "twilio"},
+		{1, "smpp"},
+		{2, "smpp"},
+		{3, "smpp"},
+	}
+
+	var n, bound int
+	s := newTestRouter([]Route{{Prefix: "1", Targets: targets}}, &n, &bound)
+	for _, tt := range tests {
+		n = tt.n
+		result, err := s.SendSMS(context.Background(), testRecipient, "Your code is 123456")
+		if err != nil {
+			t.Fatalf("SendSMS: %v", err)
+		}
+		if result.Provider != tt.want {
+			t.Errorf("draw %d routed to %s, want %s", tt.n, result.Provider, tt.want)
+		}
+		if bound != 4 {
+			t.Errorf("drew from [0, %d), want [0, 4)", bound)
+		}
+	}
+}
+
+func TestRouterSingleTarget(t *testing.T) {
+	n, bound := 0, -1
+	s := newTestRouter([]Route{{Prefix: "", Targets: []RouteTarget{{Name: "twilio", Service: &stubService{}, Weight: 5}}}}, &n, &bound)
+
+	if _, err := s.SendSMS(context.Background(), testRecipient, "Your code is 123456"); err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if bound != -1 {
+		t.Error("router drew a random number for a route with one target")
+	}
+}
+
+func TestRouterProviderError(t *testing.T) {
+	failing := &stubService{err: &ProviderError{Provider: "twilio", Code: "21211", Err: ErrInvalidNumber}}
+	var n, bound int
+	s := newTestRouter([]Route{{Prefix: "", Targets: []RouteTarget{{Name: "twilio", Service: failing, Weight: 1}}}}, &n, &bound)
+
+	if _, err := s.SendSMS(context.Background(), testRecipient, "Your code is 123456"); !errors.Is(err, ErrInvalidNumber) {
+		t.Errorf("SendSMS error = %v, want the provider's error", err)
+	}
+	if failing.calls != 1 {
+		t.Errorf("provider called %d times, want once", failing.calls)
+	}
+}
@@ -0,0 +1,122 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sms-otp-service/internal/infrastructure/sms/smpp"
+)
+
+type smppSMSService struct {
+	receiptDispatcher
+	client *smpp.Client
+	source string
+	logger *logrus.Logger
+}
+
+// NewSMPPSMSService binds to an SMSC as a transceiver and submits messages
+// over that bind. The connection is established in the background; sends
+// wait up to the response timeout for a bound session. Delivery receipts
+// arrive over the same bind and are passed to the OnReceipt handler.
+func NewSMPPSMSService(cfg config.SMSConfig, logger *logrus.Logger) Service {
+	client := smpp.NewClient(smpp.Config{
+		Address:             cfg.SMPP.Address,
+		SystemID:            cfg.SMPP.SystemID,
+		Password:            cfg.SMPP.Password,
+		SystemType:          cfg.SMPP.SystemType,
+		EnquireLinkInterval: cfg.SMPP.EnquireLinkInterval,
+		ResponseTimeout:     cfg.SMPP.ResponseTimeout,
+		ReconnectMinBackoff: cfg.SMPP.ReconnectMinBackoff,
+		ReconnectMaxBackoff: cfg.SMPP.ReconnectMaxBackoff,
+	}, logger)
+
+	s := &smppSMSService{
+		client: client,
+		source: cfg.SenderName,
+		logger: logger,
+	}
+	client.OnDeliver(s.handleDeliver)
+	client.Start()
+
+	return s
+}
+
+func (s *smppSMSService) SendSMS(ctx context.Context, phoneNumber, message string) (*entities.SendResult, error) {
+	messageID, err := s.client.Submit(ctx, s.source, phoneNumber, message)
+	if err != nil {
+		return nil, mapSMPPError(err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"phone_number": phoneNumber,
+		"message_id":   messageID,
+	}).Info("SMPP message submitted")
+
+	return &entities.SendResult{Provider: "smpp", MessageID: messageID}, nil
+}
+
+func (s *smppSMSService) handleDeliver(msg *smpp.ShortMessage) {
+	dlr, ok := smpp.ParseDeliveryReceipt(msg)
+	if !ok {
+		s.logger.WithField("source", msg.SourceAddr).Debug("Ignoring SMPP deliver_sm that is not a delivery receipt")
+		return
+	}
+
+	logEntry := s.logger.WithFields(logrus.Fields{
+		"message_id": dlr.MessageID,
+		"stat":       dlr.Stat,
+	})
+
+	status, ok := normalizeReceiptStatus(dlr.Stat, nil)
+	if !ok {
+		logEntry.Debug("Ignoring intermediate SMPP delivery receipt")
+		return
+	}
+
+	receipt := &entities.DeliveryReceipt{
+		Provider:    "smpp",
+		MessageID:   dlr.MessageID,
+		Status:      status,
+		ErrorCode:   dlr.Error,
+		Description: dlr.Stat,
+	}
+	if !s.dispatch(receipt) {
+		logEntry.Warn("Dropping SMPP delivery receipt, no receipt handler registered")
+	}
+}
+
+// Close unbinds from the SMSC.
+func (s *smppSMSService) Close() error {
+	return s.client.Close()
+}
+
+func mapSMPPError(err error) error {
+	var statusErr *smpp.StatusError
+	if !errors.As(err, &statusErr) {
+		return &ProviderError{Provider: "smpp", Message: err.Error(), Err: ErrProviderUnavailable}
+	}
+
+	providerErr := &ProviderError{
+		Provider: "smpp",
+		Code:     fmt.Sprintf("0x%08X", statusErr.Status),
+		Message:  statusErr.Error(),
+	}
+
+	switch statusErr.Status {
+	case smpp.StatusInvalidDstAddr:
+		providerErr.Err = ErrInvalidNumber
+	case smpp.StatusThrottled, smpp.StatusMsgQueueFull:
+		providerErr.Err = ErrThrottled
+	case smpp.StatusInvalidPasswd, smpp.StatusInvalidSysID, smpp.StatusBindFailed:
+		providerErr.Err = ErrAuthentication
+	case smpp.StatusSystemError:
+		providerErr.Err = ErrProviderUnavailable
+	default:
+		providerErr.Err = ErrRejected
+	}
+
+	return providerErr
+}
@@ -0,0 +1,451 @@
+package smpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrClosed   = errors.New("smpp: client closed")
+	ErrNotBound = errors.New("smpp: no bound session")
+	ErrUnbound  = errors.New("smpp: session unbound by peer")
+
+	errWriteFailed = errors.New("smpp: write failed")
+)
+
+// multipartThreshold is the largest payload sent in short_message; longer
+// messages go in message_payload and are segmented by the SMSC.
+const multipartThreshold = 140
+
+// StatusError is returned when the SMSC answers a request with a non-zero
+// command_status.
+type StatusError struct {
+	Command CommandID
+	Status  uint32
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("smpp: %s failed with status 0x%08X", e.Command, e.Status)
+}
+
+type Config struct {
+	Address             string
+	SystemID            string
+	Password            string
+	SystemType          string
+	EnquireLinkInterval time.Duration
+	ResponseTimeout     time.Duration
+	ReconnectMinBackoff time.Duration
+	ReconnectMaxBackoff time.Duration
+}
+
+// DeliverHandler is called for every deliver_sm received from the SMSC, such
+// as delivery receipts. It runs on its own goroutine.
+type DeliverHandler func(msg *ShortMessage)
+
+// Client keeps a transceiver bind to an SMSC open, answering keepalives and
+// rebinding with exponential backoff whenever the connection drops.
+type Client struct {
+	cfg       Config
+	logger    *logrus.Logger
+	onDeliver DeliverHandler
+
+	mu      sync.Mutex
+	session *session
+	bound   chan struct{}
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewClient(cfg Config, logger *logrus.Logger) *Client {
+	if cfg.EnquireLinkInterval <= 0 {
+		cfg.EnquireLinkInterval = 30 * time.Second
+	}
+	if cfg.ResponseTimeout <= 0 {
+		cfg.ResponseTimeout = 10 * time.Second
+	}
+	if cfg.ReconnectMinBackoff <= 0 {
+		cfg.ReconnectMinBackoff = time.Second
+	}
+	if cfg.ReconnectMaxBackoff < cfg.ReconnectMinBackoff {
+		cfg.ReconnectMaxBackoff = cfg.ReconnectMinBackoff
+	}
+
+	return &Client{
+		cfg:    cfg,
+		logger: logger,
+		bound:  make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+// OnDeliver registers the handler for incoming deliver_sm PDUs. It must be
+// called before Start.
+func (c *Client) OnDeliver(handler DeliverHandler) {
+	c.onDeliver = handler
+}
+
+// Start begins connecting in the background.
+func (c *Client) Start() {
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Close unbinds the current session and stops reconnecting.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	c.wg.Wait()
+	return nil
+}
+
+// Submit sends text to destination and returns the SMSC message ID.
+func (c *Client) Submit(ctx context.Context, source, destination, text string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ResponseTimeout)
+	defer cancel()
+
+	data, dataCoding := EncodeText(text)
+	sourceTON, sourceNPI := addressType(source)
+	msg := &ShortMessage{
+		SourceAddrTON:      sourceTON,
+		SourceAddrNPI:      sourceNPI,
+		SourceAddr:         strings.TrimPrefix(source, "+"),
+		DestAddrTON:        0x01,
+		DestAddrNPI:        0x01,
+		DestinationAddr:    strings.TrimPrefix(destination, "+"),
+		RegisteredDelivery: 0x01,
+		DataCoding:         dataCoding,
+	}
+	if len(data) > multipartThreshold {
+		msg.TLVs = map[uint16][]byte{TagMessagePayload: data}
+	} else {
+		msg.ShortMessage = data
+	}
+
+	body, err := msg.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	// A dropped connection is often only noticed on the next write. Nothing
+	// reached the SMSC in that case, so the submit is retried once on the
+	// session that replaces it.
+	var resp *PDU
+	for attempt := 0; ; attempt++ {
+		sess, err := c.waitForSession(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		resp, err = sess.request(ctx, SubmitSM, body)
+		if err == nil {
+			break
+		}
+		if attempt > 0 || !errors.Is(err, errWriteFailed) {
+			return "", err
+		}
+		c.clearSession(sess)
+	}
+
+	if resp.Status != StatusOK {
+		return "", &StatusError{Command: SubmitSM, Status: resp.Status}
+	}
+
+	return ParseMessageIDBody(resp.Body), nil
+}
+
+func (c *Client) waitForSession(ctx context.Context) (*session, error) {
+	for {
+		c.mu.Lock()
+		sess, bound := c.session, c.bound
+		c.mu.Unlock()
+
+		if sess != nil {
+			return sess, nil
+		}
+
+		select {
+		case <-bound:
+		case <-c.closed:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrNotBound, ctx.Err())
+		}
+	}
+}
+
+func (c *Client) run() {
+	defer c.wg.Done()
+
+	backoff := c.cfg.ReconnectMinBackoff
+	for {
+		sess, err := c.connect()
+		if err != nil {
+			wait := jitter(backoff)
+			c.logger.WithError(err).WithFields(logrus.Fields{
+				"address": c.cfg.Address,
+				"retry":   wait,
+			}).Warn("SMPP bind failed")
+
+			select {
+			case <-time.After(wait):
+			case <-c.closed:
+				return
+			}
+
+			backoff = min(backoff*2, c.cfg.ReconnectMaxBackoff)
+			continue
+		}
+
+		backoff = c.cfg.ReconnectMinBackoff
+		c.setSession(sess)
+		c.logger.WithField("address", c.cfg.Address).Info("SMPP transceiver bound")
+
+		select {
+		case <-sess.done:
+			c.clearSession(sess)
+			c.logger.WithError(sess.err).Warn("SMPP session lost, reconnecting")
+		case <-c.closed:
+			c.clearSession(sess)
+			ctx, cancel := context.WithTimeout(context.Background(), c.cfg.ResponseTimeout)
+			if _, err := sess.request(ctx, Unbind, nil); err != nil {
+				c.logger.WithError(err).Warn("SMPP unbind failed")
+			}
+			cancel()
+			sess.close(ErrClosed)
+			return
+		}
+	}
+}
+
+func (c *Client) connect() (*session, error) {
+	conn, err := net.DialTimeout("tcp", c.cfg.Address, c.cfg.ResponseTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &session{
+		conn:         conn,
+		writeTimeout: c.cfg.ResponseTimeout,
+		logger:       c.logger,
+		onDeliver:    c.onDeliver,
+		pending:      make(map[uint32]chan *PDU),
+		done:         make(chan struct{}),
+	}
+	go sess.readLoop()
+
+	bind := &Bind{
+		SystemID:         c.cfg.SystemID,
+		Password:         c.cfg.Password,
+		SystemType:       c.cfg.SystemType,
+		InterfaceVersion: InterfaceV34,
+	}
+	body, _ := bind.MarshalBinary()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.ResponseTimeout)
+	defer cancel()
+
+	resp, err := sess.request(ctx, BindTransceiver, body)
+	if err != nil {
+		sess.close(err)
+		return nil, err
+	}
+	if resp.Status != StatusOK {
+		err := &StatusError{Command: BindTransceiver, Status: resp.Status}
+		sess.close(err)
+		return nil, err
+	}
+
+	go sess.keepalive(c.cfg.EnquireLinkInterval, c.cfg.ResponseTimeout)
+
+	return sess, nil
+}
+
+func (c *Client) setSession(sess *session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = sess
+	close(c.bound)
+}
+
+func (c *Client) clearSession(sess *session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == sess {
+		c.session = nil
+		c.bound = make(chan struct{})
+	}
+}
+
+type session struct {
+	conn         net.Conn
+	writeTimeout time.Duration
+	logger       *logrus.Logger
+	onDeliver    DeliverHandler
+
+	writeMu  sync.Mutex
+	sequence atomic.Uint32
+
+	mu      sync.Mutex
+	pending map[uint32]chan *PDU
+
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+func (s *session) close(err error) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		close(s.done)
+		_ = s.conn.Close()
+	})
+}
+
+func (s *session) nextSequence() uint32 {
+	// Sequence numbers are limited to 0x00000001-0x7FFFFFFF.
+	return s.sequence.Add(1)%0x7FFFFFFF + 1
+}
+
+func (s *session) write(pdu *PDU) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	return WritePDU(s.conn, pdu)
+}
+
+func (s *session) request(ctx context.Context, command CommandID, body []byte) (*PDU, error) {
+	seq := s.nextSequence()
+	ch := make(chan *PDU, 1)
+
+	s.mu.Lock()
+	s.pending[seq] = ch
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, seq)
+		s.mu.Unlock()
+	}()
+
+	if err := s.write(&PDU{CommandID: command, Sequence: seq, Body: body}); err != nil {
+		s.close(err)
+		return nil, fmt.Errorf("%w: %v", errWriteFailed, err)
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.CommandID == GenericNack {
+			return nil, &StatusError{Command: command, Status: resp.Status}
+		}
+		return resp, nil
+	case <-s.done:
+		return nil, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *session) readLoop() {
+	for {
+		pdu, err := ReadPDU(s.conn)
+		if err != nil {
+			s.close(err)
+			return
+		}
+
+		if pdu.CommandID.IsResponse() {
+			s.mu.Lock()
+			ch, ok := s.pending[pdu.Sequence]
+			s.mu.Unlock()
+			if ok {
+				ch <- pdu
+			}
+			continue
+		}
+
+		s.handleRequest(pdu)
+	}
+}
+
+func (s *session) handleRequest(pdu *PDU) {
+	var err error
+	switch pdu.CommandID {
+	case EnquireLink:
+		err = s.write(&PDU{CommandID: EnquireLinkResp, Sequence: pdu.Sequence})
+	case DeliverSM:
+		var msg ShortMessage
+		if decodeErr := msg.UnmarshalBinary(pdu.Body); decodeErr != nil {
+			err = s.write(&PDU{CommandID: DeliverSMResp, Status: StatusInvalidMsgLen, Sequence: pdu.Sequence, Body: MessageIDBody("")})
+			break
+		}
+		err = s.write(&PDU{CommandID: DeliverSMResp, Sequence: pdu.Sequence, Body: MessageIDBody("")})
+		if s.onDeliver != nil {
+			go s.onDeliver(&msg)
+		}
+	case Unbind:
+		_ = s.write(&PDU{CommandID: UnbindResp, Sequence: pdu.Sequence})
+		s.close(ErrUnbound)
+		return
+	default:
+		s.logger.WithField("command", pdu.CommandID).Warn("Unsupported SMPP request")
+		err = s.write(&PDU{CommandID: GenericNack, Status: StatusInvalidCmdID, Sequence: pdu.Sequence})
+	}
+
+	if err != nil {
+		s.close(err)
+	}
+}
+
+func (s *session) keepalive(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			_, err := s.request(ctx, EnquireLink, nil)
+			cancel()
+			if err != nil {
+				s.close(fmt.Errorf("enquire_link failed: %w", err))
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// addressType returns the TON/NPI pair for a source address: international
+// numbers, network specific short codes or alphanumeric sender IDs.
+func addressType(addr string) (byte, byte) {
+	digits := strings.TrimPrefix(addr, "+")
+	if digits != "" && strings.Trim(digits, "0123456789") == "" {
+		if strings.HasPrefix(addr, "+") || len(digits) > 8 {
+			return 0x01, 0x01
+		}
+		return 0x03, 0x00
+	}
+	return 0x05, 0x00
+}
+
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int64N(half))
+}
@@ -0,0 +1,295 @@
+package smpp_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sms-otp-service/internal/infrastructure/sms/smpp"
+	"sms-otp-service/internal/infrastructure/sms/smstest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testSystemID = "otp"
+	testPassword = "secret"
+	testSource   = "OTPService"
+	testDest     = "+14155550100"
+)
+
+func testConfig(smsc *smstest.SMSC) smpp.Config {
+	return smpp.Config{
+		Address:             smsc.Addr(),
+		SystemID:            testSystemID,
+		Password:            testPassword,
+		EnquireLinkInterval: time.Minute,
+		ResponseTimeout:     2 * time.Second,
+		ReconnectMinBackoff: 10 * time.Millisecond,
+		ReconnectMaxBackoff: 20 * time.Millisecond,
+	}
+}
+
+// startClient starts a client with cfg, closed when t ends.
+func startClient(t *testing.T, cfg smpp.Config, onDeliver smpp.DeliverHandler) *smpp.Client {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	client := smpp.NewClient(cfg, logger)
+	if onDeliver != nil {
+		client.OnDeliver(onDeliver)
+	}
+	client.Start()
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// waitFor polls cond until it holds, failing t after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBindAndUnbind(t *testing.T) {
+	smsc := smstest.NewSMSC(t, testSystemID, testPassword)
+	client := startClient(t, testConfig(smsc), nil)
+
+	messageID, err := client.Submit(context.Background(), testSource, testDest, "Your code is 123456")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	messages := smsc.Messages()
+	if len(messages) != 1 || messages[0].MessageID != messageID {
+		t.Fatalf("SMSC got %+v, want one message with ID %s", messages, messageID)
+	}
+	if messages[0].Source != testSource || messages[0].Destination != "14155550100" {
+		t.Errorf("SMSC got source %q and destination %q", messages[0].Source, messages[0].Destination)
+	}
+	if smsc.BindCount() != 1 {
+		t.Errorf("bind count = %d, want 1", smsc.BindCount())
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if smsc.UnbindCount() != 1 {
+		t.Errorf("unbind count = %d, want 1", smsc.UnbindCount())
+	}
+	if _, err := client.Submit(context.Background(), testSource, testDest, "Your code is 123456"); !errors.Is(err, smpp.ErrClosed) {
+		t.Errorf("Submit after Close = %v, want %v", err, smpp.ErrClosed)
+	}
+}
+
+func TestEnquireLink(t *testing.T) {
+	smsc := smstest.NewSMSC(t, testSystemID, testPassword)
+	cfg := testConfig(smsc)
+	cfg.EnquireLinkInterval = 10 * time.Millisecond
+	client := startClient(t, cfg, nil)
+
+	waitFor(t, "enquire_link", func() bool { return smsc.EnquireLinkCount() >= 3 })
+
+	// Answered keepalives keep the first bind open.
+	if _, err := client.Submit(context.Background(), testSource, testDest, "Your code is 123456"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if smsc.BindCount() != 1 {
+		t.Errorf("bind count = %d, want 1", smsc.BindCount())
+	}
+}
+
+func TestSubmitEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		text           string
+		wantDataCoding byte
+		wantInPayload  bool
+	}{
+		{
+			name:           "gsm-7",
+			text:           "Your code is 123456",
+			wantDataCoding: smpp.DataCodingDefault,
+		},
+		{
+			name:           "gsm-7 extension table",
+			text:           "Code {123456} costs 0€ [ok]",
+			wantDataCoding: smpp.DataCodingDefault,
+		},
+		{
+			name:           "ucs-2",
+			text:           "Ваш код 123456",
+			wantDataCoding: smpp.DataCodingUCS2,
+		},
+		{
+			name:           "ucs-2 emoji",
+			text:           "Your code is 123456 🔐",
+			wantDataCoding: smpp.DataCodingUCS2,
+		},
+		{
+			name:           "gsm-7 at the short_message limit",
+			text:           strings.Repeat("a", 140),
+			wantDataCoding: smpp.DataCodingDefault,
+		},
+		{
+			name:           "long gsm-7",
+			text:           strings.Repeat("Your code is 123456. ", 10),
+			wantDataCoding: smpp.DataCodingDefault,
+			wantInPayload:  true,
+		},
+		{
+			name:           "long ucs-2",
+			text:           strings.Repeat("Код 123456. ", 6),
+			wantDataCoding: smpp.DataCodingUCS2,
+			wantInPayload:  true,
+		},
+	}
+
+	smsc := smstest.NewSMSC(t, testSystemID, testPassword)
+	client := startClient(t, testConfig(smsc), nil)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Submit(context.Background(), testSource, testDest, tt.text); err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+
+			msg := smsc.Messages()[i]
+			if msg.DataCoding != tt.wantDataCoding {
+				t.Errorf("data_coding = 0x%02X, want 0x%02X", msg.DataCoding, tt.wantDataCoding)
+			}
+			if msg.InPayload != tt.wantInPayload {
+				t.Errorf("sent in message_payload = %v, want %v", msg.InPayload, tt.wantInPayload)
+			}
+			if msg.Text != tt.text {
+				t.Errorf("SMSC decoded %q, want %q", msg.Text, tt.text)
+			}
+		})
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	data, dataCoding := smpp.EncodeText("a€")
+	if dataCoding != smpp.DataCodingDefault || string(data) != "a\x1b\x65" {
+		t.Errorf("EncodeText(a€) = %q, 0x%02X, want an escaped euro sign in GSM-7", data, dataCoding)
+	}
+
+	data, dataCoding = smpp.EncodeText("ş")
+	if dataCoding != smpp.DataCodingUCS2 || string(data) != "\x01\x5f" {
+		t.Errorf("EncodeText(ş) = %q, 0x%02X, want big endian UCS-2", data, dataCoding)
+	}
+}
+
+func TestSubmitStatusErrors(t *testing.T) {
+	smsc := smstest.NewSMSC(t, testSystemID, testPassword)
+	client := startClient(t, testConfig(smsc), nil)
+
+	smsc.FailNext(smpp.StatusThrottled)
+	_, err := client.Submit(context.Background(), testSource, testDest, "Your code is 123456")
+	var statusErr *smpp.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Command != smpp.SubmitSM || statusErr.Status != smpp.StatusThrottled {
+		t.Errorf("Submit = %v, want a throttled submit_sm status", err)
+	}
+
+	_, err = client.Submit(context.Background(), testSource, "+123", "Your code is 123456")
+	if !errors.As(err, &statusErr) || statusErr.Status != smpp.StatusInvalidDstAddr {
+		t.Errorf("Submit to an invalid number = %v, want an invalid destination status", err)
+	}
+
+	if len(smsc.Messages()) != 0 {
+		t.Errorf("SMSC accepted %d messages, want none", len(smsc.Messages()))
+	}
+}
+
+func TestDeliveryReceipts(t *testing.T) {
+	smsc := smstest.NewSMSC(t, testSystemID, testPassword)
+	delivered := make(chan *smpp.ShortMessage, 2)
+	client := startClient(t, testConfig(smsc), func(msg *smpp.ShortMessage) { delivered <- msg })
+
+	messageID, err := client.Submit(context.Background(), testSource, testDest, "Your code is 123456")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	for _, stat := range []string{"DELIVRD", "UNDELIV"} {
+		if err := smsc.DeliverReceipt(messageID, stat); err != nil {
+			t.Fatalf("DeliverReceipt: %v", err)
+		}
+
+		var msg *smpp.ShortMessage
+		select {
+		case msg = <-delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no deliver_sm for %s", stat)
+		}
+
+		receipt, ok := smpp.ParseDeliveryReceipt(msg)
+		if !ok {
+			t.Fatalf("deliver_sm %+v is not a delivery receipt", msg)
+		}
+		if receipt.MessageID != messageID || receipt.Stat != stat {
+			t.Errorf("receipt = %+v, want %s for %s", receipt, stat, messageID)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	smsc := smstest.NewSMSC(t, testSystemID, testPassword)
+	client := startClient(t, testConfig(smsc), nil)
+
+	if _, err := client.Submit(context.Background(), testSource, testDest, "first"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	smsc.DropConnections()
+	waitFor(t, "rebind", func() bool { return smsc.BindCount() == 2 })
+
+	if _, err := client.Submit(context.Background(), testSource, testDest, "second"); err != nil {
+		t.Fatalf("Submit after reconnect: %v", err)
+	}
+	if len(smsc.Messages()) != 2 {
+		t.Errorf("SMSC got %d messages, want 2", len(smsc.Messages()))
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	smsc := smstest.NewSMSC(t, testSystemID, testPassword)
+	cfg := testConfig(smsc)
+	cfg.Password = "wrong"
+	cfg.ReconnectMinBackoff = 20 * time.Millisecond
+	cfg.ReconnectMaxBackoff = 40 * time.Millisecond
+	client := startClient(t, cfg, nil)
+
+	waitFor(t, "bind attempts", func() bool { return len(smsc.BindAttempts()) >= 6 })
+	attempts := smsc.BindAttempts()
+
+	// Each wait is jittered into [backoff/2, backoff): the backoff doubles from
+	// the minimum and stops at the maximum, where it would be 320ms by now.
+	backoff := cfg.ReconnectMinBackoff
+	for i := 1; i < 6; i++ {
+		gap := attempts[i].Sub(attempts[i-1])
+		if gap < backoff/2 {
+			t.Errorf("attempt %d came %v after the previous one, want at least %v", i, gap, backoff/2)
+		}
+		if gap > cfg.ReconnectMaxBackoff+100*time.Millisecond {
+			t.Errorf("attempt %d came %v after the previous one, want the backoff capped at %v", i, gap, cfg.ReconnectMaxBackoff)
+		}
+		backoff = min(backoff*2, cfg.ReconnectMaxBackoff)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Submit(ctx, testSource, testDest, "Your code is 123456"); !errors.Is(err, smpp.ErrNotBound) {
+		t.Errorf("Submit without a bind = %v, want %v", err, smpp.ErrNotBound)
+	}
+	if smsc.BindCount() != 0 {
+		t.Errorf("bind count = %d, want 0", smsc.BindCount())
+	}
+}
@@ -0,0 +1,110 @@
+package smpp
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+const gsmEscape = 0x1B
+
+// gsm7Alphabet is the GSM 03.38 default alphabet indexed by septet value.
+// Index 0x1B is the escape to the extension table and never matches a rune.
+var gsm7Alphabet = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+var gsm7Extension = map[rune]byte{
+	'\f': 0x0A,
+	'^':  0x14,
+	'{':  0x28,
+	'}':  0x29,
+	'\\': 0x2F,
+	'[':  0x3C,
+	'~':  0x3D,
+	']':  0x3E,
+	'|':  0x40,
+	'€':  0x65,
+}
+
+var (
+	gsm7Lookup          = make(map[rune]byte, len(gsm7Alphabet))
+	gsm7ExtensionLookup = make(map[byte]rune, len(gsm7Extension))
+)
+
+func init() {
+	for i, r := range gsm7Alphabet {
+		if i != gsmEscape {
+			gsm7Lookup[r] = byte(i)
+		}
+	}
+	for r, b := range gsm7Extension {
+		gsm7ExtensionLookup[b] = r
+	}
+}
+
+// EncodeText picks GSM-7 when every rune is in the default alphabet or its
+// extension table and falls back to UCS-2 otherwise. GSM-7 is returned
+// unpacked, one septet per octet, as SMPP expects for data_coding 0.
+func EncodeText(text string) ([]byte, byte) {
+	if encoded, ok := encodeGSM7(text); ok {
+		return encoded, DataCodingDefault
+	}
+	return encodeUCS2(text), DataCodingUCS2
+}
+
+// DecodeText reverses EncodeText for the supported data codings.
+func DecodeText(data []byte, dataCoding byte) string {
+	switch dataCoding {
+	case DataCodingUCS2:
+		return decodeUCS2(data)
+	default:
+		return decodeGSM7(data)
+	}
+}
+
+func encodeGSM7(text string) ([]byte, bool) {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		if b, ok := gsm7Lookup[r]; ok {
+			encoded = append(encoded, b)
+			continue
+		}
+		if b, ok := gsm7Extension[r]; ok {
+			encoded = append(encoded, gsmEscape, b)
+			continue
+		}
+		return nil, false
+	}
+	return encoded, true
+}
+
+func decodeGSM7(data []byte) string {
+	runes := make([]rune, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		b := data[i] & 0x7F
+		if b == gsmEscape && i+1 < len(data) {
+			i++
+			if r, ok := gsm7ExtensionLookup[data[i]]; ok {
+				runes = append(runes, r)
+			}
+			continue
+		}
+		runes = append(runes, gsm7Alphabet[b])
+	}
+	return string(runes)
+}
+
+func encodeUCS2(text string) []byte {
+	units := utf16.Encode([]rune(text))
+	encoded := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.BigEndian.PutUint16(encoded[i*2:], u)
+	}
+	return encoded
+}
+
+func decodeUCS2(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
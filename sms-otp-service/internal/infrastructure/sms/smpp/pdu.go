@@ -0,0 +1,473 @@
+// Package smpp implements the subset of SMPP 3.4 needed to submit messages to
+// an SMSC over a transceiver bind.
+package smpp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type CommandID uint32
+
+const (
+	GenericNack         CommandID = 0x80000000
+	BindTransceiver     CommandID = 0x00000009
+	BindTransceiverResp CommandID = 0x80000009
+	Unbind              CommandID = 0x00000006
+	UnbindResp          CommandID = 0x80000006
+	SubmitSM            CommandID = 0x00000004
+	SubmitSMResp        CommandID = 0x80000004
+	DeliverSM           CommandID = 0x00000005
+	DeliverSMResp       CommandID = 0x80000005
+	EnquireLink         CommandID = 0x00000015
+	EnquireLinkResp     CommandID = 0x80000015
+)
+
+// IsResponse reports whether the command is a response to a request.
+func (c CommandID) IsResponse() bool {
+	return c&0x80000000 != 0
+}
+
+func (c CommandID) String() string {
+	switch c {
+	case GenericNack:
+		return "generic_nack"
+	case BindTransceiver:
+		return "bind_transceiver"
+	case BindTransceiverResp:
+		return "bind_transceiver_resp"
+	case Unbind:
+		return "unbind"
+	case UnbindResp:
+		return "unbind_resp"
+	case SubmitSM:
+		return "submit_sm"
+	case SubmitSMResp:
+		return "submit_sm_resp"
+	case DeliverSM:
+		return "deliver_sm"
+	case DeliverSMResp:
+		return "deliver_sm_resp"
+	case EnquireLink:
+		return "enquire_link"
+	case EnquireLinkResp:
+		return "enquire_link_resp"
+	default:
+		return fmt.Sprintf("command_id(0x%08x)", uint32(c))
+	}
+}
+
+// Command status values used by this package.
+const (
+	StatusOK             uint32 = 0x00000000
+	StatusInvalidMsgLen  uint32 = 0x00000001
+	StatusInvalidCmdID   uint32 = 0x00000003
+	StatusInvalidBind    uint32 = 0x00000004
+	StatusAlreadyBound   uint32 = 0x00000005
+	StatusSystemError    uint32 = 0x00000008
+	StatusInvalidSrcAddr uint32 = 0x0000000A
+	StatusInvalidDstAddr uint32 = 0x0000000B
+	StatusBindFailed     uint32 = 0x0000000D
+	StatusInvalidPasswd  uint32 = 0x0000000E
+	StatusInvalidSysID   uint32 = 0x0000000F
+	StatusMsgQueueFull   uint32 = 0x00000014
+	StatusSubmitFailed   uint32 = 0x00000045
+	StatusThrottled      uint32 = 0x00000058
+)
+
+// Data coding schemes.
+const (
+	DataCodingDefault byte = 0x00
+	DataCodingUCS2    byte = 0x08
+)
+
+// Optional parameter tags.
+const (
+	TagReceiptedMessageID uint16 = 0x001E
+	TagMessageState       uint16 = 0x0427
+	TagMessagePayload     uint16 = 0x0424
+)
+
+// ESM class bits.
+const (
+	ESMClassDeliveryReceipt byte = 0x04
+)
+
+const (
+	headerLength   = 16
+	maxPDULength   = 64 * 1024
+	InterfaceV34   = 0x34
+	maxShortLength = 254
+)
+
+var ErrPDUTooLarge = errors.New("smpp: pdu exceeds maximum length")
+
+// PDU is a raw SMPP protocol data unit.
+type PDU struct {
+	CommandID CommandID
+	Status    uint32
+	Sequence  uint32
+	Body      []byte
+}
+
+// ReadPDU reads a single PDU from r.
+func ReadPDU(r io.Reader) (*PDU, error) {
+	var header [headerLength]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length < headerLength {
+		return nil, fmt.Errorf("smpp: invalid pdu length %d", length)
+	}
+	if length > maxPDULength {
+		return nil, ErrPDUTooLarge
+	}
+
+	pdu := &PDU{
+		CommandID: CommandID(binary.BigEndian.Uint32(header[4:8])),
+		Status:    binary.BigEndian.Uint32(header[8:12]),
+		Sequence:  binary.BigEndian.Uint32(header[12:16]),
+		Body:      make([]byte, length-headerLength),
+	}
+	if _, err := io.ReadFull(r, pdu.Body); err != nil {
+		return nil, err
+	}
+
+	return pdu, nil
+}
+
+// WritePDU writes p to w in a single call.
+func WritePDU(w io.Writer, p *PDU) error {
+	length := headerLength + len(p.Body)
+	if length > maxPDULength {
+		return ErrPDUTooLarge
+	}
+
+	buf := make([]byte, length)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(length))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(p.CommandID))
+	binary.BigEndian.PutUint32(buf[8:12], p.Status)
+	binary.BigEndian.PutUint32(buf[12:16], p.Sequence)
+	copy(buf[headerLength:], p.Body)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// Bind is the body of a bind_transceiver request.
+type Bind struct {
+	SystemID         string
+	Password         string
+	SystemType       string
+	InterfaceVersion byte
+	AddrTON          byte
+	AddrNPI          byte
+	AddressRange     string
+}
+
+func (b *Bind) MarshalBinary() ([]byte, error) {
+	var e encoder
+	e.cstring(b.SystemID)
+	e.cstring(b.Password)
+	e.cstring(b.SystemType)
+	e.byte(b.InterfaceVersion)
+	e.byte(b.AddrTON)
+	e.byte(b.AddrNPI)
+	e.cstring(b.AddressRange)
+	return e.Bytes(), nil
+}
+
+func (b *Bind) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	b.SystemID = d.cstring()
+	b.Password = d.cstring()
+	b.SystemType = d.cstring()
+	b.InterfaceVersion = d.byte()
+	b.AddrTON = d.byte()
+	b.AddrNPI = d.byte()
+	b.AddressRange = d.cstring()
+	return d.err
+}
+
+// ShortMessage is the body shared by submit_sm and deliver_sm.
+type ShortMessage struct {
+	ServiceType          string
+	SourceAddrTON        byte
+	SourceAddrNPI        byte
+	SourceAddr           string
+	DestAddrTON          byte
+	DestAddrNPI          byte
+	DestinationAddr      string
+	ESMClass             byte
+	ProtocolID           byte
+	PriorityFlag         byte
+	ScheduleDeliveryTime string
+	ValidityPeriod       string
+	RegisteredDelivery   byte
+	ReplaceIfPresent     byte
+	DataCoding           byte
+	SMDefaultMsgID       byte
+	ShortMessage         []byte
+	TLVs                 map[uint16][]byte
+}
+
+func (m *ShortMessage) MarshalBinary() ([]byte, error) {
+	if len(m.ShortMessage) > maxShortLength {
+		return nil, fmt.Errorf("smpp: short_message of %d octets exceeds %d, use message_payload", len(m.ShortMessage), maxShortLength)
+	}
+
+	var e encoder
+	e.cstring(m.ServiceType)
+	e.byte(m.SourceAddrTON)
+	e.byte(m.SourceAddrNPI)
+	e.cstring(m.SourceAddr)
+	e.byte(m.DestAddrTON)
+	e.byte(m.DestAddrNPI)
+	e.cstring(m.DestinationAddr)
+	e.byte(m.ESMClass)
+	e.byte(m.ProtocolID)
+	e.byte(m.PriorityFlag)
+	e.cstring(m.ScheduleDeliveryTime)
+	e.cstring(m.ValidityPeriod)
+	e.byte(m.RegisteredDelivery)
+	e.byte(m.ReplaceIfPresent)
+	e.byte(m.DataCoding)
+	e.byte(m.SMDefaultMsgID)
+	e.byte(byte(len(m.ShortMessage)))
+	e.Write(m.ShortMessage)
+	for tag, value := range m.TLVs {
+		e.tlv(tag, value)
+	}
+	return e.Bytes(), nil
+}
+
+func (m *ShortMessage) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	m.ServiceType = d.cstring()
+	m.SourceAddrTON = d.byte()
+	m.SourceAddrNPI = d.byte()
+	m.SourceAddr = d.cstring()
+	m.DestAddrTON = d.byte()
+	m.DestAddrNPI = d.byte()
+	m.DestinationAddr = d.cstring()
+	m.ESMClass = d.byte()
+	m.ProtocolID = d.byte()
+	m.PriorityFlag = d.byte()
+	m.ScheduleDeliveryTime = d.cstring()
+	m.ValidityPeriod = d.cstring()
+	m.RegisteredDelivery = d.byte()
+	m.ReplaceIfPresent = d.byte()
+	m.DataCoding = d.byte()
+	m.SMDefaultMsgID = d.byte()
+	m.ShortMessage = d.bytes(int(d.byte()))
+	m.TLVs = d.tlvs()
+	return d.err
+}
+
+// Payload returns the message content, preferring the message_payload
+// optional parameter when present.
+func (m *ShortMessage) Payload() []byte {
+	if payload, ok := m.TLVs[TagMessagePayload]; ok {
+		return payload
+	}
+	return m.ShortMessage
+}
+
+// MessageIDBody encodes a body that consists of a single C-octet string, as
+// used by bind and submit_sm responses.
+func MessageIDBody(id string) []byte {
+	var e encoder
+	e.cstring(id)
+	return e.Bytes()
+}
+
+// ParseMessageIDBody decodes a body produced by MessageIDBody.
+func ParseMessageIDBody(body []byte) string {
+	d := decoder{data: body}
+	return d.cstring()
+}
+
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) cstring(s string) {
+	e.WriteString(s)
+	e.WriteByte(0)
+}
+
+func (e *encoder) byte(b byte) {
+	e.WriteByte(b)
+}
+
+func (e *encoder) tlv(tag uint16, value []byte) {
+	var header [4]byte
+	binary.BigEndian.PutUint16(header[0:2], tag)
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(value)))
+	e.Write(header[:])
+	e.Write(value)
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+var errShortBody = errors.New("smpp: truncated pdu body")
+
+func (d *decoder) cstring() string {
+	if d.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(d.data[d.pos:], 0)
+	if end < 0 {
+		// Some SMSCs omit the terminator on the last field of a response.
+		s := string(d.data[d.pos:])
+		d.pos = len(d.data)
+		return s
+	}
+	s := string(d.data[d.pos : d.pos+end])
+	d.pos += end + 1
+	return s
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.data) {
+		d.err = errShortBody
+		return 0
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if d.pos+n > len(d.data) {
+		d.err = errShortBody
+		return nil
+	}
+	b := append([]byte(nil), d.data[d.pos:d.pos+n]...)
+	d.pos += n
+	return b
+}
+
+func (d *decoder) tlvs() map[uint16][]byte {
+	if d.err != nil || d.pos >= len(d.data) {
+		return nil
+	}
+	tlvs := make(map[uint16][]byte)
+	for d.pos+4 <= len(d.data) {
+		tag := binary.BigEndian.Uint16(d.data[d.pos : d.pos+2])
+		length := int(binary.BigEndian.Uint16(d.data[d.pos+2 : d.pos+4]))
+		d.pos += 4
+		value := d.bytes(length)
+		if d.err != nil {
+			return nil
+		}
+		tlvs[tag] = value
+	}
+	return tlvs
+}
+
+// Message states carried in the message_state optional parameter of a
+// delivery receipt, with the stat values used in the receipt text.
+var messageStates = map[byte]string{
+	1: "ENROUTE",
+	2: "DELIVRD",
+	3: "EXPIRED",
+	4: "DELETED",
+	5: "UNDELIV",
+	6: "ACCEPTD",
+	7: "UNKNOWN",
+	8: "REJECTD",
+}
+
+// MessageStateCode returns the message_state value for a receipt stat such
+// as "DELIVRD", or 0 when the stat is unknown.
+func MessageStateCode(stat string) byte {
+	for code, name := range messageStates {
+		if name == stat {
+			return code
+		}
+	}
+	return 0
+}
+
+// DeliveryReceipt is the content of a deliver_sm that reports the fate of a
+// previously submitted message.
+type DeliveryReceipt struct {
+	MessageID string
+	// Stat is the final state as written in receipt text, e.g. "DELIVRD".
+	Stat  string
+	Error string
+}
+
+// ParseDeliveryReceipt extracts the delivery receipt from msg. The optional
+// parameters are preferred; the de facto "id:... stat:... err:..." text
+// format of Appendix B is used for SMSCs that only send the text.
+func ParseDeliveryReceipt(msg *ShortMessage) (*DeliveryReceipt, bool) {
+	if msg.ESMClass&ESMClassDeliveryReceipt == 0 {
+		return nil, false
+	}
+
+	receipt := &DeliveryReceipt{}
+	fields := parseReceiptText(string(msg.Payload()))
+	receipt.MessageID = fields["id"]
+	receipt.Stat = fields["stat"]
+	receipt.Error = fields["err"]
+
+	if id, ok := msg.TLVs[TagReceiptedMessageID]; ok {
+		receipt.MessageID = strings.TrimRight(string(id), "\x00")
+	}
+	if state, ok := msg.TLVs[TagMessageState]; ok && len(state) == 1 {
+		if name, known := messageStates[state[0]]; known {
+			receipt.Stat = name
+		}
+	}
+
+	if receipt.MessageID == "" {
+		return nil, false
+	}
+	return receipt, true
+}
+
+// parseReceiptText splits receipt text into its key:value fields. Keys may
+// contain spaces ("submit date") and the text field runs to the end.
+func parseReceiptText(text string) map[string]string {
+	fields := make(map[string]string)
+	keys := []string{"id", "sub", "dlvrd", "submit date", "done date", "stat", "err", "text"}
+
+	lower := strings.ToLower(text)
+	for i, key := range keys {
+		start := strings.Index(lower, key+":")
+		if start < 0 {
+			continue
+		}
+		start += len(key) + 1
+
+		end := len(text)
+		if key != "text" {
+			for _, next := range keys[i+1:] {
+				if idx := strings.Index(lower[start:], " "+next+":"); idx >= 0 {
+					end = start + idx
+					break
+				}
+			}
+		}
+		fields[key] = strings.TrimSpace(text[start:end])
+	}
+	return fields
+}
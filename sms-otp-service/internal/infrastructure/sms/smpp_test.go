@@ -0,0 +1,102 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sms-otp-service/internal/infrastructure/sms/smpp"
+	"sms-otp-service/internal/infrastructure/sms/smstest"
+	"testing"
+	"time"
+)
+
+func newTestSMPPService(t *testing.T, smsc *smstest.SMSC) Service {
+	t.Helper()
+	service := NewSMPPSMSService(config.SMSConfig{
+		SenderName: "OTPService",
+		SMPP: config.SMPPConfig{
+			Address:             smsc.Addr(),
+			SystemID:            "otp",
+			Password:            "secret",
+			EnquireLinkInterval: time.Minute,
+			ResponseTimeout:     2 * time.Second,
+			ReconnectMinBackoff: 10 * time.Millisecond,
+			ReconnectMaxBackoff: 20 * time.Millisecond,
+		},
+	}, testLogger())
+	t.Cleanup(func() { service.(io.Closer).Close() })
+	return service
+}
+
+func TestSMPPSendErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   uint32
+		want     error
+		wantCode string
+	}{
+		{name: "invalid destination", status: smpp.StatusInvalidDstAddr, want: ErrInvalidNumber, wantCode: "0x0000000B"},
+		{name: "throttled", status: smpp.StatusThrottled, want: ErrThrottled, wantCode: "0x00000058"},
+		{name: "queue full", status: smpp.StatusMsgQueueFull, want: ErrThrottled, wantCode: "0x00000014"},
+		{name: "system error", status: smpp.StatusSystemError, want: ErrProviderUnavailable, wantCode: "0x00000008"},
+		{name: "submit failed", status: smpp.StatusSubmitFailed, want: ErrRejected, wantCode: "0x00000045"},
+	}
+
+	smsc := smstest.NewSMSC(t, "otp", "secret")
+	service := newTestSMPPService(t, smsc)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smsc.FailNext(tt.status)
+
+			_, err := service.SendSMS(context.Background(), testRecipient, "Your code is 123456")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SendSMS error = %v, want %v", err, tt.want)
+			}
+			var providerErr *ProviderError
+			if !errors.As(err, &providerErr) || providerErr.Provider != "smpp" || providerErr.Code != tt.wantCode {
+				t.Errorf("SendSMS error = %+v, want smpp code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSMPPReceipts(t *testing.T) {
+	smsc := smstest.NewSMSC(t, "otp", "secret")
+	service := newTestSMPPService(t, smsc)
+
+	receipts := make(chan *entities.DeliveryReceipt, 2)
+	service.(ReceiptSource).OnReceipt(func(receipt *entities.DeliveryReceipt) { receipts <- receipt })
+
+	result, err := service.SendSMS(context.Background(), testRecipient, "Your code is 123456")
+	if err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if result.Provider != "smpp" || result.MessageID != smsc.Messages()[0].MessageID {
+		t.Errorf("result = %+v, want the SMSC message ID", result)
+	}
+
+	// A state the service cannot map is dropped.
+	if err := smsc.DeliverReceipt(result.MessageID, "UNKNOWN"); err != nil {
+		t.Fatalf("DeliverReceipt: %v", err)
+	}
+	if err := smsc.DeliverReceipt(result.MessageID, "DELIVRD"); err != nil {
+		t.Fatalf("DeliverReceipt: %v", err)
+	}
+
+	select {
+	case receipt := <-receipts:
+		if receipt.Provider != "smpp" || receipt.MessageID != result.MessageID || receipt.Status != entities.DeliveryDelivered {
+			t.Errorf("receipt = %+v, want delivered for %s", receipt, result.MessageID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery receipt")
+	}
+	select {
+	case receipt := <-receipts:
+		t.Errorf("got extra receipt %+v", receipt)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
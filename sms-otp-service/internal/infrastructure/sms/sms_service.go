@@ -2,25 +2,158 @@ package sms
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"sms-otp-service/internal/domain/entities"
 	"sms-otp-service/internal/infrastructure/config"
+	"time"
+)
+
+var (
+	ErrInvalidNumber       = errors.New("sms: invalid destination number")
+	ErrUnreachable         = errors.New("sms: destination unreachable")
+	ErrThrottled           = errors.New("sms: provider throttled the request")
+	ErrAuthentication      = errors.New("sms: provider rejected credentials")
+	ErrRejected            = errors.New("sms: message rejected by provider")
+	ErrProviderUnavailable = errors.New("sms: provider unavailable")
 )
 
 type Service interface {
-	SendSMS(ctx context.Context, phoneNumber, message string) error
+	SendSMS(ctx context.Context, phoneNumber, message string) (*entities.SendResult, error)
+}
+
+// ProviderError carries the provider specific error code next to one of the
+// typed errors above, so callers can use errors.Is without knowing the vendor.
+type ProviderError struct {
+	Provider   string
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%s: %s", e.Provider, e.Message)
+	}
+	return fmt.Sprintf("%s: %s (code %s)", e.Provider, e.Message, e.Code)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent reports whether retrying the same message can never succeed.
+func (e *ProviderError) Permanent() bool {
+	return errors.Is(e.Err, ErrInvalidNumber)
+}
+
+func NewSMSService(cfg *config.Config, logger *logrus.Logger) (Service, error) {
+	factory := &providerFactory{
+		cfg:    cfg,
+		logger: logger,
+		built:  make(map[string]Service),
+	}
+	return factory.get(cfg.SMS.Provider)
+}
+
+// providerFactory builds providers by name. Composite providers may refer to
+// the same provider more than once, so every provider is built only once and
+// shares its connections.
+type providerFactory struct {
+	cfg    *config.Config
+	logger *logrus.Logger
+	built  map[string]Service
 }
 
-func NewSMSService(cfg *config.Config, logger *logrus.Logger) Service {
-	switch cfg.SMS.Provider {
+func (f *providerFactory) get(name string) (Service, error) {
+	if service, ok := f.built[name]; ok {
+		return service, nil
+	}
+
+	service, err := f.build(name)
+	if err != nil {
+		return nil, err
+	}
+	f.built[name] = service
+	return service, nil
+}
+
+func (f *providerFactory) build(name string) (Service, error) {
+	switch name {
 	case "mock":
-		return NewMockSMSService(logger)
+		return NewMockSMSService(f.logger), nil
+	case "twilio":
+		return NewTwilioSMSService(f.cfg.SMS, f.logger), nil
+	case "smpp":
+		return NewSMPPSMSService(f.cfg.SMS, f.logger), nil
+	case "webhook":
+		return NewWebhookSMSService(f.cfg.SMS, f.logger)
+	case "failover":
+		return f.buildFailover()
+	case "routing":
+		return f.buildRouting()
 	default:
-		logger.Warn("Unknown SMS provider, falling back to mock")
-		return NewMockSMSService(logger)
+		f.logger.Warn("Unknown SMS provider, falling back to mock")
+		return NewMockSMSService(f.logger), nil
 	}
 }
 
+func (f *providerFactory) buildFailover() (Service, error) {
+	failover := f.cfg.SMS.Failover
+	if len(failover.Providers) == 0 {
+		return nil, fmt.Errorf("failover sms provider requires SMS_FAILOVER_PROVIDERS")
+	}
+
+	var providers []NamedService
+	for _, name := range failover.Providers {
+		if name == "failover" || name == "routing" {
+			return nil, fmt.Errorf("failover sms provider cannot contain %s", name)
+		}
+		service, err := f.get(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build %s provider: %w", name, err)
+		}
+		providers = append(providers, NamedService{Name: name, Service: service})
+	}
+
+	return NewFailoverSMSService(providers, BreakerConfig{
+		FailureThreshold: failover.FailureThreshold,
+		OpenTimeout:      failover.OpenTimeout,
+		HalfOpenProbes:   failover.HalfOpenProbes,
+	}, f.logger), nil
+}
+
+func (f *providerFactory) buildRouting() (Service, error) {
+	if len(f.cfg.SMS.Routes) == 0 {
+		return nil, fmt.Errorf("routing sms provider requires SMS_ROUTES")
+	}
+
+	var routes []Route
+	for _, routeCfg := range f.cfg.SMS.Routes {
+		route := Route{Prefix: routeCfg.Prefix}
+		for _, targetCfg := range routeCfg.Targets {
+			if targetCfg.Provider == "routing" {
+				return nil, fmt.Errorf("routing sms provider cannot route to itself")
+			}
+			service, err := f.get(targetCfg.Provider)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build %s provider: %w", targetCfg.Provider, err)
+			}
+			route.Targets = append(route.Targets, RouteTarget{
+				Name:    targetCfg.Provider,
+				Service: service,
+				Weight:  targetCfg.Weight,
+			})
+		}
+		routes = append(routes, route)
+	}
+
+	return NewRoutingSMSService(routes, f.logger), nil
+}
+
 type mockSMSService struct {
 	logger *logrus.Logger
 }
@@ -31,7 +164,7 @@ func NewMockSMSService(logger *logrus.Logger) Service {
 	}
 }
 
-func (s *mockSMSService) SendSMS(ctx context.Context, phoneNumber, message string) error {
+func (s *mockSMSService) SendSMS(ctx context.Context, phoneNumber, message string) (*entities.SendResult, error) {
 	s.logger.WithFields(logrus.Fields{
 		"phone_number": phoneNumber,
 		"message":      message,
@@ -43,5 +176,5 @@ func (s *mockSMSService) SendSMS(ctx context.Context, phoneNumber, message strin
 	fmt.Printf("Message: %s\n", message)
 	fmt.Printf("================\n\n")
 
-	return nil
+	return &entities.SendResult{Provider: "mock", MessageID: uuid.NewString()}, nil
 }
@@ -0,0 +1,327 @@
+package smstest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sms-otp-service/internal/infrastructure/sms/smpp"
+	"sms-otp-service/internal/testutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SMPPMessage is a submit_sm accepted by the SMSC.
+type SMPPMessage struct {
+	MessageID   string
+	Source      string
+	Destination string
+	DataCoding  byte
+	Text        string
+	// InPayload reports whether the text came in the message_payload optional
+	// parameter rather than in short_message.
+	InPayload bool
+}
+
+// SMSC is an in-process SMPP 3.4 server that accepts transceiver binds,
+// answers enquire_link, records submitted messages and can send delivery
+// receipts for them. Queued failures are command statuses that the next
+// submit_sm fails with.
+type SMSC struct {
+	testutil.Recorder[SMPPMessage, uint32]
+
+	listener net.Listener
+	systemID string
+	password string
+
+	mu           sync.Mutex
+	conns        map[net.Conn]*smscConn
+	bindAttempts []time.Time
+	binds        int
+	unbinds      int
+	enquireLinks int
+	closed       bool
+	sequence     uint32
+	wg           sync.WaitGroup
+}
+
+type smscConn struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	bound   bool
+}
+
+func (c *smscConn) write(pdu *smpp.PDU) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return smpp.WritePDU(c.conn, pdu)
+}
+
+// NewSMSC starts an SMSC on a random loopback port, shut down when t ends,
+// that accepts binds with the given credentials.
+func NewSMSC(t testing.TB, systemID, password string) *SMSC {
+	s := &SMSC{
+		listener: testutil.Listen(t),
+		systemID: systemID,
+		password: password,
+		conns:    make(map[net.Conn]*smscConn),
+	}
+
+	s.wg.Add(1)
+	go s.accept()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Addr returns the host:port the SMSC listens on.
+func (s *SMSC) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the listener and drops every connection.
+func (s *SMSC) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	_ = s.listener.Close()
+	s.DropConnections()
+	s.wg.Wait()
+}
+
+// DropConnections closes all client connections without unbinding, as a
+// network failure would.
+func (s *SMSC) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// DeliverReceipt sends a delivery receipt for the message with the given ID
+// to a bound client. stat is the receipt state, such as "DELIVRD", "UNDELIV"
+// or "EXPIRED", and is sent both in the receipt text and as message_state.
+func (s *SMSC) DeliverReceipt(messageID, stat string) error {
+	s.mu.Lock()
+	var target *smscConn
+	for _, c := range s.conns {
+		if c.bound {
+			target = c
+			break
+		}
+	}
+	s.sequence++
+	sequence := 0x40000000 + s.sequence
+	s.mu.Unlock()
+
+	if target == nil {
+		return errors.New("smstest: no bound client")
+	}
+
+	errCode := "000"
+	if stat != "DELIVRD" {
+		errCode = "001"
+	}
+	text := fmt.Sprintf("id:%s sub:001 dlvrd:%03d submit date:%s done date:%s stat:%s err:%s text:",
+		messageID, boolToInt(stat == "DELIVRD"), receiptDate(), receiptDate(), stat, errCode)
+
+	msg := &smpp.ShortMessage{
+		ESMClass:     smpp.ESMClassDeliveryReceipt,
+		ShortMessage: []byte(text),
+		TLVs: map[uint16][]byte{
+			smpp.TagReceiptedMessageID: append([]byte(messageID), 0),
+			smpp.TagMessageState:       {smpp.MessageStateCode(stat)},
+		},
+	}
+	body, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return target.write(&smpp.PDU{CommandID: smpp.DeliverSM, Sequence: sequence, Body: body})
+}
+
+// BindCount returns the number of successful binds so far.
+func (s *SMSC) BindCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.binds
+}
+
+// BindAttempts returns when each bind_transceiver arrived, including those
+// rejected for wrong credentials.
+func (s *SMSC) BindAttempts() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.bindAttempts...)
+}
+
+// UnbindCount returns the number of unbind requests answered.
+func (s *SMSC) UnbindCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unbinds
+}
+
+// EnquireLinkCount returns the number of enquire_link requests answered.
+func (s *SMSC) EnquireLinkCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enquireLinks
+}
+
+func (s *SMSC) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		c := &smscConn{conn: conn}
+		s.conns[conn] = c
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+func (s *SMSC) serve(c *smscConn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c.conn)
+		s.mu.Unlock()
+		_ = c.conn.Close()
+	}()
+
+	bound := false
+	for {
+		pdu, err := smpp.ReadPDU(c.conn)
+		if err != nil {
+			return
+		}
+
+		resp, closeAfter := s.handle(pdu, &bound)
+		s.mu.Lock()
+		c.bound = bound
+		s.mu.Unlock()
+		if resp != nil {
+			if err := c.write(resp); err != nil {
+				return
+			}
+		}
+		if closeAfter {
+			return
+		}
+	}
+}
+
+func (s *SMSC) handle(pdu *smpp.PDU, bound *bool) (*smpp.PDU, bool) {
+	reply := func(command smpp.CommandID, status uint32, body []byte) *smpp.PDU {
+		return &smpp.PDU{CommandID: command, Status: status, Sequence: pdu.Sequence, Body: body}
+	}
+
+	switch pdu.CommandID {
+	case smpp.BindTransceiver:
+		s.mu.Lock()
+		s.bindAttempts = append(s.bindAttempts, time.Now())
+		s.mu.Unlock()
+		if *bound {
+			return reply(smpp.BindTransceiverResp, smpp.StatusAlreadyBound, nil), false
+		}
+		var bind smpp.Bind
+		if err := bind.UnmarshalBinary(pdu.Body); err != nil {
+			return reply(smpp.BindTransceiverResp, smpp.StatusInvalidMsgLen, nil), true
+		}
+		if bind.SystemID != s.systemID {
+			return reply(smpp.BindTransceiverResp, smpp.StatusInvalidSysID, nil), true
+		}
+		if bind.Password != s.password {
+			return reply(smpp.BindTransceiverResp, smpp.StatusInvalidPasswd, nil), true
+		}
+
+		*bound = true
+		s.mu.Lock()
+		s.binds++
+		s.mu.Unlock()
+		return reply(smpp.BindTransceiverResp, smpp.StatusOK, smpp.MessageIDBody("smstest")), false
+
+	case smpp.EnquireLink:
+		s.mu.Lock()
+		s.enquireLinks++
+		s.mu.Unlock()
+		return reply(smpp.EnquireLinkResp, smpp.StatusOK, nil), false
+
+	case smpp.Unbind:
+		s.mu.Lock()
+		s.unbinds++
+		s.mu.Unlock()
+		return reply(smpp.UnbindResp, smpp.StatusOK, nil), true
+
+	case smpp.SubmitSM:
+		if !*bound {
+			return reply(smpp.SubmitSMResp, smpp.StatusInvalidBind, nil), false
+		}
+		var msg smpp.ShortMessage
+		if err := msg.UnmarshalBinary(pdu.Body); err != nil {
+			return reply(smpp.SubmitSMResp, smpp.StatusInvalidMsgLen, nil), false
+		}
+		if status, ok := s.NextFailure(); ok {
+			return reply(smpp.SubmitSMResp, status, nil), false
+		}
+		if err := validateDestination(msg.DestinationAddr); err != nil {
+			return reply(smpp.SubmitSMResp, smpp.StatusInvalidDstAddr, nil), false
+		}
+
+		accepted := s.Record(func(seq int) SMPPMessage {
+			return SMPPMessage{
+				MessageID:   fmt.Sprintf("%08X", seq),
+				Source:      msg.SourceAddr,
+				Destination: msg.DestinationAddr,
+				DataCoding:  msg.DataCoding,
+				Text:        smpp.DecodeText(msg.Payload(), msg.DataCoding),
+				InPayload:   msg.TLVs[smpp.TagMessagePayload] != nil,
+			}
+		})
+		return reply(smpp.SubmitSMResp, smpp.StatusOK, smpp.MessageIDBody(accepted.MessageID)), false
+
+	default:
+		if pdu.CommandID.IsResponse() {
+			return nil, false
+		}
+		return reply(smpp.GenericNack, smpp.StatusInvalidCmdID, nil), false
+	}
+}
+
+func validateDestination(addr string) error {
+	if len(addr) < 8 || len(addr) > 15 {
+		return errors.New("invalid destination length")
+	}
+	for _, r := range addr {
+		if r < '0' || r > '9' {
+			return errors.New("invalid destination digits")
+		}
+	}
+	return nil
+}
+
+func receiptDate() string {
+	return time.Now().UTC().Format("0601021504")
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
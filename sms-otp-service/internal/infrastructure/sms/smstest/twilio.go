@@ -0,0 +1,214 @@
+// Package smstest provides local stand-ins for SMS provider APIs so the
+// providers in package sms can be exercised without network access.
+package smstest
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sms-otp-service/internal/testutil"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{7,14}$`)
+
+// TwilioMessage is a message accepted by the TwilioServer.
+type TwilioMessage struct {
+	SID                 string
+	To                  string
+	From                string
+	MessagingServiceSID string
+	Body                string
+	StatusCallback      string
+}
+
+// TwilioError is a canned error response returned by the TwilioServer.
+type TwilioError struct {
+	HTTPStatus int
+	Code       int
+	Message    string
+	RetryAfter string
+}
+
+// TwilioServer emulates the Messages resource of the Twilio REST API.
+type TwilioServer struct {
+	testutil.Recorder[TwilioMessage, TwilioError]
+
+	URL string
+
+	server     *httptest.Server
+	accountSID string
+	authToken  string
+}
+
+// NewTwilioServer starts a stand-in, shut down when t ends, that accepts
+// requests authenticated with the given account SID and auth token.
+func NewTwilioServer(t testing.TB, accountSID, authToken string) *TwilioServer {
+	s := &TwilioServer{
+		accountSID: accountSID,
+		authToken:  authToken,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close shuts the server down.
+func (s *TwilioServer) Close() {
+	s.server.Close()
+}
+
+// SendStatusCallback posts a signed status callback for the message with the
+// given SID to the StatusCallback URL it was sent with, as Twilio does when
+// the message status changes.
+func (s *TwilioServer) SendStatusCallback(sid, status, errorCode string) error {
+	var msg TwilioMessage
+	for _, m := range s.Messages() {
+		if m.SID == sid {
+			msg = m
+		}
+	}
+
+	if msg.SID == "" {
+		return fmt.Errorf("smstest: unknown message %s", sid)
+	}
+	if msg.StatusCallback == "" {
+		return fmt.Errorf("smstest: message %s has no status callback", sid)
+	}
+
+	form := url.Values{}
+	form.Set("AccountSid", s.accountSID)
+	form.Set("MessageSid", sid)
+	form.Set("SmsSid", sid)
+	form.Set("MessageStatus", status)
+	form.Set("SmsStatus", status)
+	form.Set("To", msg.To)
+	if errorCode != "" {
+		form.Set("ErrorCode", errorCode)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, msg.StatusCallback, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Twilio-Signature", signTwilioRequest(s.authToken, msg.StatusCallback, form))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("smstest: status callback returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *TwilioServer) handle(w http.ResponseWriter, r *http.Request) {
+	path := fmt.Sprintf("/2010-04-01/Accounts/%s/Messages.json", s.accountSID)
+	if r.Method != http.MethodPost || r.URL.Path != path {
+		writeTwilioError(w, TwilioError{HTTPStatus: http.StatusNotFound, Code: 20404, Message: "The requested resource was not found"})
+		return
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if !ok || user != s.accountSID || pass != s.authToken {
+		writeTwilioError(w, TwilioError{HTTPStatus: http.StatusUnauthorized, Code: 20003, Message: "Authenticate"})
+		return
+	}
+
+	if failure, ok := s.NextFailure(); ok {
+		writeTwilioError(w, failure)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeTwilioError(w, TwilioError{HTTPStatus: http.StatusBadRequest, Code: 21100, Message: "Invalid request body"})
+		return
+	}
+
+	msg := TwilioMessage{
+		To:                  r.PostForm.Get("To"),
+		From:                r.PostForm.Get("From"),
+		MessagingServiceSID: r.PostForm.Get("MessagingServiceSid"),
+		Body:                r.PostForm.Get("Body"),
+		StatusCallback:      r.PostForm.Get("StatusCallback"),
+	}
+
+	if !e164Pattern.MatchString(msg.To) {
+		writeTwilioError(w, TwilioError{
+			HTTPStatus: http.StatusBadRequest,
+			Code:       21211,
+			Message:    fmt.Sprintf("The 'To' number %s is not a valid phone number.", msg.To),
+		})
+		return
+	}
+	if msg.From == "" && msg.MessagingServiceSID == "" {
+		writeTwilioError(w, TwilioError{HTTPStatus: http.StatusBadRequest, Code: 21603, Message: "The 'From' number is required."})
+		return
+	}
+	if strings.TrimSpace(msg.Body) == "" {
+		writeTwilioError(w, TwilioError{HTTPStatus: http.StatusBadRequest, Code: 21602, Message: "Message body is required."})
+		return
+	}
+
+	msg = s.Record(func(seq int) TwilioMessage {
+		msg.SID = fmt.Sprintf("SM%032x", seq)
+		return msg
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"sid":         msg.SID,
+		"account_sid": s.accountSID,
+		"to":          msg.To,
+		"from":        msg.From,
+		"body":        msg.Body,
+		"status":      "queued",
+	})
+}
+
+func writeTwilioError(w http.ResponseWriter, failure TwilioError) {
+	if failure.RetryAfter != "" {
+		w.Header().Set("Retry-After", failure.RetryAfter)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(failure.HTTPStatus)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"code":      failure.Code,
+		"message":   failure.Message,
+		"more_info": fmt.Sprintf("https://www.twilio.com/docs/errors/%d", failure.Code),
+		"status":    failure.HTTPStatus,
+	})
+}
+
+func signTwilioRequest(authToken, target string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	data := target
+	for _, key := range keys {
+		for _, value := range form[key] {
+			data += key + value
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
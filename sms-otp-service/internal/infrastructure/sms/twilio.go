@@ -0,0 +1,263 @@
+package sms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTwilioEndpoint = "https://api.twilio.com"
+
+// Twilio error codes, see https://www.twilio.com/docs/api/errors.
+var twilioErrorCodes = map[int]error{
+	20003: ErrAuthentication,
+	20429: ErrThrottled,
+	14107: ErrThrottled,
+	30022: ErrThrottled,
+	21211: ErrInvalidNumber,
+	21217: ErrInvalidNumber,
+	21401: ErrInvalidNumber,
+	21421: ErrInvalidNumber,
+	21614: ErrInvalidNumber,
+	21408: ErrUnreachable,
+	21610: ErrUnreachable,
+	21612: ErrUnreachable,
+	30003: ErrUnreachable,
+	30004: ErrUnreachable,
+	30005: ErrUnreachable,
+	30006: ErrUnreachable,
+}
+
+type twilioSMSService struct {
+	accountSID     string
+	authToken      string
+	from           string
+	endpoint       string
+	statusCallback string
+	client         *http.Client
+	logger         *logrus.Logger
+}
+
+type twilioMessageResponse struct {
+	SID    string `json:"sid"`
+	Status string `json:"status"`
+}
+
+type twilioErrorResponse struct {
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	MoreInfo string `json:"more_info"`
+	Status   int    `json:"status"`
+}
+
+// NewTwilioSMSService sends messages through the Twilio Programmable Messaging
+// REST API. APIKey is the account SID, APISecret the auth token and SenderName
+// the sending number, alphanumeric ID or messaging service SID. APIEndpoint
+// overrides the API base URL, which allows pointing the provider at a local
+// stand-in. When a DLR public URL is configured, Twilio posts status
+// callbacks to the DLR webhook.
+func NewTwilioSMSService(cfg config.SMSConfig, logger *logrus.Logger) Service {
+	endpoint := cfg.APIEndpoint
+	if endpoint == "" {
+		endpoint = defaultTwilioEndpoint
+	}
+
+	s := &twilioSMSService{
+		accountSID: cfg.APIKey,
+		authToken:  cfg.APISecret,
+		from:       cfg.SenderName,
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		client:     &http.Client{Timeout: cfg.Timeout},
+		logger:     logger,
+	}
+	if cfg.DLR.PublicURL != "" {
+		s.statusCallback = strings.TrimRight(cfg.DLR.PublicURL, "/") + ReceiptPath + "twilio"
+	}
+	return s
+}
+
+func (s *twilioSMSService) SendSMS(ctx context.Context, phoneNumber, message string) (*entities.SendResult, error) {
+	form := url.Values{}
+	form.Set("To", phoneNumber)
+	form.Set("Body", message)
+	if strings.HasPrefix(s.from, "MG") {
+		form.Set("MessagingServiceSid", s.from)
+	} else {
+		form.Set("From", s.from)
+	}
+	if s.statusCallback != "" {
+		form.Set("StatusCallback", s.statusCallback)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.endpoint, url.PathEscape(s.accountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build twilio request: %w", err)
+	}
+	req.SetBasicAuth(s.accountSID, s.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, &ProviderError{Provider: "twilio", Message: err.Error(), Err: ErrProviderUnavailable}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, &ProviderError{Provider: "twilio", Message: err.Error(), Err: ErrProviderUnavailable}
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var msg twilioMessageResponse
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return nil, fmt.Errorf("failed to decode twilio response: %w", err)
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"phone_number": phoneNumber,
+			"sid":          msg.SID,
+			"status":       msg.Status,
+		}).Info("Twilio SMS accepted")
+
+		return &entities.SendResult{Provider: "twilio", MessageID: msg.SID}, nil
+	}
+
+	return nil, s.parseError(resp, body)
+}
+
+func (s *twilioSMSService) parseError(resp *http.Response, body []byte) error {
+	var apiErr twilioErrorResponse
+	_ = json.Unmarshal(body, &apiErr)
+
+	providerErr := &ProviderError{
+		Provider: "twilio",
+		Code:     strconv.Itoa(apiErr.Code),
+		Message:  apiErr.Message,
+	}
+	if apiErr.Code == 0 {
+		providerErr.Code = strconv.Itoa(resp.StatusCode)
+	}
+	if providerErr.Message == "" {
+		providerErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	if mapped, ok := twilioErrorCodes[apiErr.Code]; ok {
+		providerErr.Err = mapped
+	} else {
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			providerErr.Err = ErrThrottled
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			providerErr.Err = ErrAuthentication
+		case resp.StatusCode >= 500:
+			providerErr.Err = ErrProviderUnavailable
+		default:
+			providerErr.Err = ErrRejected
+		}
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			providerErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return providerErr
+}
+
+type twilioReceiptDecoder struct {
+	authToken string
+	publicURL string
+}
+
+// newTwilioReceiptDecoder parses Twilio status callbacks. Every callback must
+// carry a valid X-Twilio-Signature, made with the auth token.
+func newTwilioReceiptDecoder(cfg config.SMSConfig) *twilioReceiptDecoder {
+	return &twilioReceiptDecoder{
+		authToken: cfg.APISecret,
+		publicURL: strings.TrimRight(cfg.DLR.PublicURL, "/"),
+	}
+}
+
+func (d *twilioReceiptDecoder) DecodeReceipts(requestURL string, header http.Header, body []byte) ([]*entities.DeliveryReceipt, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", entities.ErrInvalidDeliveryReceipt, err)
+	}
+
+	if d.authToken == "" {
+		return nil, entities.ErrUnauthorizedReceipt
+	}
+	signed := requestURL
+	// Behind a proxy the request URL differs from the one Twilio called.
+	if parsed, err := url.Parse(requestURL); err == nil && d.publicURL != "" {
+		signed = d.publicURL + parsed.RequestURI()
+	}
+	expected := twilioSignature(d.authToken, signed, form)
+	if !hmac.Equal([]byte(header.Get("X-Twilio-Signature")), []byte(expected)) {
+		return nil, entities.ErrUnauthorizedReceipt
+	}
+
+	sid := form.Get("MessageSid")
+	if sid == "" {
+		sid = form.Get("SmsSid")
+	}
+	if sid == "" {
+		return nil, fmt.Errorf("%w: missing MessageSid", entities.ErrInvalidDeliveryReceipt)
+	}
+
+	rawStatus := form.Get("MessageStatus")
+	if rawStatus == "" {
+		rawStatus = form.Get("SmsStatus")
+	}
+	status, ok := normalizeReceiptStatus(rawStatus, nil)
+	if !ok {
+		return nil, nil
+	}
+
+	return []*entities.DeliveryReceipt{{
+		Provider:    "twilio",
+		MessageID:   sid,
+		Status:      status,
+		ErrorCode:   form.Get("ErrorCode"),
+		Description: rawStatus,
+	}}, nil
+}
+
+// twilioSignature computes X-Twilio-Signature: the base64 HMAC-SHA1 of the
+// URL followed by every POST parameter name and value, sorted by name.
+func twilioSignature(authToken, requestURL string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var data strings.Builder
+	data.WriteString(requestURL)
+	for _, key := range keys {
+		for _, value := range form[key] {
+			data.WriteString(key)
+			data.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(data.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,370 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"sms-otp-service/internal/infrastructure/sms/smstest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testAccountSID = "AC00000000000000000000000000000001"
+	testAuthToken  = "secret-token"
+	testRecipient  = "+14155550100"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func twilioConfig(server *smstest.TwilioServer, from string) config.SMSConfig {
+	return config.SMSConfig{
+		Provider:    "twilio",
+		APIKey:      testAccountSID,
+		APISecret:   testAuthToken,
+		SenderName:  from,
+		APIEndpoint: server.URL,
+		Timeout:     5 * time.Second,
+	}
+}
+
+func TestTwilioSend(t *testing.T) {
+	server := smstest.NewTwilioServer(t, testAccountSID, testAuthToken)
+	service := NewTwilioSMSService(twilioConfig(server, "+15005550006"), testLogger())
+
+	result, err := service.SendSMS(context.Background(), testRecipient, "Your code is 123456")
+	if err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("server got %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if result.Provider != "twilio" || result.MessageID != msg.SID {
+		t.Errorf("result = %+v, want provider twilio and message ID %s", result, msg.SID)
+	}
+	if msg.To != testRecipient || msg.From != "+15005550006" || msg.Body != "Your code is 123456" {
+		t.Errorf("server got %+v", msg)
+	}
+	if msg.MessagingServiceSID != "" || msg.StatusCallback != "" {
+		t.Errorf("server got messaging service %q and status callback %q, want neither", msg.MessagingServiceSID, msg.StatusCallback)
+	}
+}
+
+func TestTwilioSendThroughMessagingService(t *testing.T) {
+	server := smstest.NewTwilioServer(t, testAccountSID, testAuthToken)
+	cfg := twilioConfig(server, "MG00000000000000000000000000000001")
+	cfg.DLR.PublicURL = "https://otp.example.com/"
+	service := NewTwilioSMSService(cfg, testLogger())
+
+	if _, err := service.SendSMS(context.Background(), testRecipient, "Your code is 123456"); err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+
+	msg := server.Messages()[0]
+	if msg.MessagingServiceSID != cfg.SenderName || msg.From != "" {
+		t.Errorf("server got messaging service %q and from %q", msg.MessagingServiceSID, msg.From)
+	}
+	if want := "https://otp.example.com/api/v1/dlr/twilio"; msg.StatusCallback != want {
+		t.Errorf("status callback = %q, want %q", msg.StatusCallback, want)
+	}
+}
+
+func TestTwilioSendErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		failure        *smstest.TwilioError
+		recipient      string
+		authToken      string
+		want           error
+		wantCode       string
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:      "invalid number",
+			recipient: "12345",
+			want:      ErrInvalidNumber,
+			wantCode:  "21211",
+		},
+		{
+			name:     "unsubscribed recipient",
+			failure:  &smstest.TwilioError{HTTPStatus: http.StatusBadRequest, Code: 21610, Message: "Attempt to send to unsubscribed recipient"},
+			want:     ErrUnreachable,
+			wantCode: "21610",
+		},
+		{
+			name:     "unknown destination",
+			failure:  &smstest.TwilioError{HTTPStatus: http.StatusBadRequest, Code: 30005, Message: "Unknown destination handset"},
+			want:     ErrUnreachable,
+			wantCode: "30005",
+		},
+		{
+			name:           "too many requests",
+			failure:        &smstest.TwilioError{HTTPStatus: http.StatusTooManyRequests, Code: 20429, Message: "Too Many Requests", RetryAfter: "7"},
+			want:           ErrThrottled,
+			wantCode:       "20429",
+			wantRetryAfter: 7 * time.Second,
+		},
+		{
+			name:     "queue overflow without retry after",
+			failure:  &smstest.TwilioError{HTTPStatus: http.StatusBadRequest, Code: 30022, Message: "Account exceeded the messages per second limit"},
+			want:     ErrThrottled,
+			wantCode: "30022",
+		},
+		{
+			name:           "unknown code throttled by status",
+			failure:        &smstest.TwilioError{HTTPStatus: http.StatusTooManyRequests, Code: 99999, Message: "Slow down", RetryAfter: "soon"},
+			want:           ErrThrottled,
+			wantCode:       "99999",
+			wantRetryAfter: 0,
+		},
+		{
+			name:      "wrong credentials",
+			authToken: "wrong-token",
+			want:      ErrAuthentication,
+			wantCode:  "20003",
+		},
+		{
+			name:     "server error",
+			failure:  &smstest.TwilioError{HTTPStatus: http.StatusServiceUnavailable, Code: 20500, Message: "Service Unavailable"},
+			want:     ErrProviderUnavailable,
+			wantCode: "20500",
+		},
+		{
+			name:     "unknown rejection",
+			failure:  &smstest.TwilioError{HTTPStatus: http.StatusBadRequest, Code: 21602, Message: "Message body is required."},
+			want:     ErrRejected,
+			wantCode: "21602",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := smstest.NewTwilioServer(t, testAccountSID, testAuthToken)
+			if tt.failure != nil {
+				server.FailNext(*tt.failure)
+			}
+			cfg := twilioConfig(server, "+15005550006")
+			if tt.authToken != "" {
+				cfg.APISecret = tt.authToken
+			}
+			recipient := tt.recipient
+			if recipient == "" {
+				recipient = testRecipient
+			}
+
+			_, err := NewTwilioSMSService(cfg, testLogger()).SendSMS(context.Background(), recipient, "Your code is 123456")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SendSMS error = %v, want %v", err, tt.want)
+			}
+			var providerErr *ProviderError
+			if !errors.As(err, &providerErr) {
+				t.Fatalf("SendSMS error = %T, want *ProviderError", err)
+			}
+			if providerErr.Provider != "twilio" || providerErr.Code != tt.wantCode {
+				t.Errorf("provider error = %+v, want code %s", providerErr, tt.wantCode)
+			}
+			if providerErr.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("retry after = %v, want %v", providerErr.RetryAfter, tt.wantRetryAfter)
+			}
+			if len(server.Messages()) != 0 {
+				t.Errorf("server accepted %d messages, want none", len(server.Messages()))
+			}
+		})
+	}
+}
+
+func TestTwilioSendUnavailable(t *testing.T) {
+	server := smstest.NewTwilioServer(t, testAccountSID, testAuthToken)
+	cfg := twilioConfig(server, "+15005550006")
+	server.Close()
+
+	_, err := NewTwilioSMSService(cfg, testLogger()).SendSMS(context.Background(), testRecipient, "Your code is 123456")
+	if !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("SendSMS error = %v, want %v", err, ErrProviderUnavailable)
+	}
+}
+
+// receivedCallback is a status callback as it reached the DLR webhook.
+type receivedCallback struct {
+	url    string
+	header http.Header
+	body   []byte
+}
+
+// sendTwilioCallback sends a message through server and has it post a status
+// callback for the message, returning the request the webhook received.
+func sendTwilioCallback(t *testing.T, server *smstest.TwilioServer, status, errorCode string) receivedCallback {
+	t.Helper()
+
+	received := make(chan receivedCallback, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedCallback{
+			url:    "http://" + r.Host + r.URL.RequestURI(),
+			header: r.Header.Clone(),
+			body:   body,
+		}
+	}))
+	t.Cleanup(webhook.Close)
+
+	cfg := twilioConfig(server, "+15005550006")
+	cfg.DLR.PublicURL = webhook.URL
+	result, err := NewTwilioSMSService(cfg, testLogger()).SendSMS(context.Background(), testRecipient, "Your code is 123456")
+	if err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if err := server.SendStatusCallback(result.MessageID, status, errorCode); err != nil {
+		t.Fatalf("SendStatusCallback: %v", err)
+	}
+	return <-received
+}
+
+func TestTwilioReceipts(t *testing.T) {
+	server := smstest.NewTwilioServer(t, testAccountSID, testAuthToken)
+	callback := sendTwilioCallback(t, server, "undelivered", "30005")
+	sid := server.Messages()[0].SID
+
+	decoder := newTwilioReceiptDecoder(config.SMSConfig{APISecret: testAuthToken})
+	receipts, err := decoder.DecodeReceipts(callback.url, callback.header, callback.body)
+	if err != nil {
+		t.Fatalf("DecodeReceipts: %v", err)
+	}
+	if len(receipts) != 1 {
+		t.Fatalf("got %d receipts, want 1", len(receipts))
+	}
+	receipt := receipts[0]
+	if receipt.Provider != "twilio" || receipt.MessageID != sid || receipt.Status != entities.DeliveryFailed || receipt.ErrorCode != "30005" {
+		t.Errorf("receipt = %+v", receipt)
+	}
+}
+
+func TestTwilioReceiptBehindProxy(t *testing.T) {
+	server := smstest.NewTwilioServer(t, testAccountSID, testAuthToken)
+	callback := sendTwilioCallback(t, server, "delivered", "")
+	publicURL := strings.TrimSuffix(callback.url, ReceiptPath+"twilio")
+
+	// The service sees the address of the proxy in front of it, so the
+	// signature has to be checked against the configured public URL.
+	decoder := newTwilioReceiptDecoder(config.SMSConfig{
+		APISecret: testAuthToken,
+		DLR:       config.DLRConfig{PublicURL: publicURL + "/"},
+	})
+	receipts, err := decoder.DecodeReceipts("http://10.0.0.5:8080"+ReceiptPath+"twilio", callback.header, callback.body)
+	if err != nil {
+		t.Fatalf("DecodeReceipts: %v", err)
+	}
+	if len(receipts) != 1 || receipts[0].Status != entities.DeliveryDelivered {
+		t.Errorf("receipts = %+v, want one delivered receipt", receipts)
+	}
+}
+
+func TestTwilioReceiptSignature(t *testing.T) {
+	server := smstest.NewTwilioServer(t, testAccountSID, testAuthToken)
+	callback := sendTwilioCallback(t, server, "delivered", "")
+
+	tests := []struct {
+		name   string
+		token  string
+		url    string
+		header func(http.Header)
+		body   func([]byte) []byte
+	}{
+		{
+			name:  "wrong auth token",
+			token: "other-token",
+		},
+		{
+			name:   "missing signature",
+			header: func(h http.Header) { h.Del("X-Twilio-Signature") },
+		},
+		{
+			name:   "forged signature",
+			header: func(h http.Header) { h.Set("X-Twilio-Signature", "bm90IGEgc2lnbmF0dXJl") },
+		},
+		{
+			name: "tampered body",
+			body: func(b []byte) []byte { return []byte(strings.Replace(string(b), "delivered", "failed", 2)) },
+		},
+		{
+			name: "different url",
+			url:  "https://evil.example.com" + ReceiptPath + "twilio",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := testAuthToken
+			if tt.token != "" {
+				token = tt.token
+			}
+			requestURL := callback.url
+			if tt.url != "" {
+				requestURL = tt.url
+			}
+			header := callback.header.Clone()
+			if tt.header != nil {
+				tt.header(header)
+			}
+			body := callback.body
+			if tt.body != nil {
+				body = tt.body(body)
+			}
+
+			decoder := newTwilioReceiptDecoder(config.SMSConfig{APISecret: token})
+			receipts, err := decoder.DecodeReceipts(requestURL, header, body)
+			if !errors.Is(err, entities.ErrUnauthorizedReceipt) {
+				t.Fatalf("DecodeReceipts = %v, %v, want %v", receipts, err, entities.ErrUnauthorizedReceipt)
+			}
+		})
+	}
+}
+
+func TestTwilioReceiptFields(t *testing.T) {
+	decoder := newTwilioReceiptDecoder(config.SMSConfig{APISecret: testAuthToken})
+	requestURL := "http://localhost" + ReceiptPath + "twilio"
+	decode := func(body string) ([]*entities.DeliveryReceipt, error) {
+		form, _ := url.ParseQuery(body)
+		header := http.Header{"X-Twilio-Signature": {twilioSignature(testAuthToken, requestURL, form)}}
+		return decoder.DecodeReceipts(requestURL, header, []byte(body))
+	}
+
+	// Older callbacks name the fields SmsSid and SmsStatus.
+	receipts, err := decode("SmsSid=SM1&SmsStatus=sent")
+	if err != nil {
+		t.Fatalf("DecodeReceipts: %v", err)
+	}
+	if len(receipts) != 1 || receipts[0].MessageID != "SM1" || receipts[0].Status != entities.DeliverySent {
+		t.Errorf("receipts = %+v, want one sent receipt for SM1", receipts)
+	}
+
+	if _, err := decode("MessageStatus=sent"); !errors.Is(err, entities.ErrInvalidDeliveryReceipt) {
+		t.Errorf("DecodeReceipts without a SID = %v, want %v", err, entities.ErrInvalidDeliveryReceipt)
+	}
+}
+
+func TestTwilioReceiptWithoutAuthToken(t *testing.T) {
+	decoder := newTwilioReceiptDecoder(config.SMSConfig{})
+	requestURL := "http://localhost" + ReceiptPath + "twilio"
+	form := url.Values{"MessageSid": {"SM1"}, "MessageStatus": {"delivered"}}
+
+	// A signature made with the empty key proves nothing.
+	header := http.Header{"X-Twilio-Signature": {twilioSignature("", requestURL, form)}}
+	if _, err := decoder.DecodeReceipts(requestURL, header, []byte(form.Encode())); !errors.Is(err, entities.ErrUnauthorizedReceipt) {
+		t.Errorf("DecodeReceipts = %v, want %v", err, entities.ErrUnauthorizedReceipt)
+	}
+}
@@ -0,0 +1,327 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// webhookTemplateData holds the values available to webhook templates. Each
+// template receives a copy escaped for the context it renders into, so
+// `{"to":"{{.To}}"}` and `to={{.To}}` are both safe to write as-is.
+type webhookTemplateData struct {
+	To        string
+	Message   string
+	Sender    string
+	APIKey    string
+	APISecret string
+}
+
+type webhookSMSService struct {
+	method       string
+	urlTemplate  *template.Template
+	headers      map[string]*template.Template
+	bodyTemplate *template.Template
+	bodyFormat   string
+	successCodes map[int]bool
+	successPath  string
+	successValue string
+	idPath       string
+	data         webhookTemplateData
+	client       *http.Client
+	logger       *logrus.Logger
+}
+
+// NewWebhookSMSService builds a provider for plain HTTP gateways entirely from
+// configuration: request method, URL, headers and body are Go templates over
+// To, Message, Sender, APIKey and APISecret, and success is decided by the
+// response status code and optionally a value in the JSON response. The
+// provider message ID, if any, is read from the JSON response as well.
+func NewWebhookSMSService(cfg config.SMSConfig, logger *logrus.Logger) (Service, error) {
+	webhook := cfg.Webhook
+	if webhook.URLTemplate == "" {
+		return nil, fmt.Errorf("webhook sms provider requires SMS_WEBHOOK_URL")
+	}
+
+	s := &webhookSMSService{
+		method:       strings.ToUpper(webhook.Method),
+		headers:      make(map[string]*template.Template, len(webhook.Headers)),
+		bodyFormat:   strings.ToLower(webhook.BodyFormat),
+		successCodes: make(map[int]bool, len(webhook.SuccessStatusCodes)),
+		successPath:  webhook.SuccessJSONPath,
+		successValue: webhook.SuccessValue,
+		idPath:       webhook.MessageIDJSONPath,
+		data: webhookTemplateData{
+			Sender:    cfg.SenderName,
+			APIKey:    cfg.APIKey,
+			APISecret: cfg.APISecret,
+		},
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+	}
+	if s.method == "" {
+		s.method = http.MethodPost
+	}
+
+	var err error
+	if s.urlTemplate, err = template.New("url").Parse(webhook.URLTemplate); err != nil {
+		return nil, fmt.Errorf("invalid webhook url template: %w", err)
+	}
+	for name, value := range webhook.Headers {
+		if s.headers[name], err = template.New(name).Parse(value); err != nil {
+			return nil, fmt.Errorf("invalid webhook header template %q: %w", name, err)
+		}
+	}
+	if webhook.BodyTemplate != "" {
+		if s.bodyTemplate, err = template.New("body").Parse(webhook.BodyTemplate); err != nil {
+			return nil, fmt.Errorf("invalid webhook body template: %w", err)
+		}
+	}
+
+	switch s.bodyFormat {
+	case "", "json", "form":
+	default:
+		return nil, fmt.Errorf("unsupported webhook body format %q", webhook.BodyFormat)
+	}
+
+	for _, code := range webhook.SuccessStatusCodes {
+		s.successCodes[code] = true
+	}
+
+	return s, nil
+}
+
+func (s *webhookSMSService) SendSMS(ctx context.Context, phoneNumber, message string) (*entities.SendResult, error) {
+	req, err := s.buildRequest(ctx, phoneNumber, message)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, &ProviderError{Provider: "webhook", Message: err.Error(), Err: ErrProviderUnavailable}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, &ProviderError{Provider: "webhook", Message: err.Error(), Err: ErrProviderUnavailable}
+	}
+
+	if err := s.checkResponse(resp.StatusCode, body); err != nil {
+		return nil, err
+	}
+
+	messageID := s.messageID(body)
+	s.logger.WithFields(logrus.Fields{
+		"phone_number": phoneNumber,
+		"status_code":  resp.StatusCode,
+		"message_id":   messageID,
+	}).Info("Webhook SMS accepted")
+
+	return &entities.SendResult{Provider: "webhook", MessageID: messageID}, nil
+}
+
+func (s *webhookSMSService) buildRequest(ctx context.Context, phoneNumber, message string) (*http.Request, error) {
+	data := s.data
+	data.To = phoneNumber
+	data.Message = message
+
+	target, err := render(s.urlTemplate, escapeTemplateData(data, url.QueryEscape))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render webhook url: %w", err)
+	}
+
+	var body io.Reader
+	contentType := ""
+	if s.bodyTemplate != nil {
+		var rendered string
+		switch s.bodyFormat {
+		case "form":
+			rendered, err = render(s.bodyTemplate, escapeTemplateData(data, url.QueryEscape))
+			contentType = "application/x-www-form-urlencoded"
+		case "json":
+			rendered, err = render(s.bodyTemplate, escapeTemplateData(data, jsonEscape))
+			contentType = "application/json"
+		default:
+			rendered, err = render(s.bodyTemplate, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to render webhook body: %w", err)
+		}
+		body = strings.NewReader(rendered)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, s.method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, tmpl := range s.headers {
+		value, err := render(tmpl, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render webhook header %q: %w", name, err)
+		}
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
+}
+
+func (s *webhookSMSService) checkResponse(statusCode int, body []byte) error {
+	statusOK := statusCode >= 200 && statusCode < 300
+	if len(s.successCodes) > 0 {
+		statusOK = s.successCodes[statusCode]
+	}
+
+	if !statusOK {
+		providerErr := &ProviderError{
+			Provider: "webhook",
+			Code:     strconv.Itoa(statusCode),
+			Message:  strings.TrimSpace(string(body)),
+		}
+		switch {
+		case statusCode == http.StatusTooManyRequests:
+			providerErr.Err = ErrThrottled
+		case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+			providerErr.Err = ErrAuthentication
+		case statusCode >= 500:
+			providerErr.Err = ErrProviderUnavailable
+		default:
+			providerErr.Err = ErrRejected
+		}
+		return providerErr
+	}
+
+	if s.successPath == "" {
+		return nil
+	}
+
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return &ProviderError{Provider: "webhook", Code: strconv.Itoa(statusCode), Message: "response is not valid JSON", Err: ErrRejected}
+	}
+
+	value, found := lookupJSONPath(decoded, s.successPath)
+	if found && matchesSuccessValue(value, s.successValue) {
+		return nil
+	}
+
+	return &ProviderError{
+		Provider: "webhook",
+		Code:     fmt.Sprint(value),
+		Message:  fmt.Sprintf("unexpected value at %s", s.successPath),
+		Err:      ErrRejected,
+	}
+}
+
+func (s *webhookSMSService) messageID(body []byte) string {
+	if s.idPath == "" {
+		return ""
+	}
+
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return ""
+	}
+	value, found := lookupJSONPath(decoded, s.idPath)
+	if !found {
+		return ""
+	}
+	return jsonString(value)
+}
+
+func render(tmpl *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func escapeTemplateData(data webhookTemplateData, escape func(string) string) webhookTemplateData {
+	return webhookTemplateData{
+		To:        escape(data.To),
+		Message:   escape(data.Message),
+		Sender:    escape(data.Sender),
+		APIKey:    escape(data.APIKey),
+		APISecret: escape(data.APISecret),
+	}
+}
+
+// jsonEscape escapes s for use inside a JSON string literal.
+func jsonEscape(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded[1 : len(encoded)-1])
+}
+
+// lookupJSONPath resolves a dot separated path such as "data.0.status"
+// against a decoded JSON document.
+func lookupJSONPath(doc any, path string) (any, bool) {
+	current := doc
+	for _, part := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonString formats a decoded JSON scalar without exponent notation, so
+// numeric IDs survive the round trip.
+func jsonString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// matchesSuccessValue compares a JSON value with the configured expectation.
+// Without an expectation any truthy value counts as success.
+func matchesSuccessValue(value any, expected string) bool {
+	if expected != "" {
+		return jsonString(value) == expected
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	default:
+		return true
+	}
+}
@@ -0,0 +1,270 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sms-otp-service/internal/infrastructure/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testMessage needs escaping in every context it is rendered into.
+const testMessage = "Your \"Acme\" code is 123456 & valid\nfor 5 minutes"
+
+// webhookRequest is a request the gateway stand-in received.
+type webhookRequest struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   string
+}
+
+// webhookGateway answers every request with a canned response and records
+// what it received.
+type webhookGateway struct {
+	URL string
+
+	mu       sync.Mutex
+	status   int
+	response string
+	requests []webhookRequest
+}
+
+func newWebhookGateway(t *testing.T, status int, response string) *webhookGateway {
+	g := &webhookGateway{status: status, response: response}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.requests = append(g.requests, webhookRequest{
+			method: r.Method,
+			path:   r.URL.Path,
+			query:  r.URL.Query(),
+			header: r.Header.Clone(),
+			body:   string(body),
+		})
+		w.WriteHeader(g.status)
+		io.WriteString(w, g.response)
+	}))
+	t.Cleanup(server.Close)
+	g.URL = server.URL
+	return g
+}
+
+func (g *webhookGateway) lastRequest(t *testing.T) webhookRequest {
+	t.Helper()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.requests) != 1 {
+		t.Fatalf("gateway got %d requests, want 1", len(g.requests))
+	}
+	return g.requests[0]
+}
+
+func webhookConfig(webhook config.WebhookConfig) config.SMSConfig {
+	return config.SMSConfig{
+		Provider:   "webhook",
+		APIKey:     "key id",
+		APISecret:  "s3cr=t&x",
+		SenderName: "Acme & Co",
+		Timeout:    5 * time.Second,
+		Webhook:    webhook,
+	}
+}
+
+func newTestWebhookService(t *testing.T, webhook config.WebhookConfig) Service {
+	t.Helper()
+	service, err := NewWebhookSMSService(webhookConfig(webhook), testLogger())
+	if err != nil {
+		t.Fatalf("NewWebhookSMSService: %v", err)
+	}
+	return service
+}
+
+func TestWebhookJSONBody(t *testing.T) {
+	gateway := newWebhookGateway(t, http.StatusOK, `{"data":{"id":12345678901,"state":"queued"}}`)
+	service := newTestWebhookService(t, config.WebhookConfig{
+		URLTemplate:       gateway.URL + "/v1/messages",
+		BodyTemplate:      `{"to":"{{.To}}","text":"{{.Message}}","from":"{{.Sender}}"}`,
+		BodyFormat:        "json",
+		Headers:           map[string]string{"Authorization": "Bearer {{.APISecret}}", "X-Account": "{{.APIKey}}"},
+		MessageIDJSONPath: "data.id",
+	})
+
+	result, err := service.SendSMS(context.Background(), testRecipient, testMessage)
+	if err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if result.Provider != "webhook" || result.MessageID != "12345678901" {
+		t.Errorf("result = %+v, want the ID from data.id", result)
+	}
+
+	req := gateway.lastRequest(t)
+	if req.method != http.MethodPost || req.path != "/v1/messages" {
+		t.Errorf("request = %s %s, want POST /v1/messages", req.method, req.path)
+	}
+	if req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", req.header.Get("Content-Type"))
+	}
+
+	// Headers are rendered unescaped.
+	if req.header.Get("Authorization") != "Bearer s3cr=t&x" || req.header.Get("X-Account") != "key id" {
+		t.Errorf("headers = %v", req.header)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal([]byte(req.body), &body); err != nil {
+		t.Fatalf("body %q is not valid JSON: %v", req.body, err)
+	}
+	if body["to"] != testRecipient || body["text"] != testMessage || body["from"] != "Acme & Co" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestWebhookFormBody(t *testing.T) {
+	gateway := newWebhookGateway(t, http.StatusOK, "OK")
+	service := newTestWebhookService(t, config.WebhookConfig{
+		URLTemplate:  gateway.URL + "/send",
+		BodyTemplate: "to={{.To}}&text={{.Message}}&from={{.Sender}}&secret={{.APISecret}}",
+		BodyFormat:   "form",
+	})
+
+	result, err := service.SendSMS(context.Background(), testRecipient, testMessage)
+	if err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if result.MessageID != "" {
+		t.Errorf("message ID = %q without a configured path", result.MessageID)
+	}
+
+	req := gateway.lastRequest(t)
+	if req.header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type = %q", req.header.Get("Content-Type"))
+	}
+	form, err := url.ParseQuery(req.body)
+	if err != nil {
+		t.Fatalf("body %q is not a valid form: %v", req.body, err)
+	}
+	want := url.Values{
+		"to":     {testRecipient},
+		"text":   {testMessage},
+		"from":   {"Acme & Co"},
+		"secret": {"s3cr=t&x"},
+	}
+	for name, values := range want {
+		if form.Get(name) != values[0] {
+			t.Errorf("form %s = %q, want %q", name, form.Get(name), values[0])
+		}
+	}
+	if len(form) != len(want) {
+		t.Errorf("form = %v, a value escaped its field", form)
+	}
+}
+
+func TestWebhookURLTemplate(t *testing.T) {
+	gateway := newWebhookGateway(t, http.StatusOK, "OK")
+	service := newTestWebhookService(t, config.WebhookConfig{
+		Method:      "get",
+		URLTemplate: gateway.URL + "/api/send?user={{.APIKey}}&to={{.To}}&msg={{.Message}}",
+	})
+
+	if _, err := service.SendSMS(context.Background(), testRecipient, testMessage); err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+
+	req := gateway.lastRequest(t)
+	if req.method != http.MethodGet || req.path != "/api/send" || req.body != "" {
+		t.Errorf("request = %s %s with body %q, want GET /api/send without a body", req.method, req.path, req.body)
+	}
+	if req.query.Get("user") != "key id" || req.query.Get("to") != testRecipient || req.query.Get("msg") != testMessage {
+		t.Errorf("query = %v", req.query)
+	}
+	if len(req.query) != 3 {
+		t.Errorf("query = %v, a value escaped its parameter", req.query)
+	}
+}
+
+func TestWebhookSuccessDetection(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		response     string
+		successCodes []int
+		successPath  string
+		successValue string
+		want         error
+		wantCode     string
+	}{
+		{name: "ok", status: http.StatusOK, response: "OK"},
+		{name: "accepted", status: http.StatusAccepted, response: "OK"},
+		{name: "throttled", status: http.StatusTooManyRequests, want: ErrThrottled, wantCode: "429"},
+		{name: "unauthorized", status: http.StatusUnauthorized, want: ErrAuthentication, wantCode: "401"},
+		{name: "forbidden", status: http.StatusForbidden, want: ErrAuthentication, wantCode: "403"},
+		{name: "unavailable", status: http.StatusServiceUnavailable, want: ErrProviderUnavailable, wantCode: "503"},
+		{name: "bad request", status: http.StatusBadRequest, response: "bad number", want: ErrRejected, wantCode: "400"},
+		{name: "status not listed", status: http.StatusAccepted, successCodes: []int{200}, want: ErrRejected, wantCode: "202"},
+		{name: "status listed", status: http.StatusCreated, successCodes: []int{200, 201}},
+		{name: "value matches", status: http.StatusOK, response: `{"status":"ok"}`, successPath: "status", successValue: "ok"},
+		{name: "value differs", status: http.StatusOK, response: `{"status":"error"}`, successPath: "status", successValue: "ok", want: ErrRejected, wantCode: "error"},
+		{name: "numeric value", status: http.StatusOK, response: `{"code":0}`, successPath: "code", successValue: "0"},
+		{name: "numeric error", status: http.StatusOK, response: `{"code":17}`, successPath: "code", successValue: "0", want: ErrRejected, wantCode: "17"},
+		{name: "truthy in array", status: http.StatusOK, response: `{"results":[{"accepted":true}]}`, successPath: "results.0.accepted"},
+		{name: "false in array", status: http.StatusOK, response: `{"results":[{"accepted":false}]}`, successPath: "results.0.accepted", want: ErrRejected, wantCode: "false"},
+		{name: "index out of range", status: http.StatusOK, response: `{"results":[]}`, successPath: "results.0.accepted", want: ErrRejected},
+		{name: "path missing", status: http.StatusOK, response: `{}`, successPath: "status", want: ErrRejected},
+		{name: "not json", status: http.StatusOK, response: "OK", successPath: "status", want: ErrRejected, wantCode: "200"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := newWebhookGateway(t, tt.status, tt.response)
+			service := newTestWebhookService(t, config.WebhookConfig{
+				URLTemplate:        gateway.URL,
+				SuccessStatusCodes: tt.successCodes,
+				SuccessJSONPath:    tt.successPath,
+				SuccessValue:       tt.successValue,
+			})
+
+			_, err := service.SendSMS(context.Background(), testRecipient, testMessage)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("SendSMS: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SendSMS error = %v, want %v", err, tt.want)
+			}
+			var providerErr *ProviderError
+			if tt.wantCode != "" && (!errors.As(err, &providerErr) || providerErr.Code != tt.wantCode) {
+				t.Errorf("SendSMS error = %+v, want code %q", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestNewWebhookSMSServiceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook config.WebhookConfig
+	}{
+		{name: "no url", webhook: config.WebhookConfig{}},
+		{name: "invalid url template", webhook: config.WebhookConfig{URLTemplate: "http://gateway/{{.To"}},
+		{name: "invalid header template", webhook: config.WebhookConfig{URLTemplate: "http://gateway", Headers: map[string]string{"X-Key": "{{.APIKey"}}},
+		{name: "invalid body template", webhook: config.WebhookConfig{URLTemplate: "http://gateway", BodyTemplate: "{{if}}"}},
+		{name: "unknown body format", webhook: config.WebhookConfig{URLTemplate: "http://gateway", BodyFormat: "xml"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewWebhookSMSService(webhookConfig(tt.webhook), testLogger()); err == nil {
+			t.Errorf("%s: NewWebhookSMSService accepted the configuration", tt.name)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Package token issues and validates the signed JWTs handed out after a
+// successful OTP verification.
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// clockSkew is the leeway allowed when checking the lifetime of a token
+// issued by another instance.
+const clockSkew = 30 * time.Second
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+type claims struct {
+	TokenID             string              `json:"jti"`
+	Issuer              string              `json:"iss,omitempty"`
+	Subject             string              `json:"sub"`
+	Audience            audience            `json:"aud,omitempty"`
+	IssuedAt            int64               `json:"iat"`
+	NotBefore           int64               `json:"nbf"`
+	ExpiresAt           int64               `json:"exp"`
+	PhoneNumber         string              `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool                `json:"phone_number_verified,omitempty"`
+	Email               string              `json:"email,omitempty"`
+	EmailVerified       bool                `json:"email_verified,omitempty"`
+	Purpose             entities.OTPPurpose `json:"purpose"`
+	OTPID               string              `json:"otp_id"`
+	VerifiedAt          int64               `json:"verified_at"`
+}
+
+// audience is the aud claim, which is either a string or a list of strings.
+type audience []string
+
+func (a audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Signer issues verification tokens and validates tokens it issued.
+type Signer struct {
+	algorithm string
+	keyID     string
+	issuer    string
+	audience  string
+	ttl       time.Duration
+	key       signingKey
+	ephemeral bool
+}
+
+// NewSigner loads the signing key described by cfg. Without a configured
+// key a random one is generated; see Ephemeral.
+func NewSigner(cfg config.TokenConfig) (*Signer, error) {
+	key, ephemeral, err := loadSigningKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := cfg.KeyID
+	if keyID == "" {
+		keyID = key.thumbprint()
+	}
+
+	return &Signer{
+		algorithm: cfg.Algorithm,
+		keyID:     keyID,
+		issuer:    cfg.Issuer,
+		audience:  cfg.Audience,
+		ttl:       cfg.TTL,
+		key:       key,
+		ephemeral: ephemeral,
+	}, nil
+}
+
+// Ephemeral reports whether the signer uses a generated key, so tokens stop
+// validating on restart and are not accepted by other instances.
+func (s *Signer) Ephemeral() bool {
+	return s.ephemeral
+}
+
+// Issue signs a token for c, filling in its token ID, issuer, audience and
+// lifetime.
+func (s *Signer) Issue(c *entities.VerificationClaims) (string, error) {
+	now := time.Now()
+	c.TokenID = uuid.NewString()
+	c.Issuer = s.issuer
+	c.Audience = s.audience
+	c.IssuedAt = now
+	c.ExpiresAt = now.Add(s.ttl)
+
+	body := claims{
+		TokenID:             c.TokenID,
+		Issuer:              c.Issuer,
+		Subject:             c.Recipient.Address,
+		IssuedAt:            c.IssuedAt.Unix(),
+		NotBefore:           c.IssuedAt.Unix(),
+		ExpiresAt:           c.ExpiresAt.Unix(),
+		PhoneNumber:         c.Recipient.PhoneNumber(),
+		PhoneNumberVerified: c.Recipient.Type == entities.RecipientPhone,
+		Email:               c.Recipient.Email(),
+		EmailVerified:       c.Recipient.Type == entities.RecipientEmail,
+		Purpose:             c.Purpose,
+		OTPID:               c.OTPID,
+		VerifiedAt:          c.VerifiedAt.Unix(),
+	}
+	if c.Audience != "" {
+		body.Audience = audience{c.Audience}
+	}
+
+	headerJSON, err := json.Marshal(header{Algorithm: s.algorithm, Type: "JWT", KeyID: s.keyID})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encode(headerJSON) + "." + encode(claimsJSON)
+	signature, err := s.key.sign([]byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return signingInput + "." + encode(signature), nil
+}
+
+// Parse validates the signature, lifetime, issuer and audience of token and
+// returns its claims. Only the configured algorithm is accepted.
+func (s *Signer) Parse(token string) (*entities.VerificationClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, entities.ErrInvalidToken
+	}
+
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return nil, entities.ErrInvalidToken
+	}
+	if h.Algorithm != s.algorithm || (h.KeyID != "" && h.KeyID != s.keyID) {
+		return nil, entities.ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, entities.ErrInvalidToken
+	}
+	if !s.key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, entities.ErrInvalidToken
+	}
+
+	var body claims
+	if err := decodeJSON(parts[1], &body); err != nil {
+		return nil, entities.ErrInvalidToken
+	}
+
+	now := time.Now()
+	if now.After(time.Unix(body.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, entities.ErrTokenExpired
+	}
+	if now.Add(clockSkew).Before(time.Unix(body.NotBefore, 0)) {
+		return nil, entities.ErrInvalidToken
+	}
+	if body.Issuer != s.issuer {
+		return nil, entities.ErrInvalidToken
+	}
+	if s.audience != "" && !body.Audience.contains(s.audience) {
+		return nil, entities.ErrInvalidToken
+	}
+
+	recipient := entities.PhoneRecipient(body.PhoneNumber)
+	if body.Email != "" {
+		recipient = entities.EmailRecipient(body.Email)
+	}
+
+	return &entities.VerificationClaims{
+		TokenID:    body.TokenID,
+		Issuer:     body.Issuer,
+		Audience:   s.audience,
+		Recipient:  recipient,
+		Purpose:    body.Purpose,
+		OTPID:      body.OTPID,
+		VerifiedAt: time.Unix(body.VerifiedAt, 0),
+		IssuedAt:   time.Unix(body.IssuedAt, 0),
+		ExpiresAt:  time.Unix(body.ExpiresAt, 0),
+	}, nil
+}
+
+// Keys returns the public keys that validate issued tokens. HS256 keys are
+// secret and never published.
+func (s *Signer) Keys() []entities.JSONWebKey {
+	jwk, ok := s.key.public()
+	if !ok {
+		return []entities.JSONWebKey{}
+	}
+	jwk.Use = "sig"
+	jwk.KeyID = s.keyID
+	jwk.Algorithm = s.algorithm
+	return []entities.JSONWebKey{jwk}
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJSON(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// signingKey is the key material of one algorithm.
+type signingKey interface {
+	sign(input []byte) ([]byte, error)
+	verify(input, signature []byte) bool
+	// public returns the JWK of the public key, or false for symmetric keys.
+	public() (entities.JSONWebKey, bool)
+	// thumbprint identifies the key without revealing it.
+	thumbprint() string
+}
+
+type hmacKey []byte
+
+func (k hmacKey) sign(input []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k)
+	mac.Write(input)
+	return mac.Sum(nil), nil
+}
+
+func (k hmacKey) verify(input, signature []byte) bool {
+	expected, _ := k.sign(input)
+	return hmac.Equal(expected, signature)
+}
+
+func (k hmacKey) public() (entities.JSONWebKey, bool) {
+	return entities.JSONWebKey{}, false
+}
+
+func (k hmacKey) thumbprint() string {
+	// An HMAC of a fixed label identifies the secret without revealing it.
+	mac := hmac.New(sha256.New, k)
+	mac.Write([]byte("kid"))
+	return encode(mac.Sum(nil)[:8])
+}
+
+type rsaKey struct {
+	*rsa.PrivateKey
+}
+
+func (k rsaKey) sign(input []byte) ([]byte, error) {
+	digest := sha256.Sum256(input)
+	return rsa.SignPKCS1v15(rand.Reader, k.PrivateKey, crypto.SHA256, digest[:])
+}
+
+func (k rsaKey) verify(input, signature []byte) bool {
+	digest := sha256.Sum256(input)
+	return rsa.VerifyPKCS1v15(&k.PublicKey, crypto.SHA256, digest[:], signature) == nil
+}
+
+func (k rsaKey) public() (entities.JSONWebKey, bool) {
+	return entities.JSONWebKey{
+		KeyType: "RSA",
+		N:       encode(k.N.Bytes()),
+		E:       encode(bigEndian(k.E)),
+	}, true
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint.
+func (k rsaKey) thumbprint() string {
+	jwk, _ := k.public()
+	digest := sha256.Sum256([]byte(fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)))
+	return encode(digest[:])
+}
+
+type ed25519Key ed25519.PrivateKey
+
+func (k ed25519Key) sign(input []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(k), input), nil
+}
+
+func (k ed25519Key) verify(input, signature []byte) bool {
+	publicKey := ed25519.PrivateKey(k).Public().(ed25519.PublicKey)
+	return ed25519.Verify(publicKey, input, signature)
+}
+
+func (k ed25519Key) public() (entities.JSONWebKey, bool) {
+	publicKey := ed25519.PrivateKey(k).Public().(ed25519.PublicKey)
+	return entities.JSONWebKey{
+		KeyType: "OKP",
+		Curve:   "Ed25519",
+		X:       encode(publicKey),
+	}, true
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint.
+func (k ed25519Key) thumbprint() string {
+	jwk, _ := k.public()
+	digest := sha256.Sum256([]byte(fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, jwk.X)))
+	return encode(digest[:])
+}
+
+func bigEndian(n int) []byte {
+	var out []byte
+	for ; n > 0; n >>= 8 {
+		out = append([]byte{byte(n)}, out...)
+	}
+	return out
+}
@@ -0,0 +1,385 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://otp.example.com"
+	testAudience = "acme-app"
+	testSecret   = "0123456789abcdef0123456789abcdef"
+	// rfc8037Seed and rfc8037Thumbprint are the Ed25519 private key of
+	// RFC 8037, Appendix A.1, and its JWK thumbprint from Appendix A.3.
+	rfc8037Seed       = "nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A"
+	rfc8037X          = "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"
+	rfc8037Thumbprint = "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"
+)
+
+var algorithms = []string{"HS256", "RS256", "EdDSA"}
+
+// testRSAKey is generated once, as RSA key generation is slow.
+var testRSAKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// testConfig returns a token config for algorithm with a fixed key.
+func testConfig(t *testing.T, algorithm string) config.TokenConfig {
+	t.Helper()
+	cfg := config.TokenConfig{
+		Algorithm: algorithm,
+		Issuer:    testIssuer,
+		Audience:  testAudience,
+		TTL:       10 * time.Minute,
+	}
+
+	switch algorithm {
+	case "HS256":
+		cfg.Secret = testSecret
+	case "RS256":
+		cfg.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testRSAKey)}))
+	case "EdDSA":
+		seed, err := base64.RawURLEncoding.DecodeString(rfc8037Seed)
+		if err != nil {
+			t.Fatalf("decode seed: %v", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(ed25519.NewKeyFromSeed(seed))
+		if err != nil {
+			t.Fatalf("marshal key: %v", err)
+		}
+		cfg.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	}
+	return cfg
+}
+
+func newTestSigner(t *testing.T, cfg config.TokenConfig) *Signer {
+	t.Helper()
+	signer, err := NewSigner(cfg)
+	if err != nil {
+		t.Fatalf("NewSigner(%s): %v", cfg.Algorithm, err)
+	}
+	return signer
+}
+
+// signToken signs a token with any header and claims under the key of s.
+func signToken(t *testing.T, s *Signer, h header, c any) string {
+	t.Helper()
+	headerJSON, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("marshal header: %v", err)
+	}
+	claimsJSON, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	input := encode(headerJSON) + "." + encode(claimsJSON)
+	signature, err := s.key.sign([]byte(input))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return input + "." + encode(signature)
+}
+
+// validClaims returns claims that s accepts, issued at now.
+func validClaims(now time.Time) claims {
+	return claims{
+		TokenID:             "token-1",
+		Issuer:              testIssuer,
+		Subject:             "+14155550100",
+		Audience:            audience{testAudience},
+		IssuedAt:            now.Unix(),
+		NotBefore:           now.Unix(),
+		ExpiresAt:           now.Add(10 * time.Minute).Unix(),
+		PhoneNumber:         "+14155550100",
+		PhoneNumberVerified: true,
+		Purpose:             entities.PurposeLogin,
+		OTPID:               "otp-1",
+		VerifiedAt:          now.Unix(),
+	}
+}
+
+func TestIssueAndParse(t *testing.T) {
+	recipients := []entities.Recipient{
+		entities.PhoneRecipient("+14155550100"),
+		entities.EmailRecipient("user@example.com"),
+	}
+
+	for _, algorithm := range algorithms {
+		for _, recipient := range recipients {
+			t.Run(algorithm+" "+string(recipient.Type), func(t *testing.T) {
+				signer := newTestSigner(t, testConfig(t, algorithm))
+				verifiedAt := time.Now().Truncate(time.Second)
+				issued := &entities.VerificationClaims{
+					Recipient:  recipient,
+					Purpose:    entities.PurposeLogin,
+					OTPID:      "otp-1",
+					VerifiedAt: verifiedAt,
+				}
+
+				token, err := signer.Issue(issued)
+				if err != nil {
+					t.Fatalf("Issue: %v", err)
+				}
+				if issued.TokenID == "" || issued.Issuer != testIssuer || issued.Audience != testAudience {
+					t.Errorf("Issue filled in %+v", issued)
+				}
+
+				var h header
+				if err := decodeJSON(strings.Split(token, ".")[0], &h); err != nil {
+					t.Fatalf("decode header: %v", err)
+				}
+				if h.Algorithm != algorithm || h.Type != "JWT" || h.KeyID != signer.keyID {
+					t.Errorf("header = %+v, want alg %s and kid %s", h, algorithm, signer.keyID)
+				}
+
+				parsed, err := signer.Parse(token)
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				if parsed.TokenID != issued.TokenID || parsed.Recipient != recipient || parsed.Purpose != entities.PurposeLogin ||
+					parsed.OTPID != "otp-1" || !parsed.VerifiedAt.Equal(verifiedAt) ||
+					parsed.Issuer != testIssuer || parsed.Audience != testAudience ||
+					parsed.ExpiresAt.Unix() != issued.ExpiresAt.Unix() {
+					t.Errorf("Parse = %+v, want %+v", parsed, issued)
+				}
+			})
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// token builds the token to parse from a signer and valid claims.
+		token func(t *testing.T, s *Signer, c claims) string
+		want  error
+	}{
+		{
+			name: "valid",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				return signToken(t, s, header{Algorithm: s.algorithm, KeyID: s.keyID}, c)
+			},
+		},
+		{
+			name: "no kid",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+		},
+		{
+			name: "other kid",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				return signToken(t, s, header{Algorithm: s.algorithm, KeyID: "other"}, c)
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "other alg",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				other := "HS256"
+				if s.algorithm == "HS256" {
+					other = "RS256"
+				}
+				return signToken(t, s, header{Algorithm: other, KeyID: s.keyID}, c)
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "alg none",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				token := signToken(t, s, header{Algorithm: "none"}, c)
+				return token[:strings.LastIndex(token, ".")+1]
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "signed with another key",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				cfg := testConfig(t, s.algorithm)
+				cfg.Secret, cfg.PrivateKey = "", ""
+				if s.algorithm == "HS256" {
+					cfg.Secret = strings.Repeat("x", 32)
+				}
+				other := newTestSigner(t, cfg)
+				return signToken(t, other, header{Algorithm: s.algorithm}, c)
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "tampered claims",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				parts := strings.Split(signToken(t, s, header{Algorithm: s.algorithm}, c), ".")
+				c.PhoneNumber = "+14155550199"
+				tampered, _ := json.Marshal(c)
+				return parts[0] + "." + encode(tampered) + "." + parts[2]
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "not three segments",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				token := signToken(t, s, header{Algorithm: s.algorithm}, c)
+				return token[:strings.LastIndex(token, ".")]
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "expired within the skew",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				c.ExpiresAt = time.Now().Add(-clockSkew / 2).Unix()
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+		},
+		{
+			name: "expired beyond the skew",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				c.ExpiresAt = time.Now().Add(-clockSkew - time.Second).Unix()
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+			want: entities.ErrTokenExpired,
+		},
+		{
+			name: "not yet valid within the skew",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				c.NotBefore = time.Now().Add(clockSkew / 2).Unix()
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+		},
+		{
+			name: "not yet valid beyond the skew",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				c.NotBefore = time.Now().Add(clockSkew + 2*time.Second).Unix()
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "other issuer",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				c.Issuer = "https://evil.example.com"
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "other audience",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				c.Audience = audience{"other-app"}
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "no audience",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				c.Audience = nil
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+			want: entities.ErrInvalidToken,
+		},
+		{
+			name: "audience list",
+			token: func(t *testing.T, s *Signer, c claims) string {
+				c.Audience = audience{"other-app", testAudience}
+				return signToken(t, s, header{Algorithm: s.algorithm}, c)
+			},
+		},
+	}
+
+	for _, algorithm := range algorithms {
+		signer := newTestSigner(t, testConfig(t, algorithm))
+		for _, tt := range tests {
+			t.Run(algorithm+" "+tt.name, func(t *testing.T) {
+				_, err := signer.Parse(tt.token(t, signer, validClaims(time.Now())))
+				if !errors.Is(err, tt.want) {
+					t.Errorf("Parse = %v, want %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestParseWithoutAudience(t *testing.T) {
+	cfg := testConfig(t, "HS256")
+	cfg.Audience = ""
+	signer := newTestSigner(t, cfg)
+
+	// A signer without an audience accepts tokens with any.
+	c := validClaims(time.Now())
+	c.Audience = audience{"other-app"}
+	if _, err := signer.Parse(signToken(t, signer, header{Algorithm: "HS256"}, c)); err != nil {
+		t.Errorf("Parse = %v, want the token accepted", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	t.Run("HS256", func(t *testing.T) {
+		signer := newTestSigner(t, testConfig(t, "HS256"))
+		if keys := signer.Keys(); len(keys) != 0 {
+			t.Errorf("Keys = %+v, want the secret unpublished", keys)
+		}
+	})
+
+	t.Run("RS256", func(t *testing.T) {
+		keys := newTestSigner(t, testConfig(t, "RS256")).Keys()
+		if len(keys) != 1 {
+			t.Fatalf("Keys = %+v, want one key", keys)
+		}
+		jwk := keys[0]
+		if jwk.KeyType != "RSA" || jwk.Use != "sig" || jwk.Algorithm != "RS256" || jwk.E != "AQAB" {
+			t.Errorf("JWK = %+v", jwk)
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil || new(big.Int).SetBytes(n).Cmp(testRSAKey.N) != 0 {
+			t.Errorf("JWK n = %q does not match the key", jwk.N)
+		}
+
+		// RFC 7638: the SHA-256 of the required members in lexicographic
+		// order, without whitespace.
+		canonical, _ := json.Marshal(map[string]string{"e": jwk.E, "kty": "RSA", "n": jwk.N})
+		digest := sha256.Sum256(canonical)
+		if want := encode(digest[:]); jwk.KeyID != want {
+			t.Errorf("kid = %q, want the RFC 7638 thumbprint %q", jwk.KeyID, want)
+		}
+	})
+
+	t.Run("EdDSA", func(t *testing.T) {
+		keys := newTestSigner(t, testConfig(t, "EdDSA")).Keys()
+		if len(keys) != 1 {
+			t.Fatalf("Keys = %+v, want one key", keys)
+		}
+		jwk := keys[0]
+		if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || jwk.Use != "sig" || jwk.Algorithm != "EdDSA" || jwk.X != rfc8037X {
+			t.Errorf("JWK = %+v, want the RFC 8037 public key", jwk)
+		}
+		if jwk.KeyID != rfc8037Thumbprint {
+			t.Errorf("kid = %q, want the RFC 8037 thumbprint %q", jwk.KeyID, rfc8037Thumbprint)
+		}
+	})
+
+	t.Run("configured kid", func(t *testing.T) {
+		cfg := testConfig(t, "EdDSA")
+		cfg.KeyID = "2024-01"
+		signer := newTestSigner(t, cfg)
+		if keys := signer.Keys(); len(keys) != 1 || keys[0].KeyID != "2024-01" {
+			t.Errorf("Keys = %+v, want kid 2024-01", keys)
+		}
+	})
+}
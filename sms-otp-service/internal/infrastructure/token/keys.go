@@ -0,0 +1,105 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sms-otp-service/internal/infrastructure/config"
+)
+
+const minRSABits = 2048
+
+// loadSigningKey returns the key for the configured algorithm. It reports
+// true when no key was configured and a random one was generated instead.
+func loadSigningKey(cfg config.TokenConfig) (signingKey, bool, error) {
+	if cfg.Algorithm == "HS256" {
+		if cfg.Secret != "" {
+			if len(cfg.Secret) < 32 {
+				return nil, false, errors.New("TOKEN_SECRET must be at least 32 bytes")
+			}
+			return hmacKey(cfg.Secret), false, nil
+		}
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, false, err
+		}
+		return hmacKey(secret), true, nil
+	}
+
+	pemData := []byte(cfg.PrivateKey)
+	if len(pemData) == 0 && cfg.PrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read token private key: %w", err)
+		}
+		pemData = data
+	}
+
+	if len(pemData) == 0 {
+		key, err := generateKey(cfg.Algorithm)
+		return key, true, err
+	}
+
+	key, err := parsePrivateKey(pemData)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if cfg.Algorithm != "RS256" {
+			return nil, false, fmt.Errorf("token private key is an RSA key, but TOKEN_ALGORITHM is %s", cfg.Algorithm)
+		}
+		if k.N.BitLen() < minRSABits {
+			return nil, false, fmt.Errorf("token RSA key must be at least %d bits", minRSABits)
+		}
+		return rsaKey{k}, false, nil
+	case ed25519.PrivateKey:
+		if cfg.Algorithm != "EdDSA" {
+			return nil, false, fmt.Errorf("token private key is an Ed25519 key, but TOKEN_ALGORITHM is %s", cfg.Algorithm)
+		}
+		return ed25519Key(k), false, nil
+	default:
+		return nil, false, fmt.Errorf("unsupported token private key type %T", key)
+	}
+}
+
+func parsePrivateKey(pemData []byte) (any, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("token private key is not PEM encoded")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in token private key", block.Type)
+	}
+}
+
+func generateKey(algorithm string) (signingKey, error) {
+	switch algorithm {
+	case "RS256":
+		key, err := rsa.GenerateKey(rand.Reader, minRSABits)
+		if err != nil {
+			return nil, err
+		}
+		return rsaKey{key}, nil
+	case "EdDSA":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return ed25519Key(key), nil
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", algorithm)
+	}
+}
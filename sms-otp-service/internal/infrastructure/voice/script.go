@@ -0,0 +1,135 @@
+package voice
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+	"strings"
+)
+
+// phrases holds the spoken text of one language.
+type phrases struct {
+	locale  string
+	intro   map[entities.OTPPurpose]string
+	repeat  string
+	goodbye string
+}
+
+var languages = map[string]phrases{
+	"en": {
+		locale: "en-US",
+		intro: map[entities.OTPPurpose]string{
+			entities.PurposeVerification: "Your verification code is",
+			entities.PurposeLogin:        "Your login code is",
+			entities.PurposeReset:        "Your password reset code is",
+		},
+		repeat:  "Once again, your code is",
+		goodbye: "Do not share this code. Goodbye.",
+	},
+	"az": {
+		locale: "az-AZ",
+		intro: map[entities.OTPPurpose]string{
+			entities.PurposeVerification: "Təsdiq kodunuz",
+			entities.PurposeLogin:        "Giriş kodunuz",
+			entities.PurposeReset:        "Şifrə bərpa kodunuz",
+		},
+		repeat:  "Bir daha, kodunuz",
+		goodbye: "Bu kodu heç kimlə paylaşmayın. Sağ olun.",
+	},
+	"ru": {
+		locale: "ru-RU",
+		intro: map[entities.OTPPurpose]string{
+			entities.PurposeVerification: "Ваш код подтверждения",
+			entities.PurposeLogin:        "Ваш код для входа",
+			entities.PurposeReset:        "Ваш код для сброса пароля",
+		},
+		repeat:  "Повторяю, ваш код",
+		goodbye: "Никому не сообщайте этот код. До свидания.",
+	},
+	"tr": {
+		locale: "tr-TR",
+		intro: map[entities.OTPPurpose]string{
+			entities.PurposeVerification: "Doğrulama kodunuz",
+			entities.PurposeLogin:        "Giriş kodunuz",
+			entities.PurposeReset:        "Şifre sıfırlama kodunuz",
+		},
+		repeat:  "Tekrar ediyorum, kodunuz",
+		goodbye: "Bu kodu kimseyle paylaşmayın. Hoşça kalın.",
+	},
+}
+
+// ScriptBuilder renders the SSML read out on OTP calls.
+type ScriptBuilder struct {
+	defaultLanguage string
+	repeat          int
+}
+
+// NewScriptBuilder returns a builder that falls back to defaultLanguage for
+// unsupported languages and reads the code repeat times.
+func NewScriptBuilder(cfg config.VoiceConfig) *ScriptBuilder {
+	repeat := cfg.Repeat
+	if repeat < 1 {
+		repeat = 1
+	}
+	return &ScriptBuilder{
+		defaultLanguage: cfg.DefaultLanguage,
+		repeat:          repeat,
+	}
+}
+
+// Build returns an SSML script that reads code one character at a time with
+// pauses in between, then repeats it.
+func (b *ScriptBuilder) Build(code string, purpose entities.OTPPurpose, language string) string {
+	lang, ok := languages[normalizeLanguage(language)]
+	if !ok {
+		lang, ok = languages[normalizeLanguage(b.defaultLanguage)]
+	}
+	if !ok {
+		lang = languages["en"]
+	}
+
+	intro, ok := lang.intro[purpose]
+	if !ok {
+		intro = lang.intro[entities.PurposeVerification]
+	}
+
+	var script strings.Builder
+	fmt.Fprintf(&script, `<speak xml:lang="%s">`, lang.locale)
+	script.WriteString(`<prosody rate="slow">`)
+	for i := 0; i < b.repeat; i++ {
+		if i == 0 {
+			script.WriteString(escape(intro))
+		} else {
+			script.WriteString(`<break time="1s"/>`)
+			script.WriteString(escape(lang.repeat))
+		}
+		script.WriteString(`<break time="500ms"/>`)
+		for j, c := range code {
+			if j > 0 {
+				script.WriteString(`<break time="400ms"/>`)
+			}
+			fmt.Fprintf(&script, `<say-as interpret-as="characters">%s</say-as>`, escape(string(c)))
+		}
+	}
+	script.WriteString(`<break time="1s"/>`)
+	script.WriteString(escape(lang.goodbye))
+	script.WriteString(`</prosody></speak>`)
+	return script.String()
+}
+
+// normalizeLanguage reduces tags such as "en-GB" to the base language.
+func normalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(language, "-_"); i > 0 {
+		language = language[:i]
+	}
+	return language
+}
+
+func escape(text string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
@@ -0,0 +1,50 @@
+// Package voice delivers OTP codes as text-to-speech phone calls, for users
+// who cannot receive SMS such as landlines.
+package voice
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/infrastructure/config"
+)
+
+// Service places a call that reads an SSML script to the callee.
+type Service interface {
+	Call(ctx context.Context, phoneNumber, script string) (*entities.SendResult, error)
+}
+
+func NewVoiceService(cfg *config.Config, logger *logrus.Logger) (Service, error) {
+	switch cfg.Voice.Provider {
+	case "mock":
+		return NewMockVoiceService(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown voice provider %q", cfg.Voice.Provider)
+	}
+}
+
+type mockVoiceService struct {
+	logger *logrus.Logger
+}
+
+func NewMockVoiceService(logger *logrus.Logger) Service {
+	return &mockVoiceService{
+		logger: logger,
+	}
+}
+
+func (s *mockVoiceService) Call(ctx context.Context, phoneNumber, script string) (*entities.SendResult, error) {
+	s.logger.WithFields(logrus.Fields{
+		"phone_number": phoneNumber,
+		"script":       script,
+	}).Info("📞 Mock voice call placed")
+
+	fmt.Printf("\n=== MOCK VOICE CALL ===\n")
+	fmt.Printf("To: %s\n", phoneNumber)
+	fmt.Printf("Script: %s\n", script)
+	fmt.Printf("=======================\n\n")
+
+	return &entities.SendResult{Provider: "mock-voice", MessageID: uuid.NewString()}, nil
+}
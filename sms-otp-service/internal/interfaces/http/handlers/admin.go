@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"sms-otp-service/internal/application/dto"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NewAdminAuth returns middleware that admits requests carrying token as a
+// bearer token. Without a token the admin API is disabled.
+func NewAdminAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{
+				Success: false,
+				Error:   "Admin API is disabled",
+				Code:    "ADMIN_DISABLED",
+			})
+		}
+
+		presented, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(dto.ErrorResponse{
+				Success: false,
+				Error:   "Missing or invalid admin token",
+				Code:    "UNAUTHORIZED",
+			})
+		}
+
+		return c.Next()
+	}
+}
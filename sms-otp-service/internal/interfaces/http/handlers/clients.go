@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"sms-otp-service/internal/domain/entities"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Clients knows the API keys of the configured clients. Anyone can send an
+// X-API-Key, so the number rules created for a key apply only to requests
+// with a configured key; any other request gets the global rules alone.
+type Clients struct {
+	ids map[string]bool
+}
+
+func NewClients(apiKeys []string) *Clients {
+	ids := make(map[string]bool, len(apiKeys))
+	for _, key := range apiKeys {
+		ids[entities.ClientID(key)] = true
+	}
+	return &Clients{ids: ids}
+}
+
+// ruleClientID returns the ClientID of the request's API key if it is a
+// configured key, and "" otherwise.
+func (cl *Clients) ruleClientID(c *fiber.Ctx) string {
+	if clientID := entities.ClientID(c.Get(APIKeyHeader)); cl.ids[clientID] {
+		return clientID
+	}
+	return ""
+}
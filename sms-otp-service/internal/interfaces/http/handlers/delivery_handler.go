@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/application/usecases"
+	"sms-otp-service/internal/domain/entities"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type DeliveryHandler struct {
+	deliveryUseCase usecases.DeliveryUseCase
+	logger          *logrus.Logger
+}
+
+func NewDeliveryHandler(deliveryUseCase usecases.DeliveryUseCase, logger *logrus.Logger) *DeliveryHandler {
+	return &DeliveryHandler{
+		deliveryUseCase: deliveryUseCase,
+		logger:          logger,
+	}
+}
+
+// ReceiveDLR godoc
+// @Summary Receive delivery receipt
+// @Description Delivery receipt (DLR) callback for the configured SMS providers. The body format depends on the provider. Every receipt must be authenticated: Twilio status callbacks with X-Twilio-Signature, other providers with the X-DLR-Token header or token query parameter. Providers whose secret is not configured get 404.
+// @Tags Delivery
+// @Accept json,x-www-form-urlencoded
+// @Produce json
+// @Param provider path string true "Provider name" Enums(twilio, webhook, mock)
+// @Success 200 {object} dto.DeliveryReceiptResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/dlr/{provider} [post]
+func (h *DeliveryHandler) ReceiveDLR(c *fiber.Ctx) error {
+	header := make(http.Header)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		header.Add(string(key), string(value))
+	})
+
+	resp, err := h.deliveryUseCase.HandleReceiptCallback(
+		c.Context(),
+		c.Params("provider"),
+		c.BaseURL()+c.OriginalURL(),
+		header,
+		c.Body(),
+	)
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// GetDeliveryStatus godoc
+// @Summary Get OTP delivery status
+// @Description Get the delivery status of an OTP message and its full delivery history
+// @Tags Delivery
+// @Produce json
+// @Param id path string true "OTP ID"
+// @Success 200 {object} dto.DeliveryStatusResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/otp/{id}/delivery [get]
+func (h *DeliveryHandler) GetDeliveryStatus(c *fiber.Ctx) error {
+	resp, err := h.deliveryUseCase.GetDeliveryStatus(c.Context(), c.Params("id"))
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (h *DeliveryHandler) handleError(err error) (int, dto.ErrorResponse) {
+	switch {
+	case errors.Is(err, usecases.ErrUnknownReceiptProvider):
+		return fiber.StatusNotFound, dto.ErrorResponse{
+			Success: false,
+			Error:   "Unknown delivery receipt provider",
+			Code:    "UNKNOWN_PROVIDER",
+		}
+	case errors.Is(err, entities.ErrUnauthorizedReceipt):
+		return fiber.StatusUnauthorized, dto.ErrorResponse{
+			Success: false,
+			Error:   "Delivery receipt could not be authenticated",
+			Code:    "UNAUTHORIZED",
+		}
+	case errors.Is(err, entities.ErrInvalidDeliveryReceipt):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid delivery receipt",
+			Code:    "INVALID_RECEIPT",
+		}
+	case errors.Is(err, entities.ErrOTPNotFound):
+		return fiber.StatusNotFound, dto.ErrorResponse{
+			Success: false,
+			Error:   "OTP not found",
+			Code:    "OTP_NOT_FOUND",
+		}
+	default:
+		h.logger.WithError(err).Error("Unexpected error occurred")
+		return fiber.StatusInternalServerError, dto.ErrorResponse{
+			Success: false,
+			Error:   "Internal server error",
+			Code:    "INTERNAL_ERROR",
+		}
+	}
+}
@@ -5,24 +5,29 @@ import (
 	"github.com/sirupsen/logrus"
 	"sms-otp-service/internal/application/dto"
 	"sms-otp-service/internal/infrastructure/database"
+	"sms-otp-service/internal/infrastructure/sms"
 	"time"
 )
 
 type HealthHandler struct {
-	db     *database.Database
-	logger *logrus.Logger
+	db       *database.Database
+	breakers sms.BreakerReporter
+	logger   *logrus.Logger
 }
 
-func NewHealthHandler(db *database.Database, logger *logrus.Logger) *HealthHandler {
+// NewHealthHandler returns the health handler. breakers reports the circuits
+// of the SMS providers, and may be nil when they have none.
+func NewHealthHandler(db *database.Database, breakers sms.BreakerReporter, logger *logrus.Logger) *HealthHandler {
 	return &HealthHandler{
-		db:     db,
-		logger: logger,
+		db:       db,
+		breakers: breakers,
+		logger:   logger,
 	}
 }
 
 // Health godoc
 // @Summary Health check
-// @Description Get health status of the service
+// @Description Get health status of the service. SMS is degraded while the circuit of some provider in a failover chain is open, and unhealthy while all are; the service then still answers 200, as only the database makes it unhealthy.
 // @Tags Health
 // @Produce json
 // @Success 200 {object} dto.HealthResponse
@@ -40,12 +45,35 @@ func (h *HealthHandler) Health(c *fiber.Ctx) error {
 		services["database"] = "healthy"
 	}
 
-	services["sms"] = "healthy" // Mock SMS is always healthy
+	// Providers without circuit breakers are taken to be healthy.
+	services["sms"] = "healthy"
+	var circuits map[string]string
+	if h.breakers != nil {
+		states := h.breakers.BreakerStates()
+		circuits = make(map[string]string, len(states))
+		open := 0
+		for name, state := range states {
+			circuits[name] = state.String()
+			if state == sms.BreakerOpen {
+				open++
+			}
+		}
+		switch {
+		case open > 0 && open == len(states):
+			services["sms"] = "unhealthy"
+		case open > 0:
+			services["sms"] = "degraded"
+		}
+		if open > 0 && overallStatus == "healthy" {
+			overallStatus = "degraded"
+		}
+	}
 
 	response := dto.HealthResponse{
 		Status:    overallStatus,
 		Timestamp: time.Now(),
 		Services:  services,
+		Circuits:  circuits,
 		Version:   "1.0.0",
 	}
 
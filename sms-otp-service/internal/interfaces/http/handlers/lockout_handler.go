@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/application/usecases"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// LockoutHandler lets admins inspect and lift the locks recipients get after
+// checking too many wrong codes.
+type LockoutHandler struct {
+	lockoutUseCase usecases.LockoutUseCase
+	recipients     recipientNormalizer
+	logger         *logrus.Logger
+}
+
+func NewLockoutHandler(lockoutUseCase usecases.LockoutUseCase, phoneValidator *utils.PhoneValidator, logger *logrus.Logger) *LockoutHandler {
+	return &LockoutHandler{
+		lockoutUseCase: lockoutUseCase,
+		recipients:     newRecipientNormalizer(phoneValidator),
+		logger:         logger,
+	}
+}
+
+// ListLockouts godoc
+// @Summary List verification lockouts
+// @Description Show the wrong codes and locks of a phone number or email address, for one purpose or every purpose
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param phone_number query string false "Phone number; exactly one of phone_number and email is required"
+// @Param email query string false "Email address"
+// @Param purpose query string false "Only this purpose"
+// @Success 200 {object} dto.LockoutListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/admin/lockouts [get]
+func (h *LockoutHandler) ListLockouts(c *fiber.Ctx) error {
+	query, err := h.parseQuery(c)
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	resp, err := h.lockoutUseCase.ListLockouts(c.Context(), query)
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// ClearLockouts godoc
+// @Summary Clear verification lockouts
+// @Description Lift the locks of a phone number or email address and forget their wrong codes, for one purpose or every purpose
+// @Tags Admin
+// @Security AdminToken
+// @Param phone_number query string false "Phone number; exactly one of phone_number and email is required"
+// @Param email query string false "Email address"
+// @Param purpose query string false "Only this purpose"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/admin/lockouts [delete]
+func (h *LockoutHandler) ClearLockouts(c *fiber.Ctx) error {
+	query, err := h.parseQuery(c)
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	if err := h.lockoutUseCase.ClearLockouts(c.Context(), query); err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// parseQuery reads the lockout query and normalizes its recipient, so it
+// names the same keys the code checks counted under.
+func (h *LockoutHandler) parseQuery(c *fiber.Ctx) (*dto.LockoutQuery, error) {
+	query := &dto.LockoutQuery{
+		PhoneNumber: c.Query("phone_number"),
+		Email:       c.Query("email"),
+		Purpose:     entities.OTPPurpose(c.Query("purpose")),
+	}
+	if err := h.recipients.normalize(&query.PhoneNumber, &query.Email); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+func (h *LockoutHandler) handleError(err error) (int, dto.ErrorResponse) {
+	switch {
+	case errors.Is(err, entities.ErrInvalidPhoneNumber):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid phone number format",
+			Code:    "INVALID_PHONE",
+		}
+	case errors.Is(err, entities.ErrInvalidEmail):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid email address",
+			Code:    "INVALID_EMAIL",
+		}
+	case errors.Is(err, entities.ErrInvalidRecipient):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Exactly one of phone_number and email is required",
+			Code:    "INVALID_RECIPIENT",
+		}
+	case errors.Is(err, entities.ErrUnknownPurpose):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Unknown OTP purpose",
+			Code:    "UNKNOWN_PURPOSE",
+		}
+	default:
+		h.logger.WithError(err).Error("Unexpected error occurred")
+		return fiber.StatusInternalServerError, dto.ErrorResponse{
+			Success: false,
+			Error:   "Internal server error",
+			Code:    "INTERNAL_ERROR",
+		}
+	}
+}
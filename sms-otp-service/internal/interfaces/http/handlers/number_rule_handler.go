@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/application/usecases"
+	"sms-otp-service/internal/domain/entities"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type NumberRuleHandler struct {
+	numberRuleUseCase usecases.NumberRuleUseCase
+	logger            *logrus.Logger
+}
+
+func NewNumberRuleHandler(numberRuleUseCase usecases.NumberRuleUseCase, logger *logrus.Logger) *NumberRuleHandler {
+	return &NumberRuleHandler{
+		numberRuleUseCase: numberRuleUseCase,
+		logger:            logger,
+	}
+}
+
+// ListRules godoc
+// @Summary List number rules
+// @Description List the rules that block or allow OTPs to phone numbers
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param client_id query string false "Only rules limited to this client"
+// @Param action query string false "Only rules with this action" Enums(block, allow)
+// @Success 200 {object} dto.NumberRuleListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/admin/number-rules [get]
+func (h *NumberRuleHandler) ListRules(c *fiber.Ctx) error {
+	resp, err := h.numberRuleUseCase.ListRules(c.Context(), c.Query("client_id"), entities.NumberRuleAction(c.Query("action")))
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// CreateRule godoc
+// @Summary Create number rule
+// @Description Block or allow OTPs to a phone number, a prefix or a country, for the requests sent with api_key or, without it, for every request. The most specific matching rule decides; once a client has allow rules of its own, numbers no allow rule matches are blocked for that client. Allow rules without an API key do the same for requests without one.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param request body dto.NumberRuleRequest true "Number rule"
+// @Success 201 {object} dto.NumberRuleDetailResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/admin/number-rules [post]
+func (h *NumberRuleHandler) CreateRule(c *fiber.Ctx) error {
+	var req dto.NumberRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+		})
+	}
+
+	resp, err := h.numberRuleUseCase.CreateRule(c.Context(), &req)
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// GetRule godoc
+// @Summary Get number rule
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param id path string true "Rule ID"
+// @Success 200 {object} dto.NumberRuleDetailResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/admin/number-rules/{id} [get]
+func (h *NumberRuleHandler) GetRule(c *fiber.Ctx) error {
+	resp, err := h.numberRuleUseCase.GetRule(c.Context(), c.Params("id"))
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// UpdateRule godoc
+// @Summary Update number rule
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param id path string true "Rule ID"
+// @Param request body dto.NumberRuleRequest true "Number rule"
+// @Success 200 {object} dto.NumberRuleDetailResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/admin/number-rules/{id} [put]
+func (h *NumberRuleHandler) UpdateRule(c *fiber.Ctx) error {
+	var req dto.NumberRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+		})
+	}
+
+	resp, err := h.numberRuleUseCase.UpdateRule(c.Context(), c.Params("id"), &req)
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// DeleteRule godoc
+// @Summary Delete number rule
+// @Tags Admin
+// @Security AdminToken
+// @Param id path string true "Rule ID"
+// @Success 204
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/admin/number-rules/{id} [delete]
+func (h *NumberRuleHandler) DeleteRule(c *fiber.Ctx) error {
+	if err := h.numberRuleUseCase.DeleteRule(c.Context(), c.Params("id")); err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *NumberRuleHandler) handleError(err error) (int, dto.ErrorResponse) {
+	switch {
+	case errors.Is(err, entities.ErrInvalidNumberRule):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid number rule: action must be block or allow, and value a number, prefix or country matching match",
+			Code:    "INVALID_RULE",
+		}
+	case errors.Is(err, entities.ErrNumberRuleNotFound):
+		return fiber.StatusNotFound, dto.ErrorResponse{
+			Success: false,
+			Error:   "Number rule not found",
+			Code:    "RULE_NOT_FOUND",
+		}
+	case errors.Is(err, entities.ErrDuplicateNumberRule):
+		return fiber.StatusConflict, dto.ErrorResponse{
+			Success: false,
+			Error:   "A rule for this number, prefix or country already exists",
+			Code:    "DUPLICATE_RULE",
+		}
+	default:
+		h.logger.WithError(err).Error("Unexpected error occurred")
+		return fiber.StatusInternalServerError, dto.ErrorResponse{
+			Success: false,
+			Error:   "Internal server error",
+			Code:    "INTERNAL_ERROR",
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"sms-otp-service/internal/application/dto"
 	"sms-otp-service/internal/application/usecases"
 	"sms-otp-service/internal/domain/entities"
@@ -11,29 +12,48 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// IdempotencyKeyHeader carries the client-chosen key that makes retries of
+// send and resend safe.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// storedResponseLocal holds the completed idempotency record that
+// FindStoredResponse found for the request.
+const storedResponseLocal = "idempotency_stored_response"
+
 type OTPHandler struct {
-	otpUseCase     usecases.OTPUseCase
-	phoneValidator *utils.PhoneValidator
-	logger         *logrus.Logger
+	otpUseCase         usecases.OTPUseCase
+	idempotencyUseCase usecases.IdempotencyUseCase
+	clients            *Clients
+	recipients         recipientNormalizer
+	logger             *logrus.Logger
 }
 
-func NewOTPHandler(otpUseCase usecases.OTPUseCase, logger *logrus.Logger) *OTPHandler {
+func NewOTPHandler(otpUseCase usecases.OTPUseCase, idempotencyUseCase usecases.IdempotencyUseCase, clients *Clients, phoneValidator *utils.PhoneValidator, logger *logrus.Logger) *OTPHandler {
 	return &OTPHandler{
-		otpUseCase:     otpUseCase,
-		phoneValidator: utils.NewPhoneValidator(),
-		logger:         logger,
+		otpUseCase:         otpUseCase,
+		idempotencyUseCase: idempotencyUseCase,
+		clients:            clients,
+		recipients:         newRecipientNormalizer(phoneValidator),
+		logger:             logger,
 	}
 }
 
 // SendOTP godoc
 // @Summary Send OTP
-// @Description Send OTP to phone number
+// @Description Send OTP to a phone number or email address
 // @Tags OTP
 // @Accept json
 // @Produce json
 // @Param request body dto.SendOTPRequest true "Send OTP request"
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
 // @Success 200 {object} dto.SendOTPResponse
 // @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 428 {object} dto.ErrorResponse
 // @Failure 429 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
 // @Router /api/v1/otp/send [post]
@@ -47,27 +67,24 @@ func (h *OTPHandler) SendOTP(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.phoneValidator.Validate(req.PhoneNumber); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
-			Success: false,
-			Error:   "Invalid phone number format",
-			Code:    "INVALID_PHONE",
-		})
+	if err := h.recipients.normalize(&req.PhoneNumber, &req.Email); err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
 	}
 
-	req.PhoneNumber = h.phoneValidator.NormalizePhoneNumber(req.PhoneNumber)
-
 	if req.Purpose == "" {
 		req.Purpose = entities.PurposeVerification
 	}
+	req.ClientIP = c.IP()
+	req.ClientID = h.clients.ruleClientID(c)
 
-	resp, err := h.otpUseCase.SendOTP(c.Context(), &req)
-	if err != nil {
-		statusCode, errorResp := h.handleError(err)
-		return c.Status(statusCode).JSON(errorResp)
-	}
-
-	return c.Status(fiber.StatusOK).JSON(resp)
+	return h.respondIdempotent(c, "send", req.Fingerprint(), func() (int, any) {
+		resp, err := h.otpUseCase.SendOTP(c.Context(), &req)
+		if err != nil {
+			return h.handleError(err)
+		}
+		return fiber.StatusOK, resp
+	})
 }
 
 // VerifyOTP godoc
@@ -79,6 +96,8 @@ func (h *OTPHandler) SendOTP(c *fiber.Ctx) error {
 // @Param request body dto.VerifyOTPRequest true "Verify OTP request"
 // @Success 200 {object} dto.VerifyOTPResponse
 // @Failure 400 {object} dto.ErrorResponse
+// @Failure 423 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
 // @Router /api/v1/otp/verify [post]
 func (h *OTPHandler) VerifyOTP(c *fiber.Ctx) error {
@@ -91,24 +110,11 @@ func (h *OTPHandler) VerifyOTP(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.phoneValidator.Validate(req.PhoneNumber); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
-			Success: false,
-			Error:   "Invalid phone number format",
-			Code:    "INVALID_PHONE",
-		})
-	}
-
-	if len(req.Code) != 6 {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
-			Success: false,
-			Error:   "OTP code must be 6 digits",
-			Code:    "INVALID_CODE",
-		})
+	if err := h.recipients.normalize(&req.PhoneNumber, &req.Email); err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
 	}
 
-	req.PhoneNumber = h.phoneValidator.NormalizePhoneNumber(req.PhoneNumber)
-
 	if req.Purpose == "" {
 		req.Purpose = entities.PurposeVerification
 	}
@@ -128,13 +134,18 @@ func (h *OTPHandler) VerifyOTP(c *fiber.Ctx) error {
 
 // ResendOTP godoc
 // @Summary Resend OTP
-// @Description Resend OTP to phone number
+// @Description Resend OTP to a phone number or email address
 // @Tags OTP
 // @Accept json
 // @Produce json
 // @Param request body dto.ResendOTPRequest true "Resend OTP request"
+// @Param Idempotency-Key header string false "Replays the first response to retries with the same key"
 // @Success 200 {object} dto.ResendOTPResponse
 // @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 428 {object} dto.ErrorResponse
 // @Failure 429 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
 // @Router /api/v1/otp/resend [post]
@@ -148,43 +159,174 @@ func (h *OTPHandler) ResendOTP(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.phoneValidator.Validate(req.PhoneNumber); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
-			Success: false,
-			Error:   "Invalid phone number format",
-			Code:    "INVALID_PHONE",
-		})
+	if err := h.recipients.normalize(&req.PhoneNumber, &req.Email); err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
 	}
 
-	req.PhoneNumber = h.phoneValidator.NormalizePhoneNumber(req.PhoneNumber)
-
 	if req.Purpose == "" {
 		req.Purpose = entities.PurposeVerification
 	}
+	req.ClientIP = c.IP()
+	req.ClientID = h.clients.ruleClientID(c)
+
+	return h.respondIdempotent(c, "resend", req.Fingerprint(), func() (int, any) {
+		resp, err := h.otpUseCase.ResendOTP(c.Context(), &req)
+		if err != nil {
+			return h.handleError(err)
+		}
+		return fiber.StatusOK, resp
+	})
+}
+
+// FindStoredResponse is middleware that runs ahead of the rate limiter. It
+// looks up the response stored for the request's Idempotency-Key, so that
+// retries of a completed request are replayed without being rate limited.
+func (h *OTPHandler) FindStoredResponse(c *fiber.Ctx) error {
+	key := c.Get(IdempotencyKeyHeader)
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return c.Next()
+	}
+
+	record, err := h.idempotencyUseCase.Lookup(c.Context(), idempotencyClientID(c), key)
+	if err != nil {
+		// The request is then handled as a first attempt, rate limit and
+		// all, and Execute still finds the stored response.
+		h.logger.WithError(err).Error("Failed to look up idempotency key")
+	} else if record != nil {
+		c.Locals(storedResponseLocal, record)
+	}
+	return c.Next()
+}
+
+// respondIdempotent writes the response produced by handle. With an
+// Idempotency-Key header, handle runs once per client and key and retries
+// get the stored response, marked with Idempotent-Replayed.
+func (h *OTPHandler) respondIdempotent(c *fiber.Ctx, endpoint string, payload any, handle func() (int, any)) error {
+	key := c.Get(IdempotencyKeyHeader)
+	if key == "" {
+		statusCode, resp := handle()
+		return c.Status(statusCode).JSON(resp)
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
+			Success: false,
+			Error:   "Idempotency-Key must be at most 255 characters",
+			Code:    "INVALID_IDEMPOTENCY_KEY",
+		})
+	}
 
-	resp, err := h.otpUseCase.ResendOTP(c.Context(), &req)
+	stored, _ := c.Locals(storedResponseLocal).(*entities.IdempotencyRecord)
+	result, err := h.idempotencyUseCase.Execute(c.Context(), usecases.IdempotentRequest{
+		ClientID: idempotencyClientID(c),
+		Key:      key,
+		Endpoint: endpoint,
+		Payload:  payload,
+		Stored:   stored,
+	}, handle)
 	if err != nil {
 		statusCode, errorResp := h.handleError(err)
 		return c.Status(statusCode).JSON(errorResp)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(resp)
+	if result.Replayed {
+		c.Set("Idempotent-Replayed", "true")
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(result.StatusCode).Send(result.Body)
+}
+
+// idempotencyClientID scopes idempotency keys to the client, told apart by
+// its API key, or by IP address when it sends none.
+func idempotencyClientID(c *fiber.Ctx) string {
+	if clientID := entities.ClientID(c.Get(APIKeyHeader)); clientID != "" {
+		return clientID
+	}
+	return c.IP()
 }
 
 func (h *OTPHandler) handleError(err error) (int, dto.ErrorResponse) {
+	if errors.Is(err, services.ErrRateLimitExceeded) {
+		return fiber.StatusTooManyRequests, rateLimitedResponse(err)
+	}
+	if errors.Is(err, services.ErrVerificationLocked) {
+		return fiber.StatusLocked, lockedResponse(err)
+	}
+
 	switch err {
-	case services.ErrRateLimitExceeded:
-		return fiber.StatusTooManyRequests, dto.ErrorResponse{
-			Success: false,
-			Error:   "Rate limit exceeded. Please wait before requesting a new OTP.",
-			Code:    "RATE_LIMIT",
-		}
 	case entities.ErrInvalidPhoneNumber:
 		return fiber.StatusBadRequest, dto.ErrorResponse{
 			Success: false,
 			Error:   "Invalid phone number format",
 			Code:    "INVALID_PHONE",
 		}
+	case entities.ErrNotMobileNumber:
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Phone number is not a mobile number; use the voice channel",
+			Code:    "NOT_MOBILE_NUMBER",
+		}
+	case entities.ErrNumberBlocked:
+		return fiber.StatusForbidden, dto.ErrorResponse{
+			Success: false,
+			Error:   "OTPs cannot be sent to this phone number",
+			Code:    "NUMBER_BLOCKED",
+		}
+	case entities.ErrSendBlocked:
+		return fiber.StatusForbidden, dto.ErrorResponse{
+			Success: false,
+			Error:   "This request was blocked as likely fraud",
+			Code:    "SEND_BLOCKED",
+		}
+	case entities.ErrChallengeRequired:
+		return fiber.StatusPreconditionRequired, dto.ErrorResponse{
+			Success: false,
+			Error:   "Pass the challenge and retry with its challenge_token",
+			Code:    "CHALLENGE_REQUIRED",
+		}
+	case entities.ErrInvalidEmail:
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid email address",
+			Code:    "INVALID_EMAIL",
+		}
+	case entities.ErrInvalidRecipient:
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Exactly one of phone_number and email is required",
+			Code:    "INVALID_RECIPIENT",
+		}
+	case entities.ErrInvalidCodeFormat:
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "OTP code does not match the format of the purpose",
+			Code:    "INVALID_CODE",
+		}
+	case entities.ErrIdempotencyKeyReused:
+		return fiber.StatusUnprocessableEntity, dto.ErrorResponse{
+			Success: false,
+			Error:   "Idempotency-Key was already used with a different request",
+			Code:    "IDEMPOTENCY_KEY_REUSED",
+		}
+	case entities.ErrIdempotencyKeyInFlight:
+		return fiber.StatusConflict, dto.ErrorResponse{
+			Success: false,
+			Error:   "A request with this Idempotency-Key is still in progress",
+			Code:    "IDEMPOTENCY_KEY_IN_FLIGHT",
+		}
+	case entities.ErrUnknownPurpose:
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Unknown OTP purpose",
+			Code:    "UNKNOWN_PURPOSE",
+		}
+	case entities.ErrUnsupportedChannel:
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Unsupported delivery channel",
+			Code:    "UNSUPPORTED_CHANNEL",
+		}
 	default:
 		h.logger.WithError(err).Error("Unexpected error occurred")
 		return fiber.StatusInternalServerError, dto.ErrorResponse{
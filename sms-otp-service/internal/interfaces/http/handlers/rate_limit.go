@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/repositories"
+	"sms-otp-service/internal/domain/services"
+	"sms-otp-service/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyHeader identifies the client for rate limiting and number rules. The
+// service does not authenticate the key; a gateway in front of it is
+// expected to, and only configured keys get number rules of their own.
+const APIKeyHeader = "X-API-Key"
+
+// RateLimitRule limits the requests sharing a scope, such as a client IP.
+type RateLimitRule struct {
+	Scope entities.RateLimitScope
+	Limit entities.RateLimit
+}
+
+type rateLimiter struct {
+	store          repositories.LimiterStore
+	rules          []RateLimitRule
+	phoneValidator *utils.PhoneValidator
+	prefixLength   int
+	logger         *logrus.Logger
+}
+
+// NewRateLimiter returns middleware that counts each request against every
+// rule whose scope the request has, in order, and rejects it with 429 once
+// any of them is exhausted. Phone and prefix scopes are taken from the
+// phone_number in the request body. Responses carry the RateLimit-* headers
+// of the rule closest to its limit.
+func NewRateLimiter(
+	store repositories.LimiterStore,
+	rules []RateLimitRule,
+	phoneValidator *utils.PhoneValidator,
+	prefixLength int,
+	logger *logrus.Logger,
+) fiber.Handler {
+	l := &rateLimiter{
+		store:          store,
+		rules:          rules,
+		phoneValidator: phoneValidator,
+		prefixLength:   prefixLength,
+		logger:         logger,
+	}
+	return l.handle
+}
+
+func (l *rateLimiter) handle(c *fiber.Ctx) error {
+	// A retry that gets a stored response does no work, so it spends no
+	// budget and is never refused.
+	if len(l.rules) == 0 || c.Locals(storedResponseLocal) != nil {
+		return c.Next()
+	}
+
+	keys := l.requestKeys(c)
+
+	var tightest *entities.RateLimitResult
+	var tightestRule RateLimitRule
+	for _, rule := range l.rules {
+		key, ok := keys[rule.Scope]
+		if !ok {
+			continue
+		}
+
+		// Rules of one scope with different limits keep separate counters.
+		key += ":" + string(rule.Limit.Algorithm) + ":" + rule.Limit.Policy()
+		result, err := l.store.Take(c.Context(), key, rule.Limit)
+		if err != nil {
+			// Rate limiting fails open, so a store outage does not take
+			// OTP delivery down with it.
+			l.logger.WithError(err).WithField("scope", rule.Scope).Error("Failed to check rate limit")
+			continue
+		}
+
+		if !result.Allowed {
+			l.logger.WithFields(logrus.Fields{
+				"scope":       rule.Scope,
+				"client_ip":   c.IP(),
+				"path":        c.Path(),
+				"retry_after": result.RetryAfter,
+			}).Warn("Request rate limited")
+
+			setRateLimitHeaders(c, rule, result)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			nextResendAt := time.Now().Add(result.RetryAfter)
+			return c.Status(fiber.StatusTooManyRequests).JSON(dto.ErrorResponse{
+				Success:      false,
+				Error:        "Too many requests. Please retry later.",
+				Code:         "RATE_LIMIT",
+				RetryAfter:   ceilSeconds(result.RetryAfter),
+				NextResendAt: &nextResendAt,
+			})
+		}
+
+		if tightest == nil || result.Remaining*tightest.Limit < tightest.Remaining*result.Limit {
+			tightest, tightestRule = &result, rule
+		}
+	}
+
+	if tightest != nil {
+		setRateLimitHeaders(c, tightestRule, *tightest)
+	}
+	return c.Next()
+}
+
+// requestKeys returns the key of every scope the request has.
+func (l *rateLimiter) requestKeys(c *fiber.Ctx) map[entities.RateLimitScope]string {
+	keys := map[entities.RateLimitScope]string{
+		entities.RateLimitGlobal: entities.RateLimitKey(entities.RateLimitGlobal),
+		entities.RateLimitByIP:   entities.RateLimitKey(entities.RateLimitByIP, c.IP()),
+	}
+
+	if clientID := entities.ClientID(c.Get(APIKeyHeader)); clientID != "" {
+		// Keys are stored hashed, so the store never holds client secrets.
+		keys[entities.RateLimitByAPIKey] = entities.RateLimitKey(entities.RateLimitByAPIKey, clientID)
+	}
+
+	var body struct {
+		PhoneNumber string `json:"phone_number" form:"phone_number"`
+	}
+	if err := c.BodyParser(&body); err == nil && body.PhoneNumber != "" {
+		if phoneNumber, err := l.phoneValidator.NormalizePhoneNumber(body.PhoneNumber); err == nil {
+			digits := strings.TrimPrefix(phoneNumber, "+")
+			keys[entities.RateLimitByPhone] = entities.RateLimitKey(entities.RateLimitByPhone, phoneNumber)
+			keys[entities.RateLimitByPrefix] = entities.RateLimitKey(entities.RateLimitByPrefix, digits[:min(len(digits), l.prefixLength)])
+		}
+	}
+	return keys
+}
+
+// setRateLimitHeaders writes the RateLimit-* headers of the IETF draft
+// "RateLimit header fields for HTTP".
+func setRateLimitHeaders(c *fiber.Ctx, rule RateLimitRule, result entities.RateLimitResult) {
+	c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	c.Set("RateLimit-Policy", rule.Limit.Policy())
+}
+
+// rateLimitedResponse is the body of a 429 response to a request refused
+// with ErrRateLimitExceeded, saying when to retry if err tells.
+func rateLimitedResponse(err error) dto.ErrorResponse {
+	resp := dto.ErrorResponse{
+		Success: false,
+		Error:   "Rate limit exceeded. Please wait before requesting a new OTP.",
+		Code:    "RATE_LIMIT",
+	}
+	var limitErr *services.RateLimitError
+	if errors.As(err, &limitErr) {
+		resp.RetryAfter = ceilSeconds(limitErr.RetryAfter)
+		resp.NextResendAt = limitErr.NextResendAt
+	}
+	return resp
+}
+
+// lockedResponse is the body of a 423 response to a code check refused with
+// ErrVerificationLocked.
+func lockedResponse(err error) dto.ErrorResponse {
+	resp := dto.ErrorResponse{
+		Success: false,
+		Error:   "Too many wrong codes. Verification is locked; please try again later.",
+		Code:    "LOCKED",
+	}
+	var lockedErr *services.LockedError
+	if errors.As(err, &lockedErr) {
+		resp.RetryAfter = ceilSeconds(lockedErr.RetryAfter)
+	}
+	return resp
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/pkg/utils"
+)
+
+// recipientNormalizer checks the phone number or email address a request is
+// addressed to and rewrites it in canonical form.
+type recipientNormalizer struct {
+	phoneValidator *utils.PhoneValidator
+	emailValidator *utils.EmailValidator
+}
+
+func newRecipientNormalizer(phoneValidator *utils.PhoneValidator) recipientNormalizer {
+	return recipientNormalizer{
+		phoneValidator: phoneValidator,
+		emailValidator: utils.NewEmailValidator(),
+	}
+}
+
+// normalize requires exactly one of phoneNumber and email and normalizes it
+// in place.
+func (n recipientNormalizer) normalize(phoneNumber, email *string) error {
+	switch {
+	case *phoneNumber != "" && *email != "":
+		return entities.ErrInvalidRecipient
+	case *phoneNumber != "":
+		normalized, err := n.phoneValidator.NormalizePhoneNumber(*phoneNumber)
+		if err != nil {
+			return entities.ErrInvalidPhoneNumber
+		}
+		*phoneNumber = normalized
+	case *email != "":
+		*email = n.emailValidator.NormalizeEmail(*email)
+		if err := n.emailValidator.Validate(*email); err != nil {
+			return entities.ErrInvalidEmail
+		}
+	default:
+		return entities.ErrInvalidRecipient
+	}
+	return nil
+}
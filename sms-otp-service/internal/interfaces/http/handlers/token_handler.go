@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/application/usecases"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type TokenHandler struct {
+	tokenUseCase usecases.TokenUseCase
+	logger       *logrus.Logger
+}
+
+func NewTokenHandler(tokenUseCase usecases.TokenUseCase, logger *logrus.Logger) *TokenHandler {
+	return &TokenHandler{
+		tokenUseCase: tokenUseCase,
+		logger:       logger,
+	}
+}
+
+// Introspect godoc
+// @Summary Introspect verification token
+// @Description Validate a verification token issued by /api/v1/otp/verify and return its claims (RFC 7662). Invalid and expired tokens are reported with active set to false.
+// @Tags Token
+// @Accept json,x-www-form-urlencoded
+// @Produce json
+// @Param request body dto.IntrospectTokenRequest true "Introspect token request"
+// @Success 200 {object} dto.IntrospectTokenResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/otp/token/introspect [post]
+func (h *TokenHandler) Introspect(c *fiber.Ctx) error {
+	var req dto.IntrospectTokenRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Token) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+		})
+	}
+
+	resp, err := h.tokenUseCase.Introspect(c.Context(), &req)
+	if err != nil {
+		h.logger.WithError(err).Error("Unexpected error occurred")
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{
+			Success: false,
+			Error:   "Internal server error",
+			Code:    "INTERNAL_ERROR",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// JWKS godoc
+// @Summary Verification token keys
+// @Description Public keys that validate verification tokens, as a JSON Web Key Set. Empty when tokens are signed with HS256.
+// @Tags Token
+// @Produce json
+// @Success 200 {object} dto.JWKSResponse
+// @Router /.well-known/jwks.json [get]
+func (h *TokenHandler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(h.tokenUseCase.JWKS(c.Context()))
+}
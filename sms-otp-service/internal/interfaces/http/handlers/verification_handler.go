@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"sms-otp-service/internal/application/dto"
+	"sms-otp-service/internal/application/usecases"
+	"sms-otp-service/internal/domain/entities"
+	"sms-otp-service/internal/domain/services"
+	"sms-otp-service/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// VerificationHandler serves verification sessions, which address an OTP by
+// its ID so that parallel flows for one recipient stay independent.
+type VerificationHandler struct {
+	otpUseCase usecases.OTPUseCase
+	clients    *Clients
+	recipients recipientNormalizer
+	logger     *logrus.Logger
+}
+
+func NewVerificationHandler(otpUseCase usecases.OTPUseCase, clients *Clients, phoneValidator *utils.PhoneValidator, logger *logrus.Logger) *VerificationHandler {
+	return &VerificationHandler{
+		otpUseCase: otpUseCase,
+		clients:    clients,
+		recipients: newRecipientNormalizer(phoneValidator),
+		logger:     logger,
+	}
+}
+
+// CreateVerification godoc
+// @Summary Start verification
+// @Description Send an OTP and open a verification session for it. Sessions for the same recipient and purpose do not supersede each other.
+// @Tags Verifications
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateVerificationRequest true "Create verification request"
+// @Success 201 {object} dto.VerificationResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 428 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/verifications [post]
+func (h *VerificationHandler) CreateVerification(c *fiber.Ctx) error {
+	var req dto.CreateVerificationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+		})
+	}
+
+	if err := h.recipients.normalize(&req.PhoneNumber, &req.Email); err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	if req.Purpose == "" {
+		req.Purpose = entities.PurposeVerification
+	}
+	req.ClientIP = c.IP()
+	req.ClientID = h.clients.ruleClientID(c)
+
+	resp, err := h.otpUseCase.CreateVerification(c.Context(), &req)
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// CheckVerification godoc
+// @Summary Check verification code
+// @Description Check a code against a verification session. An approved check returns a signed verification token.
+// @Tags Verifications
+// @Accept json
+// @Produce json
+// @Param id path string true "Verification ID"
+// @Param request body dto.CheckVerificationRequest true "Check verification request"
+// @Success 200 {object} dto.VerificationResponse
+// @Failure 400 {object} dto.VerificationResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 423 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/verifications/{id}/check [post]
+func (h *VerificationHandler) CheckVerification(c *fiber.Ctx) error {
+	var req dto.CheckVerificationRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid request format",
+			Code:    "INVALID_REQUEST",
+		})
+	}
+
+	resp, err := h.otpUseCase.CheckVerification(c.Context(), c.Params("id"), &req)
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	if !resp.Success {
+		return c.Status(fiber.StatusBadRequest).JSON(resp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// GetVerification godoc
+// @Summary Get verification
+// @Description Get the state of a verification session
+// @Tags Verifications
+// @Produce json
+// @Param id path string true "Verification ID"
+// @Success 200 {object} dto.VerificationResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/verifications/{id} [get]
+func (h *VerificationHandler) GetVerification(c *fiber.Ctx) error {
+	resp, err := h.otpUseCase.GetVerification(c.Context(), c.Params("id"))
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// CancelVerification godoc
+// @Summary Cancel verification
+// @Description Cancel a pending verification session so its code can no longer be used. Canceling twice is allowed.
+// @Tags Verifications
+// @Produce json
+// @Param id path string true "Verification ID"
+// @Success 200 {object} dto.VerificationResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/v1/verifications/{id}/cancel [post]
+func (h *VerificationHandler) CancelVerification(c *fiber.Ctx) error {
+	resp, err := h.otpUseCase.CancelVerification(c.Context(), c.Params("id"))
+	if err != nil {
+		statusCode, errorResp := h.handleError(err)
+		return c.Status(statusCode).JSON(errorResp)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (h *VerificationHandler) handleError(err error) (int, dto.ErrorResponse) {
+	switch {
+	case errors.Is(err, entities.ErrOTPNotFound):
+		return fiber.StatusNotFound, dto.ErrorResponse{
+			Success: false,
+			Error:   "Verification not found",
+			Code:    "VERIFICATION_NOT_FOUND",
+		}
+	case errors.Is(err, entities.ErrOTPAlreadyUsed):
+		return fiber.StatusConflict, dto.ErrorResponse{
+			Success: false,
+			Error:   "Verification has already been approved",
+			Code:    "VERIFICATION_APPROVED",
+		}
+	case errors.Is(err, services.ErrRateLimitExceeded):
+		return fiber.StatusTooManyRequests, rateLimitedResponse(err)
+	case errors.Is(err, services.ErrVerificationLocked):
+		return fiber.StatusLocked, lockedResponse(err)
+	case errors.Is(err, entities.ErrInvalidPhoneNumber):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid phone number format",
+			Code:    "INVALID_PHONE",
+		}
+	case errors.Is(err, entities.ErrNotMobileNumber):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Phone number is not a mobile number; use the voice channel",
+			Code:    "NOT_MOBILE_NUMBER",
+		}
+	case errors.Is(err, entities.ErrNumberBlocked):
+		return fiber.StatusForbidden, dto.ErrorResponse{
+			Success: false,
+			Error:   "OTPs cannot be sent to this phone number",
+			Code:    "NUMBER_BLOCKED",
+		}
+	case errors.Is(err, entities.ErrSendBlocked):
+		return fiber.StatusForbidden, dto.ErrorResponse{
+			Success: false,
+			Error:   "This request was blocked as likely fraud",
+			Code:    "SEND_BLOCKED",
+		}
+	case errors.Is(err, entities.ErrChallengeRequired):
+		return fiber.StatusPreconditionRequired, dto.ErrorResponse{
+			Success: false,
+			Error:   "Pass the challenge and retry with its challenge_token",
+			Code:    "CHALLENGE_REQUIRED",
+		}
+	case errors.Is(err, entities.ErrInvalidEmail):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Invalid email address",
+			Code:    "INVALID_EMAIL",
+		}
+	case errors.Is(err, entities.ErrInvalidRecipient):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Exactly one of phone_number and email is required",
+			Code:    "INVALID_RECIPIENT",
+		}
+	case errors.Is(err, entities.ErrInvalidCodeFormat):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "OTP code does not match the format of the purpose",
+			Code:    "INVALID_CODE",
+		}
+	case errors.Is(err, entities.ErrUnknownPurpose):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Unknown OTP purpose",
+			Code:    "UNKNOWN_PURPOSE",
+		}
+	case errors.Is(err, entities.ErrUnsupportedChannel):
+		return fiber.StatusBadRequest, dto.ErrorResponse{
+			Success: false,
+			Error:   "Unsupported delivery channel",
+			Code:    "UNSUPPORTED_CHANNEL",
+		}
+	default:
+		h.logger.WithError(err).Error("Unexpected error occurred")
+		return fiber.StatusInternalServerError, dto.ErrorResponse{
+			Success: false,
+			Error:   "Internal server error",
+			Code:    "INTERNAL_ERROR",
+		}
+	}
+}
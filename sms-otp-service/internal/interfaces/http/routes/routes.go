@@ -10,14 +10,38 @@ import (
 )
 
 type Routes struct {
-	otpHandler    *handlers.OTPHandler
-	healthHandler *handlers.HealthHandler
+	otpHandler          *handlers.OTPHandler
+	verificationHandler *handlers.VerificationHandler
+	deliveryHandler     *handlers.DeliveryHandler
+	tokenHandler        *handlers.TokenHandler
+	numberRuleHandler   *handlers.NumberRuleHandler
+	lockoutHandler      *handlers.LockoutHandler
+	healthHandler       *handlers.HealthHandler
+	adminAuth           fiber.Handler
+	rateLimit           fiber.Handler
 }
 
-func NewRoutes(otpHandler *handlers.OTPHandler, healthHandler *handlers.HealthHandler) *Routes {
+func NewRoutes(
+	otpHandler *handlers.OTPHandler,
+	verificationHandler *handlers.VerificationHandler,
+	deliveryHandler *handlers.DeliveryHandler,
+	tokenHandler *handlers.TokenHandler,
+	numberRuleHandler *handlers.NumberRuleHandler,
+	lockoutHandler *handlers.LockoutHandler,
+	healthHandler *handlers.HealthHandler,
+	adminAuth fiber.Handler,
+	rateLimit fiber.Handler,
+) *Routes {
 	return &Routes{
-		otpHandler:    otpHandler,
-		healthHandler: healthHandler,
+		otpHandler:          otpHandler,
+		verificationHandler: verificationHandler,
+		deliveryHandler:     deliveryHandler,
+		tokenHandler:        tokenHandler,
+		numberRuleHandler:   numberRuleHandler,
+		lockoutHandler:      lockoutHandler,
+		healthHandler:       healthHandler,
+		adminAuth:           adminAuth,
+		rateLimit:           rateLimit,
 	}
 }
 
@@ -29,20 +53,41 @@ func (r *Routes) Setup(app *fiber.App) {
 		Format: "[${time}] ${status} - ${method} ${path} - ${ip} - ${latency}\n",
 	}))
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,Idempotency-Key,X-API-Key",
+		ExposeHeaders: "Idempotent-Replayed,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After",
 	}))
 
 	app.Get("/health", r.healthHandler.Health)
 	app.Get("/ready", r.healthHandler.Ready)
+	app.Get("/.well-known/jwks.json", r.tokenHandler.JWKS)
 
 	v1 := app.Group("/api/v1")
 
 	otp := v1.Group("/otp")
-	otp.Post("/send", r.otpHandler.SendOTP)
+	otp.Post("/send", r.otpHandler.FindStoredResponse, r.rateLimit, r.otpHandler.SendOTP)
 	otp.Post("/verify", r.otpHandler.VerifyOTP)
-	otp.Post("/resend", r.otpHandler.ResendOTP)
+	otp.Post("/resend", r.otpHandler.FindStoredResponse, r.rateLimit, r.otpHandler.ResendOTP)
+	otp.Post("/token/introspect", r.tokenHandler.Introspect)
+	otp.Get("/:id/delivery", r.deliveryHandler.GetDeliveryStatus)
+
+	verifications := v1.Group("/verifications")
+	verifications.Post("/", r.rateLimit, r.verificationHandler.CreateVerification)
+	verifications.Get("/:id", r.verificationHandler.GetVerification)
+	verifications.Post("/:id/check", r.verificationHandler.CheckVerification)
+	verifications.Post("/:id/cancel", r.verificationHandler.CancelVerification)
+
+	v1.Post("/dlr/:provider", r.deliveryHandler.ReceiveDLR)
+
+	admin := v1.Group("/admin", r.adminAuth)
+	admin.Get("/number-rules", r.numberRuleHandler.ListRules)
+	admin.Post("/number-rules", r.numberRuleHandler.CreateRule)
+	admin.Get("/number-rules/:id", r.numberRuleHandler.GetRule)
+	admin.Put("/number-rules/:id", r.numberRuleHandler.UpdateRule)
+	admin.Delete("/number-rules/:id", r.numberRuleHandler.DeleteRule)
+	admin.Get("/lockouts", r.lockoutHandler.ListLockouts)
+	admin.Delete("/lockouts", r.lockoutHandler.ClearLockouts)
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -0,0 +1,64 @@
+// Package testutil holds what the local stand-ins for external services,
+// such as the SMS, messenger and SMTP servers, have in common.
+package testutil
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+// Recorder keeps the messages a stand-in accepted and the canned failures it
+// is told to return next. Stand-ins embed it. It is safe for concurrent use.
+type Recorder[M, F any] struct {
+	mu       sync.Mutex
+	messages []M
+	failures []F
+}
+
+// FailNext queues a failure for the next request.
+func (r *Recorder[M, F]) FailNext(failure F) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, failure)
+}
+
+// Messages returns a copy of all accepted messages.
+func (r *Recorder[M, F]) Messages() []M {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]M(nil), r.messages...)
+}
+
+// NextFailure takes the failure queued first, if any.
+func (r *Recorder[M, F]) NextFailure() (F, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.failures) == 0 {
+		var none F
+		return none, false
+	}
+	failure := r.failures[0]
+	r.failures = r.failures[1:]
+	return failure, true
+}
+
+// Record accepts the message built from its sequence number, counted from 1,
+// and returns it.
+func (r *Recorder[M, F]) Record(build func(seq int) M) M {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg := build(len(r.messages) + 1)
+	r.messages = append(r.messages, msg)
+	return msg
+}
+
+// Listen listens on a random loopback port, failing t if it cannot.
+func Listen(t testing.TB) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	return listener
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// CodeHasher hashes OTP codes with HMAC-SHA256 under a server-side key.
+type CodeHasher struct {
+	key []byte
+}
+
+func NewCodeHasher(key []byte) *CodeHasher {
+	return &CodeHasher{key: key}
+}
+
+func (h *CodeHasher) Hash(salt, code string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(salt))
+	mac.Write([]byte{0})
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
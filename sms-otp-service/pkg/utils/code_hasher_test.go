@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCodeHasher(t *testing.T) {
+	h := NewCodeHasher([]byte("key"))
+	hash := h.Hash("salt", "123456")
+
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("salt\x00123456"))
+	if want := hex.EncodeToString(mac.Sum(nil)); hash != want {
+		t.Errorf("Hash = %s, want HMAC-SHA256 of salt, NUL and code: %s", hash, want)
+	}
+	if h.Hash("salt", "123456") != hash {
+		t.Error("Hash is not deterministic")
+	}
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "other code", hash: h.Hash("salt", "123457")},
+		{name: "other salt", hash: h.Hash("salt2", "123456")},
+		{name: "other key", hash: NewCodeHasher([]byte("key2")).Hash("salt", "123456")},
+		// The separator keeps the salt and code from running together.
+		{name: "shifted boundary", hash: h.Hash("salt1", "23456")},
+	}
+	for _, tt := range tests {
+		if tt.hash == hash {
+			t.Errorf("%s: hash collides with %s", tt.name, hash)
+		}
+	}
+}
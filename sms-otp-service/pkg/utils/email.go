@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+type EmailValidator struct{}
+
+func NewEmailValidator() *EmailValidator {
+	return &EmailValidator{}
+}
+
+// Validate accepts a bare address such as "user@example.com": no display
+// name or comments, and a domain with at least two labels.
+func (v *EmailValidator) Validate(email string) error {
+	if len(email) > 254 {
+		return fmt.Errorf("email address must be at most 254 characters")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return fmt.Errorf("invalid email address format")
+	}
+
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], email[at+1:]
+	if len(local) > 64 {
+		return fmt.Errorf("email local part must be at most 64 characters")
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("invalid email domain")
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid email domain")
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("invalid email domain")
+			}
+		}
+	}
+
+	return nil
+}
+
+// NormalizeEmail trims and lower-cases the address, so codes sent to
+// "User@Example.com" verify as "user@example.com".
+func (v *EmailValidator) NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmailValidatorValidate(t *testing.T) {
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"user@example.com", true},
+		{"first.last+otp@mail.example.co.uk", true},
+		{"USER@Example.COM", true},
+		{"o'brien@example-mail.com", true},
+		{strings.Repeat("a", 64) + "@example.com", true},
+
+		{"", false},
+		{"user", false},
+		{"user@", false},
+		{"@example.com", false},
+		{"user@localhost", false},
+		{"user@example..com", false},
+		{"user@-example.com", false},
+		{"user@example-.com", false},
+		{"user@exa_mple.com", false},
+		{"user@[127.0.0.1]", false},
+		{"User <user@example.com>", false},
+		{"<user@example.com>", false},
+		{"user@example.com (comment)", false},
+		{" user@example.com", false},
+		{"user@example.com\r\nBcc: attacker@evil.example", false},
+		{"a@b@example.com", false},
+		{strings.Repeat("a", 65) + "@example.com", false},
+		{"user@" + strings.Repeat("a", 64) + ".com", false},
+		{"user@" + strings.Repeat(strings.Repeat("a", 60)+".", 5) + "com", false},
+	}
+
+	v := NewEmailValidator()
+	for _, tt := range tests {
+		err := v.Validate(tt.email)
+		if tt.valid && err != nil {
+			t.Errorf("Validate(%q) = %v, want valid", tt.email, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Validate(%q) accepted an invalid address", tt.email)
+		}
+	}
+}
+
+func TestEmailValidatorNormalize(t *testing.T) {
+	v := NewEmailValidator()
+	if got := v.NormalizeEmail("  User.Name@Example.COM "); got != "user.name@example.com" {
+		t.Errorf("NormalizeEmail = %q, want user.name@example.com", got)
+	}
+}
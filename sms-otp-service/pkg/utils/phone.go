@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidPhoneNumber = errors.New("invalid phone number")
+	ErrUnknownRegion      = errors.New("unknown phone number region")
+)
+
+// PhoneNumberType is the kind of line a phone number belongs to.
+type PhoneNumberType string
+
+const (
+	PhoneTypeMobile    PhoneNumberType = "mobile"
+	PhoneTypeFixedLine PhoneNumberType = "fixed_line"
+	// PhoneTypeFixedLineOrMobile is used where the numbering plan does not
+	// tell the two apart, as in North America.
+	PhoneTypeFixedLineOrMobile PhoneNumberType = "fixed_line_or_mobile"
+	PhoneTypeTollFree          PhoneNumberType = "toll_free"
+	PhoneTypePremiumRate       PhoneNumberType = "premium_rate"
+	PhoneTypeSharedCost        PhoneNumberType = "shared_cost"
+	PhoneTypeVoIP              PhoneNumberType = "voip"
+	// PhoneTypeUnknown is used for regions whose numbers are only checked
+	// for length.
+	PhoneTypeUnknown PhoneNumberType = "unknown"
+)
+
+// CanReceiveSMS reports whether numbers of type t may be mobile. Numbers of
+// unknown type are given the benefit of the doubt.
+func (t PhoneNumberType) CanReceiveSMS() bool {
+	switch t {
+	case PhoneTypeMobile, PhoneTypeFixedLineOrMobile, PhoneTypeUnknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// PhoneNumber is a parsed and validated phone number.
+type PhoneNumber struct {
+	CountryCode string
+	// NationalNumber is the national significant number: the digits after
+	// the country code, without any national prefix.
+	NationalNumber string
+	// Region is the ISO 3166-1 alpha-2 code of the region the number
+	// belongs to.
+	Region string
+	Type   PhoneNumberType
+}
+
+// E164 returns the number in E.164 form, such as "+994501234567".
+func (n PhoneNumber) E164() string {
+	return "+" + n.CountryCode + n.NationalNumber
+}
+
+// PhoneValidator parses phone numbers in international or national format
+// against per-region numbering plan metadata. National numbers are read as
+// numbers of the default region.
+type PhoneValidator struct {
+	defaultRegion *numberingPlan
+}
+
+var phoneFormatting = regexp.MustCompile(`[\s\-().\/]`)
+
+// NewPhoneValidator returns a validator that reads national numbers as
+// numbers of defaultRegion. Without a default region only numbers in
+// international format are accepted.
+func NewPhoneValidator(defaultRegion string) (*PhoneValidator, error) {
+	v := &PhoneValidator{}
+	if defaultRegion != "" {
+		plan, ok := plansByRegion[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownRegion, defaultRegion)
+		}
+		v.defaultRegion = plan
+	}
+	return v, nil
+}
+
+// Parse reads a phone number such as "+994 50 123 45 67", "00994501234567"
+// or, in the default region, "050 123 45 67".
+func (v *PhoneValidator) Parse(phoneNumber string) (PhoneNumber, error) {
+	cleaned := phoneFormatting.ReplaceAllString(strings.TrimSpace(phoneNumber), "")
+
+	var number PhoneNumber
+	var ok bool
+	switch {
+	case strings.HasPrefix(cleaned, "+"):
+		number, ok = parseInternational(cleaned[1:])
+	case strings.HasPrefix(cleaned, "00"):
+		number, ok = parseInternational(cleaned[2:])
+	default:
+		number, ok = v.parseNational(cleaned)
+	}
+
+	if !ok || len(number.CountryCode)+len(number.NationalNumber) > 15 {
+		return PhoneNumber{}, ErrInvalidPhoneNumber
+	}
+	return number, nil
+}
+
+func (v *PhoneValidator) Validate(phoneNumber string) error {
+	_, err := v.Parse(phoneNumber)
+	return err
+}
+
+// NormalizePhoneNumber returns the number in E.164 form.
+func (v *PhoneValidator) NormalizePhoneNumber(phoneNumber string) (string, error) {
+	number, err := v.Parse(phoneNumber)
+	if err != nil {
+		return "", err
+	}
+	return number.E164(), nil
+}
+
+// IsMobile reports whether a valid phone number may be able to receive SMS.
+func (v *PhoneValidator) IsMobile(phoneNumber string) bool {
+	number, err := v.Parse(phoneNumber)
+	return err == nil && number.Type.CanReceiveSMS()
+}
+
+// Region returns the ISO 3166-1 alpha-2 code of the region a valid phone
+// number belongs to.
+func (v *PhoneValidator) Region(phoneNumber string) (string, error) {
+	number, err := v.Parse(phoneNumber)
+	if err != nil {
+		return "", err
+	}
+	return number.Region, nil
+}
+
+// parseInternational reads the digits of a number after its "+" or "00".
+// Country codes are prefix-free, so at most one of their 1 to 3 digit
+// prefixes is known.
+func parseInternational(digits string) (PhoneNumber, bool) {
+	if !isDigits(digits) {
+		return PhoneNumber{}, false
+	}
+
+	for n := 1; n <= 3 && n < len(digits); n++ {
+		plans, ok := plansByCountryCode[digits[:n]]
+		if !ok {
+			continue
+		}
+
+		national := digits[n:]
+		if number, ok := match(plans, national); ok {
+			return number, true
+		}
+		// Numbers are often written with their national prefix, as in
+		// "+44 (0)20 7946 0018".
+		for _, plan := range plans {
+			if plan.nationalPrefix != "" && strings.HasPrefix(national, plan.nationalPrefix) {
+				if number, ok := match(plans, strings.TrimPrefix(national, plan.nationalPrefix)); ok {
+					return number, true
+				}
+			}
+		}
+		return PhoneNumber{}, false
+	}
+	return PhoneNumber{}, false
+}
+
+// parseNational reads a number written without "+" as a number of the
+// default region, with or without its national prefix, or as one that only
+// lacks the "+" before the default region's country code.
+func (v *PhoneValidator) parseNational(digits string) (PhoneNumber, bool) {
+	if v.defaultRegion == nil || !isDigits(digits) {
+		return PhoneNumber{}, false
+	}
+
+	region := v.defaultRegion
+	plans := plansByCountryCode[region.countryCode]
+
+	if region.nationalPrefix != "" && strings.HasPrefix(digits, region.nationalPrefix) {
+		if number, ok := match(plans, strings.TrimPrefix(digits, region.nationalPrefix)); ok {
+			return number, true
+		}
+	}
+	if number, ok := match(plans, digits); ok {
+		return number, true
+	}
+	if strings.HasPrefix(digits, region.countryCode) {
+		return match(plans, strings.TrimPrefix(digits, region.countryCode))
+	}
+	return PhoneNumber{}, false
+}
+
+// match finds the region and type of a national significant number among
+// the regions sharing a country code.
+func match(plans []*numberingPlan, national string) (PhoneNumber, bool) {
+	for _, plan := range plans {
+		if numberType, ok := plan.classify(national); ok {
+			return PhoneNumber{
+				CountryCode:    plan.countryCode,
+				NationalNumber: national,
+				Region:         plan.region,
+				Type:           numberType,
+			}, true
+		}
+	}
+	return PhoneNumber{}, false
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
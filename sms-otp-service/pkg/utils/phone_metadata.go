@@ -0,0 +1,635 @@
+package utils
+
+import "regexp"
+
+// numberingPlan describes the phone numbers of one region. Its patterns
+// match whole national significant numbers. Typed patterns are tried in
+// order, so more specific ranges such as premium rate come before the broad
+// fixed-line ranges they overlap; regions without type data only have a
+// general pattern checking the length of their numbers.
+type numberingPlan struct {
+	region         string
+	countryCode    string
+	nationalPrefix string
+	types          []numberTypePattern
+	general        *regexp.Regexp
+}
+
+type numberTypePattern struct {
+	numberType PhoneNumberType
+	pattern    *regexp.Regexp
+}
+
+// classify returns the type of a national significant number, and false
+// when the number is not valid in the region.
+func (p *numberingPlan) classify(national string) (PhoneNumberType, bool) {
+	for _, t := range p.types {
+		if t.pattern.MatchString(national) {
+			return t.numberType, true
+		}
+	}
+	if p.general != nil && p.general.MatchString(national) {
+		return PhoneTypeUnknown, true
+	}
+	return "", false
+}
+
+func typedPlan(region, countryCode, nationalPrefix string, types ...numberTypePattern) *numberingPlan {
+	return &numberingPlan{region: region, countryCode: countryCode, nationalPrefix: nationalPrefix, types: types}
+}
+
+func lengthPlan(region, countryCode, nationalPrefix, general string) *numberingPlan {
+	return &numberingPlan{region: region, countryCode: countryCode, nationalPrefix: nationalPrefix, general: anchored(general)}
+}
+
+func anchored(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(`^(?:` + pattern + `)$`)
+}
+
+func mobile(pattern string) numberTypePattern {
+	return numberTypePattern{PhoneTypeMobile, anchored(pattern)}
+}
+
+func fixedLine(pattern string) numberTypePattern {
+	return numberTypePattern{PhoneTypeFixedLine, anchored(pattern)}
+}
+
+func fixedLineOrMobile(pattern string) numberTypePattern {
+	return numberTypePattern{PhoneTypeFixedLineOrMobile, anchored(pattern)}
+}
+
+func tollFree(pattern string) numberTypePattern {
+	return numberTypePattern{PhoneTypeTollFree, anchored(pattern)}
+}
+
+func premiumRate(pattern string) numberTypePattern {
+	return numberTypePattern{PhoneTypePremiumRate, anchored(pattern)}
+}
+
+func sharedCost(pattern string) numberTypePattern {
+	return numberTypePattern{PhoneTypeSharedCost, anchored(pattern)}
+}
+
+func voip(pattern string) numberTypePattern {
+	return numberTypePattern{PhoneTypeVoIP, anchored(pattern)}
+}
+
+// nanpPlan is a North American Numbering Plan region other than the United
+// States, identified by its area codes.
+func nanpPlan(region, areaCodes string) *numberingPlan {
+	return typedPlan(region, "1", "1", fixedLineOrMobile(`(?:`+areaCodes+`)[2-9]\d{6}`))
+}
+
+// numberingPlans lists every supported region. Regions sharing a country
+// code are tried in order, so the narrower ones come first.
+var numberingPlans = []*numberingPlan{
+	// North America: Canada and the Caribbean by area code, then the United
+	// States, which also holds the shared toll-free and premium ranges.
+	nanpPlan("CA", `204|226|236|249|250|257|263|289|306|343|354|365|367|368|382|387|403|416|418|428|431|437|438|450|460|468|474|506|514|519|548|579|581|584|587|604|613|639|647|672|683|705|709|742|753|778|780|782|807|819|825|867|873|879|902|905|942`),
+	nanpPlan("AG", `268`),
+	nanpPlan("AI", `264`),
+	nanpPlan("AS", `684`),
+	nanpPlan("BB", `246`),
+	nanpPlan("BM", `441`),
+	nanpPlan("BS", `242`),
+	nanpPlan("DM", `767`),
+	nanpPlan("DO", `809|829|849`),
+	nanpPlan("GD", `473`),
+	nanpPlan("GU", `671`),
+	nanpPlan("JM", `658|876`),
+	nanpPlan("KN", `869`),
+	nanpPlan("KY", `345`),
+	nanpPlan("LC", `758`),
+	nanpPlan("MP", `670`),
+	nanpPlan("MS", `664`),
+	nanpPlan("PR", `787|939`),
+	nanpPlan("SX", `721`),
+	nanpPlan("TC", `649`),
+	nanpPlan("TT", `868`),
+	nanpPlan("VC", `784`),
+	nanpPlan("VG", `284`),
+	nanpPlan("VI", `340`),
+	typedPlan("US", "1", "1",
+		tollFree(`8(?:00|33|44|55|66|77|88)[2-9]\d{6}`),
+		premiumRate(`900[2-9]\d{6}`),
+		fixedLineOrMobile(`[2-9]\d{2}[2-9]\d{6}`),
+	),
+
+	// Kazakhstan shares +7 with Russia.
+	typedPlan("KZ", "7", "8",
+		mobile(`7(?:0[0-8]|47|5\d|7[0-8])\d{7}`),
+		fixedLine(`(?:6\d|7[12])\d{8}`),
+	),
+	typedPlan("RU", "7", "8",
+		tollFree(`800\d{7}`),
+		premiumRate(`809\d{7}`),
+		mobile(`9\d{9}`),
+		fixedLine(`(?:[34]\d|8[1-79])\d{8}`),
+	),
+
+	// Caucasus and Central Asia
+	typedPlan("AZ", "994", "0",
+		tollFree(`88\d{7}`),
+		premiumRate(`900\d{6}`),
+		mobile(`(?:10|5[015]|60|7[07]|99)\d{7}`),
+		fixedLine(`(?:1[28]|2\d|36)\d{7}`),
+	),
+	typedPlan("GE", "995", "0",
+		tollFree(`800\d{6}`),
+		mobile(`5\d{8}`),
+		fixedLine(`(?:3[2-5]|4[1-9])\d{7}`),
+	),
+	typedPlan("AM", "374", "0",
+		tollFree(`800\d{5}`),
+		premiumRate(`90[016]\d{5}`),
+		mobile(`(?:33|4[1349]|55|77|9[1-9])\d{6}`),
+		fixedLine(`(?:1[0-2]|2[2-6]|3[1-8])\d{6}`),
+	),
+	typedPlan("UZ", "998", "",
+		mobile(`(?:33|5[05]|7[07]|8[08]|9[0-579])\d{7}`),
+		fixedLine(`(?:6[125679]|7[0-69])\d{7}`),
+	),
+	typedPlan("KG", "996", "0",
+		mobile(`(?:2[0-2]|5\d|7[0-57-9]|88|99)\d{7}`),
+		fixedLine(`3\d{8}`),
+	),
+	typedPlan("TM", "993", "8",
+		mobile(`6\d{7}`),
+		fixedLine(`[1-5]\d{7}`),
+	),
+	lengthPlan("TJ", "992", "", `\d{9}`),
+	typedPlan("IR", "98", "0",
+		mobile(`9\d{9}`),
+		fixedLine(`[1-8]\d{9}`),
+	),
+
+	// Europe
+	typedPlan("TR", "90", "0",
+		tollFree(`800\d{7}`),
+		premiumRate(`900\d{7}`),
+		voip(`850\d{7}`),
+		mobile(`5(?:0[1-9]|[3-5]\d|6[1-9]|9[1-9])\d{7}`),
+		fixedLine(`[2-4]\d{9}`),
+	),
+	typedPlan("UA", "380", "0",
+		tollFree(`800\d{6}`),
+		premiumRate(`900\d{6}`),
+		mobile(`(?:39|50|6[36-8]|7[1-3]|9[1-9])\d{7}`),
+		fixedLine(`(?:3[1-8]|4[13-8]|5[1-7]|6[12459])\d{7}`),
+	),
+	typedPlan("BY", "375", "8",
+		mobile(`(?:2[5-9]|33|44)\d{7}`),
+		fixedLine(`(?:1[5-7]|2[1-4])\d{7}`),
+	),
+	typedPlan("GB", "44", "0",
+		tollFree(`80[08]\d{7}|800\d{6}`),
+		premiumRate(`9[018]\d{8}`),
+		sharedCost(`8(?:4[2-5]|7[0-3])\d{7}`),
+		voip(`56\d{8}`),
+		mobile(`7[1-57-9]\d{8}`),
+		fixedLine(`1\d{8,9}|2\d{9}`),
+	),
+	typedPlan("DE", "49", "0",
+		tollFree(`800\d{7,12}`),
+		premiumRate(`900\d{7}`),
+		voip(`32\d{9,11}`),
+		mobile(`15\d{9}|1[67]\d{8,9}`),
+		fixedLine(`[2-9]\d{5,10}`),
+	),
+	typedPlan("FR", "33", "0",
+		tollFree(`80[0-5]\d{6}`),
+		premiumRate(`8[129]\d{7}`),
+		voip(`9\d{8}`),
+		mobile(`[67]\d{8}`),
+		fixedLine(`[1-5]\d{8}`),
+	),
+	typedPlan("IT", "39", "",
+		tollFree(`80[03]\d{6}`),
+		premiumRate(`89\d{6,7}`),
+		mobile(`3\d{8,9}`),
+		fixedLine(`0\d{5,10}`),
+	),
+	typedPlan("ES", "34", "",
+		tollFree(`[89]00\d{6}`),
+		premiumRate(`80[367]\d{6}`),
+		mobile(`(?:6\d|7[1-9])\d{7}`),
+		fixedLine(`[89][1-9]\d{7}`),
+	),
+	typedPlan("PT", "351", "",
+		tollFree(`800\d{6}`),
+		premiumRate(`6[0146]\d{7}|70[78]\d{6}`),
+		mobile(`9[1236]\d{7}`),
+		fixedLine(`2\d{8}`),
+	),
+	typedPlan("NL", "31", "0",
+		tollFree(`800\d{4,7}`),
+		premiumRate(`90[069]\d{4,7}`),
+		voip(`85\d{7}`),
+		mobile(`6[1-58]\d{7}`),
+		fixedLine(`(?:1[0-35-8]|2[0-6]|3[0-8]|4[0-8]|5\d|7\d)\d{7}`),
+	),
+	typedPlan("BE", "32", "0",
+		tollFree(`800\d{5}`),
+		premiumRate(`(?:70|90)\d{6}`),
+		mobile(`4[5-9]\d{7}`),
+		fixedLine(`[1-9]\d{7}`),
+	),
+	typedPlan("CH", "41", "0",
+		tollFree(`800\d{6}`),
+		premiumRate(`90[016]\d{6}`),
+		mobile(`7[35-9]\d{7}`),
+		fixedLine(`(?:2[12467]|3[1-4]|4[134]|5[256]|6[12]|[7-9]1)\d{7}`),
+	),
+	typedPlan("AT", "43", "0",
+		tollFree(`800\d{6,10}`),
+		premiumRate(`9[0-3]\d{6,10}`),
+		mobile(`6(?:5[0-3579]|6[013-9]|[7-9]\d)\d{4,10}`),
+		fixedLine(`[1-57-8]\d{3,12}`),
+	),
+	typedPlan("PL", "48", "",
+		tollFree(`800\d{6}`),
+		premiumRate(`70[01346-8]\d{6}`),
+		mobile(`(?:45|5[0137]|6[069]|7[2389]|88)\d{7}`),
+		fixedLine(`(?:1[2-8]|2[2-69]|3[2-4]|4[1-468]|5[24-689]|6[1-3578]|7[14-7]|8[1-79]|9[145])\d{7}`),
+	),
+	typedPlan("CZ", "420", "",
+		tollFree(`800\d{6}`),
+		premiumRate(`9(?:0[05689]|76)\d{6}`),
+		mobile(`(?:60[1-8]|7[2-9]\d)\d{6}`),
+		fixedLine(`[2-5]\d{8}`),
+	),
+	typedPlan("RO", "40", "0",
+		tollFree(`800\d{6}`),
+		premiumRate(`90[036]\d{6}`),
+		mobile(`7[0-8]\d{7}`),
+		fixedLine(`[23]\d{8}`),
+	),
+	typedPlan("HU", "36", "06",
+		tollFree(`80\d{6}`),
+		premiumRate(`9[01]\d{6}`),
+		mobile(`(?:[257]0|3[01])\d{7}`),
+		fixedLine(`1\d{7}|[2-9]\d{7}`),
+	),
+	typedPlan("GR", "30", "",
+		tollFree(`800\d{7}`),
+		premiumRate(`90[19]\d{7}`),
+		mobile(`69\d{8}`),
+		fixedLine(`2\d{9}`),
+	),
+	typedPlan("IE", "353", "0",
+		tollFree(`1800\d{6}`),
+		premiumRate(`15\d{8}`),
+		mobile(`8[35-9]\d{7}`),
+		fixedLine(`[1-9]\d{6,9}`),
+	),
+	typedPlan("SE", "46", "0",
+		tollFree(`20\d{4,7}`),
+		premiumRate(`900\d{4,7}`),
+		mobile(`7[02369]\d{7}`),
+		fixedLine(`[1-8]\d{5,8}`),
+	),
+	typedPlan("NO", "47", "",
+		tollFree(`80[01]\d{5}`),
+		premiumRate(`82[09]\d{5}`),
+		mobile(`[49]\d{7}`),
+		fixedLine(`[235-7]\d{7}`),
+	),
+	typedPlan("DK", "45", "",
+		tollFree(`80\d{6}`),
+		premiumRate(`90\d{6}`),
+		fixedLineOrMobile(`[2-9]\d{7}`),
+	),
+	lengthPlan("FI", "358", "0", `\d{5,12}`),
+	lengthPlan("BG", "359", "0", `\d{7,9}`),
+	lengthPlan("GI", "350", "", `\d{8}`),
+	lengthPlan("LU", "352", "", `\d{4,11}`),
+	lengthPlan("IS", "354", "", `\d{7,9}`),
+	lengthPlan("AL", "355", "0", `\d{8,9}`),
+	lengthPlan("MT", "356", "", `\d{8}`),
+	lengthPlan("CY", "357", "", `\d{8}`),
+	lengthPlan("LT", "370", "8", `\d{8}`),
+	lengthPlan("LV", "371", "", `\d{8}`),
+	lengthPlan("EE", "372", "", `\d{7,8}`),
+	lengthPlan("MD", "373", "0", `\d{8}`),
+	lengthPlan("AD", "376", "", `\d{6,9}`),
+	lengthPlan("MC", "377", "0", `\d{8,9}`),
+	lengthPlan("SM", "378", "", `\d{6,10}`),
+	lengthPlan("RS", "381", "0", `\d{6,12}`),
+	lengthPlan("ME", "382", "0", `\d{8}`),
+	lengthPlan("XK", "383", "0", `\d{8,9}`),
+	lengthPlan("HR", "385", "0", `\d{6,9}`),
+	lengthPlan("SI", "386", "0", `\d{8}`),
+	lengthPlan("BA", "387", "0", `\d{8,9}`),
+	lengthPlan("MK", "389", "0", `\d{8}`),
+	lengthPlan("SK", "421", "0", `\d{9}`),
+	lengthPlan("LI", "423", "", `\d{7,9}`),
+	lengthPlan("FO", "298", "", `\d{6}`),
+	lengthPlan("GL", "299", "", `\d{6}`),
+
+	// Middle East
+	typedPlan("AE", "971", "0",
+		tollFree(`800\d{2,9}`),
+		premiumRate(`900[02]\d{5}`),
+		mobile(`5[024-68]\d{7}`),
+		fixedLine(`[2-4679]\d{7}`),
+	),
+	typedPlan("SA", "966", "0",
+		tollFree(`800\d{7}`),
+		sharedCost(`920\d{6}`),
+		mobile(`5\d{8}`),
+		fixedLine(`1[1-8]\d{7}`),
+	),
+	typedPlan("IL", "972", "0",
+		tollFree(`1800\d{6}`),
+		voip(`7\d{8}`),
+		mobile(`5\d{8}`),
+		fixedLine(`[2-489]\d{7}`),
+	),
+	typedPlan("QA", "974", "",
+		tollFree(`800\d{4}`),
+		mobile(`[35-7]\d{7}`),
+		fixedLine(`4\d{7}`),
+	),
+	typedPlan("KW", "965", "",
+		tollFree(`18\d{5}`),
+		mobile(`[569]\d{7}`),
+		fixedLine(`2\d{7}`),
+	),
+	typedPlan("BH", "973", "",
+		mobile(`3\d{7}|6[3-6]\d{6}`),
+		fixedLine(`1\d{7}`),
+	),
+	typedPlan("OM", "968", "",
+		tollFree(`800\d{4,5}`),
+		mobile(`[79]\d{7}`),
+		fixedLine(`2\d{7}`),
+	),
+	typedPlan("JO", "962", "0",
+		mobile(`7[789]\d{7}`),
+		fixedLine(`[2-6]\d{7}`),
+	),
+	lengthPlan("LB", "961", "0", `\d{7,8}`),
+	lengthPlan("SY", "963", "0", `\d{8,9}`),
+	lengthPlan("IQ", "964", "0", `\d{8,10}`),
+	lengthPlan("YE", "967", "0", `\d{7,9}`),
+	lengthPlan("PS", "970", "0", `\d{8,9}`),
+
+	// Asia and Oceania
+	typedPlan("IN", "91", "0",
+		tollFree(`1800\d{6,7}`),
+		mobile(`[6-9]\d{9}`),
+		fixedLine(`[1-5]\d{9}`),
+	),
+	typedPlan("PK", "92", "0",
+		tollFree(`800\d{5}`),
+		mobile(`3[0-6]\d{8}`),
+		fixedLine(`[2-9]\d{7,9}`),
+	),
+	typedPlan("CN", "86", "0",
+		tollFree(`800\d{7}`),
+		sharedCost(`400\d{7}`),
+		mobile(`1[3-9]\d{9}`),
+		fixedLine(`(?:10|2\d|[3-9]\d{2})\d{6,8}`),
+	),
+	typedPlan("HK", "852", "",
+		tollFree(`800\d{6}`),
+		premiumRate(`900\d{8}`),
+		mobile(`[5679]\d{7}|4[6-9]\d{6}`),
+		fixedLine(`[23]\d{7}`),
+	),
+	typedPlan("JP", "81", "0",
+		tollFree(`120\d{6}|800\d{7}`),
+		voip(`50\d{8}`),
+		mobile(`[7-9]0\d{8}`),
+		fixedLine(`[1-9]\d{8}`),
+	),
+	typedPlan("KR", "82", "0",
+		tollFree(`80\d{7}`),
+		voip(`70\d{8}`),
+		mobile(`1[0-26-9]\d{7,8}`),
+		fixedLine(`(?:2|[3-6][1-5])\d{6,8}`),
+	),
+	typedPlan("SG", "65", "",
+		tollFree(`800\d{7}`),
+		premiumRate(`1900\d{7}`),
+		voip(`3\d{7}`),
+		mobile(`[89]\d{7}`),
+		fixedLine(`6\d{7}`),
+	),
+	typedPlan("MY", "60", "0",
+		tollFree(`1[378]00\d{6}`),
+		mobile(`1[0-46-9]\d{7,8}`),
+		fixedLine(`[3-9]\d{7,8}`),
+	),
+	typedPlan("ID", "62", "0",
+		tollFree(`800\d{5,7}`),
+		mobile(`8[1-9]\d{6,10}`),
+		fixedLine(`[2-7]\d{6,10}`),
+	),
+	typedPlan("PH", "63", "0",
+		tollFree(`1800\d{7,9}`),
+		mobile(`9\d{9}`),
+		fixedLine(`[2-8]\d{7,9}`),
+	),
+	typedPlan("TH", "66", "0",
+		tollFree(`1800\d{6}`),
+		premiumRate(`1900\d{6}`),
+		mobile(`[689]\d{8}`),
+		fixedLine(`[2-57]\d{7}`),
+	),
+	typedPlan("VN", "84", "0",
+		tollFree(`1800\d{4,6}`),
+		premiumRate(`1900\d{4,6}`),
+		mobile(`(?:3\d|5[2689]|7[06-9]|8[1-9]|9\d)\d{7}`),
+		fixedLine(`2\d{9}`),
+	),
+	typedPlan("AU", "61", "0",
+		tollFree(`180\d{6,7}`),
+		premiumRate(`190\d{7}`),
+		sharedCost(`13\d{4}|1300\d{6}`),
+		mobile(`4\d{8}`),
+		fixedLine(`[2378]\d{8}`),
+	),
+	typedPlan("NZ", "64", "0",
+		tollFree(`80\d{6,7}`),
+		premiumRate(`90\d{6,7}`),
+		mobile(`2\d{7,9}`),
+		fixedLine(`[3-79]\d{7}`),
+	),
+	lengthPlan("AF", "93", "0", `\d{9}`),
+	lengthPlan("LK", "94", "0", `\d{9}`),
+	lengthPlan("MM", "95", "0", `\d{7,10}`),
+	lengthPlan("BD", "880", "0", `\d{8,10}`),
+	lengthPlan("NP", "977", "0", `\d{8,10}`),
+	lengthPlan("BT", "975", "", `\d{7,8}`),
+	lengthPlan("MV", "960", "", `\d{7}`),
+	lengthPlan("MN", "976", "0", `\d{8}`),
+	lengthPlan("TW", "886", "0", `\d{8,9}`),
+	lengthPlan("MO", "853", "", `\d{8}`),
+	lengthPlan("KH", "855", "0", `\d{8,9}`),
+	lengthPlan("LA", "856", "0", `\d{8,10}`),
+	lengthPlan("KP", "850", "0", `\d{8,10}`),
+	lengthPlan("BN", "673", "", `\d{7}`),
+	lengthPlan("TL", "670", "", `\d{7,8}`),
+	lengthPlan("PG", "675", "", `\d{7,8}`),
+	lengthPlan("FJ", "679", "", `\d{7}`),
+	lengthPlan("SB", "677", "", `\d{5,7}`),
+	lengthPlan("VU", "678", "", `\d{5,7}`),
+	lengthPlan("NC", "687", "", `\d{6}`),
+	lengthPlan("PF", "689", "", `\d{8}`),
+	lengthPlan("WS", "685", "", `\d{5,7}`),
+	lengthPlan("TO", "676", "", `\d{5,7}`),
+	lengthPlan("KI", "686", "", `\d{5,8}`),
+	lengthPlan("FM", "691", "", `\d{7}`),
+	lengthPlan("MH", "692", "", `\d{7}`),
+	lengthPlan("PW", "680", "", `\d{7}`),
+	lengthPlan("NR", "674", "", `\d{7}`),
+	lengthPlan("TV", "688", "", `\d{5,6}`),
+	lengthPlan("CK", "682", "", `\d{5}`),
+	lengthPlan("NU", "683", "", `\d{4,7}`),
+	lengthPlan("TK", "690", "", `\d{4,7}`),
+	lengthPlan("WF", "681", "", `\d{6}`),
+	lengthPlan("NF", "672", "", `\d{6}`),
+
+	// Africa
+	typedPlan("EG", "20", "0",
+		tollFree(`800\d{7}`),
+		premiumRate(`900\d{7}`),
+		mobile(`1[0-25]\d{8}`),
+		fixedLine(`[2-9]\d{7,8}`),
+	),
+	typedPlan("ZA", "27", "0",
+		tollFree(`80\d{7}`),
+		premiumRate(`86\d{7}`),
+		mobile(`(?:6[0-5]|7[0-46-9]|8[1-5])\d{7}`),
+		fixedLine(`(?:1\d|2[1-9]|3\d|4\d|5[1-8])\d{7}`),
+	),
+	typedPlan("NG", "234", "0",
+		mobile(`(?:70|8[01]|9[01])\d{8}`),
+		fixedLine(`[1-9]\d{6,8}`),
+	),
+	typedPlan("KE", "254", "0",
+		mobile(`(?:1[01]|7\d)\d{7}`),
+		fixedLine(`[2-6]\d{6,8}`),
+	),
+	lengthPlan("SS", "211", "0", `\d{9}`),
+	lengthPlan("MA", "212", "0", `\d{9}`),
+	lengthPlan("DZ", "213", "0", `\d{8,9}`),
+	lengthPlan("TN", "216", "", `\d{8}`),
+	lengthPlan("LY", "218", "0", `\d{8,9}`),
+	lengthPlan("GM", "220", "", `\d{7}`),
+	lengthPlan("SN", "221", "", `\d{9}`),
+	lengthPlan("MR", "222", "", `\d{8}`),
+	lengthPlan("ML", "223", "", `\d{8}`),
+	lengthPlan("GN", "224", "", `\d{8,9}`),
+	lengthPlan("CI", "225", "", `\d{10}`),
+	lengthPlan("BF", "226", "", `\d{8}`),
+	lengthPlan("NE", "227", "", `\d{8}`),
+	lengthPlan("TG", "228", "", `\d{8}`),
+	lengthPlan("BJ", "229", "", `\d{8,10}`),
+	lengthPlan("MU", "230", "", `\d{7,8}`),
+	lengthPlan("LR", "231", "0", `\d{7,9}`),
+	lengthPlan("SL", "232", "0", `\d{8}`),
+	lengthPlan("GH", "233", "0", `\d{9}`),
+	lengthPlan("TD", "235", "", `\d{8}`),
+	lengthPlan("CF", "236", "", `\d{8}`),
+	lengthPlan("CM", "237", "", `\d{9}`),
+	lengthPlan("CV", "238", "", `\d{7}`),
+	lengthPlan("ST", "239", "", `\d{7}`),
+	lengthPlan("GQ", "240", "", `\d{9}`),
+	lengthPlan("GA", "241", "", `\d{7,8}`),
+	lengthPlan("CG", "242", "", `\d{9}`),
+	lengthPlan("CD", "243", "0", `\d{9}`),
+	lengthPlan("AO", "244", "", `\d{9}`),
+	lengthPlan("GW", "245", "", `\d{9}`),
+	lengthPlan("IO", "246", "", `\d{7}`),
+	lengthPlan("AC", "247", "", `\d{5,6}`),
+	lengthPlan("SC", "248", "", `\d{7}`),
+	lengthPlan("SD", "249", "0", `\d{9}`),
+	lengthPlan("RW", "250", "", `\d{9}`),
+	lengthPlan("ET", "251", "0", `\d{9}`),
+	lengthPlan("SO", "252", "0", `\d{7,9}`),
+	lengthPlan("DJ", "253", "", `\d{8}`),
+	lengthPlan("TZ", "255", "0", `\d{9}`),
+	lengthPlan("UG", "256", "0", `\d{9}`),
+	lengthPlan("BI", "257", "", `\d{8}`),
+	lengthPlan("MZ", "258", "", `\d{8,9}`),
+	lengthPlan("ZM", "260", "0", `\d{9}`),
+	lengthPlan("MG", "261", "0", `\d{9}`),
+	lengthPlan("RE", "262", "0", `\d{9}`),
+	lengthPlan("ZW", "263", "0", `\d{9,10}`),
+	lengthPlan("NA", "264", "0", `\d{8,9}`),
+	lengthPlan("MW", "265", "0", `\d{7,9}`),
+	lengthPlan("LS", "266", "", `\d{8}`),
+	lengthPlan("BW", "267", "", `\d{7,8}`),
+	lengthPlan("SZ", "268", "", `\d{8}`),
+	lengthPlan("KM", "269", "", `\d{7}`),
+	lengthPlan("SH", "290", "", `\d{4,5}`),
+	lengthPlan("ER", "291", "0", `\d{7}`),
+
+	// Latin America
+	typedPlan("MX", "52", "",
+		tollFree(`800\d{7}`),
+		premiumRate(`900\d{7}`),
+		fixedLineOrMobile(`[1-9]\d{9}`),
+	),
+	typedPlan("BR", "55", "0",
+		tollFree(`800\d{6,7}`),
+		premiumRate(`[359]00\d{6,7}`),
+		mobile(`[1-9][1-9]9\d{8}`),
+		fixedLine(`[1-9][1-9][2-5]\d{7}`),
+	),
+	typedPlan("AR", "54", "0",
+		tollFree(`800\d{7}`),
+		mobile(`9[1-9]\d{9}`),
+		fixedLine(`[1-8]\d{9}`),
+	),
+	typedPlan("CO", "57", "",
+		tollFree(`1800\d{7}`),
+		mobile(`3\d{9}`),
+		fixedLine(`60\d{8}`),
+	),
+	typedPlan("CL", "56", "",
+		mobile(`9\d{8}`),
+		fixedLine(`[2-8]\d{8}`),
+	),
+	typedPlan("PE", "51", "0",
+		mobile(`9\d{8}`),
+		fixedLine(`[1-8]\d{6,7}`),
+	),
+	lengthPlan("CU", "53", "0", `\d{6,8}`),
+	lengthPlan("VE", "58", "0", `\d{10}`),
+	lengthPlan("FK", "500", "", `\d{5}`),
+	lengthPlan("BZ", "501", "", `\d{7}`),
+	lengthPlan("GT", "502", "", `\d{8}`),
+	lengthPlan("SV", "503", "", `\d{8}`),
+	lengthPlan("HN", "504", "", `\d{8}`),
+	lengthPlan("NI", "505", "", `\d{8}`),
+	lengthPlan("CR", "506", "", `\d{8}`),
+	lengthPlan("PA", "507", "", `\d{7,8}`),
+	lengthPlan("PM", "508", "", `\d{6}`),
+	lengthPlan("HT", "509", "", `\d{8}`),
+	lengthPlan("GP", "590", "0", `\d{9}`),
+	lengthPlan("BO", "591", "0", `\d{8}`),
+	lengthPlan("GY", "592", "", `\d{7}`),
+	lengthPlan("EC", "593", "0", `\d{8,9}`),
+	lengthPlan("GF", "594", "0", `\d{9}`),
+	lengthPlan("PY", "595", "0", `\d{9}`),
+	lengthPlan("MQ", "596", "0", `\d{9}`),
+	lengthPlan("SR", "597", "", `\d{6,7}`),
+	lengthPlan("UY", "598", "0", `\d{8}`),
+	lengthPlan("CW", "599", "", `\d{7,8}`),
+	lengthPlan("AW", "297", "", `\d{7}`),
+}
+
+var (
+	plansByRegion      = make(map[string]*numberingPlan, len(numberingPlans))
+	plansByCountryCode = make(map[string][]*numberingPlan)
+)
+
+func init() {
+	for _, plan := range numberingPlans {
+		plansByRegion[plan.region] = plan
+		plansByCountryCode[plan.countryCode] = append(plansByCountryCode[plan.countryCode], plan)
+	}
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPhoneValidatorParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultRegion string
+		input         string
+		want          string
+		region        string
+		numberType    PhoneNumberType
+	}{
+		// Written forms
+		{name: "international", input: "+994 50 123 45 67", want: "+994501234567", region: "AZ", numberType: PhoneTypeMobile},
+		{name: "00 prefix", input: "00994501234567", want: "+994501234567", region: "AZ", numberType: PhoneTypeMobile},
+		{name: "punctuation", input: " +994 (50) 123-45.67 ", want: "+994501234567", region: "AZ", numberType: PhoneTypeMobile},
+		{name: "national prefix in brackets", input: "+44 (0)20 7946 0018", want: "+442079460018", region: "GB", numberType: PhoneTypeFixedLine},
+		{name: "national prefix after country code", input: "+44 07911 123456", want: "+447911123456", region: "GB", numberType: PhoneTypeMobile},
+
+		// Default region
+		{name: "national with prefix", defaultRegion: "AZ", input: "050 123 45 67", want: "+994501234567", region: "AZ", numberType: PhoneTypeMobile},
+		{name: "national without prefix", defaultRegion: "AZ", input: "50 123 45 67", want: "+994501234567", region: "AZ", numberType: PhoneTypeMobile},
+		{name: "country code without plus", defaultRegion: "AZ", input: "994 50 123 45 67", want: "+994501234567", region: "AZ", numberType: PhoneTypeMobile},
+		{name: "international beats default region", defaultRegion: "AZ", input: "+90 532 123 45 67", want: "+905321234567", region: "TR", numberType: PhoneTypeMobile},
+		{name: "russian trunk prefix", defaultRegion: "RU", input: "8 912 345-67-89", want: "+79123456789", region: "RU", numberType: PhoneTypeMobile},
+		{name: "north american", defaultRegion: "US", input: "(415) 555-0100", want: "+14155550100", region: "US", numberType: PhoneTypeFixedLineOrMobile},
+		{name: "north american with 1", defaultRegion: "US", input: "1 415 555 0100", want: "+14155550100", region: "US", numberType: PhoneTypeFixedLineOrMobile},
+
+		// Azerbaijan
+		{name: "AZ fixed line", input: "+994 12 345 67 89", want: "+994123456789", region: "AZ", numberType: PhoneTypeFixedLine},
+		{name: "AZ premium", input: "+994 900 123 456", want: "+994900123456", region: "AZ", numberType: PhoneTypePremiumRate},
+
+		// Turkey
+		{name: "TR mobile", input: "+90 532 123 45 67", want: "+905321234567", region: "TR", numberType: PhoneTypeMobile},
+		{name: "TR fixed line", input: "+90 212 123 45 67", want: "+902121234567", region: "TR", numberType: PhoneTypeFixedLine},
+		{name: "TR premium", input: "+90 900 123 45 67", want: "+909001234567", region: "TR", numberType: PhoneTypePremiumRate},
+		{name: "TR voip", input: "+90 850 123 45 67", want: "+908501234567", region: "TR", numberType: PhoneTypeVoIP},
+
+		// Russia and Kazakhstan share +7
+		{name: "RU mobile", input: "+7 912 345 67 89", want: "+79123456789", region: "RU", numberType: PhoneTypeMobile},
+		{name: "RU fixed line", input: "+7 495 123 45 67", want: "+74951234567", region: "RU", numberType: PhoneTypeFixedLine},
+		{name: "RU premium", input: "+7 809 123 45 67", want: "+78091234567", region: "RU", numberType: PhoneTypePremiumRate},
+		{name: "KZ mobile", input: "+7 701 234 56 78", want: "+77012345678", region: "KZ", numberType: PhoneTypeMobile},
+		{name: "KZ fixed line", input: "+7 727 123 45 67", want: "+77271234567", region: "KZ", numberType: PhoneTypeFixedLine},
+
+		// United States
+		{name: "US", input: "+1 415 555 0100", want: "+14155550100", region: "US", numberType: PhoneTypeFixedLineOrMobile},
+		{name: "US toll free", input: "+1 800 555 0100", want: "+18005550100", region: "US", numberType: PhoneTypeTollFree},
+		{name: "US premium", input: "+1 900 555 0100", want: "+19005550100", region: "US", numberType: PhoneTypePremiumRate},
+
+		// Regions checked for length only
+		{name: "length only", input: "+992 123 456 789", want: "+992123456789", region: "TJ", numberType: PhoneTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewPhoneValidator(tt.defaultRegion)
+			if err != nil {
+				t.Fatalf("NewPhoneValidator: %v", err)
+			}
+			number, err := v.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if number.E164() != tt.want || number.Region != tt.region || number.Type != tt.numberType {
+				t.Errorf("Parse(%q) = %s %s %s, want %s %s %s",
+					tt.input, number.E164(), number.Region, number.Type, tt.want, tt.region, tt.numberType)
+			}
+		})
+	}
+}
+
+func TestPhoneValidatorRejects(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultRegion string
+		input         string
+	}{
+		{name: "empty", input: ""},
+		{name: "plus only", input: "+"},
+		{name: "letters", input: "+994 50 123 45 6a"},
+		{name: "too short", input: "+994 50 123 45 6"},
+		{name: "too long", input: "+994 50 123 45 678"},
+		{name: "unassigned AZ range", input: "+994 30 123 45 67"},
+		{name: "short after national prefix", input: "+44 (0)20 7946 001"},
+		{name: "longer than E.164 allows", input: "+49 800 123456789012"},
+		{name: "unknown country code", input: "+999 123 456 789"},
+		{name: "US area code starting with 1", input: "+1 115 555 0100"},
+		{name: "RU too short", input: "+7 912 345 67 8"},
+		{name: "national without default region", input: "050 123 45 67"},
+		{name: "national in another region", defaultRegion: "TR", input: "050 123 45 67"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewPhoneValidator(tt.defaultRegion)
+			if err != nil {
+				t.Fatalf("NewPhoneValidator: %v", err)
+			}
+			if number, err := v.Parse(tt.input); !errors.Is(err, ErrInvalidPhoneNumber) {
+				t.Errorf("Parse(%q) = %+v, %v, want %v", tt.input, number, err, ErrInvalidPhoneNumber)
+			}
+		})
+	}
+}
+
+func TestPhoneValidatorIsMobile(t *testing.T) {
+	v, err := NewPhoneValidator("")
+	if err != nil {
+		t.Fatalf("NewPhoneValidator: %v", err)
+	}
+
+	tests := []struct {
+		input  string
+		mobile bool
+	}{
+		{"+994501234567", true},
+		{"+994123456789", false},
+		{"+994900123456", false},
+		{"+905321234567", true},
+		{"+902121234567", false},
+		{"+79123456789", true},
+		{"+74951234567", false},
+		{"+77012345678", true},
+		{"+14155550100", true},
+		{"+19005550100", false},
+		{"+992123456789", true},
+		{"not a number", false},
+	}
+	for _, tt := range tests {
+		if got := v.IsMobile(tt.input); got != tt.mobile {
+			t.Errorf("IsMobile(%q) = %v, want %v", tt.input, got, tt.mobile)
+		}
+	}
+}
+
+func TestNewPhoneValidatorUnknownRegion(t *testing.T) {
+	if _, err := NewPhoneValidator("XX"); !errors.Is(err, ErrUnknownRegion) {
+		t.Errorf("NewPhoneValidator(XX) = %v, want %v", err, ErrUnknownRegion)
+	}
+	if _, err := NewPhoneValidator("az"); err != nil {
+		t.Errorf("NewPhoneValidator(az) = %v, want the region matched case-insensitively", err)
+	}
+}
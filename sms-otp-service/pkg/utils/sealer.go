@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// sealedPrefix marks sealed values, telling them apart from values stored
+// before sealing was introduced.
+const sealedPrefix = "sealed:v1:"
+
+// ErrUnsealable means a sealed value was tampered with, belongs to other
+// additional data, or was sealed under another key.
+var ErrUnsealable = errors.New("sealed value cannot be opened")
+
+// Sealer encrypts secrets at rest with AES-256-GCM. Each value is bound to
+// additional data, such as the ID of its row, so a sealed value copied to
+// another row does not open.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer returns a sealer keyed from secret for purpose. Sealers for
+// different purposes get independent keys from the same secret.
+func NewSealer(secret []byte, purpose string) *Sealer {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("sealer\x00" + purpose))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		panic(err) // a SHA-256 sum is always a valid AES-256 key
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &Sealer{aead: aead}
+}
+
+// Seal encrypts plaintext bound to additionalData. The empty string stays
+// empty, as there is nothing to hide.
+func (s *Sealer) Seal(plaintext, additionalData string) string {
+	if plaintext == "" {
+		return ""
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+// Open decrypts a value sealed with additionalData. Values without the
+// sealed prefix were stored before sealing and are returned as they are.
+func (s *Sealer) Open(value, additionalData string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return "", ErrUnsealable
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+	if err != nil {
+		return "", ErrUnsealable
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealer(t *testing.T) {
+	s := NewSealer([]byte("secret"), "outbox")
+	const message = "Your verification code is: 123456"
+
+	sealed := s.Seal(message, "row-1")
+	if strings.Contains(sealed, "123456") {
+		t.Fatalf("sealed value %q holds the plaintext", sealed)
+	}
+	if s.Seal(message, "row-1") == sealed {
+		t.Error("sealing twice gave the same value, want a fresh nonce")
+	}
+	if got, err := s.Open(sealed, "row-1"); err != nil || got != message {
+		t.Errorf("Open = %q, %v, want %q", got, err, message)
+	}
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name           string
+		sealer         *Sealer
+		value          string
+		additionalData string
+	}{
+		{name: "other row", sealer: s, value: sealed, additionalData: "row-2"},
+		{name: "other secret", sealer: NewSealer([]byte("secret2"), "outbox"), value: sealed, additionalData: "row-1"},
+		{name: "other purpose", sealer: NewSealer([]byte("secret"), "tokens"), value: sealed, additionalData: "row-1"},
+		{name: "tampered", sealer: s, value: string(tampered), additionalData: "row-1"},
+		{name: "truncated", sealer: s, value: sealedPrefix + "AAAA", additionalData: "row-1"},
+		{name: "not base64", sealer: s, value: sealedPrefix + "!", additionalData: "row-1"},
+	}
+	for _, tt := range tests {
+		if got, err := tt.sealer.Open(tt.value, tt.additionalData); !errors.Is(err, ErrUnsealable) {
+			t.Errorf("%s: Open = %q, %v, want %v", tt.name, got, err, ErrUnsealable)
+		}
+	}
+}
+
+func TestSealerPassesThroughUnsealedValues(t *testing.T) {
+	s := NewSealer([]byte("secret"), "outbox")
+	if s.Seal("", "row-1") != "" {
+		t.Error("Seal of the empty string is not empty")
+	}
+	for _, value := range []string{"", "Your code is 123456"} {
+		if got, err := s.Open(value, "row-1"); err != nil || got != value {
+			t.Errorf("Open(%q) = %q, %v, want it unchanged", value, got, err)
+		}
+	}
+}